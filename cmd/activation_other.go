@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import stdnet "net"
+
+// activatedListeners returns nothing on platforms without socket activation.
+func activatedListeners() ([]stdnet.Listener, error) {
+	return nil, nil
+}
@@ -0,0 +1,48 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	stdnet "net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// listenFDsStart is the first file descriptor passed by socket activation.
+const listenFDsStart = 3
+
+// activatedListeners returns the sockets passed by a service manager such as systemd through
+// LISTEN_PID and LISTEN_FDS, or nothing when the process was not socket-activated. The
+// variables are cleared so that child processes do not take the sockets for their own.
+func activatedListeners() ([]stdnet.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]stdnet.Listener, 0, n)
+	for fd := listenFDsStart; fd < listenFDsStart+n; fd++ {
+		syscall.CloseOnExec(fd)
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		ln, err := stdnet.FileListener(f)
+		// The listener holds a duplicate, so the inherited descriptor is not needed anymore.
+		f.Close()
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return nil, fmt.Errorf("socket-activated descriptor %d is not a stream socket: %v", fd, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
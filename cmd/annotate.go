@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Limits on annotations, which may arrive from anyone who can reach the metrics endpoint.
+const (
+	annotationMaxLabel = 200         // Longest label, in characters
+	annotationMaxBody  = 4096        // Largest accepted request body, in bytes
+	annotationBurst    = 10          // Annotations accepted at once after a quiet period
+	annotationRefill   = time.Second // Time in which one more annotation is allowed
+	annotationQueue    = 16          // Annotations waiting for the sampling loop
+)
+
+// Reasons an annotation is refused.
+var (
+	errAnnotationLimited = errors.New("too many annotations, try again later")
+	errAnnotationBusy    = errors.New("too many annotations are waiting to be emitted")
+)
+
+// annotator accepts external markers, such as "iperf start", and hands them to the sampling
+// loop, which emits them as annotation events between samples.
+type annotator struct {
+	labels chan string
+
+	mu     sync.Mutex
+	tokens float64   // Annotations that may be accepted now
+	last   time.Time // When tokens was last refilled
+}
+
+func newAnnotator() *annotator {
+	return &annotator{labels: make(chan string, annotationQueue), tokens: annotationBurst, last: time.Now()}
+}
+
+// parseAnnotationLabel checks and trims an annotation label.
+func parseAnnotationLabel(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return "", errors.New("the label is empty")
+	case len([]rune(s)) > annotationMaxLabel:
+		return "", fmt.Errorf("the label is longer than %d characters", annotationMaxLabel)
+	case strings.ContainsFunc(s, unicode.IsControl):
+		return "", errors.New("the label contains control characters")
+	}
+	return s, nil
+}
+
+// add queues a label, refusing it when the rate limit is exceeded or the queue is full.
+func (a *annotator) add(label string) error {
+	a.mu.Lock()
+	now := time.Now()
+	a.tokens = min(annotationBurst, a.tokens+float64(now.Sub(a.last))/float64(annotationRefill))
+	a.last = now
+	if a.tokens < 1 {
+		a.mu.Unlock()
+		return errAnnotationLimited
+	}
+	a.tokens--
+	a.mu.Unlock()
+
+	select {
+	case a.labels <- label:
+		return nil
+	default:
+		return errAnnotationBusy
+	}
+}
+
+// ServeHTTP implements POST /annotate with a body such as {"label": "iperf start"}.
+func (a *annotator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Label string `json:"label"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, annotationMaxBody)).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf("expected {\"label\": \"...\"} of at most %d bytes", annotationMaxBody), http.StatusBadRequest)
+		return
+	}
+	label, err := parseAnnotationLabel(body.Label)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch err := a.add(label); {
+	case errors.Is(err, errAnnotationLimited):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// readLabels adds one annotation per line read from sc, logging refused ones.
+func (a *annotator) readLabels(sc *bufio.Scanner, source string) {
+	sc.Buffer(make([]byte, annotationMaxBody), annotationMaxBody)
+	for sc.Scan() {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		label, err := parseAnnotationLabel(sc.Text())
+		if err == nil {
+			err = a.add(label)
+		}
+		if err != nil {
+			logWarnf("Annotation from %s ignored: %v", source, err)
+		}
+	}
+}
+
+// annotations returns the channel of queued annotation labels, nil when annotations are off.
+func (nm *NetworkMonitor) annotations() <-chan string {
+	if nm.annotator == nil {
+		return nil
+	}
+	return nm.annotator.labels
+}
+
+// emitAnnotation emits an annotation event for label.
+func (nm *NetworkMonitor) emitAnnotation(label string) {
+	nm.emitEvent(Event{
+		Type:      eventAnnotation,
+		Timestamp: Timestamp(time.Now()),
+		Interface: nm.interfaceName,
+		Message:   label,
+	})
+}
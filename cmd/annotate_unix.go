@@ -0,0 +1,41 @@
+//go:build !windows
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// startAnnotationFIFO creates the named pipe at path if needed and reads annotations from it,
+// one label per line, from any number of successive writers.
+func (a *annotator) startAnnotationFIFO(path string) error {
+	if info, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		if err := unix.Mkfifo(path, 0o600); err != nil {
+			return fmt.Errorf("cannot create annotation FIFO: %v", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("cannot use annotation FIFO: %v", err)
+	} else if info.Mode()&fs.ModeNamedPipe == 0 {
+		return fmt.Errorf("cannot use annotation FIFO: %s exists and is not a named pipe", path)
+	}
+	go func() {
+		defer handlePanic()
+		for {
+			// Opening blocks until a writer appears; reading ends when the last one closes.
+			f, err := os.Open(path)
+			if err != nil {
+				logErrorf("Stopped reading annotations: %v", err)
+				return
+			}
+			a.readLabels(bufio.NewScanner(f), path)
+			f.Close()
+		}
+	}()
+	return nil
+}
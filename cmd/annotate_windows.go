@@ -0,0 +1,8 @@
+package main
+
+import "errors"
+
+// startAnnotationFIFO reports that named pipes of this kind are not available on Windows.
+func (a *annotator) startAnnotationFIFO(string) error {
+	return errors.New("-annotate-fifo is not supported on Windows; use POST /annotate on the -listen endpoint")
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// baselineWeight caps how many past days an hour-of-day baseline averages over, so it follows
+// gradual changes in what is normal.
+const baselineWeight = 7
+
+// BaselineMultiple expresses a sample's rates as multiples of the usual rate for its
+// hour of day, e.g. 3.2 for "3.2 times normal".
+type BaselineMultiple struct {
+	SentMultiple float64 `json:"sentMultiple"`
+	RecvMultiple float64 `json:"recvMultiple"`
+}
+
+// hourBaseline is the learned average rate of one hour of day, in bytes per second.
+type hourBaseline struct {
+	Sent  float64 `json:"sent"`
+	Recv  float64 `json:"recv"`
+	Hours int     `json:"hours"` // Completed hours averaged so far, 0 while learning
+}
+
+// baselineFile is the persisted form of the baselines, keyed by interface name.
+type baselineFile struct {
+	Interfaces map[string]*[24]hourBaseline `json:"interfaces"`
+}
+
+// baselineTracker learns per-hour-of-day rates of one interface and compares samples with them.
+type baselineTracker struct {
+	path  string
+	iface string
+	file  baselineFile
+	hours *[24]hourBaseline // Baselines of iface within file
+	hour  time.Time         // Start of the hour being accumulated
+	sent  uint64            // Bytes of the current hour
+	recv  uint64
+	secs  float64 // Seconds sampled in the current hour
+}
+
+// loadBaselines reads the baselines of iface from path. A missing file starts learning from
+// scratch.
+func loadBaselines(path, iface string) (*baselineTracker, error) {
+	b := &baselineTracker{path: path, iface: iface}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &b.file); err != nil {
+			return nil, fmt.Errorf("error parsing baseline file %s: %v", path, err)
+		}
+	}
+	if b.file.Interfaces == nil {
+		b.file.Interfaces = make(map[string]*[24]hourBaseline)
+	}
+	if b.file.Interfaces[iface] == nil {
+		b.file.Interfaces[iface] = new([24]hourBaseline)
+	}
+	b.hours = b.file.Interfaces[iface]
+	return b, nil
+}
+
+// add records a sample of sent and recv bytes over interval seconds taken at now, and returns
+// its rates as multiples of the hour's baseline, or nil while that hour is still being learned.
+func (b *baselineTracker) add(sent, recv uint64, interval float64, now time.Time, precision int) *BaselineMultiple {
+	hour := nextHour(now).Add(-time.Hour)
+	if !hour.Equal(b.hour) {
+		b.fold()
+		b.hour = hour
+	}
+	b.sent += sent
+	b.recv += recv
+	b.secs += interval
+
+	h := b.hours[now.Hour()]
+	if h.Hours == 0 || h.Sent <= 0 || h.Recv <= 0 {
+		return nil
+	}
+	return &BaselineMultiple{
+		SentMultiple: netstats.Round(float64(sent)/interval/h.Sent, precision),
+		RecvMultiple: netstats.Round(float64(recv)/interval/h.Recv, precision),
+	}
+}
+
+// fold merges the hour accumulated so far into its hour-of-day baseline and saves the file.
+// Hours sampled for less than half their length are dropped, as they say little about normal.
+func (b *baselineTracker) fold() {
+	if b.hour.IsZero() || b.secs < time.Hour.Seconds()/2 {
+		b.sent, b.recv, b.secs = 0, 0, 0
+		return
+	}
+
+	h := &b.hours[b.hour.Hour()]
+	h.Hours = min(h.Hours+1, baselineWeight)
+	h.Sent += (float64(b.sent)/b.secs - h.Sent) / float64(h.Hours)
+	h.Recv += (float64(b.recv)/b.secs - h.Recv) / float64(h.Hours)
+	b.sent, b.recv, b.secs = 0, 0, 0
+
+	if err := b.save(); err != nil {
+		logWarnf("Error saving baselines: %v", err)
+	}
+}
+
+// save writes the baselines of every interface to the file, replacing it atomically.
+func (b *baselineTracker) save() error {
+	data, err := json.MarshalIndent(b.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+// loadBaseline attaches the learned baselines of the monitored interface from path, if set.
+func (nm *NetworkMonitor) loadBaseline(path string) error {
+	if path == "" {
+		return nil
+	}
+	b, err := loadBaselines(path, nm.interfaceName)
+	if err != nil {
+		return newStartupError(errCodeInvalidValue, exitUsage, err)
+	}
+	nm.baseline = b
+	return nil
+}
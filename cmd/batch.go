@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"time"
+)
+
+// BatchEnvelope groups several JSON samples into one document, for ingestion endpoints that
+// prefer fewer, larger payloads.
+type BatchEnvelope struct {
+	Interface string    `json:"interface"`
+	From      Timestamp `json:"from"` // Time of the first sample in the batch
+	To        Timestamp `json:"to"`   // Time of the last sample in the batch
+	Samples   []any     `json:"samples"`
+}
+
+// sampleBatch collects JSON samples until the batch fills or its oldest sample gets too old.
+type sampleBatch struct {
+	size    int           // Samples per batch
+	maxAge  time.Duration // Maximum age of the oldest sample; 0 for no limit
+	samples []any
+	from    time.Time
+	to      time.Time
+}
+
+// add appends a sample taken at t and reports whether the batch is due to be emitted.
+func (b *sampleBatch) add(sample any, t time.Time) bool {
+	if len(b.samples) == 0 {
+		b.from = t
+	}
+	b.samples = append(b.samples, sample)
+	b.to = t
+	return len(b.samples) >= b.size || b.expired(t)
+}
+
+// expired reports whether the oldest sample has waited longer than the age limit.
+func (b *sampleBatch) expired(now time.Time) bool {
+	return b.maxAge > 0 && len(b.samples) > 0 && now.Sub(b.from) >= b.maxAge
+}
+
+// flushBatch emits the pending batch, if any, as a single JSON document.
+func (nm *NetworkMonitor) flushBatch() error {
+	b := nm.batch
+	if b == nil || len(b.samples) == 0 {
+		return nil
+	}
+
+	env := BatchEnvelope{
+		Interface: nm.interfaceName,
+		From:      Timestamp(b.from),
+		To:        Timestamp(b.to),
+		Samples:   b.samples,
+	}
+	b.samples = nil
+
+	return nm.out.writeRecord(func(w io.Writer) { printJSON(w, env) })
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"runtime/metrics"
+	"slices"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// benchMetrics names the runtime metrics sampled before and after a benchmark.
+var benchMetrics = []string{
+	"/gc/heap/allocs:bytes",
+	"/gc/heap/allocs:objects",
+	"/gc/cycles/total:gc-cycles",
+}
+
+// runBench implements the bench subcommand. It runs the collection and formatting pipeline
+// against the real counter source at a high rate, discarding the output, and reports the
+// per-tick latency distribution, CPU time and allocations.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	iface := fs.String("interface", "", "Network interface to sample (default: first non-loopback interface)")
+	duration := fs.Duration("duration", 30*time.Second, "How long to run the benchmark")
+	interval := fs.Duration("interval", 100*time.Millisecond, "Time between samples")
+	format := fs.String("format", "json", "Output format to render and discard: json or table")
+	addFlagAliases(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *duration <= 0 || *interval <= 0 {
+		return errors.New("Duration and interval must be positive")
+	}
+	if !slices.Contains(textFormats, *format) {
+		return fmt.Errorf("Invalid output format: %s", *format)
+	}
+
+	name := *iface
+	if name == "" {
+		var err error
+		if name, err = defaultBenchInterface(); err != nil {
+			return err
+		}
+	}
+	sel := interfaceSelector{kind: selectorName, value: name}
+	nm := NewNetworkMonitor(sel, name, kernelSource{}, monitorConfig{Interval: *interval, Precision: 2, Format: *format})
+
+	prev, err := nm.readCounters()
+	if err != nil {
+		return interfaceError(err, name)
+	}
+	totalSentStart, totalRecvStart := prev.BytesSent, prev.BytesRecv
+
+	before := readBenchMetrics()
+	cpuBefore, err := processCPUTime()
+	if err != nil {
+		return fmt.Errorf("error reading CPU time: %v", err)
+	}
+	var latencies []time.Duration
+	var errCount int
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	deadline := time.Now().Add(*duration)
+
+	for now := range ticker.C {
+		if now.After(deadline) {
+			break
+		}
+
+		start := time.Now()
+		cur, err := nm.readCounters()
+		if err != nil {
+			errCount++
+			continue
+		}
+		stats := NetStats{Stats: netstats.Stats{
+			Interface:  nm.interfaceName,
+			SentSpeed:  nm.units.Speed(cur.BytesSent-prev.BytesSent, nm.refreshInterval.Seconds(), nm.precision),
+			RecvSpeed:  nm.units.Speed(cur.BytesRecv-prev.BytesRecv, nm.refreshInterval.Seconds(), nm.precision),
+			TotalSent:  nm.units.Usage(cur.BytesSent-totalSentStart, nm.precision),
+			TotalRecv:  nm.units.Usage(cur.BytesRecv-totalRecvStart, nm.precision),
+			TotalUsage: nm.units.Usage(cur.BytesSent-totalSentStart+cur.BytesRecv-totalRecvStart, nm.precision),
+		}}
+		nm.formatter.samples(io.Discard, []NetStats{stats}, false)
+		prev = cur
+		latencies = append(latencies, time.Since(start))
+	}
+
+	cpuAfter, _ := processCPUTime()
+	after := readBenchMetrics()
+	if len(latencies) == 0 {
+		return errors.New("no successful samples collected")
+	}
+
+	slices.Sort(latencies)
+	pct := func(p float64) time.Duration { return latencies[int(p*float64(len(latencies)-1))] }
+	cpu := cpuAfter - cpuBefore
+	allocBytes := after[0] - before[0]
+	allocObjects := after[1] - before[1]
+	ticks := float64(len(latencies))
+
+	fmt.Printf("Benchmark: %d ticks on %s every %v for %v (%s output, %d errors)\n",
+		len(latencies), name, *interval, *duration, *format, errCount)
+	fmt.Printf("Tick latency: min %v, p50 %v, p90 %v, p99 %v, max %v\n",
+		latencies[0], pct(0.5), pct(0.9), pct(0.99), latencies[len(latencies)-1])
+	fmt.Printf("CPU time: %v (%.2f%% of one core, %v per tick)\n",
+		cpu, 100*cpu.Seconds()/duration.Seconds(), cpu/time.Duration(len(latencies)))
+	fmt.Printf("Allocations: %.0f objects, %.0f bytes (%.1f objects, %.0f bytes per tick), %.0f GC cycles\n",
+		allocObjects, allocBytes, allocObjects/ticks, allocBytes/ticks, after[2]-before[2])
+
+	return nil
+}
+
+// defaultBenchInterface returns the first interface that is not a loopback device.
+func defaultBenchInterface() (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("error listing interfaces: %v", err)
+	}
+	for _, iface := range ifaces {
+		if !slices.Contains(iface.Flags, "loopback") {
+			return iface.Name, nil
+		}
+	}
+	return "", errors.New("no non-loopback interface found, use -i")
+}
+
+// readBenchMetrics samples benchMetrics, returning their values in the same order.
+func readBenchMetrics() []float64 {
+	samples := make([]metrics.Sample, len(benchMetrics))
+	for i, name := range benchMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+
+	values := make([]float64, len(samples))
+	for i, s := range samples {
+		switch s.Value.Kind() {
+		case metrics.KindFloat64:
+			values[i] = s.Value.Float64()
+		case metrics.KindUint64:
+			values[i] = float64(s.Value.Uint64())
+		}
+	}
+	return values
+}
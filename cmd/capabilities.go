@@ -0,0 +1,99 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// Counter groups whose availability is detected. Some virtualized platforms report valid byte
+// counters but leave the others at zero.
+const (
+	counterGroupPackets = "packets" // packetsSent and packetsRecv
+	counterGroupErrors  = "errors"  // errin, errout, dropin and dropout
+)
+
+// capabilityProbeSamples is how many readings with traffic are examined before packet counters
+// that never moved are declared unpopulated.
+const capabilityProbeSamples = 3
+
+// counterCapabilities detects which counter fields the platform populates for the monitored
+// interface. Byte counters are always trusted. Packet counters are unpopulated when bytes flow
+// but the packet counters stay at zero. Zero error and drop counters are normal on a healthy
+// link, so they are only distrusted along with the packet counters.
+type counterCapabilities struct {
+	observed int      // Readings with traffic examined so far
+	packets  bool     // Whether the packet counters were seen populated
+	errors   bool     // Whether an error or drop counter was seen non-zero
+	decided  bool     // Whether detection is complete
+	missing  []string // Counter groups found unpopulated, once decided
+}
+
+// observe examines a reading and reports whether detection completed with it.
+func (c *counterCapabilities) observe(prev, cur net.IOCountersStat) bool {
+	if c.decided {
+		return false
+	}
+	c.packets = c.packets || cur.PacketsSent > 0 || cur.PacketsRecv > 0
+	c.errors = c.errors || cur.Errin > 0 || cur.Errout > 0 || cur.Dropin > 0 || cur.Dropout > 0
+	if cur.BytesSent == prev.BytesSent && cur.BytesRecv == prev.BytesRecv {
+		return false // An idle reading proves nothing
+	}
+	c.observed++
+	if !c.packets && c.observed < capabilityProbeSamples {
+		return false
+	}
+
+	c.decided = true
+	if !c.packets {
+		c.missing = append(c.missing, counterGroupPackets)
+		if !c.errors {
+			c.missing = append(c.missing, counterGroupErrors)
+		}
+	}
+	return true
+}
+
+// available reports whether the counters of group can be trusted. Before detection completes
+// every group is assumed available.
+func (c *counterCapabilities) available(group string) bool {
+	return !slices.Contains(c.missing, group)
+}
+
+// unavailableFields returns the JSON names of the counter fields found unpopulated.
+func (c *counterCapabilities) unavailableFields() []string {
+	var fields []string
+	if !c.available(counterGroupPackets) {
+		fields = append(fields, "packetsSent", "packetsRecv")
+	}
+	if !c.available(counterGroupErrors) {
+		fields = append(fields, "errin", "errout", "dropin", "dropout")
+	}
+	return fields
+}
+
+// checkCapabilities feeds a reading to the capability detection and, when it finds counters the
+// platform leaves at zero, emits a single partial-counters event listing what was disabled.
+func (nm *NetworkMonitor) checkCapabilities(prev, cur net.IOCountersStat, now time.Time) {
+	c := &nm.capabilities
+	if !c.observe(prev, cur) || len(c.missing) == 0 {
+		return
+	}
+
+	msg := "the platform leaves the packet counters at zero; they are marked unavailable"
+	if !c.available(counterGroupErrors) {
+		msg = "the platform reports byte counters only; packet, error and drop counters are marked unavailable and hourly error and drop figures left out"
+	}
+	nm.emitEvent(Event{
+		Type:      eventPartialCounters,
+		Timestamp: Timestamp(now),
+		Interface: nm.interfaceName,
+		Message:   msg,
+		Details: map[string]bool{
+			"bytes":             true,
+			counterGroupPackets: c.available(counterGroupPackets),
+			counterGroupErrors:  c.available(counterGroupErrors),
+		},
+	})
+}
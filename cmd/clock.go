@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// clockStepThreshold is the smallest wall-clock jump between two ticks reported as a step.
+const clockStepThreshold = time.Second
+
+// clock tells the time of ticks. Differences between instants returned by now follow the
+// monotonic clock, which intervals and rates are computed from; wall returns the wall-clock
+// time of such an instant, which may step, as on an NTP correction. Tests inject a clock whose
+// wall clock steps on demand.
+type clock interface {
+	now() time.Time
+	wall(t time.Time) time.Time
+}
+
+// systemClock is the clock of the operating system, whose instants carry both readings.
+type systemClock struct{}
+
+func (systemClock) now() time.Time { return time.Now() }
+
+// wall strips the monotonic reading, so differences of the result follow the wall clock.
+func (systemClock) wall(t time.Time) time.Time { return t.Round(0) }
+
+// clockStep returns how far the wall clock of c moved relative to its monotonic clock between
+// two of its instants, and whether that exceeds clockStepThreshold. Rates and durations are
+// always computed from the monotonic readings, so a step only ever affects displayed timestamps.
+func clockStep(c clock, prev, cur time.Time) (time.Duration, bool) {
+	mono := cur.Sub(prev)
+	wall := c.wall(cur).Sub(c.wall(prev))
+	step := wall - mono
+	return step, step >= clockStepThreshold || step <= -clockStepThreshold
+}
+
+// checkClockStep reports a wall-clock step since the previous tick as an informational event.
+func (nm *NetworkMonitor) checkClockStep(now time.Time) {
+	if !nm.lastTick.IsZero() {
+		if step, ok := clockStep(nm.clock, nm.lastTick, now); ok {
+			logInfof("Wall clock stepped by %v", step)
+			nm.emitEvent(Event{
+				Type:      eventClockStep,
+				Timestamp: Timestamp(now),
+				Interface: nm.interfaceName,
+				Message:   fmt.Sprintf("wall clock stepped by %v", step),
+				Details:   map[string]float64{"stepSeconds": step.Seconds()},
+			})
+		}
+	}
+	nm.lastTick = now
+}
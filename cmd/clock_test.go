@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// fakeClock is a clock whose wall clock steps on demand while its monotonic clock only
+// advances. Its instants carry no monotonic reading, so their differences follow the
+// monotonic clock, and wall adds the steps taken up to an instant.
+type fakeClock struct {
+	mono  time.Time // Current instant
+	steps []wallStep
+}
+
+// wallStep is a step of the wall clock of a fakeClock, taken at an instant.
+type wallStep struct {
+	at time.Time
+	by time.Duration
+}
+
+func (c *fakeClock) now() time.Time { return c.mono }
+
+func (c *fakeClock) wall(t time.Time) time.Time {
+	w := t
+	for _, s := range c.steps {
+		if !s.at.After(t) {
+			w = w.Add(s.by)
+		}
+	}
+	return w
+}
+
+// advance moves both clocks forward by d.
+func (c *fakeClock) advance(d time.Duration) { c.mono = c.mono.Add(d) }
+
+// step moves the wall clock by d, forward or back, leaving the monotonic clock alone.
+func (c *fakeClock) step(d time.Duration) { c.steps = append(c.steps, wallStep{c.mono, d}) }
+
+// measuredSource serves the counters of a fakeSource without its fixed span, so rates are
+// computed over the time measured between reads.
+type measuredSource struct{ src *fakeSource }
+
+func (m measuredSource) counters(iface string) (net.IOCountersStat, error) {
+	return m.src.counters(iface)
+}
+
+func TestClockStepKeepsRates(t *testing.T) {
+	src := newFakeSource()
+	src.set("eth0", 0, 0)
+	nm, buf := newTestMonitor(t, "eth0", measuredSource{src}, "-f", "json")
+	clk := &fakeClock{mono: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	nm.clock = clk
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []time.Duration{0, -time.Hour, 500 * time.Millisecond, 90 * time.Second}
+	for i, step := range steps {
+		clk.advance(2 * time.Second)
+		if step != 0 {
+			clk.step(step)
+		}
+		src.set("eth0", uint64(i+1)*1000, uint64(i+1)*2000)
+		tick(t, nm, clk.now())
+	}
+
+	samples := decodeSamples(t, buf)
+	if len(samples) != len(steps) {
+		t.Fatalf("got %d samples, want %d: %s", len(samples), len(steps), buf)
+	}
+	for i, s := range samples {
+		if s.SentSpeed.Value != 500 || s.RecvSpeed.Value != 1000 {
+			t.Errorf("sample %d rates %v/%v, want 500/1000 whatever the wall clock did", i, s.SentSpeed.Value, s.RecvSpeed.Value)
+		}
+	}
+
+	// Steps below clockStepThreshold are not reported.
+	want := []float64{-3600, 90}
+	var events []jsonEvent
+	for _, ev := range decodeEvents(t, buf) {
+		if ev.Type == eventClockStep {
+			events = append(events, ev)
+		}
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d clock-step events, want %d: %s", len(events), len(want), buf)
+	}
+	for i, ev := range events {
+		var details struct {
+			StepSeconds float64 `json:"stepSeconds"`
+		}
+		if err := json.Unmarshal(ev.Details, &details); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Severity != severityInfo || details.StepSeconds != want[i] {
+			t.Errorf("event %d = %s step %vs, want %s step %vs", i, ev.Severity, details.StepSeconds, severityInfo, want[i])
+		}
+	}
+}
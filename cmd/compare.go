@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// compareMetric is a figure compared between runs.
+type compareMetric struct {
+	name           string // JSON name, as in the session summary
+	kind           string // How values are rendered: bytes, rate or count
+	higherIsBetter bool   // Whether a decrease, rather than an increase, is a regression
+}
+
+// compareMetrics lists the compared figures in output order. Percentiles are only known for
+// recordings, which hold every reading.
+var compareMetrics = []compareMetric{
+	{"totalSentBytes", "bytes", true},
+	{"totalRecvBytes", "bytes", true},
+	{"avgSentBytesPerSecond", "rate", true},
+	{"avgRecvBytesPerSecond", "rate", true},
+	{"peakSentBytesPerSecond", "rate", true},
+	{"peakRecvBytesPerSecond", "rate", true},
+	{"p50SentBytesPerSecond", "rate", true},
+	{"p50RecvBytesPerSecond", "rate", true},
+	{"p95SentBytesPerSecond", "rate", true},
+	{"p95RecvBytesPerSecond", "rate", true},
+	{"p99SentBytesPerSecond", "rate", true},
+	{"p99RecvBytesPerSecond", "rate", true},
+	{"errors", "count", false},
+	{"implausibleSamples", "count", false},
+}
+
+// compareFormats lists the supported output formats of compare.
+var compareFormats = []string{"table", "markdown", "json"}
+
+// ComparedMetric is one figure of a run next to the baseline run.
+type ComparedMetric struct {
+	Metric       string   `json:"metric"`
+	Baseline     float64  `json:"baseline"`
+	Value        float64  `json:"value"`
+	Delta        float64  `json:"delta"`
+	DeltaPercent *float64 `json:"deltaPercent"` // Nil when the baseline is zero
+	Regression   bool     `json:"regression"`
+}
+
+// RunComparison holds the figures of one run compared with the baseline run.
+type RunComparison struct {
+	File    string           `json:"file"`
+	Metrics []ComparedMetric `json:"metrics"`
+}
+
+// Comparison is the result of the compare subcommand.
+type Comparison struct {
+	Baseline         string          `json:"baseline"`
+	TolerancePercent float64         `json:"tolerancePercent"`
+	Runs             []RunComparison `json:"runs"`
+	Regressions      int             `json:"regressions"`
+}
+
+// runCompare implements the compare subcommand. It loads saved session summaries or raw
+// recordings, compares every run with the first, and exits with status 1 when any figure
+// regressed by more than the tolerance.
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	format := fs.String("format", "table", "Output format: table, markdown or json")
+	tolerance := fs.Float64("tolerance", 5, "Change in `percent` beyond which a worse figure counts as a regression")
+	addFlagAliases(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if !slices.Contains(compareFormats, *format) {
+		return fmt.Errorf("Invalid output format. Allowed values: %s", strings.Join(compareFormats, ", "))
+	}
+	if *tolerance < 0 {
+		return errors.New("Tolerance must not be negative")
+	}
+	files := fs.Args()
+	if len(files) < 2 {
+		return errors.New("compare needs at least two files: the baseline run followed by the runs to compare")
+	}
+
+	runs := make([]map[string]float64, len(files))
+	for i, name := range files {
+		var err error
+		if runs[i], err = loadRunFigures(name); err != nil {
+			return err
+		}
+	}
+	c := compareRuns(files, runs, *tolerance)
+
+	switch *format {
+	case "json":
+		printJSON(os.Stdout, c)
+	case "markdown":
+		printComparisonMarkdown(os.Stdout, c)
+	default:
+		printComparisonTable(os.Stdout, c, isTerminal(os.Stdout))
+	}
+
+	if c.Regressions > 0 {
+		logErrorf("%d regressions beyond the %g%% tolerance", c.Regressions, c.TolerancePercent)
+		return exitStatus(exitFailure)
+	}
+	return nil
+}
+
+// loadRunFigures reads the figures of a run from a session summary, as written by
+// -summary-json-fd, or from a -record-raw recording, whose figures are computed from its readings.
+func loadRunFigures(name string) (map[string]float64, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	first, _, _ := bytes.Cut(bytes.TrimSpace(data), []byte("\n"))
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(first, &probe); err != nil {
+		return nil, fmt.Errorf("%s is neither a session summary nor a recording: %v", name, err)
+	}
+
+	switch {
+	case probe["counters"] != nil:
+		records, err := readRecording(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) < 2 {
+			return nil, fmt.Errorf("recording %s holds fewer than two readings", name)
+		}
+		return recordingFigures(records), nil
+	case probe["durationSeconds"] != nil:
+		figures := make(map[string]float64)
+		for _, m := range compareMetrics {
+			if v, err := strconv.ParseFloat(string(probe[m.name]), 64); err == nil {
+				figures[m.name] = v
+			}
+		}
+		return figures, nil
+	default:
+		return nil, fmt.Errorf("%s is neither a session summary nor a recording", name)
+	}
+}
+
+// recordingFigures computes the totals, averages, peaks and rate percentiles of a recording.
+func recordingFigures(records []rawRecord) map[string]float64 {
+	first, last := records[0], records[len(records)-1]
+	totalSent := netstats.Growth(first.Counters.BytesSent, last.Counters.BytesSent)
+	totalRecv := netstats.Growth(first.Counters.BytesRecv, last.Counters.BytesRecv)
+	figures := map[string]float64{
+		"totalSentBytes": float64(totalSent),
+		"totalRecvBytes": float64(totalRecv),
+	}
+	if d := last.Timestamp.Sub(first.Timestamp).Seconds(); d > 0 {
+		figures["avgSentBytesPerSecond"] = netstats.Round(float64(totalSent)/d, 2)
+		figures["avgRecvBytesPerSecond"] = netstats.Round(float64(totalRecv)/d, 2)
+	}
+
+	var sent, recv []float64
+	for i := 1; i < len(records); i++ {
+		prev, cur := records[i-1], records[i]
+		d := cur.Timestamp.Sub(prev.Timestamp).Seconds()
+		if d <= 0 {
+			continue
+		}
+		sent = append(sent, netstats.Round(float64(netstats.Growth(prev.Counters.BytesSent, cur.Counters.BytesSent))/d, 2))
+		recv = append(recv, netstats.Round(float64(netstats.Growth(prev.Counters.BytesRecv, cur.Counters.BytesRecv))/d, 2))
+	}
+	if len(sent) == 0 {
+		return figures
+	}
+	slices.Sort(sent)
+	slices.Sort(recv)
+	figures["peakSentBytesPerSecond"] = sent[len(sent)-1]
+	figures["peakRecvBytesPerSecond"] = recv[len(recv)-1]
+	for _, p := range []int{50, 95, 99} {
+		figures[fmt.Sprintf("p%dSentBytesPerSecond", p)] = percentile(sent, p)
+		figures[fmt.Sprintf("p%dRecvBytesPerSecond", p)] = percentile(recv, p)
+	}
+	return figures
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted values.
+func percentile(sorted []float64, p int) float64 {
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// compareRuns compares the figures of every run with those of the first. Figures missing from
+// either run, such as percentiles of a summary, are skipped.
+func compareRuns(files []string, runs []map[string]float64, tolerance float64) Comparison {
+	c := Comparison{Baseline: files[0], TolerancePercent: tolerance}
+	base := runs[0]
+	for i, run := range runs[1:] {
+		rc := RunComparison{File: files[i+1]}
+		for _, m := range compareMetrics {
+			b, okBase := base[m.name]
+			v, okRun := run[m.name]
+			if !okBase || !okRun {
+				continue
+			}
+			cm := ComparedMetric{Metric: m.name, Baseline: b, Value: v, Delta: v - b}
+			worse := cm.Delta < 0
+			if !m.higherIsBetter {
+				worse = cm.Delta > 0
+			}
+			if b != 0 {
+				pct := netstats.Round(cm.Delta/math.Abs(b)*100, 2)
+				cm.DeltaPercent = &pct
+				cm.Regression = worse && math.Abs(pct) > tolerance
+			} else {
+				cm.Regression = worse // Any new error is a regression when there were none
+			}
+			if cm.Regression {
+				c.Regressions++
+			}
+			rc.Metrics = append(rc.Metrics, cm)
+		}
+		c.Runs = append(c.Runs, rc)
+	}
+	return c
+}
+
+// formatFigure renders a figure for human-facing output according to the kind of its metric.
+func formatFigure(name string, v float64) string {
+	i := slices.IndexFunc(compareMetrics, func(m compareMetric) bool { return m.name == name })
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	switch compareMetrics[i].kind {
+	case "bytes":
+		u := netstats.CalculateUsage(uint64(v), 2)
+		return sign + formatQuantity(u.Value, u.Unit, 2, false)
+	case "rate":
+		s := netstats.CalculateSpeed(uint64(v), 1, 2)
+		return sign + formatQuantity(s.Value, s.Unit, 2, false)
+	default:
+		return sign + strconv.FormatFloat(v, 'f', -1, 64)
+	}
+}
+
+// formatDeltaPercent renders the relative change of a compared figure.
+func formatDeltaPercent(m ComparedMetric) string {
+	if m.DeltaPercent == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.2f%%", *m.DeltaPercent)
+}
+
+// comparisonRows returns the rows of a comparison for tabular output, one per metric and run,
+// with regressions marked by mark.
+func comparisonRows(c Comparison, mark func(string) string) [][]string {
+	var rows [][]string
+	for _, run := range c.Runs {
+		for _, m := range run.Metrics {
+			delta := formatFigure(m.Metric, m.Delta)
+			if m.Delta > 0 {
+				delta = "+" + delta
+			}
+			pct := formatDeltaPercent(m)
+			if m.Regression {
+				pct = mark(pct)
+			}
+			rows = append(rows, []string{run.File, m.Metric, formatFigure(m.Metric, m.Baseline), formatFigure(m.Metric, m.Value), delta, pct})
+		}
+	}
+	return rows
+}
+
+// comparisonHeader is the header of tabular comparison output.
+var comparisonHeader = []string{"Run", "Metric", "Baseline", "Value", "Delta", "Delta %"}
+
+// printComparisonTable prints a comparison as a table to w, regressions flagged with "!" and,
+// when color is set, in red.
+func printComparisonTable(w io.Writer, c Comparison, color bool) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader(comparisonHeader)
+	table.AppendBulk(comparisonRows(c, func(s string) string {
+		if color {
+			return severityColors[severityError] + s + " !\x1b[0m"
+		}
+		return s + " !"
+	}))
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(true)
+	table.SetRowLine(true)
+	table.Render()
+	fmt.Fprintf(w, "Baseline %s; %d regressions beyond %g%% (marked !)\n", c.Baseline, c.Regressions, c.TolerancePercent)
+}
+
+// printComparisonMarkdown prints a comparison as a Markdown table to w, regressions in bold,
+// for CI job summaries and pull request comments.
+func printComparisonMarkdown(w io.Writer, c Comparison) {
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	row := func(cells []string) {
+		fmt.Fprintf(bw, "| %s |\n", strings.Join(cells, " | "))
+	}
+	row(comparisonHeader)
+	row(slices.Repeat([]string{"---"}, len(comparisonHeader)))
+	for _, r := range comparisonRows(c, func(s string) string { return "**" + s + "**" }) {
+		row(r)
+	}
+	fmt.Fprintf(bw, "\nBaseline `%s`; %d regressions beyond %g%% (in bold).\n", c.Baseline, c.Regressions, c.TolerancePercent)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Completion sources for flag values, completed dynamically or from a fixed list.
+const (
+	completeInterfaces = "interfaces" // Interface names, enumerated at completion time via the list subcommand
+	completeFormats    = "formats"    // Output format names
+)
+
+// flagCompletions maps long monitor flag names to the source used to complete their values.
+var flagCompletions = map[string]string{
+	"interface": completeInterfaces,
+	"format":    completeFormats,
+}
+
+// completionFlag describes a monitor flag spelling for the completion script templates.
+type completionFlag struct {
+	Name     string // Flag name without leading dashes
+	Long     bool   // Whether this is a long (--name) rather than a short (-n) spelling
+	Usage    string // One-line description
+	TakesArg bool   // Whether the flag expects a value
+	Source   string // Completion source for the value, empty for free-form values
+}
+
+// Spelling returns the flag as typed on the command line, including its dashes.
+func (f completionFlag) Spelling() string {
+	if f.Long {
+		return "--" + f.Name
+	}
+	return "-" + f.Name
+}
+
+// completionData is passed to the completion script templates.
+type completionData struct {
+	Prog        string           // Program name as invoked
+	Func        string           // Program name sanitized for use as a shell function name
+	Subcommands []subcommand     // Available subcommands
+	Flags       []completionFlag // Monitor flags
+	Formats     []string         // Output format names
+}
+
+// completionTemplates holds the completion script template for each supported shell.
+var completionTemplates = map[string]string{
+	"bash": `# bash completion for {{.Prog}}
+_{{.Func}}() {
+    local cur prev
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+    case "$prev" in
+{{- range .Flags}}{{if .Source}}
+        {{.Spelling}})
+            COMPREPLY=($(compgen -W "{{if eq .Source "interfaces"}}$({{$.Prog}} list --names 2>/dev/null){{else}}{{join $.Formats " "}}{{end}}" -- "$cur"))
+            return
+            ;;
+{{- end}}{{end}}
+    esac
+
+    if [[ $COMP_CWORD -eq 1 && "$cur" != -* ]]; then
+        COMPREPLY=($(compgen -W "{{range $i, $c := .Subcommands}}{{if $i}} {{end}}{{$c.Name}}{{end}}" -- "$cur"))
+        return
+    fi
+
+    COMPREPLY=($(compgen -W "{{range $i, $f := .Flags}}{{if $i}} {{end}}{{$f.Spelling}}{{end}}" -- "$cur"))
+}
+complete -F _{{.Func}} {{.Prog}}
+`,
+	"zsh": `#compdef {{.Prog}}
+
+_{{.Func}}() {
+    local -a subcommands flags
+    subcommands=(
+{{- range .Subcommands}}
+        '{{zsh .Name}}:{{zsh .Summary}}'
+{{- end}}
+    )
+    flags=(
+{{- range .Flags}}
+        '{{zsh .Spelling}}:{{zsh .Usage}}'
+{{- end}}
+    )
+
+    case "${words[CURRENT-1]}" in
+{{- range .Flags}}{{if .Source}}
+        {{.Spelling}})
+            compadd -- {{if eq .Source "interfaces"}}${(f)"$({{$.Prog}} list --names 2>/dev/null)"}{{else}}{{join $.Formats " "}}{{end}}
+            return
+            ;;
+{{- end}}{{end}}
+    esac
+
+    if (( CURRENT == 2 )) && [[ "${words[CURRENT]}" != -* ]]; then
+        _describe 'subcommand' subcommands
+        return
+    fi
+
+    _describe 'flag' flags
+}
+
+compdef _{{.Func}} {{.Prog}}
+`,
+	"fish": `# fish completion for {{.Prog}}
+complete -c {{.Prog}} -f
+{{- range .Subcommands}}
+complete -c {{$.Prog}} -n '__fish_use_subcommand' -a {{.Name}} -d '{{fish .Summary}}'
+{{- end}}
+{{- range .Flags}}
+complete -c {{$.Prog}} {{if .Long}}-l{{else}}-o{{end}} {{.Name}} -d '{{fish .Usage}}'{{if .TakesArg}} -x{{end}}{{if eq .Source "interfaces"}} -a '({{$.Prog}} list --names 2>/dev/null)'{{else if eq .Source "formats"}} -a '{{join $.Formats " "}}'{{end}}
+{{- end}}
+`,
+}
+
+// completionFuncs are the helpers available to the completion script templates.
+var completionFuncs = template.FuncMap{
+	"join": strings.Join,
+	// zsh escapes a value for a single-quoted _describe entry, where colons separate the description.
+	"zsh": func(s string) string {
+		return strings.NewReplacer("'", `'\''`, ":", `\:`).Replace(s)
+	},
+	// fish escapes a value for a single-quoted fish string.
+	"fish": func(s string) string {
+		return strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s)
+	},
+}
+
+// progName returns the program name as invoked, without directory or extension.
+func progName() string {
+	return strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+}
+
+// runCompletion implements the completion subcommand, printing a completion script for the given shell.
+func runCompletion(args []string) error {
+	if len(args) != 1 || completionTemplates[args[0]] == "" {
+		return fmt.Errorf("usage: %s completion bash|zsh|fish", progName())
+	}
+
+	tmpl, err := template.New(args[0]).Funcs(completionFuncs).Parse(completionTemplates[args[0]])
+	if err != nil {
+		return err
+	}
+
+	prog := progName()
+	data := completionData{
+		Prog:        prog,
+		Func:        strings.NewReplacer("-", "_", ".", "_").Replace(prog),
+		Subcommands: subcommandList(),
+		Formats:     outputFormats,
+	}
+
+	newMonitorFlagSet("monitor", &monitorConfig{}).VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		_, short := flagAliases[f.Name]
+		data.Flags = append(data.Flags, completionFlag{
+			Name:     f.Name,
+			Long:     !short,
+			Usage:    f.Usage,
+			TakesArg: !ok || !boolFlag.IsBoolFlag(),
+			Source:   flagCompletions[canonicalFlag(f.Name)],
+		})
+	})
+
+	return tmpl.Execute(os.Stdout, data)
+}
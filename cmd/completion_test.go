@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestCompletionScriptsParse checks the generated scripts with each shell's syntax check,
+// skipping the shells that are not installed.
+func TestCompletionScriptsParse(t *testing.T) {
+	checks := map[string][]string{
+		"bash": {"-n"},
+		"zsh":  {"-n"},
+		"fish": {"--no-execute"},
+	}
+	for shell, args := range checks {
+		t.Run(shell, func(t *testing.T) {
+			script := captureStdout(t, func() {
+				if err := runCompletion([]string{shell}); err != nil {
+					t.Fatal(err)
+				}
+			})
+			path, err := exec.LookPath(shell)
+			if err != nil {
+				t.Skipf("%s is not installed", shell)
+			}
+			file := filepath.Join(t.TempDir(), "completion."+shell)
+			if err := os.WriteFile(file, script, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if out, err := exec.Command(path, append(args, file)...).CombinedOutput(); err != nil {
+				t.Errorf("%s %v: %v\n%s", shell, args, err, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,735 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	stdnet "net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// outputFormats lists the supported values of the -f flag.
+var outputFormats = []string{"table", "json", "ndjson", "json-array", "yaml", "csv", "influx", "tui"}
+
+// textFormats lists the formats offered where CSV does not apply: by list and bench, and by the
+// format command of a running monitor.
+var textFormats = []string{"table", "json"}
+
+// monitorConfig holds the settings of the monitor subcommand as parsed from the command line.
+type monitorConfig struct {
+	Interface        string        `json:"interface"`        // Interface name or stable identifier (mac:, path:)
+	Interval         time.Duration `json:"interval"`         // Time between samples
+	Precision        int           `json:"precision"`        // Decimal places for rounding numerical values
+	Format           string        `json:"format"`           // Output format ("table", "json", "yaml", "csv", "influx" or "tui")
+	ShowMeta         bool          `json:"showMeta"`         // Whether to include interface metadata in samples
+	TimeFormat       string        `json:"timeFormat"`       // Timestamp preset name or Go layout
+	SummaryJSON      string        `json:"summaryJSON"`      // File descriptor number or path receiving the session summary
+	ExitSummary      bool          `json:"exitSummary"`      // Whether a readable session summary is printed to stderr at exit
+	DecimalComma     bool          `json:"decimalComma"`     // Whether human-facing output uses a comma decimal separator
+	LogLevel         string        `json:"logLevel"`         // Least severe log level written to stderr
+	Warmup           int           `json:"warmup"`           // Number of initial samples collected but not emitted
+	WarmupExclude    bool          `json:"warmupExclude"`    // Whether warm-up traffic is left out of totals
+	BufferSamples    int           `json:"bufferSamples"`    // Records batched before a write, 0 to disable
+	BufferFlush      time.Duration `json:"bufferFlush"`      // Maximum age of a buffered record, 0 for no limit
+	Counters         bool          `json:"counters"`         // Whether samples include the absolute kernel counters
+	CountersOnly     bool          `json:"countersOnly"`     // Whether samples carry only the absolute kernel counters
+	Source           string        `json:"source"`           // Counter source specification, empty for the kernel
+	RecordRaw        string        `json:"recordRaw"`        // JSON Lines file receiving every raw counter reading
+	Plan             string        `json:"plan"`             // Subscribed plan rates, e.g. "down=500Mbit,up=50Mbit" or "total=100Mbit"
+	Batch            int           `json:"batch"`            // JSON samples grouped per document, 0 or 1 to disable
+	BatchMaxAge      time.Duration `json:"batchMaxAge"`      // Maximum age of an incomplete batch, 0 for no limit
+	Heartbeat        time.Duration `json:"heartbeat"`        // Longest silence before a heartbeat record, 0 to disable
+	SuppressZero     bool          `json:"suppressZero"`     // Whether idle samples are left out of the output
+	ZeroEpsilon      sizeValue     `json:"zeroEpsilon"`      // Largest per-direction byte delta still considered idle
+	Profile          string        `json:"profile"`          // Name of the preset of defaults, empty for none
+	UTC              bool          `json:"utc"`              // Whether timestamps are rendered in UTC
+	Stagger          time.Duration `json:"stagger"`          // Slot length over which the reads of several interfaces are spread, 0 to read all each tick
+	ReportInterval   int           `json:"reportInterval"`   // Seconds per emitted record, a multiple of Interval; 0 to emit every sample
+	SelfStats        bool          `json:"selfStats"`        // Whether samples include the monitor's own resource use
+	MaxErrors        int           `json:"maxErrors"`        // Consecutive read failures tolerated before giving up, 0 for no limit
+	Pair             string        `json:"pair"`             // Two comma-separated interfaces compared for asymmetric routing
+	PairFactor       float64       `json:"pairFactor"`       // Asymmetry factor at which a pair is flagged
+	PairSustain      int           `json:"pairSustain"`      // Consecutive asymmetric samples before an event
+	HourlySummary    bool          `json:"hourlySummary"`    // Whether a summary record is emitted at each wall-clock hour boundary
+	PushGateway      string        `json:"pushGateway"`      // Pushgateway base URL receiving the final figures at exit
+	PushJob          string        `json:"pushJob"`          // Job label of the push
+	PushGrouping     string        `json:"pushGrouping"`     // Extra grouping key labels, e.g. "instance=web1"
+	StrictPush       bool          `json:"strictPush"`       // Whether a failed push fails the run with exit code 6
+	Header           bool          `json:"header"`           // Whether the stream starts with a header record describing the session
+	MaxPlausibleRate rateValue     `json:"maxPlausibleRate"` // Rate above which samples are implausible, 0 to derive it from the link speed
+	CrashDir         string        `json:"crashDir"`         // Directory receiving crash bundles, empty for the system temporary directory
+	NoCrashBundle    bool          `json:"noCrashBundle"`    // Whether crash bundles are disabled
+	BaselineFile     string        `json:"baselineFile"`     // File holding the learned hour-of-day rates, empty to disable
+	Redact           bool          `json:"redact"`           // Whether identifying details are scrubbed from the output
+	RedactMap        string        `json:"redactMap"`        // File holding the pseudonym mapping of -redact, empty for none
+	SkipLoopback     bool          `json:"skipLoopback"`     // Whether loopback interfaces are left out of -i all
+	IncludeLoopback  bool          `json:"includeLoopback"`  // Whether loopback traffic counts towards -i total
+	Softnet          bool          `json:"softnet"`          // Whether samples include softirq drop counts
+	Qdisc            bool          `json:"qdisc"`            // Whether samples include root qdisc statistics
+	MatchRegex       string        `json:"matchRegex"`       // Regular expression selecting the interfaces to monitor
+	Exclude          string        `json:"exclude"`          // Interface names or glob patterns left out of multi-interface selections
+	Probe            string        `json:"probe"`            // Hosts whose latency is probed alongside sampling
+	PrintDefault     bool          `json:"printDefault"`     // Whether to print the default-route interface and exit
+	ForceUnlock      bool          `json:"forceUnlock"`      // Whether stale state file locks are broken
+	Once             bool          `json:"once"`             // Whether monitoring stops after the first sample
+	Count            int           `json:"count"`            // Samples emitted before monitoring stops, 0 for no limit
+	JSONArray        bool          `json:"jsonArray"`        // Whether JSON records are emitted as a single array
+	Duration         time.Duration `json:"duration"`         // Time after which monitoring stops, 0 for no limit
+	QuietHours       string        `json:"quietHours"`       // Daily windows whose warning events are suppressed, e.g. 01:00-05:00
+	QuietHoursTZ     string        `json:"quietHoursTZ"`     // Time zone of the quiet hours, empty for local time
+	ShowTime         bool          `json:"showTime"`         // Whether tables get a column with the sample time
+	CSVDelimiter     string        `json:"csvDelimiter"`     // Field separator of CSV output
+	Groups           groupsValue   `json:"groups"`           // Named interface groups, name=if1,if2
+	GroupOverlap     bool          `json:"groupOverlap"`     // Whether an interface may belong to several groups
+	Strict           bool          `json:"strict"`           // Whether startup fails when a requested feature lacks the privileges it needs
+	Realtime         bool          `json:"realtime"`         // Whether the sampling thread requests SCHED_FIFO scheduling
+	Nice             int           `json:"nice"`             // Nice value of the sampling thread, 0 to leave it unchanged
+	PinCPU           int           `json:"pinCpu"`           // CPU the sampling thread is pinned to, -1 for none
+	Listen           string        `json:"listen"`           // Comma-separated addresses of the Prometheus metrics endpoint, empty to disable
+	Quiet            bool          `json:"quiet"`            // Whether records are kept off stdout, as when running as an exporter
+	InfluxAddr       string        `json:"influxAddr"`       // UDP or TCP target of -f influx lines instead of stdout, empty for stdout
+	Tags             string        `json:"tags"`             // Static tags appended to -f influx lines, e.g. env=prod,host=web1
+	OutputQueue      int           `json:"outputQueue"`      // Records queued for the output goroutine, 0 to write from the sampling loop
+	Sinks            sinksValue    `json:"sinks"`            // Sink definitions of -sink, kind:target with optional filters
+	Graphite         string        `json:"graphite"`         // Carbon server host:port of the Graphite sink, empty to disable
+	GraphitePrefix   string        `json:"graphitePrefix"`   // Metric path prefix of the Graphite sink
+	Statsd           string        `json:"statsd"`           // StatsD server host:port of the StatsD sink, empty to disable
+	StatsdTags       bool          `json:"statsdTags"`       // Whether StatsD metrics carry the interface as a DogStatsD tag
+	Output           string        `json:"output"`           // File the output is written to instead of stdout, empty for stdout
+	Append           bool          `json:"append"`           // Whether an existing output file is continued rather than truncated
+	Sync             bool          `json:"sync"`             // Whether the output file is opened with O_SYNC
+	Tee              bool          `json:"tee"`              // Whether output goes to stdout as well as the output file
+	MaxFileSize      sizeValue     `json:"maxFileSize"`      // Size at which the output file is rotated, 0 to never rotate
+	MaxFiles         int           `json:"maxFiles"`         // Rotated output files kept
+	TeeFile          string        `json:"teeFile"`          // Debugging file receiving a timestamped copy of every output write, empty for none
+	Live             bool          `json:"live"`             // Whether tables are redrawn in place when stdout is a terminal
+	AnnotateFIFO     string        `json:"annotateFifo"`     // Named pipe annotation labels are read from, one per line, empty for none
+	WarnSpeed        rateValue     `json:"warnSpeed"`        // Rate above which speeds turn yellow, 0 for none
+	CritSpeed        rateValue     `json:"critSpeed"`        // Rate above which speeds turn red, 0 for none
+	ColorBandsSent   bandsValue    `json:"colorBandsSent"`   // Warning and critical send rates, replacing -warn-speed and -crit-speed
+	ColorBandsRecv   bandsValue    `json:"colorBandsRecv"`   // Warning and critical receive rates, replacing -warn-speed and -crit-speed
+	NoColor          bool          `json:"noColor"`          // Whether terminal output stays uncolored
+	StrictSchema     bool          `json:"strictSchema"`     // Whether JSON records are checked against the record schema before they are written
+	FailoverWatch    string        `json:"failoverWatch"`    // Primary and backup interfaces whose switches are measured, e.g. "primary=eth0,backup=wwan0"
+	FailoverIdle     rateValue     `json:"failoverIdle"`     // Rate below which a -failover-watch interface has stopped carrying traffic
+	FailoverActive   rateValue     `json:"failoverActive"`   // Rate at which a -failover-watch interface carries traffic
+	Bits             bool          `json:"bits"`             // Whether speeds are reported in bits per second
+	StrictSinks      bool          `json:"strictSinks"`      // Whether a sink dropping over -sink-drop-budget of its deliveries fails the run with exit code 6
+	SinkDropBudget   float64       `json:"sinkDropBudget"`   // Percentage of deliveries -strict-sinks lets each sink drop over the window
+	SinkBudgetWindow time.Duration `json:"sinkBudgetWindow"` // Rolling window of sink drop ratios
+	SI               bool          `json:"si"`               // Whether scaled values step by 1000 (KB, MB, GB) instead of 1024 (KiB, MiB, GiB)
+	Unit             string        `json:"unit"`             // Unit every speed and total is reported in, such as MB or Mbit; empty to scale each value
+	RateOfChange     bool          `json:"rateOfChange"`     // Whether samples carry the rate of change of their smoothed rates
+	Alerts           alertsValue   `json:"alerts"`           // Rules on the change of a rate, such as delta(recv_speed, 10s) < -80%
+	AlertMinRate     rateValue     `json:"alertMinRate"`     // Rate below which relative -alert rules are not judged
+}
+
+// newMonitorFlagSet creates the flag set of the monitor subcommand, storing parsed values in cfg.
+func newMonitorFlagSet(name string, cfg *monitorConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.BoolVar(&cfg.ForceUnlock, "force-unlock", false, "Break the lock of a state file whose holder PID no longer exists")
+	fs.StringVar(&cfg.Profile, "profile", "", "Preset of defaults: human or machine; explicit flags still override it")
+	fs.StringVar(&cfg.Interface, "interface", "", "Network interface to monitor: name, mac:<address> or path:<sysfs device>, a comma-separated list of them, glob patterns such as 'eth0.*', all, or total for the sum of all interfaces (default: the interface carrying the default route)")
+	fs.BoolVar(&cfg.PrintDefault, "print-default", false, "Print the name of the interface carrying the default route and exit")
+	fs.StringVar(&cfg.MatchRegex, "match-regex", "", "Monitor every interface whose name matches this regular expression, e.g. ^veth (replaces -i)")
+	fs.BoolVar(&cfg.IncludeLoopback, "include-loopback", false, "Count loopback traffic towards -i total")
+	fs.StringVar(&cfg.Exclude, "exclude", "", "Comma-separated interface names or glob patterns to leave out of -i all, patterns and total, e.g. 'lo,veth*,docker0'")
+	fs.BoolVar(&cfg.SkipLoopback, "skip-loopback", false, "Leave loopback interfaces out of -i all")
+	fs.Var(&cfg.Groups, "group", "Also report a group of interfaces as one, e.g. wan=ppp0,wwan0 (repeatable; members are monitored if -i is not given)")
+	fs.BoolVar(&cfg.GroupOverlap, "group-overlap", false, "Allow an interface to belong to several -group definitions")
+	fs.StringVar(&cfg.Pair, "pair", "", "Compare two interfaces, e.g. eth0,eth1, to detect asymmetric routing (replaces -i)")
+	fs.StringVar(&cfg.FailoverWatch, "failover-watch", "", "Measure switches of traffic between two interfaces, e.g. primary=eth0,backup=wwan0 (replaces -i)")
+	cfg.FailoverIdle, cfg.FailoverActive = 1024, 10*1024
+	fs.Var(&cfg.FailoverIdle, "failover-idle", "Rate below which a -failover-watch interface has stopped carrying traffic")
+	fs.Var(&cfg.FailoverActive, "failover-active", "Rate at which a -failover-watch interface carries traffic")
+	fs.Float64Var(&cfg.PairFactor, "pair-factor", 10, "Asymmetry factor at which a -pair is flagged")
+	fs.IntVar(&cfg.PairSustain, "pair-sustain", 5, "Consecutive asymmetric samples before an asymmetric-route event")
+	fs.StringVar(&cfg.Source, "source", "", "Counter source: kernel (default), synthetic:profile=constant|wave|burst|walk|script[,options], replay:<file>[,speed=N] or pdh (Windows performance counters)")
+	fs.StringVar(&cfg.RecordRaw, "record-raw", "", "Record every raw counter reading to this JSON Lines file for later replay")
+	cfg.Interval = time.Second
+	fs.Var((*intervalValue)(&cfg.Interval), "interval", "Sampling `interval` from 50ms to 1h, e.g. 250ms or 2s; a bare number counts seconds")
+	fs.Var((*intervalValue)(&cfg.Interval), "sample-interval", "Same as -interval")
+	fs.DurationVar(&cfg.Stagger, "stagger", 0, "Spread the reads of -i all, a pattern or a list over slots of this length within the interval, e.g. 250ms, so each tick reads a share of the interfaces (0: read all every tick)")
+	fs.Var((*secondsValue)(&cfg.ReportInterval), "report-interval", "Emit one record per this many `seconds` with the min/avg/max of the samples taken (0: every sample)")
+	fs.IntVar(&cfg.Precision, "precision", 2, "Precision for rounding numbers")
+	fs.StringVar(&cfg.Format, "format", "table", "Output format: table, json, ndjson, json-array, yaml, csv, influx or tui (a full-screen dashboard)")
+	fs.BoolVar(&cfg.ShowMeta, "show-meta", false, "Include interface metadata (MTU, link, addresses) in JSON output")
+	fs.BoolVar(&cfg.Counters, "counters", false, "Include the absolute kernel counters (bytes, packets, errors, drops) in each sample")
+	fs.BoolVar(&cfg.CountersOnly, "counters-only", false, "Emit only the absolute kernel counters, omitting derived speeds and totals")
+	fs.BoolVar(&cfg.SelfStats, "self-stats", false, "Include the monitor's own CPU, memory, GC and latency figures in JSON samples")
+	fs.StringVar(&cfg.Plan, "plan", "", "Internet plan to compare throughput against, e.g. down=500Mbit,up=50Mbit or total=100Mbit")
+	fs.StringVar(&cfg.BaselineFile, "baseline-file", "", "Learn the usual rate of each hour of day in this file and add \"x times normal\" multiples to samples")
+	fs.BoolVar(&cfg.RateOfChange, "rate-of-change", false, "Add how fast the smoothed rates change, in bytes per second per second, to each sample")
+	fs.Var(&cfg.Alerts, "alert", "Emit a rate-shift event when a rule on the change of a rate starts to hold, e.g. 'delta(recv_speed, 10s) < -80%' (repeatable)")
+	cfg.AlertMinRate = rateValue(1e6 / 8)
+	fs.Var(&cfg.AlertMinRate, "alert-min-rate", "Rate below which relative -alert rules are not judged, as changes of a trickle are noise")
+	fs.StringVar(&cfg.Probe, "probe", "", "Probe the latency of these comma-separated hosts once per interval, e.g. 1.1.1.1, adding RTT and loss to each sample")
+	fs.BoolVar(&cfg.Qdisc, "qdisc", false, "Add root qdisc backlog, drops and requeues to each sample and emit an event when drops grow (Linux only)")
+	fs.BoolVar(&cfg.Softnet, "softnet", false, "Add packets the kernel dropped or deferred in softirq processing to each sample (Linux only)")
+	fs.BoolVar(&cfg.Redact, "redact", false, "Scrub output for sharing: pseudonymous interface names (if0, if1), no addresses or host name, totals rounded to two significant figures")
+	fs.StringVar(&cfg.RedactMap, "redact-map", "", "Write the -redact pseudonym mapping to this local file (and reuse it), to de-redact reports later")
+	fs.StringVar(&cfg.TimeFormat, "time-format", "rfc3339", timeFormatHelp)
+	fs.StringVar(&cfg.TimeFormat, "ts-format", "rfc3339", "Same as -time-format")
+	fs.BoolVar(&cfg.SI, "si", false, "Scale speeds and totals by 1000 (KB, MB, GB) instead of 1024 (KiB, MiB, GiB)")
+	fs.BoolVar(&cfg.Bits, "bits", false, "Report speeds in bits per second with 1000-based steps (Kbit/s, Mbit/s, Gbit/s); totals stay in bytes")
+	fs.StringVar(&cfg.Unit, "unit", "", "Report every speed and total in this unit instead of scaling each value: B, KB, MB or GB, or bit, Kbit, Mbit or Gbit with -bits")
+	fs.BoolVar(&cfg.Live, "live", false, "Redraw the table in place at the top of the terminal instead of appending one per tick (ignored when stdout is not a terminal)")
+	fs.BoolVar(&cfg.ShowTime, "show-time", false, "Add a column with the sample time to table output")
+	fs.BoolVar(&cfg.Header, "header", false, "Start the output with a header record (session ID, host, OS, version, interfaces, config digest)")
+	fs.BoolVar(&cfg.UTC, "utc", false, "Render timestamps in UTC instead of local time")
+	fs.StringVar(&cfg.SummaryJSON, "summary-json-fd", "", "Write the session summary as JSON at exit to this file descriptor (e.g. 2) or path")
+	fs.BoolVar(&cfg.ExitSummary, "exit-summary", false, "Print a readable session summary to stderr when monitoring stops")
+	fs.StringVar(&cfg.Output, "output", "", "Write the output to this file instead of stdout, truncating it unless -append is given")
+	fs.BoolVar(&cfg.Append, "append", false, "Continue an existing -output file instead of truncating it")
+	fs.BoolVar(&cfg.Sync, "sync", false, "Open the -output file with O_SYNC, so every write reaches the disk before sampling continues")
+	fs.Var(&cfg.MaxFileSize, "max-file-size", "Rotate the -output file to file.1, file.2, … once it reaches this size, e.g. 50MB (0: never)")
+	fs.IntVar(&cfg.MaxFiles, "max-files", 5, "Rotated -output files kept by -max-file-size, the oldest being deleted")
+	fs.BoolVar(&cfg.Tee, "tee", false, "Write the output to stdout as well as to the -output file")
+	fs.StringVar(&cfg.TeeFile, "tee-file", "", "Copy every write of the output to this debugging file, with when it started and finished and whether it succeeded")
+	fs.StringVar(&cfg.InfluxAddr, "influx-addr", "", "Send -f influx lines to this udp://host:port or tcp://host:port target instead of stdout, e.g. a Telegraf socket listener")
+	fs.StringVar(&cfg.Tags, "tags", "", "Static tags appended to every -f influx line, e.g. env=prod,host=web1")
+	fs.Var(&cfg.Sinks, "sink", "Send records to a sink, kind:target?types=...&fields=..., e.g. file:/var/log/netstats.jsonl or webhook:https://x/hook?types=event (repeatable)")
+	fs.StringVar(&cfg.Graphite, "graphite", "", "Send the samples of every tick to this Carbon server in the Graphite plaintext protocol, e.g. carbon:2003")
+	fs.StringVar(&cfg.GraphitePrefix, "graphite-prefix", "zag.netstats", "Metric path prefix of -graphite metrics")
+	fs.StringVar(&cfg.Statsd, "statsd", "", "Send rate gauges and byte counters of every tick to this StatsD server over UDP, e.g. 127.0.0.1:8125")
+	fs.BoolVar(&cfg.StatsdTags, "statsd-tags", false, "Tag -statsd metrics with the interface, DogStatsD style, instead of naming it in the metric")
+	fs.StringVar(&cfg.Listen, "listen", "", "Serve Prometheus metrics of the latest samples on these comma-separated addresses, e.g. :9123")
+	fs.BoolVar(&cfg.StrictSchema, "strict-schema", false, "Check every JSON record against the embedded record schema and stop with exit code 6 at the first mismatch")
+	fs.Var(&cfg.WarnSpeed, "warn-speed", "Color speeds yellow above this rate, e.g. 50MB/s or 400Mbit")
+	fs.Var(&cfg.CritSpeed, "crit-speed", "Color speeds red above this rate, e.g. 100MB/s or 800Mbit")
+	fs.Var(&cfg.ColorBandsSent, "color-bands-sent", "Color sent speeds yellow and red above these two rates, e.g. 500KB/s,2MB/s")
+	fs.Var(&cfg.ColorBandsRecv, "color-bands-recv", "Color received speeds yellow and red above these two rates, e.g. 10MB/s,50MB/s")
+	fs.BoolVar(&cfg.NoColor, "no-color", false, "Never color terminal output (also set by the NO_COLOR environment variable)")
+	fs.StringVar(&cfg.AnnotateFIFO, "annotate-fifo", "", "Emit an annotation event for every line written to this named pipe, created if missing, e.g. echo 'iperf start' > fifo")
+	fs.BoolVar(&cfg.Quiet, "quiet", false, "Do not write samples or other records to stdout, e.g. when running as an exporter")
+	fs.StringVar(&cfg.PushGateway, "pushgateway", "", "Push the final counters and summary gauges at exit to this Prometheus Pushgateway (http://[user:pass@]host:port)")
+	fs.StringVar(&cfg.PushJob, "push-job", "netstats", "Job label used for -pushgateway")
+	fs.StringVar(&cfg.PushGrouping, "push-grouping", "", "Additional -pushgateway grouping labels, e.g. instance=web1,region=eu")
+	fs.BoolVar(&cfg.StrictPush, "strict-push", false, "Exit with code 6 when the -pushgateway push fails")
+	fs.BoolVar(&cfg.StrictSinks, "strict-sinks", false, "Exit with code 6 when a sink such as -graphite drops more than -sink-drop-budget of its deliveries")
+	fs.Float64Var(&cfg.SinkDropBudget, "sink-drop-budget", 1, "Percentage of its deliveries a sink may drop over -sink-budget-window under -strict-sinks")
+	fs.DurationVar(&cfg.SinkBudgetWindow, "sink-budget-window", 5*time.Minute, "Rolling window of the sink drop ratios reported on /sinks and judged by -strict-sinks")
+	fs.StringVar(&cfg.CSVDelimiter, "csv-delimiter", ",", "Field separator of CSV output, a single character such as ; or \\t")
+	fs.BoolVar(&cfg.DecimalComma, "decimal-comma", false, "Use a comma as decimal separator in table output (JSON always uses dots)")
+	fs.Var(&cfg.MaxPlausibleRate, "max-plausible-rate", "Rate above which a sample is flagged implausible and left out of totals, e.g. 20Gbit (default: twice the link speed)")
+	fs.BoolVar(&cfg.Strict, "strict", false, "Refuse to start when a requested feature, such as -realtime, lacks the privileges to run as requested")
+	fs.BoolVar(&cfg.Realtime, "realtime", false, "Run the sampling thread under SCHED_FIFO real-time scheduling where permitted (Linux)")
+	fs.IntVar(&cfg.Nice, "nice", 0, "Nice value of the sampling thread, e.g. -10 for a higher priority (Linux)")
+	fs.IntVar(&cfg.PinCPU, "pin-cpu", -1, "Pin the sampling thread to this CPU (Linux)")
+	fs.IntVar(&cfg.MaxErrors, "max-errors", 0, "Give up with exit code 5 after this many consecutive counter read failures (0: never)")
+	fs.StringVar(&cfg.CrashDir, "crash-dir", "", "Directory receiving a diagnostic bundle when the monitor panics (default: the system temporary directory)")
+	fs.BoolVar(&cfg.NoCrashBundle, "no-crash-bundle", false, "Do not write a diagnostic bundle on a panic, e.g. in privacy-sensitive environments")
+	fs.StringVar(&cfg.LogLevel, "log-level", "info", "Log verbosity: debug (adds per-tick timings), info, warn or error")
+	fs.IntVar(&cfg.Warmup, "warmup", 0, "Collect but do not emit the first N samples")
+	fs.BoolVar(&cfg.WarmupExclude, "warmup-exclude", false, "Leave warm-up traffic out of totals and the session summary")
+	fs.IntVar(&cfg.BufferSamples, "buffer-samples", 0, "Batch up to N formatted records in memory before writing them")
+	fs.IntVar(&cfg.OutputQueue, "output-queue", 0, "Records queued for writing by a separate goroutine, so slow output cannot delay sampling; the oldest sample is dropped when full (0: write from the sampling loop)")
+	fs.DurationVar(&cfg.BufferFlush, "buffer-flush", 0, "Maximum time a buffered record may wait before being written (e.g. 1s)")
+	fs.IntVar(&cfg.Batch, "batch", 0, "Group N JSON samples into one {\"samples\": [...]} document")
+	fs.DurationVar(&cfg.BatchMaxAge, "batch-max-age", 0, "Maximum time a sample may wait in an incomplete batch (e.g. 30s)")
+	fs.Var((*countValue)(&cfg.Count), "count", "Stop after emitting `N` samples")
+	fs.DurationVar(&cfg.Duration, "duration", 0, "Stop monitoring after this long, e.g. 5m or 1h30m")
+	fs.BoolVar(&cfg.JSONArray, "json-array", false, "Emit the JSON records of the run as one array instead of one object per line")
+	fs.StringVar(&cfg.QuietHours, "quiet-hours", "", "Daily HH:MM-HH:MM windows, comma-separated, whose warning events are counted but not emitted; /interval after a window changes the sampling interval in it, e.g. 01:00-05:00/60s")
+	fs.StringVar(&cfg.QuietHoursTZ, "quiet-hours-tz", "", "Time zone of -quiet-hours, e.g. Europe/Berlin (default: local time)")
+	fs.BoolVar(&cfg.Once, "once", false, "Take a single sample over one interval, print it and exit")
+	fs.BoolVar(&cfg.SuppressZero, "suppress-zero", false, "Skip samples where both directions moved at most -zero-epsilon bytes")
+	fs.Var(&cfg.ZeroEpsilon, "zero-epsilon", "Largest per-direction byte delta treated as idle by -suppress-zero, e.g. 1KB")
+	fs.BoolVar(&cfg.HourlySummary, "hourly-summary", false, "Emit a record with the previous hour's bytes, average and peak rates, errors, drops and link events at the top of each hour")
+	fs.DurationVar(&cfg.Heartbeat, "heartbeat", 0, "Emit a heartbeat record when no sample was written for this long (e.g. 60s)")
+	addFlagAliases(fs)
+	fs.Usage = func() {
+		printGroupedUsage(fs)
+		fmt.Fprint(fs.Output(), quantityHelp)
+		fmt.Fprint(fs.Output(), exitCodeHelp)
+	}
+	return fs
+}
+
+// secondsValue is a flag.Value holding a whole number of seconds, given either as a plain
+// integer ("60") or as a duration ("60s", "1m").
+type secondsValue int
+
+func (v *secondsValue) String() string {
+	return strconv.Itoa(int(*v))
+}
+
+func (v *secondsValue) Set(s string) error {
+	if n, err := strconv.Atoi(s); err == nil {
+		*v = secondsValue(n)
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d%time.Second != 0 {
+		return fmt.Errorf("invalid value %q, expected whole seconds such as 5 or 5s", s)
+	}
+	*v = secondsValue(d / time.Second)
+	return nil
+}
+
+// intervalValue is a flag value holding a sampling interval, given as a duration such as 250ms
+// or, as in earlier versions, as a number of seconds.
+type intervalValue time.Duration
+
+func (v *intervalValue) String() string {
+	return time.Duration(*v).String()
+}
+
+func (v *intervalValue) Set(s string) error {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		*v = intervalValue(time.Duration(secs * float64(time.Second)))
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid value %q, expected a duration such as 250ms or 2s, or seconds", s)
+	}
+	*v = intervalValue(d)
+	return nil
+}
+
+// validInterval reports whether d is within the supported range of sampling intervals.
+func validInterval(d time.Duration) bool {
+	return d >= netstats.MinInterval && d <= time.Hour
+}
+
+// countValue is a flag value accepting only a positive count.
+type countValue int
+
+func (v *countValue) String() string {
+	return strconv.Itoa(int(*v))
+}
+
+func (v *countValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("invalid value %q, expected a positive count", s)
+	}
+	*v = countValue(n)
+	return nil
+}
+
+// validate checks the configuration for out-of-range or unsupported values.
+func (cfg *monitorConfig) validate() error {
+	if cfg.Precision < 0 || cfg.Precision > 6 {
+		return errors.New("Precision must be between 0 and 6 decimal places")
+	}
+
+	if !validInterval(cfg.Interval) {
+		return fmt.Errorf("Refresh interval must be between %v and 1h", netstats.MinInterval)
+	}
+
+	if !slices.Contains(outputFormats, cfg.Format) {
+		return fmt.Errorf("Invalid output format. Allowed values: %s", strings.Join(outputFormats, ", "))
+	}
+	// ndjson names the one-object-per-line JSON output, and json-array is short for -json-array.
+	switch cfg.Format {
+	case "ndjson":
+		cfg.Format = "json"
+	case "json-array":
+		cfg.Format, cfg.JSONArray = "json", true
+	}
+
+	if cfg.ReportInterval < 0 || (time.Duration(cfg.ReportInterval)*time.Second)%cfg.Interval != 0 {
+		return errors.New("Report interval must be a multiple of the sampling interval")
+	}
+
+	if cfg.Stagger != 0 {
+		switch {
+		case cfg.Stagger < netstats.MinInterval || cfg.Stagger >= cfg.Interval || cfg.Interval%cfg.Stagger != 0:
+			return fmt.Errorf("Stagger slot must be at least %v and divide the %v interval into several slots", netstats.MinInterval, cfg.Interval)
+		case !cfg.severalInterfaces() || cfg.Pair != "":
+			return errors.New("-stagger needs -i all, a pattern or a list of interfaces")
+		case len(cfg.Groups) > 0 || cfg.FailoverWatch != "":
+			return errors.New("-stagger cannot be combined with -group or -failover-watch, which compare interfaces read in the same tick")
+		}
+	}
+
+	if cfg.Warmup < 0 {
+		return errors.New("Warm-up sample count must not be negative")
+	}
+
+	if cfg.BufferSamples < 0 || cfg.BufferFlush < 0 || cfg.OutputQueue < 0 {
+		return errors.New("Output buffering limits must not be negative")
+	}
+
+	if cfg.Duration < 0 || (cfg.Duration > 0 && cfg.Duration < cfg.Interval) {
+		return fmt.Errorf("Duration %v is shorter than the %v sampling interval, so no sample would be emitted", cfg.Duration, cfg.Interval)
+	}
+
+	if cfg.Once && (cfg.SuppressZero || cfg.Batch > 1) {
+		return errors.New("-once cannot be combined with -suppress-zero or -batch")
+	}
+
+	if cfg.JSONArray && cfg.Format != "json" {
+		return errors.New("-json-array requires JSON output (-f json or -f ndjson)")
+	}
+
+	if len(cfg.Groups) > 0 {
+		if _, err := parseGroups(cfg.Groups, cfg.GroupOverlap); err != nil {
+			return err
+		}
+		if cfg.Pair != "" {
+			return errors.New("-group cannot be combined with -pair")
+		}
+	}
+
+	if cfg.FailoverWatch != "" {
+		if _, _, err := parseFailoverWatch(cfg.FailoverWatch); err != nil {
+			return err
+		}
+		if cfg.Pair != "" || len(cfg.Groups) > 0 || cfg.MatchRegex != "" {
+			return errors.New("-failover-watch cannot be combined with -pair, -group or -match-regex")
+		}
+		if cfg.FailoverIdle <= 0 || cfg.FailoverActive < cfg.FailoverIdle {
+			return errors.New("-failover-idle must be positive and -failover-active at least as high")
+		}
+	}
+
+	if _, err := parseCSVDelimiter(cfg.CSVDelimiter); err != nil {
+		return err
+	}
+
+	if cfg.Format == "csv" && (cfg.CountersOnly || cfg.Pair != "") {
+		return errors.New("CSV output cannot be combined with -counters-only or -pair")
+	}
+
+	if cfg.Format == "influx" && (cfg.CountersOnly || cfg.Pair != "") {
+		return errors.New("InfluxDB output cannot be combined with -counters-only or -pair")
+	}
+	if cfg.Format == "tui" && (cfg.Pair != "" || cfg.Output != "" || cfg.Quiet || cfg.CountersOnly) {
+		return errors.New("The dashboard (-f tui) cannot be combined with -pair, -output, -quiet or -counters-only")
+	}
+	if cfg.Live && cfg.Format != "table" {
+		return errors.New("-live requires table output")
+	}
+	if cfg.StrictSchema && cfg.Format != "json" {
+		return errors.New("-strict-schema requires JSON output")
+	}
+	if (cfg.WarnSpeed > 0 || cfg.CritSpeed > 0) && cfg.Format != "table" && cfg.Format != "tui" {
+		return errors.New("-warn-speed and -crit-speed require table or dashboard output")
+	}
+	if cfg.ColorBandsSent.set() || cfg.ColorBandsRecv.set() {
+		switch {
+		case cfg.Format != "table" && cfg.Format != "tui":
+			return errors.New("-color-bands-sent and -color-bands-recv require table or dashboard output")
+		case cfg.WarnSpeed > 0 || cfg.CritSpeed > 0:
+			return errors.New("-color-bands-sent and -color-bands-recv replace -warn-speed and -crit-speed; give one or the other")
+		}
+	}
+	if cfg.WarnSpeed > 0 && cfg.CritSpeed > 0 && cfg.CritSpeed < cfg.WarnSpeed {
+		return fmt.Errorf("-crit-speed %s is below -warn-speed %s", &cfg.CritSpeed, &cfg.WarnSpeed)
+	}
+	if cfg.Output == "" && (cfg.Append || cfg.Sync || cfg.Tee || cfg.MaxFileSize > 0) {
+		return errors.New("-append, -sync, -tee and -max-file-size require -output")
+	}
+	if cfg.Output != "" {
+		switch {
+		case cfg.InfluxAddr != "":
+			return errors.New("-output cannot be combined with -influx-addr")
+		case cfg.Tee && cfg.Quiet:
+			return errors.New("-tee cannot be combined with -quiet")
+		case cfg.Append && cfg.JSONArray:
+			return errors.New("-append cannot be combined with JSON array output, which would not stay one array")
+		case cfg.MaxFileSize > 0 && cfg.JSONArray:
+			return errors.New("-max-file-size cannot be combined with JSON array output, which would not stay one array")
+		case cfg.MaxFiles < 1:
+			return errors.New("-max-files must be at least 1")
+		}
+	}
+	if (cfg.InfluxAddr != "" || cfg.Tags != "") && cfg.Format != "influx" {
+		return errors.New("-influx-addr and -tags require InfluxDB output (-f influx)")
+	}
+	if cfg.InfluxAddr != "" {
+		if _, _, err := parseInfluxAddr(cfg.InfluxAddr); err != nil {
+			return err
+		}
+	}
+	if _, err := parseInfluxTags(cfg.Tags); err != nil {
+		return err
+	}
+
+	if cfg.QuietHours != "" {
+		q, err := parseQuietHours(cfg.QuietHours, cfg.QuietHoursTZ)
+		if err != nil {
+			return err
+		}
+		if q.hasIntervals() && (cfg.ReportInterval > 0 || cfg.Stagger > 0 || cfg.perInterfaceIntervals()) {
+			return errors.New("Quiet hours intervals cannot be combined with -report-interval, -stagger or per-interface intervals")
+		}
+	}
+
+	if cfg.Probe != "" {
+		if _, err := parseProbeTargets(cfg.Probe); err != nil {
+			return err
+		}
+	}
+	if cfg.Exclude != "" {
+		if _, err := parseInterfacePattern(cfg.Exclude, ""); err != nil {
+			return err
+		}
+	}
+	if cfg.MatchRegex != "" && cfg.Interface != "" {
+		return errors.New("Use either -i or --match-regex, not both")
+	}
+	if cfg.MatchRegex != "" || isInterfacePattern(cfg.Interface) {
+		if _, err := parseInterfacePattern(cfg.Interface, cfg.MatchRegex); err != nil {
+			return err
+		}
+	} else if strings.Contains(cfg.Interface, ",") {
+		_, every, err := parseInterfaceList(cfg.Interface)
+		if err != nil {
+			return err
+		}
+		if cfg.perInterfaceIntervals() {
+			switch {
+			case intervalStep(cfg.Interval, every) < netstats.MinInterval:
+				return fmt.Errorf("Per-interface intervals must be multiples of a common step of at least %v", netstats.MinInterval)
+			case cfg.Stagger > 0 || len(cfg.Groups) > 0 || cfg.FailoverWatch != "":
+				return errors.New("Per-interface intervals cannot be combined with -stagger, -group or -failover-watch")
+			}
+		}
+	} else if _, d := cutInterval(cfg.Interface); d != 0 {
+		return errors.New("A per-interface interval needs a list of interfaces; use -interval for one")
+	}
+
+	if cfg.Pair != "" {
+		if _, err := parsePair(cfg.Pair); err != nil {
+			return err
+		}
+		if cfg.PairFactor <= 1 || cfg.PairSustain < 1 {
+			return errors.New("Pair factor must be greater than 1 and pair sustain at least 1")
+		}
+		if cfg.CountersOnly {
+			return errors.New("-counters-only cannot be combined with -pair, whose records compare the rates")
+		}
+	}
+
+	if cfg.Listen != "" {
+		addrs := cfg.listenAddrs()
+		for i, addr := range addrs {
+			if _, _, err := stdnet.SplitHostPort(addr); err != nil {
+				return fmt.Errorf("Invalid listen address %q, expected host:port or :port", addr)
+			}
+			if slices.Contains(addrs[:i], addr) {
+				return fmt.Errorf("Listen address %q is given more than once", addr)
+			}
+		}
+		if cfg.Pair != "" {
+			return errors.New("-listen cannot be combined with -pair")
+		}
+	}
+
+	if cfg.AnnotateFIFO != "" && cfg.Pair != "" {
+		return errors.New("-annotate-fifo cannot be combined with -pair")
+	}
+
+	if len(cfg.Sinks) > 0 {
+		if _, err := parseSinkSpecs(cfg.Sinks); err != nil {
+			return err
+		}
+		if cfg.Pair != "" {
+			return errors.New("-sink cannot be combined with -pair")
+		}
+	}
+
+	if cfg.Graphite != "" {
+		if _, _, err := stdnet.SplitHostPort(cfg.Graphite); err != nil {
+			return fmt.Errorf("Invalid Graphite address %q, expected host:port", cfg.Graphite)
+		}
+		if cfg.Pair != "" {
+			return errors.New("-graphite cannot be combined with -pair")
+		}
+		if !graphitePrefixPattern.MatchString(cfg.GraphitePrefix) {
+			return fmt.Errorf("Invalid Graphite prefix %q, expected a dot-separated path such as zag.netstats", cfg.GraphitePrefix)
+		}
+	}
+
+	if cfg.Statsd != "" {
+		if _, _, err := stdnet.SplitHostPort(cfg.Statsd); err != nil {
+			return fmt.Errorf("Invalid StatsD address %q, expected host:port", cfg.Statsd)
+		}
+		if cfg.Pair != "" {
+			return errors.New("-statsd cannot be combined with -pair")
+		}
+	}
+	if cfg.StatsdTags && cfg.Statsd == "" {
+		return errors.New("-statsd-tags requires -statsd")
+	}
+	if rules, err := parseShiftRules(cfg.Alerts); err != nil {
+		return err
+	} else if len(rules) > 0 || cfg.RateOfChange {
+		for _, r := range rules {
+			if r.window < cfg.Interval {
+				return fmt.Errorf("Invalid alert %q: the window must be at least the sampling interval %s", r.text, cfg.Interval)
+			}
+		}
+		if cfg.Pair != "" || cfg.CountersOnly {
+			return errors.New("-rate-of-change and -alert cannot be combined with -pair or -counters-only")
+		}
+	}
+	if _, err := parseFixedUnit(cfg.Unit, cfg.Bits, cfg.SI); err != nil {
+		return err
+	}
+	if cfg.SinkDropBudget < 0 || cfg.SinkDropBudget > 100 {
+		return fmt.Errorf("Sink drop budget must be between 0 and 100 percent, got %g", cfg.SinkDropBudget)
+	}
+	if cfg.SinkBudgetWindow < time.Second {
+		return fmt.Errorf("Sink budget window must be at least 1s, got %s", cfg.SinkBudgetWindow)
+	}
+	if cfg.StrictSinks && len(cfg.Sinks) == 0 && cfg.Graphite == "" && cfg.Statsd == "" && cfg.Format != "tui" {
+		return errors.New("-strict-sinks requires a sink: -sink, -graphite, -statsd or -f tui")
+	}
+
+	if cfg.PushGateway != "" {
+		if _, err := parsePushGateway(cfg.PushGateway); err != nil {
+			return err
+		}
+		if cfg.PushJob == "" {
+			return errors.New("Push job name must not be empty")
+		}
+		if _, err := parsePushGrouping(cfg.PushGrouping); err != nil {
+			return err
+		}
+		if cfg.Pair != "" {
+			return errors.New("Pushing to a Pushgateway is not supported with -pair")
+		}
+	}
+
+	if cfg.MaxErrors < 0 {
+		return errors.New("Maximum error count must not be negative")
+	}
+
+	if cfg.Nice < -20 || cfg.Nice > 19 {
+		return errors.New("Nice value must be between -20 and 19")
+	}
+
+	if cfg.PinCPU < -1 {
+		return errors.New("CPU number must not be negative")
+	}
+
+	if cfg.Heartbeat < 0 {
+		return errors.New("Heartbeat period must not be negative")
+	}
+
+	if cfg.Batch < 0 || cfg.BatchMaxAge < 0 {
+		return errors.New("Batch limits must not be negative")
+	}
+
+	if cfg.Batch > 1 && cfg.Format != "json" {
+		return errors.New("Batching is only supported with JSON output")
+	}
+
+	if cfg.Plan != "" {
+		if _, err := parsePlan(cfg.Plan); err != nil {
+			return err
+		}
+	}
+
+	// Shaping detection, report windows and the learned baselines follow the traffic of one
+	// interface. With several, -i total sums them into one.
+	if cfg.severalInterfaces() {
+		switch {
+		case cfg.Plan != "":
+			return errors.New("-plan needs a single interface; use -i total to compare the sum of all interfaces")
+		case cfg.ReportInterval > 0:
+			return errors.New("-report-interval needs a single interface; use -i total to aggregate all interfaces")
+		case cfg.BaselineFile != "":
+			return errors.New("-baseline-file needs a single interface; use -i total to learn the sum of all interfaces")
+		}
+	}
+
+	if _, err := parseTimeFormat(cfg.TimeFormat); err != nil {
+		return err
+	}
+
+	if _, err := parseLogLevel(cfg.LogLevel); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// redacted returns a copy of the configuration with the credentials in the URLs of the
+// Pushgateway and webhook sinks masked by redactedURL, for output that may be shared, such as
+// crash bundles and config print.
+func (cfg monitorConfig) redacted() monitorConfig {
+	cfg.PushGateway = redactedURL(cfg.PushGateway)
+	cfg.Sinks = slices.Clone(cfg.Sinks)
+	for i, def := range cfg.Sinks {
+		if target, ok := strings.CutPrefix(def, "webhook:"); ok {
+			cfg.Sinks[i] = "webhook:" + redactedURL(target)
+		}
+	}
+	return cfg
+}
+
+// severalInterfaces reports whether the configuration monitors more than one interface: a
+// -pair, a -i list, -i all, a pattern, groups or -failover-watch.
+func (cfg *monitorConfig) severalInterfaces() bool {
+	return cfg.Pair != "" || cfg.Interface == "all" || cfg.MatchRegex != "" ||
+		isInterfacePattern(cfg.Interface) || strings.Contains(cfg.Interface, ",") ||
+		len(cfg.Groups) > 0 || cfg.FailoverWatch != ""
+}
+
+// perInterfaceIntervals reports whether entries of a -i list carry their own interval.
+func (cfg *monitorConfig) perInterfaceIntervals() bool {
+	if !strings.Contains(cfg.Interface, ",") || isInterfacePattern(cfg.Interface) {
+		return false
+	}
+	_, every, err := parseInterfaceList(cfg.Interface)
+	return err == nil && slices.ContainsFunc(every, func(d time.Duration) bool { return d != 0 })
+}
+
+// listenAddrs returns the addresses of -listen, which separates several with commas.
+func (cfg *monitorConfig) listenAddrs() []string {
+	var addrs []string
+	for _, addr := range strings.Split(cfg.Listen, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// parseConfig parses the monitor flags in args and applies the profile they select, returning
+// the effective settings before validation.
+func parseConfig(name string, args []string) (monitorConfig, error) {
+	var cfg monitorConfig
+	fs := newMonitorFlagSet(name, &cfg)
+	if err := parseFlags(fs, args); err != nil {
+		return cfg, err
+	}
+	err := applyProfile(fs, cfg.Profile)
+	return cfg, err
+}
+
+// runConfig implements the config subcommand. The only action is "print", which resolves
+// the given monitor flags and prints the effective settings as JSON.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("usage: zag-netStats config print [monitor flags]")
+	}
+
+	cfg, err := parseConfig("config print", args[1:])
+	if err != nil {
+		return err
+	}
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cfg.redacted(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling configuration: %v", err)
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(data))
+	return err
+}
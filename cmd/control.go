@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// controlHelp lists the commands accepted on stdin.
+const controlHelp = `Commands:
+  reset            restart the running totals from zero
+  interval <dur>   change the sampling interval, e.g. 250ms or 5s
+  pause            stop emitting samples (counters are still read)
+  resume           emit samples again
+  sample           emit the latest sample now
+  format <name>    switch the output format (json or table)
+  help             show this help`
+
+// readCommands forwards stdin lines to the returned channel when stdin is a terminal, so
+// commands can be typed at a monitor running in the foreground. It returns nil otherwise.
+// End of input stops reading but not monitoring.
+func readCommands() <-chan string {
+	if !isTerminal(os.Stdin) {
+		return nil
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer handlePanic()
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				lines <- line
+			}
+		}
+	}()
+	return lines
+}
+
+// control applies a control action, such as a command typed on stdin, and returns a short
+// acknowledgment. ticker is the sampling ticker, reset when the interval changes.
+func (nm *NetworkMonitor) control(line string, ticker *time.Ticker) (string, error) {
+	fields := strings.Fields(line)
+	cmd, args := fields[0], fields[1:]
+
+	switch {
+	case cmd == "reset" && len(args) == 0:
+		nm.resetPending = true
+		return "totals reset", nil
+
+	case cmd == "interval" && len(args) == 1:
+		var v intervalValue
+		if err := v.Set(args[0]); err != nil {
+			return "", err
+		}
+		d := time.Duration(v)
+		if !validInterval(d) {
+			return "", fmt.Errorf("interval must be between %v and 1h", netstats.MinInterval)
+		}
+		if nm.report != nil {
+			return "", errors.New("interval cannot change while -report-interval is active")
+		}
+		var step time.Duration
+		if nm.intervals != nil {
+			step = intervalStep(d, slices.Collect(maps.Values(nm.intervals)))
+			if step < netstats.MinInterval {
+				return "", fmt.Errorf("interval %v leaves the per-interface intervals no common step of at least %v", d, netstats.MinInterval)
+			}
+		}
+		// The interval keeps its -stagger slots, each a share of the new interval.
+		if slot := d / time.Duration(max(nm.staggerSlots, 1)); slot < netstats.MinInterval {
+			return "", fmt.Errorf("interval %v splits into %d stagger slots shorter than %v", d, nm.staggerSlots, netstats.MinInterval)
+		}
+		nm.refreshInterval, nm.baseInterval, nm.step = d, d, step
+		ticker.Reset(nm.tickPeriod())
+		return fmt.Sprintf("interval set to %v", d), nil
+
+	case cmd == "pause" && len(args) == 0:
+		nm.paused = true
+		nm.emitEvent(Event{Type: eventPaused, Timestamp: Timestamp(nm.clock.now()), Interface: nm.interfaceName, Message: "sample output paused"})
+		return "paused", nil
+
+	case cmd == "resume" && len(args) == 0:
+		nm.paused = false
+		nm.emitEvent(Event{Type: eventResumed, Timestamp: Timestamp(nm.clock.now()), Interface: nm.interfaceName, Message: "sample output resumed"})
+		return "resumed", nil
+
+	case cmd == "sample" && len(args) == 0:
+		if nm.pair[0] != "" {
+			return "", errors.New("sample is not available in pair mode")
+		}
+		samples := slices.Clone(nm.latestSamples())
+		if len(samples) == 0 {
+			return "", errors.New("no sample collected yet")
+		}
+		for i := range samples {
+			samples[i].recordID = nm.nextID()
+		}
+		if err := nm.writeSamples(samples); err != nil {
+			return "", err
+		}
+		return "sample emitted", nil
+
+	case cmd == "format" && len(args) == 1:
+		// CSV, YAML, InfluxDB and dashboard output are each read by a consumer of that format
+		// alone, so the running format can switch only between table and JSON.
+		if !slices.Contains(textFormats, args[0]) {
+			if slices.Contains(outputFormats, args[0]) {
+				return "", fmt.Errorf("format %s cannot be switched to at run time, allowed: %s", args[0], strings.Join(textFormats, ", "))
+			}
+			return "", fmt.Errorf("unknown format %q, allowed: %s", args[0], strings.Join(textFormats, ", "))
+		}
+		if !slices.Contains(textFormats, nm.format) {
+			return "", fmt.Errorf("format cannot change while -f %s is active", nm.format)
+		}
+		// A JSON array is one document, which records of another format would corrupt.
+		if nm.out.array {
+			return "", errors.New("format cannot change while -json-array is active")
+		}
+		if args[0] == "table" && nm.batch != nil {
+			return "", errors.New("table output is not available while batching")
+		}
+		nm.format = args[0]
+		nm.formatter = newFormatter(nm)
+		return "format set to " + args[0], nil
+
+	default:
+		return "", fmt.Errorf("unknown command %q", line)
+	}
+}
+
+// handleCommand runs a command typed on stdin and acknowledges it on stderr.
+func (nm *NetworkMonitor) handleCommand(line string, ticker *time.Ticker) {
+	if line == "help" {
+		fmt.Fprintln(nm.controlOut, controlHelp)
+		return
+	}
+	ack, err := nm.control(line, ticker)
+	if err != nil {
+		fmt.Fprintf(nm.controlOut, "error: %v\n%s\n", err, controlHelp)
+		return
+	}
+	fmt.Fprintln(nm.controlOut, "ok:", ack)
+}
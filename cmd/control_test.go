@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlCommands(t *testing.T) {
+	src := newFakeSource()
+	src.set("eth0", 0, 0)
+	nm, buf := newTestMonitor(t, "eth0", src, "-f", "json")
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	start := time.Now()
+	var sent uint64
+	step := func(sec int, d uint64) {
+		t.Helper()
+		sent += d
+		src.set("eth0", sent, 0)
+		tick(t, nm, start.Add(time.Duration(sec)*time.Second))
+	}
+	run := func(line, wantAck, wantErr string) {
+		t.Helper()
+		ack, err := nm.control(line, ticker)
+		switch {
+		case wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)):
+			t.Errorf("%s: error %v, want one containing %q", line, err, wantErr)
+		case wantErr == "" && err != nil:
+			t.Errorf("%s: %v", line, err)
+		case ack != wantAck:
+			t.Errorf("%s: acknowledged %q, want %q", line, ack, wantAck)
+		}
+	}
+
+	run("sample", "", "no sample collected yet")
+	step(1, 100)
+	run("sample", "sample emitted", "") // Repeats the sample of 1s
+
+	run("pause", "paused", "")
+	step(2, 200) // Counted but not emitted
+	run("resume", "resumed", "")
+
+	run("reset", "totals reset", "")
+	step(3, 300) // Totals restart with this sample
+	if total, _ := nm.sampler.Totals(); total != 300 {
+		t.Errorf("total sent after reset = %d, want 300", total)
+	}
+
+	run("interval 250ms", "interval set to 250ms", "")
+	if nm.refreshInterval != 250*time.Millisecond {
+		t.Errorf("interval = %v, want 250ms", nm.refreshInterval)
+	}
+	run("interval 10ms", "", "interval must be between")
+	run("interval soon", "", "invalid")
+	run("format yaml", "", "format yaml cannot be switched to")
+	run("format csv", "", "format csv cannot be switched to")
+	run("format xml", "", `unknown format "xml"`)
+	run("reset now", "", `unknown command "reset now"`)
+	run("reboot", "", `unknown command "reboot"`)
+
+	var got []string
+	for _, s := range decodeSamples(t, buf) {
+		got = append(got, s.Interface)
+	}
+	events := decodeEvents(t, buf)
+	if len(got) != 3 {
+		t.Errorf("emitted %d samples, want 3 (1s, its repeat and 3s): %s", len(got), buf)
+	}
+	var types []string
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	if !strings.Contains(strings.Join(types, " "), eventPaused+" "+eventResumed) {
+		t.Errorf("events %q lack paused followed by resumed", types)
+	}
+
+	// Switching the format changes how later samples are written.
+	run("format table", "format set to table", "")
+	buf.Reset()
+	step(4, 400)
+	if !strings.Contains(buf.String(), "eth0") || strings.HasPrefix(buf.String(), "{") {
+		t.Errorf("sample after format table is not a table: %s", buf)
+	}
+}
+
+func TestControlFormatKeepsJSONArray(t *testing.T) {
+	src := newFakeSource()
+	src.set("eth0", 0, 0)
+	nm, buf := newTestMonitor(t, "eth0", src, "-f", "json-array")
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	src.set("eth0", 100, 0)
+	tick(t, nm, time.Now().Add(time.Second))
+	if _, err := nm.control("format table", ticker); err == nil || !strings.Contains(err.Error(), "-json-array") {
+		t.Errorf("format table under -json-array: error %v, want a refusal", err)
+	}
+	if nm.format != "json" {
+		t.Errorf("format = %s, want json", nm.format)
+	}
+
+	// Refused commands print the command list on the control output.
+	var ctl bytes.Buffer
+	nm.controlOut = &ctl
+	nm.handleCommand("format table", ticker)
+	if !strings.HasPrefix(ctl.String(), "error: ") || !strings.Contains(ctl.String(), controlHelp) {
+		t.Errorf("control output = %q, want the error and the command list", ctl.String())
+	}
+	if err := nm.out.closeArray(); err != nil {
+		t.Fatal(err)
+	}
+	if text := strings.TrimSpace(buf.String()); !strings.HasPrefix(text, "[") || !strings.HasSuffix(text, "]") {
+		t.Errorf("output is not one JSON array: %s", buf)
+	}
+}
+
+func TestControlFormatKeepsStreamedFormats(t *testing.T) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for _, format := range []string{"csv", "yaml", "influx"} {
+		src := newFakeSource()
+		src.set("eth0", 0, 0)
+		nm, _ := newTestMonitor(t, "eth0", src, "-f", format)
+		for _, to := range textFormats {
+			if _, err := nm.control("format "+to, ticker); err == nil || !strings.Contains(err.Error(), "-f "+format) {
+				t.Errorf("format %s under -f %s: error %v, want a refusal", to, format, err)
+			}
+		}
+		if nm.format != format {
+			t.Errorf("format = %s, want %s", nm.format, format)
+		}
+	}
+}
+
+func TestControlPauseUsesMonitorClock(t *testing.T) {
+	src := newFakeSource()
+	src.set("eth0", 0, 0)
+	nm, buf := newTestMonitor(t, "eth0", src, "-f", "json")
+	clk := &fakeClock{mono: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	nm.clock = clk
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	nm.control("pause", ticker)
+	clk.advance(90 * time.Second)
+	nm.control("resume", ticker)
+	var got []string
+	for _, ev := range decodeEvents(t, buf) {
+		got = append(got, ev.Type+" "+ev.Timestamp)
+	}
+	want := []string{eventPaused + " 2024-05-01T12:00:00Z", eventResumed + " 2024-05-01T12:01:30Z"}
+	if !slices.Equal(got, want) {
+		t.Errorf("events %q, want %q", got, want)
+	}
+}
+
+func TestControlIntervalWithPerInterfaceIntervals(t *testing.T) {
+	src := newFakeSource()
+	src.set("eth0", 0, 0)
+	src.set("eth1", 0, 0)
+	nm, buf := newTestMonitor(t, "eth0,eth1", src, "-i", "eth0,eth1:60s", "-t", "2s", "-f", "json")
+	_, every, err := parseInterfaceList("eth0,eth1:60s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nm.multi = []string{"eth0", "eth1"}
+	nm.setIntervals(every)
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// An interval shorter than the step recomputes the step rather than dividing it to zero.
+	if _, err := nm.control("interval 1s", ticker); err != nil {
+		t.Fatal(err)
+	}
+	if nm.step != time.Second || nm.tickPeriod() != time.Second {
+		t.Errorf("step %v, tick period %v after interval 1s, want 1s", nm.step, nm.tickPeriod())
+	}
+	src.set("eth0", 100, 0)
+	src.set("eth1", 100, 0)
+	tick(t, nm, time.Now().Add(time.Second))
+	if samples := decodeSamples(t, buf); len(samples) != 1 || samples[0].Interface != "eth0" {
+		t.Errorf("samples after interval 1s = %+v, want one of eth0", samples)
+	}
+
+	// Intervals without a common step of MinInterval are refused and change nothing.
+	if _, err := nm.control("interval 59.99s", ticker); err == nil || !strings.Contains(err.Error(), "common step") {
+		t.Errorf("interval 59.99s: error %v, want a refusal", err)
+	}
+	if nm.refreshInterval != time.Second || nm.step != time.Second {
+		t.Errorf("interval %v, step %v after a refused command, want 1s", nm.refreshInterval, nm.step)
+	}
+}
+
+func TestControlIntervalWithStagger(t *testing.T) {
+	src := newFakeSource()
+	names := []string{"a", "b", "c", "d"}
+	for _, name := range names {
+		src.set(name, 0, 0)
+	}
+	nm, buf := newTestMonitor(t, "a,b,c,d", src, "-i", "a,b,c,d", "-t", "2s", "--stagger", "1s", "-f", "json")
+	nm.multi = names
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// The ticker runs at one slot of the new interval, so each interval still reads every slot.
+	if _, err := nm.control("interval 500ms", ticker); err != nil {
+		t.Fatal(err)
+	}
+	if got := nm.tickPeriod(); got != 250*time.Millisecond {
+		t.Errorf("tick period after interval 500ms = %v, want 250ms", got)
+	}
+	start := time.Now()
+	for i := 1; i <= 2; i++ {
+		for j, name := range names {
+			src.set(name, uint64(i*100*(j+1)), 0)
+		}
+		tick(t, nm, start.Add(time.Duration(i)*250*time.Millisecond))
+	}
+	var got []string
+	for _, s := range decodeSamples(t, buf) {
+		got = append(got, s.Interface)
+	}
+	if want := []string{"a", "c", "b", "d"}; !slices.Equal(got, want) {
+		t.Errorf("sampled %v, want %v", got, want)
+	}
+
+	if _, err := nm.control("interval 80ms", ticker); err == nil || !strings.Contains(err.Error(), "stagger slots") {
+		t.Errorf("interval 80ms: error %v, want a refusal", err)
+	}
+	if nm.refreshInterval != 500*time.Millisecond {
+		t.Errorf("interval = %v after a refused command, want 500ms", nm.refreshInterval)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// Counters holds the absolute kernel counters of an interface at one instant, without any
+// delta computation or unit scaling, for consumers that derive rates themselves.
+type Counters struct {
+	Timestamp   Timestamp `json:"timestamp"`
+	BytesSent   uint64    `json:"bytesSent"`
+	BytesRecv   uint64    `json:"bytesRecv"`
+	PacketsSent uint64    `json:"packetsSent"`
+	PacketsRecv uint64    `json:"packetsRecv"`
+	Errin       uint64    `json:"errin"`
+	Errout      uint64    `json:"errout"`
+	Dropin      uint64    `json:"dropin"`
+	Dropout     uint64    `json:"dropout"`
+	Unavailable []string  `json:"unavailable,omitempty"` // Fields above the platform does not populate, reported as zero
+}
+
+// counterRecord is the sample emitted in counters-only mode, where derived fields are omitted.
+type counterRecord struct {
+	Interface string `json:"interface"`
+	Counters
+	recordID
+}
+
+// newCounters captures the raw counters of an I/O reading taken at t.
+func newCounters(s net.IOCountersStat, t time.Time) *Counters {
+	return &Counters{
+		Timestamp:   Timestamp(t),
+		BytesSent:   s.BytesSent,
+		BytesRecv:   s.BytesRecv,
+		PacketsSent: s.PacketsSent,
+		PacketsRecv: s.PacketsRecv,
+		Errin:       s.Errin,
+		Errout:      s.Errout,
+		Dropin:      s.Dropin,
+		Dropout:     s.Dropout,
+	}
+}
+
+// printCountersTable prints the raw counters of an interface in a tabular format to w.
+func printCountersTable(w io.Writer, iface string, c *Counters) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Interface", "Timestamp", "Bytes Sent", "Bytes Recv", "Packets Sent", "Packets Recv", "Errors In", "Errors Out", "Drops In", "Drops Out"})
+
+	u := func(v uint64, field string) string {
+		if slices.Contains(c.Unavailable, field) {
+			return "n/a"
+		}
+		return strconv.FormatUint(v, 10)
+	}
+	table.Append([]string{
+		iface,
+		c.Timestamp.String(),
+		u(c.BytesSent, "bytesSent"), u(c.BytesRecv, "bytesRecv"),
+		u(c.PacketsSent, "packetsSent"), u(c.PacketsRecv, "packetsRecv"),
+		u(c.Errin, "errin"), u(c.Errout, "errout"),
+		u(c.Dropin, "dropin"), u(c.Dropout, "dropout"),
+	})
+
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(true)
+	table.SetRowLine(true)
+
+	table.Render()
+}
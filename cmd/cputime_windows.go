@@ -0,0 +1,22 @@
+package main
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime returns the user and system CPU time consumed by the process so far.
+func processCPUTime() (time.Duration, error) {
+	h, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0, err
+	}
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return 0, err
+	}
+	// Filetime values count 100-nanosecond intervals.
+	ticks := uint64(kernel.HighDateTime)<<32 | uint64(kernel.LowDateTime)
+	ticks += uint64(user.HighDateTime)<<32 | uint64(user.LowDateTime)
+	return time.Duration(ticks * 100), nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// crashRecentSamples is how many of the latest samples are kept for a crash bundle.
+const crashRecentSamples = 20
+
+// crashState holds what a crash bundle reports about the running monitor. It is filled in once
+// the configuration is parsed; a panic before that gets a bundle without config or samples.
+var crashState struct {
+	mu      sync.Mutex
+	cfg     *monitorConfig
+	monitor *NetworkMonitor
+	cleanup func() // Restores the terminal before the crash is reported, nil if not needed
+}
+
+// setCrashState registers the configuration and monitor described by crash bundles.
+func setCrashState(cfg *monitorConfig, nm *NetworkMonitor) {
+	crashState.mu.Lock()
+	defer crashState.mu.Unlock()
+	crashState.cfg, crashState.monitor = cfg, nm
+}
+
+// setCrashCleanup registers a function restoring the terminal when the monitor panics, such as
+// one leaving the dashboard, or removes it when f is nil.
+func setCrashCleanup(f func()) {
+	crashState.mu.Lock()
+	defer crashState.mu.Unlock()
+	crashState.cleanup = f
+}
+
+// handlePanic recovers a panic of the calling goroutine, writes a diagnostic bundle unless
+// disabled, and exits with exitPanic. It must be deferred at the top of every goroutine, since
+// a panic cannot be recovered from another one.
+func handlePanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	crashState.mu.Lock()
+	cfg, cleanup := crashState.cfg, crashState.cleanup
+	crashState.mu.Unlock()
+	if cleanup != nil {
+		cleanup()
+	}
+	log.Printf("Panic: %v", r)
+	if cfg != nil && cfg.NoCrashBundle {
+		log.Printf("Crash bundle disabled by -no-crash-bundle")
+		os.Exit(exitPanic)
+	}
+
+	dir := os.TempDir()
+	if cfg != nil && cfg.CrashDir != "" {
+		dir = cfg.CrashDir
+	}
+	path, err := writeCrashBundle(dir, r)
+	if err != nil {
+		log.Printf("Error writing crash bundle: %v", err)
+	} else {
+		log.Printf("Crash bundle written to %s", path)
+	}
+	os.Exit(exitPanic)
+}
+
+// writeCrashBundle writes the panic value, version, effective configuration, latest samples and
+// the stacks of all goroutines to a timestamped file in dir, returning its path.
+func writeCrashBundle(dir string, r any) (string, error) {
+	now := time.Now()
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+
+	crashState.mu.Lock()
+	cfg, nm := crashState.cfg, crashState.monitor
+	crashState.mu.Unlock()
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-crash-%s-%d.txt", progName(), now.Format("20060102T150405"), os.Getpid()))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(f, "%s crash report\nTime: %s\nVersion: %s\nGo: %s %s/%s\nPanic: %v\n",
+		progName(), now.Format(time.RFC3339Nano), version, runtime.Version(), runtime.GOOS, runtime.GOARCH, r)
+
+	fmt.Fprintf(f, "\n== Configuration ==\n")
+	if cfg != nil {
+		data, _ := json.MarshalIndent(cfg.redacted(), "", "  ")
+		fmt.Fprintf(f, "%s\n", data)
+	}
+
+	fmt.Fprintf(f, "\n== Recent samples ==\n")
+	// The panic may have happened with the monitor locked; samples are then left out rather
+	// than risking a deadlock.
+	if nm != nil && nm.mu.TryLock() {
+		for _, s := range nm.recent {
+			data, _ := json.Marshal(s)
+			fmt.Fprintf(f, "%s\n", data)
+		}
+		nm.mu.Unlock()
+	}
+
+	fmt.Fprintf(f, "\n== Goroutines ==\n%s\n", buf)
+	return path, f.Close()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// csvHeader is the header row of CSV output, written once when monitoring starts.
+var csvHeader = []string{"interface", "timestamp", "sent_speed_bps", "recv_speed_bps", "total_sent_bytes", "total_recv_bytes", "total_usage_bytes"}
+
+// rawFigures are the unscaled figures of a sample. CSV output writes them instead of the
+// auto-scaled values, so a column keeps one unit across rows.
+type rawFigures struct {
+	sentBps   float64 // Bytes per second
+	recvBps   float64
+	totalSent uint64 // Bytes since monitoring started
+	totalRecv uint64
+}
+
+// newRawFigures returns the figures of a sample that moved sent and recv bytes over interval
+// seconds.
+func newRawFigures(sent, recv uint64, interval float64, totalSent, totalRecv uint64) rawFigures {
+	return rawFigures{
+		sentBps:   float64(sent) / interval,
+		recvBps:   float64(recv) / interval,
+		totalSent: totalSent,
+		totalRecv: totalRecv,
+	}
+}
+
+// parseCSVDelimiter interprets the value of -csv-delimiter, a single character such as ";".
+func parseCSVDelimiter(s string) (rune, error) {
+	if s == `\t` {
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("Invalid CSV delimiter %q, expected a single character such as ; or \\t", s)
+	}
+	return r, nil
+}
+
+// csvRow returns the CSV row of a sample.
+func csvRow(s NetStats, precision int) []string {
+	return []string{
+		s.Interface,
+		s.Timestamp.String(),
+		strconv.FormatFloat(s.raw.sentBps, 'f', precision, 64),
+		strconv.FormatFloat(s.raw.recvBps, 'f', precision, 64),
+		strconv.FormatUint(s.raw.totalSent, 10),
+		strconv.FormatUint(s.raw.totalRecv, 10),
+		strconv.FormatUint(s.raw.totalSent+s.raw.totalRecv, 10),
+	}
+}
+
+// printCSV writes rows to w, delimited by comma.
+func printCSV(w io.Writer, comma rune, rows ...[]string) {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	cw.WriteAll(rows) // Flushes; write errors surface through w
+}
+
+// writeCSVHeader writes the CSV header row. It is flushed at once, so tail -f shows it before
+// the first sample. An -o file appended to already has it. With -header, the header record
+// comes first as a JSON line after "# ", which CSV readers skip as a comment; it is written
+// on every run, so an appended file records where each session starts.
+func (nm *NetworkMonitor) writeCSVHeader() error {
+	var comment []byte
+	if nm.configDigest != "" {
+		h := nm.headerRecord()
+		nm.sendSinkRecord(h)
+		data, err := json.Marshal(h)
+		if err != nil {
+			return err
+		}
+		comment = append(append([]byte("# "), data...), '\n')
+	}
+	if nm.continuesFile && comment == nil {
+		return nil
+	}
+	err := nm.out.writeRecord(func(w io.Writer) {
+		w.Write(comment)
+		if !nm.continuesFile {
+			printCSV(w, nm.csvDelimiter, csvHeader)
+		}
+	})
+	if err == nil {
+		err = nm.out.Flush()
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCSVHeaderRecord(t *testing.T) {
+	columns := strings.Join(csvHeader, ",")
+	tests := []struct {
+		args          []string
+		continuesFile bool
+		comment       bool
+		columns       bool
+	}{
+		{nil, false, false, true},
+		{[]string{"-header"}, false, true, true},
+		// An appended file already has the column row, but each run marks its start.
+		{nil, true, false, false},
+		{[]string{"-header"}, true, true, false},
+	}
+	for _, tt := range tests {
+		src := newFakeSource()
+		src.set("eth0", 1000, 2000)
+		nm, buf := newTestMonitor(t, "eth0", src, append([]string{"-f", "csv"}, tt.args...)...)
+		nm.continuesFile = tt.continuesFile
+		if err := nm.writeCSVHeader(); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		if buf.Len() == 0 {
+			lines = nil
+		}
+		var want []string
+		if tt.comment {
+			if len(lines) == 0 || !strings.HasPrefix(lines[0], "# ") {
+				t.Errorf("%q appending %v: output %q, want a # header line first", tt.args, tt.continuesFile, buf)
+				continue
+			}
+			var h struct {
+				Type       string   `json:"type"`
+				Seq        uint64   `json:"seq"`
+				Interfaces []string `json:"interfaces"`
+			}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[0], "# ")), &h); err != nil {
+				t.Fatalf("header line %q: %v", lines[0], err)
+			}
+			if h.Type != "header" || h.Seq != 1 || len(h.Interfaces) != 1 || h.Interfaces[0] != "eth0" {
+				t.Errorf("header %+v, want the eth0 header with seq 1", h)
+			}
+			want = append(want, lines[0])
+		}
+		if tt.columns {
+			want = append(want, columns)
+		}
+		if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%q appending %v: output %q, want %q", tt.args, tt.continuesFile, lines, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// debugTee decorates the output destination, copying every write to a debugging file with the
+// time it started and finished and its outcome. Failures of the copy never affect the output:
+// the first is logged and the copy stops.
+type debugTee struct {
+	w    io.Writer // Output destination
+	f    *os.File  // Copy, nil once writing it failed
+	path string
+}
+
+// newDebugTee wraps w, copying to the file at path. A file that cannot be created is logged
+// and w is returned as is.
+func newDebugTee(w io.Writer, path string) io.Writer {
+	f, err := os.Create(path)
+	if err != nil {
+		logWarnf("Not copying output to %s: %v", path, err)
+		return w
+	}
+	return &debugTee{w: w, f: f, path: path}
+}
+
+func (t *debugTee) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.w.Write(p)
+	if t.f == nil {
+		return n, err
+	}
+	outcome := "ok"
+	if err != nil {
+		outcome = "failed: " + err.Error()
+	}
+	_, terr := fmt.Fprintf(t.f, "# write started %s, finished %s, %d bytes, %s\n",
+		start.UTC().Format(time.RFC3339Nano), time.Now().UTC().Format(time.RFC3339Nano), len(p), outcome)
+	if terr == nil {
+		_, terr = t.f.Write(p)
+	}
+	if terr != nil {
+		logWarnf("Stopped copying output to %s: %v", t.path, terr)
+		t.f.Close()
+		t.f = nil
+	}
+	return n, err
+}
+
+// Close closes the copy; the output destination is left open.
+func (t *debugTee) Close() error {
+	if t.f == nil {
+		return nil
+	}
+	err := t.f.Close()
+	t.f = nil
+	return err
+}
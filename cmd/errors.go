@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// Process exit codes. Wrappers can rely on these to tell failure classes apart.
+const (
+	exitOK                = 0 // Monitoring finished normally
+	exitFailure           = 1 // Monitoring failed at runtime or an unclassified error occurred
+	exitUsage             = 2 // Invalid flags, values or flag combinations
+	exitInterfaceNotFound = 3 // The requested interface does not exist
+	exitPermission        = 4 // A file, device or counter could not be accessed for lack of permission
+	exitCollection        = 5 // Reading the counters kept failing (see -max-errors)
+	exitOutput            = 6 // Writing records to the output failed
+	exitPanic             = 7 // The monitor crashed on an internal error (see -crash-dir)
+)
+
+// exitCodeHelp documents the exit codes in --help output.
+const exitCodeHelp = `
+Exit codes:
+  0  monitoring finished normally
+  1  runtime failure or unclassified error
+  2  invalid flags, values or flag combinations
+  3  the requested interface does not exist
+  4  permission denied
+  5  reading the counters failed persistently (-max-errors)
+  6  writing output failed
+  7  internal error (panic); a crash bundle is written to -crash-dir
+`
+
+// Error codes reported in machine-readable startup errors.
+const (
+	errCodeInvalidFlag       = "invalid_flag"
+	errCodeMissingInterface  = "missing_interface"
+	errCodeInvalidValue      = "invalid_value"
+	errCodeInterfaceNotFound = "interface_not_found"
+	errCodeInterfaceLookup   = "interface_lookup_failed"
+	errCodePermission        = "permission_denied"
+	errCodeLocked            = "state_locked"
+	errCodeListen            = "listen_failed"
+	errCodeConnect           = "connect_failed"
+	errCodeOutput            = "output_failed"
+)
+
+// startupError is a failure detected while validating the configuration, before monitoring starts.
+type startupError struct {
+	Code       string   `json:"code"`                 // Stable machine-readable error class
+	Message    string   `json:"message"`              // Human-readable description
+	Available  []string `json:"available,omitempty"`  // Available interface names, for interface errors
+	Suggestion string   `json:"suggestion,omitempty"` // Closest available name to a mistyped one
+	exitCode   int      // Process exit code for this error class
+}
+
+func (e *startupError) Error() string {
+	return e.Message
+}
+
+// newStartupError classifies err into a startupError with the given code and exit code.
+// Permission failures are always classified as such, whatever the caller suggests.
+func newStartupError(code string, exitCode int, err error) *startupError {
+	if errors.Is(err, fs.ErrPermission) {
+		code, exitCode = errCodePermission, exitPermission
+	}
+	return &startupError{Code: code, Message: err.Error(), exitCode: exitCode}
+}
+
+// runtimeError is a failure that ended monitoring, carrying the exit code of its class.
+type runtimeError struct {
+	err      error
+	exitCode int
+}
+
+func (e *runtimeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *runtimeError) Unwrap() error {
+	return e.err
+}
+
+// outputError classifies a failure to write records.
+func outputError(err error) error {
+	return &runtimeError{err: fmt.Errorf("error writing output: %w", err), exitCode: exitOutput}
+}
+
+// interfaceError classifies a failure to resolve the requested interface, listing the
+// interfaces that do exist when it simply was not found. For a mistyped name, requested, the
+// closest existing name is suggested; pass "" for identifiers other than names.
+func interfaceError(err error, requested string) *startupError {
+	if !errors.Is(err, errInterfaceNotFound) {
+		return newStartupError(errCodeInterfaceLookup, exitFailure, err)
+	}
+
+	se := newStartupError(errCodeInterfaceNotFound, exitInterfaceNotFound, err)
+	if ifaces, err := net.Interfaces(); err == nil {
+		for _, iface := range ifaces {
+			se.Available = append(se.Available, iface.Name)
+		}
+	}
+	if requested != "" {
+		se.Suggestion = closestName(requested, se.Available)
+	}
+	if se.Suggestion != "" {
+		se.Message += fmt.Sprintf(" (did you mean %s?)", se.Suggestion)
+	}
+	se.Message += fmt.Sprintf("; run \"%s list\" to see all interfaces", progName())
+	return se
+}
+
+// closestName returns the candidate nearest to name by edit distance, or "" when none is
+// close enough to be a plausible typo.
+func closestName(name string, candidates []string) string {
+	best, bestDist := "", len(name)/2+1
+	for _, c := range candidates {
+		if d := editDistance(name, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// isMachineFormat reports whether the output format is meant to be consumed by programs.
+func isMachineFormat(format string) bool {
+	return format == "json"
+}
+
+// reportStartupError writes a startup error as a single JSON object on stdout when the
+// selected format is a machine format, so wrappers can react without parsing stderr.
+func reportStartupError(format string, err error) {
+	var se *startupError
+	if !isMachineFormat(format) || !errors.As(err, &se) {
+		return
+	}
+	printJSON(os.Stdout, struct {
+		Error *startupError `json:"error"`
+	}{se})
+}
+
+// exitStatus is returned by subcommands that want a specific exit code without logging an error.
+type exitStatus int
+
+func (s exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
+}
+
+// exitCode maps an error returned by a subcommand to the process exit code.
+func exitCode(err error) int {
+	var se *startupError
+	var re *runtimeError
+	var status exitStatus
+	switch {
+	case err == nil || errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &status):
+		return int(status)
+	case errors.As(err, &se):
+		return se.exitCode
+	case errors.As(err, &re):
+		return re.exitCode
+	case errors.Is(err, fs.ErrPermission):
+		return exitPermission
+	default:
+		return exitFailure
+	}
+}
+
+// exit terminates the process with the exit code matching err, logging it first.
+func exit(err error) {
+	var status exitStatus
+	if err != nil && !errors.Is(err, flag.ErrHelp) && !errors.As(err, &status) {
+		// Flag parse errors were already printed by the flag package along with the usage.
+		var se *startupError
+		if !errors.As(err, &se) || se.Code != errCodeInvalidFlag {
+			log.Print(err)
+		}
+	}
+	os.Exit(exitCode(err))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, exitOK},
+		{"help", flag.ErrHelp, exitOK},
+		{"unclassified", failed, exitFailure},
+		{"usage", &startupError{Code: errCodeInvalidValue, exitCode: exitUsage}, exitUsage},
+		{"interface not found", interfaceError(fmt.Errorf("%w: eth9", errInterfaceNotFound), "eth9"), exitInterfaceNotFound},
+		{"interface lookup", interfaceError(failed, "eth9"), exitFailure},
+		{"permission at startup", newStartupError(errCodeListen, exitFailure, fs.ErrPermission), exitPermission},
+		{"permission at runtime", fmt.Errorf("open state: %w", fs.ErrPermission), exitPermission},
+		{"collection", &runtimeError{err: failed, exitCode: exitCollection}, exitCollection},
+		{"output", outputError(io.ErrClosedPipe), exitOutput},
+		{"panic", &runtimeError{err: failed, exitCode: exitPanic}, exitPanic},
+		{"wrapped", fmt.Errorf("monitor: %w", outputError(io.ErrClosedPipe)), exitOutput},
+		{"status", exitStatus(3), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// captureStdout returns what f writes to os.Stdout.
+func captureStdout(t *testing.T, f func()) []byte {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	f()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestReportStartupError(t *testing.T) {
+	se := &startupError{Code: errCodeInterfaceNotFound, Message: "interface not found: eth9", Available: []string{"lo", "eth0"}, exitCode: exitInterfaceNotFound}
+
+	out := captureStdout(t, func() { reportStartupError("json", fmt.Errorf("setup: %w", se)) })
+	var got struct {
+		Error startupError `json:"error"`
+	}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("stdout is not a JSON error: %v: %q", err, out)
+	}
+	if got.Error.Code != se.Code || got.Error.Message != se.Message || len(got.Error.Available) != 2 {
+		t.Errorf("reported %+v, want %+v", got.Error, *se)
+	}
+
+	// Human formats and errors without a class leave stdout alone.
+	for _, format := range []string{"table", "csv"} {
+		if out := captureStdout(t, func() { reportStartupError(format, se) }); len(out) != 0 {
+			t.Errorf("format %s printed %q", format, out)
+		}
+	}
+	if out := captureStdout(t, func() { reportStartupError("json", errors.New("failed")) }); len(out) != 0 {
+		t.Errorf("unclassified error printed %q", out)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Event types emitted into the output stream alongside regular samples.
+const (
+	eventConfigChange    = "config-change"    // MTU, link speed or duplex changed
+	eventAddressChange   = "address-change"   // Addresses were added or removed
+	eventRouteChange     = "route-change"     // The default route moved to another interface
+	eventPossibleShaping = "possible-shaping" // Throughput plateaued just below a round rate
+	eventTrafficResumed  = "traffic-resumed"  // Traffic resumed after suppressed idle samples
+	eventClockStep       = "clock-step"       // The wall clock jumped, e.g. on an NTP step
+	eventPaused          = "paused"           // Sample output was paused by a control command
+	eventResumed         = "resumed"          // Sample output was resumed by a control command
+	eventImplausibleRate = "implausible-rate" // A computed rate exceeded the plausibility ceiling
+	eventQdiscDrops      = "qdisc-drops"      // The root qdisc dropped packets
+	eventPartialCounters = "partial-counters" // The platform leaves some counters of the interface at zero
+	eventAnnotation      = "annotation"       // An external marker, posted to /annotate or written to -annotate-fifo
+)
+
+// Event severities, from least to most urgent.
+const (
+	severityInfo    = "info"
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+// eventSeverities gives the severity of each event type; unlisted types are informational.
+var eventSeverities = map[string]string{
+	eventConfigChange:    severityWarning,
+	eventRouteChange:     severityWarning,
+	eventImplausibleRate: severityWarning,
+	eventQdiscDrops:      severityWarning,
+}
+
+// severityColors maps severities to the ANSI colors used for events on a terminal.
+var severityColors = map[string]string{
+	severityWarning: "\x1b[33m",
+	severityError:   "\x1b[31m",
+}
+
+// Event describes a notable occurrence while monitoring an interface. Every producer emits
+// events through emitEvent, so they share one schema in every output format.
+type Event struct {
+	Type      string    `json:"type"`
+	Severity  string    `json:"severity"`
+	Timestamp Timestamp `json:"timestamp"`
+	Interface string    `json:"interface"`
+	Message   string    `json:"message"`
+	Details   any       `json:"details,omitempty"`
+	recordID
+}
+
+// printEventLine prints an event as a single human-readable line to w, colored by severity
+// when color is set.
+func printEventLine(w io.Writer, ev Event, color bool) {
+	line := fmt.Sprintf("[%s] %s %s: %s", ev.Timestamp, ev.Interface, ev.Type, ev.Message)
+	if code, ok := severityColors[ev.Severity]; ok && color {
+		line = code + line + "\x1b[0m"
+	}
+	fmt.Fprintln(w, line)
+}
+
+// emitEvent writes an event in the configured output format. Events are urgent, so buffered
+// output is flushed right away instead of waiting for the buffer limits.
+func (nm *NetworkMonitor) emitEvent(ev Event) {
+	if nm.hourly != nil {
+		switch ev.Type {
+		case eventConfigChange, eventAddressChange, eventRouteChange:
+			nm.hourly.events++
+		}
+	}
+	if ev.Severity == "" {
+		ev.Severity = severityInfo
+		if s, ok := eventSeverities[ev.Type]; ok {
+			ev.Severity = s
+		}
+	}
+	// Warnings in quiet hours are expected, such as a backup saturating the link, and only counted.
+	if ev.Severity == severityWarning && nm.quietHours != nil && nm.quietHours.window(time.Time(ev.Timestamp)) != nil {
+		nm.session.quietHours.SuppressedEvents++
+		logDebugf("Suppressed %s event in quiet hours", ev.Type)
+		return
+	}
+	ev.recordID = nm.nextID()
+	err := nm.writeRecord(ev, func(w io.Writer) {
+		printEventLine(w, ev, nm.color)
+	})
+	if err != nil {
+		logErrorf("Error writing output: %v", err)
+	}
+}
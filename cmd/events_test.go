@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// jsonEvent holds the fields of a JSON event that tests check.
+type jsonEvent struct {
+	Type      string          `json:"type"`
+	Timestamp string          `json:"timestamp"`
+	Severity  string          `json:"severity"`
+	Details   json.RawMessage `json:"details"`
+}
+
+// decodeEvents returns the events among the JSON lines in buf, skipping samples and records
+// without a severity.
+func decodeEvents(t *testing.T, buf *bytes.Buffer) []jsonEvent {
+	t.Helper()
+	var events []jsonEvent
+	sc := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for sc.Scan() {
+		var ev jsonEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", sc.Text(), err)
+		}
+		if ev.Severity != "" {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func TestEventSeverities(t *testing.T) {
+	tests := []struct {
+		ev   Event
+		want string
+	}{
+		{Event{Type: eventConfigChange}, severityWarning},
+		{Event{Type: eventRouteChange}, severityWarning},
+		{Event{Type: eventAddressChange}, severityInfo},
+		{Event{Type: eventAnnotation}, severityInfo},
+		{Event{Type: eventAnnotation, Severity: severityError}, severityError}, // A given severity is kept
+	}
+	nm, buf := newTestMonitor(t, "eth0", newFakeSource(), "-f", "json")
+	for _, tt := range tests {
+		tt.ev.Timestamp = Timestamp(time.Now())
+		nm.emitEvent(tt.ev)
+	}
+	events := decodeEvents(t, buf)
+	if len(events) != len(tests) {
+		t.Fatalf("got %d events, want %d: %s", len(events), len(tests), buf)
+	}
+	for i, tt := range tests {
+		if events[i].Type != tt.ev.Type || events[i].Severity != tt.want {
+			t.Errorf("event %d = %s %s, want %s %s", i, events[i].Type, events[i].Severity, tt.ev.Type, tt.want)
+		}
+	}
+}
+
+func TestQuietHoursSuppressWarnings(t *testing.T) {
+	nm, buf := newTestMonitor(t, "eth0", newFakeSource(), "-f", "json", "-quiet-hours", "22:00-02:00", "-quiet-hours-tz", "UTC")
+	quiet := time.Date(2024, 5, 1, 23, 30, 0, 0, time.UTC)
+	after := time.Date(2024, 5, 2, 2, 0, 0, 0, time.UTC) // Windows end before their end minute
+
+	emit := func(typ, severity string, at time.Time) {
+		nm.emitEvent(Event{Type: typ, Severity: severity, Timestamp: Timestamp(at)})
+	}
+	emit(eventConfigChange, "", quiet)                   // Suppressed
+	emit(eventRouteChange, "", quiet)                    // Suppressed
+	emit(eventAddressChange, "", quiet)                  // Informational, emitted
+	emit(eventAnnotation, severityError, quiet)          // Errors are never suppressed
+	emit(eventConfigChange, "", after)                   // Outside the window, emitted
+	emit(eventConfigChange, "", quiet.Add(-2*time.Hour)) // Before the window, emitted
+
+	var got []string
+	for _, ev := range decodeEvents(t, buf) {
+		got = append(got, ev.Type+" "+ev.Severity)
+	}
+	want := []string{
+		"address-change info",
+		"annotation error",
+		"config-change warning",
+		"config-change warning",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("emitted %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if n := nm.session.quietHours.SuppressedEvents; n != 2 {
+		t.Errorf("suppressed %d events, want 2", n)
+	}
+}
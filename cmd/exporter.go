@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdnet "net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// exporterShutdownTimeout bounds how long scrapes in progress may delay shutdown.
+const exporterShutdownTimeout = 5 * time.Second
+
+// exporterMetrics describes the metric families served on /metrics, in base units.
+var exporterMetrics = []struct {
+	name  string
+	kind  string
+	help  string
+	value func(rawFigures) float64
+}{
+	{"zag_net_sent_bytes_per_second", "gauge", "Send rate over the latest sample.", func(r rawFigures) float64 { return r.sentBps }},
+	{"zag_net_recv_bytes_per_second", "gauge", "Receive rate over the latest sample.", func(r rawFigures) float64 { return r.recvBps }},
+	{"zag_net_total_bytes_per_second", "gauge", "Combined send and receive rate over the latest sample.", func(r rawFigures) float64 { return r.sentBps + r.recvBps }},
+	{"zag_net_sent_bytes_total", "counter", "Bytes sent since monitoring started.", func(r rawFigures) float64 { return float64(r.totalSent) }},
+	{"zag_net_recv_bytes_total", "counter", "Bytes received since monitoring started.", func(r rawFigures) float64 { return float64(r.totalRecv) }},
+	{"zag_net_total_bytes_total", "counter", "Bytes sent and received since monitoring started.", func(r rawFigures) float64 { return float64(r.totalSent + r.totalRecv) }},
+}
+
+// sinkMetrics describes the metric families of the sink deliveries served on /metrics.
+var sinkMetrics = []struct {
+	name  string
+	kind  string
+	help  string
+	value func(SinkHealth) float64
+}{
+	{"zag_sink_attempted_total", "counter", "Ticks of samples handed to the sink.", func(h SinkHealth) float64 { return float64(h.Attempted) }},
+	{"zag_sink_succeeded_total", "counter", "Ticks of samples the sink delivered.", func(h SinkHealth) float64 { return float64(h.Succeeded) }},
+	{"zag_sink_retried_total", "counter", "Repeated attempts at deliveries that failed.", func(h SinkHealth) float64 { return float64(h.Retried) }},
+	{"zag_sink_dropped_total", "counter", "Ticks of samples the sink gave up on.", func(h SinkHealth) float64 { return float64(h.Dropped) }},
+	{"zag_sink_pending", "gauge", "Deliveries without an outcome yet, such as queued ones.", func(h SinkHealth) float64 { return float64(h.Pending) }},
+	{"zag_sink_window_drop_ratio", "gauge", "Share of the deliveries of the last -sink-budget-window that were dropped.", func(h SinkHealth) float64 { return h.WindowDropRatio }},
+	{"zag_sink_last_success_timestamp_seconds", "gauge", "Unix time of the latest delivery, 0 before any.", func(h SinkHealth) float64 {
+		if h.LastSuccess == nil {
+			return 0
+		}
+		return float64(time.Time(*h.LastSuccess).UnixMilli()) / 1000
+	}},
+}
+
+// exporter serves the latest samples of a monitor for Prometheus to scrape.
+type exporter struct {
+	srv  *http.Server
+	done sync.WaitGroup // Done when the server has stopped serving on every listener
+}
+
+// startExporter listens on addrs, such as ":9123", and serves /metrics from the samples nm
+// stores under its mutex. Sockets passed by socket activation are used instead of binding
+// addrs. Listening happens before it returns, so a port in use is a startup error rather than
+// a log line.
+func startExporter(addrs []string, nm *NetworkMonitor) (*exporter, error) {
+	listeners, err := exporterListeners(addrs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot serve metrics: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		var b bytes.Buffer
+		writeExporterMetrics(&b, nm.latestSamples())
+		writeSinkMetrics(&b, nm.sinkRegistry.health())
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(b.Bytes())
+	})
+	mux.HandleFunc("/sinks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(nm.sinkRegistry.health())
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		status := struct {
+			Features []FeatureCheck `json:"features"` // Privilege probe of the requested features
+		}{Features: []FeatureCheck{}}
+		status.Features = append(status.Features, nm.features...)
+		enc.Encode(status)
+	})
+	if nm.annotator != nil {
+		mux.Handle("/annotate", nm.annotator)
+	}
+	e := &exporter{srv: &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}}
+	for _, ln := range listeners {
+		e.done.Add(1)
+		go func() {
+			defer handlePanic()
+			defer e.done.Done()
+			if err := e.srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+				logErrorf("Metrics endpoint on %s stopped: %v", ln.Addr(), err)
+			}
+		}()
+		logInfof("Serving Prometheus metrics on http://%s/metrics", ln.Addr())
+	}
+	return e, nil
+}
+
+// exporterListeners returns the sockets passed by socket activation, or else binds each of
+// addrs. An address with an IPv4 or IPv6 host binds that family only, so "0.0.0.0:9123" and
+// "[::]:9123" can be given together.
+func exporterListeners(addrs []string) ([]stdnet.Listener, error) {
+	listeners, err := activatedListeners()
+	if err != nil {
+		return nil, err
+	}
+	if len(listeners) > 0 {
+		logInfof("Using %d socket-activated listener(s) instead of binding %s", len(listeners), strings.Join(addrs, ","))
+		return listeners, nil
+	}
+	for _, addr := range addrs {
+		ln, err := stdnet.Listen(listenNetwork(addr), addr)
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// listenNetwork returns the network to bind addr on: "tcp4" or "tcp6" for an IP literal of
+// that family, and "tcp" for both otherwise.
+func listenNetwork(addr string) string {
+	host, _, _ := stdnet.SplitHostPort(addr)
+	ip := stdnet.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "tcp"
+	case ip.To4() != nil:
+		return "tcp4"
+	default:
+		return "tcp6"
+	}
+}
+
+// close stops the server, letting scrapes in progress finish. Its listeners are closed,
+// activated ones included; the service manager keeps its own copies to activate the next
+// instance with.
+func (e *exporter) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), exporterShutdownTimeout)
+	defer cancel()
+	if err := e.srv.Shutdown(ctx); err != nil {
+		logWarnf("Error stopping the metrics endpoint: %v", err)
+	}
+	e.done.Wait()
+}
+
+// writeExporterMetrics renders samples in the Prometheus text exposition format, one series per
+// interface or group in each metric family.
+func writeExporterMetrics(b *bytes.Buffer, samples []NetStats) {
+	for _, m := range exporterMetrics {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, s := range samples {
+			fmt.Fprintf(b, "%s{interface=\"%s\"} %v\n", m.name, escapeLabelValue(s.Interface), m.value(s.raw))
+		}
+	}
+}
+
+// writeSinkMetrics renders the delivery records of the sinks, one series per sink in each
+// metric family. Nothing is written without sinks.
+func writeSinkMetrics(b *bytes.Buffer, health []SinkHealth) {
+	if len(health) == 0 {
+		return
+	}
+	for _, m := range sinkMetrics {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, h := range health {
+			fmt.Fprintf(b, "%s{sink=\"%s\"} %v\n", m.name, escapeLabelValue(h.Name), m.value(h))
+		}
+	}
+}
+
+// latestSamples returns the samples of the latest tick, or nothing before the first sample.
+func (nm *NetworkMonitor) latestSamples() []NetStats {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+	if nm.tick != nil {
+		return nm.tick
+	}
+	if nm.stats.Interface == "" {
+		return nil
+	}
+	return []NetStats{nm.stats}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// Events of -failover-watch, when traffic moves from the primary interface to the backup and
+// back.
+const (
+	eventFailover = "failover"
+	eventFailback = "failback"
+)
+
+// FailoverCycle is one switch of traffic between the interfaces of -failover-watch, reported
+// in its event and in the session summary.
+type FailoverCycle struct {
+	Kind            string    `json:"kind"` // failover or failback
+	From            string    `json:"from"`
+	To              string    `json:"to"`
+	Stopped         Timestamp `json:"stopped"`         // Last sample with traffic on From
+	Resumed         Timestamp `json:"resumed"`         // First sample with traffic on To
+	DurationSeconds float64   `json:"durationSeconds"` // Time without traffic, 0 when To took over before From stopped
+	GapBytes        uint64    `json:"gapBytes"`        // Bytes both interfaces moved in between
+	LostBytes       uint64    `json:"lostBytes"`       // Estimate of the traffic not carried: the rate before the switch over the gap, less GapBytes
+}
+
+// failoverWatch follows which interface of -failover-watch carries the traffic. An interface
+// counts as carrying it at or above the active rate and as stopped below the idle rate, in
+// bytes per second of both directions.
+type failoverWatch struct {
+	primary, backup string
+	idle, active    float64
+	onBackup        bool      // Whether traffic was last seen on the backup
+	lastActive      time.Time // Last sample with traffic on the current interface, zero before any
+	takeover        time.Time // Start of the current run of active samples of the other interface
+	rate            float64   // Average rate of the current interface while active
+	gapBytes        uint64    // Bytes moved since the last active sample of the current interface
+	cycles          []FailoverCycle
+}
+
+// parseFailoverWatch interprets the value of -failover-watch, e.g. "primary=eth0,backup=wwan0".
+func parseFailoverWatch(s string) (primary, backup string, err error) {
+	for _, kv := range strings.Split(s, ",") {
+		k, v, _ := strings.Cut(kv, "=")
+		switch v = strings.TrimSpace(v); strings.TrimSpace(k) {
+		case "primary":
+			primary = v
+		case "backup":
+			backup = v
+		default:
+			return "", "", fmt.Errorf("Invalid failover watch %q, expected primary=interface,backup=interface such as primary=eth0,backup=wwan0", s)
+		}
+	}
+	if primary == "" || backup == "" || primary == backup {
+		return "", "", fmt.Errorf("Failover watch %q must name two different interfaces, such as primary=eth0,backup=wwan0", s)
+	}
+	return primary, backup, nil
+}
+
+func newFailoverWatch(primary, backup string, idle, active float64) *failoverWatch {
+	return &failoverWatch{primary: primary, backup: backup, idle: idle, active: active}
+}
+
+// observe follows the traffic of a tick, given by the byte deltas of the interfaces over
+// interval seconds, and returns the switch the tick completed, if any.
+func (f *failoverWatch) observe(deltas map[string]uint64Pair, interval float64, now time.Time) *FailoverCycle {
+	from, to := f.primary, f.backup
+	if f.onBackup {
+		from, to = to, from
+	}
+	bytesFrom, bytesTo := deltas[from].sent+deltas[from].recv, deltas[to].sent+deltas[to].recv
+	rateFrom, rateTo := float64(bytesFrom)/interval, float64(bytesTo)/interval
+	if rateTo >= f.active {
+		if f.takeover.IsZero() {
+			f.takeover = now
+		}
+	} else {
+		f.takeover = time.Time{}
+	}
+
+	switch {
+	case rateTo >= f.active && rateFrom < f.idle && f.lastActive.IsZero():
+		// Traffic was already on the other interface when watching started.
+		logInfof("Traffic is on %s rather than %s", to, from)
+		f.onBackup = !f.onBackup
+	case rateTo >= f.active && rateFrom < f.idle:
+		c := FailoverCycle{
+			Kind:            eventFailover,
+			From:            from,
+			To:              to,
+			Stopped:         Timestamp(f.lastActive),
+			Resumed:         Timestamp(f.takeover),
+			DurationSeconds: max(0, f.takeover.Sub(f.lastActive).Seconds()),
+			GapBytes:        f.gapBytes,
+		}
+		if f.onBackup {
+			c.Kind = eventFailback
+		}
+		if expected := f.rate * c.DurationSeconds; expected > float64(c.GapBytes) {
+			c.LostBytes = uint64(expected) - c.GapBytes
+		}
+		f.cycles = append(f.cycles, c)
+		f.onBackup = !f.onBackup
+		f.rate, f.gapBytes, f.takeover = rateTo, 0, time.Time{}
+		f.lastActive = now
+		return &c
+	case rateFrom >= f.active:
+		if f.rate == 0 {
+			f.rate = rateFrom
+		}
+		// The exponential average follows a change of load within a few samples, and a
+		// single burst just before the failure does not dominate the loss estimate.
+		f.rate += 0.25 * (rateFrom - f.rate)
+		f.lastActive, f.gapBytes = now, 0
+		return nil
+	}
+	f.gapBytes += bytesFrom + bytesTo
+	return nil
+}
+
+// describe returns the message of the event of a switch.
+func (c *FailoverCycle) describe() string {
+	verb := "failed over"
+	if c.Kind == eventFailback {
+		verb = "failed back"
+	}
+	if c.DurationSeconds == 0 {
+		return fmt.Sprintf("traffic %s from %s to %s without a gap", verb, c.From, c.To)
+	}
+	lost := netstats.CalculateUsage(c.LostBytes, 1)
+	return fmt.Sprintf("traffic %s from %s to %s after %.1fs without traffic (about %g %s not carried)",
+		verb, c.From, c.To, c.DurationSeconds, lost.Value, lost.Unit)
+}
+
+// observeFailover checks the tick for a switch between the -failover-watch interfaces and
+// emits its event.
+func (nm *NetworkMonitor) observeFailover(deltas map[string]uint64Pair, interval float64, now time.Time) {
+	c := nm.failover.observe(deltas, interval, now)
+	if c == nil {
+		return
+	}
+	severity := severityWarning
+	if c.Kind == eventFailback {
+		severity = severityInfo
+	}
+	nm.emitEvent(Event{
+		Type:      c.Kind,
+		Severity:  severity,
+		Timestamp: Timestamp(now),
+		Interface: c.From + "," + c.To,
+		Message:   c.describe(),
+		Details:   c,
+	})
+}
+
+// logFailovers lists the switches of the session when monitoring ends.
+func (f *failoverWatch) logFailovers() {
+	if len(f.cycles) == 0 {
+		logInfof("No failover between %s and %s was seen", f.primary, f.backup)
+		return
+	}
+	logInfof("%d failover cycles between %s and %s:", len(f.cycles), f.primary, f.backup)
+	for i, c := range f.cycles {
+		logInfof("  %d. %s %s → %s: stopped %s, resumed %s, %.1fs without traffic, %d bytes lost",
+			i+1, c.Kind, c.From, c.To, c.Stopped, c.Resumed, c.DurationSeconds, c.LostBytes)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestObserveFailover(t *testing.T) {
+	nm, buf := newTestMonitor(t, "eth0", newFakeSource(), "-f", "json")
+	nm.failover = newFailoverWatch("eth0", "wwan0", 100, 1000)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Bytes moved by eth0 and wwan0 in each one-second tick.
+	ticks := [][2]uint64{
+		{2000, 0},
+		{2000, 0},
+		{0, 50},      // eth0 failed, wwan0 is not up yet
+		{0, 1500},    // wwan0 took over: failover after 2s
+		{0, 1500},    // On the backup
+		{3000, 1500}, // eth0 is back while wwan0 still carries traffic
+		{3000, 0},    // Failback without a gap
+	}
+	for i, tk := range ticks {
+		moved := map[string]uint64Pair{"eth0": {tk[0], 0}, "wwan0": {tk[1], 0}}
+		nm.observeFailover(moved, 1, start.Add(time.Duration(i+1)*time.Second))
+	}
+
+	type details struct {
+		Kind            string  `json:"kind"`
+		From            string  `json:"from"`
+		To              string  `json:"to"`
+		DurationSeconds float64 `json:"durationSeconds"`
+		GapBytes        uint64  `json:"gapBytes"`
+		LostBytes       uint64  `json:"lostBytes"`
+	}
+	want := []struct {
+		severity string
+		details
+	}{
+		// The 2s gap at the 2000 B/s seen before it would have carried 4000 bytes; 50 got through.
+		{severityWarning, details{eventFailover, "eth0", "wwan0", 2, 50, 3950}},
+		{severityInfo, details{eventFailback, "wwan0", "eth0", 0, 0, 0}},
+	}
+	events := decodeEvents(t, buf)
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %s", len(events), len(want), buf)
+	}
+	for i, ev := range events {
+		var d details
+		if err := json.Unmarshal(ev.Details, &d); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Type != want[i].Kind || ev.Severity != want[i].severity || d != want[i].details {
+			t.Errorf("event %d = %s %s %+v, want %s %s %+v", i, ev.Type, ev.Severity, d, want[i].Kind, want[i].severity, want[i].details)
+		}
+	}
+	if len(nm.failover.cycles) != 2 || nm.failover.onBackup {
+		t.Errorf("%d cycles recorded, on backup %v; want 2 and back on the primary", len(nm.failover.cycles), nm.failover.onBackup)
+	}
+}
+
+func TestObserveFailoverStartingOnBackup(t *testing.T) {
+	nm, buf := newTestMonitor(t, "eth0", newFakeSource(), "-f", "json")
+	nm.failover = newFailoverWatch("eth0", "wwan0", 100, 1000)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Traffic already on the backup when watching starts is not a failover.
+	nm.observeFailover(map[string]uint64Pair{"wwan0": {1500, 500}}, 1, start)
+	if !nm.failover.onBackup {
+		t.Error("watch does not follow the traffic on the backup")
+	}
+	if events := decodeEvents(t, buf); len(events) != 0 {
+		t.Errorf("got %d events, want none: %s", len(events), buf)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// flagAliases maps short single-letter aliases to the long flag names they stand for.
+var flagAliases = map[string]string{
+	"i": "interface",
+	"t": "interval",
+	"p": "precision",
+	"f": "format",
+	"c": "count",
+	"d": "duration",
+	"q": "quiet",
+	"o": "output",
+	"b": "bits",
+}
+
+// flagCategories lists the help output categories in display order with the long flags in each.
+// Flags not listed here are shown under "Other".
+var flagCategories = []struct {
+	name  string
+	flags []string
+}{
+	{"General", []string{"profile", "force-unlock", "strict"}},
+	{"Selection", []string{"interface", "print-default", "match-regex", "exclude", "skip-loopback", "group", "group-overlap", "include-loopback", "pair", "failover-watch", "failover-idle", "failover-active", "source", "record-raw"}},
+	{"Sampling", []string{"interval", "count", "duration", "once", "sample-interval", "stagger", "report-interval", "precision", "warmup", "warmup-exclude", "max-errors", "max-plausible-rate", "realtime", "nice", "pin-cpu"}},
+	{"Output", []string{"format", "json-array", "strict-schema", "header", "show-meta", "counters", "counters-only", "self-stats", "softnet", "qdisc", "probe", "plan", "baseline-file", "redact", "redact-map", "time-format", "ts-format", "show-time", "si", "bits", "unit", "live", "warn-speed", "crit-speed", "color-bands-sent", "color-bands-recv", "no-color", "utc", "decimal-comma", "csv-delimiter", "output", "append", "sync", "max-file-size", "max-files", "tee", "tee-file", "summary-json-fd", "exit-summary", "listen", "annotate-fifo", "quiet", "output-queue", "buffer-samples", "buffer-flush", "batch", "batch-max-age", "heartbeat", "hourly-summary", "suppress-zero", "zero-epsilon"}},
+	{"Sinks", []string{"sink", "influx-addr", "tags", "graphite", "graphite-prefix", "statsd", "statsd-tags", "pushgateway", "push-job", "push-grouping", "strict-push", "strict-sinks", "sink-drop-budget", "sink-budget-window"}},
+	{"Alerts", []string{"rate-of-change", "alert", "alert-min-rate", "pair-factor", "pair-sustain", "quiet-hours", "quiet-hours-tz"}},
+	{"Logging", []string{"log-level", "crash-dir", "no-crash-bundle"}},
+}
+
+// deprecatedFlags maps flag spellings scheduled for removal to the replacement users should
+// switch to. Using a deprecated spelling prints a one-time warning but otherwise works as before.
+var deprecatedFlags = map[string]string{}
+
+// longAliases maps alternative long spellings to the flag whose value they share.
+var longAliases = map[string]string{
+	"sample-interval": "interval",
+	"ts-format":       "time-format",
+}
+
+// canonicalFlag returns the long name of a flag given any of its spellings.
+func canonicalFlag(name string) string {
+	if long, ok := flagAliases[name]; ok {
+		return long
+	}
+	if long, ok := longAliases[name]; ok {
+		return long
+	}
+	return name
+}
+
+// shortAlias returns the short alias of a long flag name, or an empty string if it has none.
+func shortAlias(long string) string {
+	for short, l := range flagAliases {
+		if l == long {
+			return short
+		}
+	}
+	return ""
+}
+
+// addFlagAliases registers every short alias in flagAliases that refers to a flag defined in fs.
+// The alias shares the underlying value, so either spelling sets the same option.
+func addFlagAliases(fs *flag.FlagSet) {
+	for short, long := range flagAliases {
+		if f := fs.Lookup(long); f != nil {
+			fs.Var(f.Value, short, f.Usage)
+		}
+	}
+}
+
+// parseFlags parses args into fs and warns once about every deprecated spelling that was used.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if replacement, ok := deprecatedFlags[f.Name]; ok {
+			log.Printf("Warning: -%s is deprecated and will be removed in a future release; use %s instead", f.Name, replacement)
+		}
+	})
+
+	return nil
+}
+
+// printGroupedUsage prints the flags of fs grouped by category, showing each long flag
+// together with its short alias.
+func printGroupedUsage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintf(out, "Usage of %s:\n", fs.Name())
+
+	var listed []string
+	printCategory := func(name string, flags []string) {
+		header := false
+		for _, long := range flags {
+			f := fs.Lookup(long)
+			if f == nil {
+				continue
+			}
+			if !header {
+				fmt.Fprintf(out, "\n%s:\n", name)
+				header = true
+			}
+
+			spelling := "--" + long
+			if short := shortAlias(long); short != "" {
+				spelling = "-" + short + ", " + spelling
+			}
+			typeName, usage := flag.UnquoteUsage(f)
+			if typeName != "" {
+				spelling += " " + typeName
+			}
+			fmt.Fprintf(out, "  %s\n    \t%s", spelling, strings.ReplaceAll(usage, "\n", "\n    \t"))
+			switch {
+			case f.DefValue == "" || f.DefValue == "false" || f.DefValue == "0":
+			case typeName == "string":
+				fmt.Fprintf(out, " (default %q)", f.DefValue)
+			default:
+				fmt.Fprintf(out, " (default %s)", f.DefValue)
+			}
+			fmt.Fprintln(out)
+			listed = append(listed, long)
+		}
+	}
+
+	for _, c := range flagCategories {
+		printCategory(c.name, c.flags)
+	}
+
+	var other []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, alias := flagAliases[f.Name]; !alias && !slices.Contains(listed, f.Name) {
+			other = append(other, f.Name)
+		}
+	})
+	printCategory("Other", other)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestFlagCategoriesCoverEveryFlag(t *testing.T) {
+	category := make(map[string]string)
+	for _, c := range flagCategories {
+		for _, name := range c.flags {
+			if prev, ok := category[name]; ok {
+				t.Errorf("flag %s is listed under both %s and %s", name, prev, c.name)
+			}
+			category[name] = c.name
+		}
+	}
+
+	var cfg monitorConfig
+	fs := newMonitorFlagSet("test", &cfg)
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, ok := flagAliases[f.Name]; ok {
+			return
+		}
+		if _, ok := category[f.Name]; !ok {
+			t.Errorf("flag %s has no help category", f.Name)
+		}
+	})
+	for _, name := range []string{"pushgateway", "strict-sinks", "sink-drop-budget", "graphite", "statsd"} {
+		if category[name] != "Sinks" {
+			t.Errorf("flag %s is under %q, want Sinks", name, category[name])
+		}
+	}
+	for _, name := range []string{"alert", "alert-min-rate", "rate-of-change", "pair-factor", "quiet-hours"} {
+		if category[name] != "Alerts" {
+			t.Errorf("flag %s is under %q, want Alerts", name, category[name])
+		}
+	}
+}
+
+func TestLongAliasesShareTheirFlag(t *testing.T) {
+	var cfg monitorConfig
+	fs := newMonitorFlagSet("test", &cfg)
+	// Every "Same as -x" spelling must fold to x, or profiles would override it.
+	fs.VisitAll(func(f *flag.Flag) {
+		if long, ok := strings.CutPrefix(f.Usage, "Same as -"); ok && canonicalFlag(f.Name) != long {
+			t.Errorf("canonicalFlag(%q) = %q, want %q", f.Name, canonicalFlag(f.Name), long)
+		}
+	})
+	for alias, long := range longAliases {
+		if fs.Lookup(alias) == nil || fs.Lookup(long) == nil {
+			t.Errorf("long alias %s of %s is not a monitor flag", alias, long)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// formatter renders records in one output format. Adding a format means adding an
+// implementation here and its name to outputFormats.
+type formatter interface {
+	// samples renders the samples of one tick. grouped joins them into one record where the
+	// format supports it, as JSON does for -i all.
+	samples(w io.Writer, samples []NetStats, grouped bool)
+	// record renders any other record, such as an event. line renders it as text, for formats
+	// that are not structured.
+	record(w io.Writer, v any, line func(io.Writer))
+	// samplesOnly reports whether the format holds samples only, so other records go to stderr.
+	samplesOnly() bool
+}
+
+// newFormatter returns the formatter of the monitor's output format.
+func newFormatter(nm *NetworkMonitor) formatter {
+	switch nm.format {
+	case "json":
+		return jsonFormatter{countersOnly: nm.countersOnly}
+	case "yaml":
+		return yamlFormatter{countersOnly: nm.countersOnly}
+	case "csv":
+		return csvFormatter{comma: nm.csvDelimiter, precision: nm.precision}
+	case "influx":
+		return influxFormatter{tags: nm.influxTags, precision: nm.precision}
+	case "tui":
+		return tuiFormatter{}
+	default:
+		return tableFormatter{precision: nm.precision, decimalComma: nm.decimalComma, showTime: nm.showTime, countersOnly: nm.countersOnly, live: nm.live, thresholds: nm.thresholds}
+	}
+}
+
+// tableFormatter renders samples as tables and other records as text lines.
+type tableFormatter struct {
+	precision    int
+	decimalComma bool
+	showTime     bool
+	countersOnly bool
+	live         bool             // Whether each tick redraws the previous one at the top of the terminal
+	thresholds   *speedThresholds // Levels at which speed cells are colored, nil for none
+}
+
+// ANSI sequences of live tables: move to the top left, clear to the end of the line, clear to
+// the end of the screen.
+const (
+	ansiHome      = "\x1b[H"
+	ansiClearLine = "\x1b[K"
+	ansiClearDown = "\x1b[J"
+)
+
+func (f tableFormatter) samples(w io.Writer, samples []NetStats, grouped bool) {
+	if f.live {
+		// Each frame is drawn from the top and clears whatever the previous one left, such as
+		// longer lines or lines wrapped before the terminal was resized.
+		var b bytes.Buffer
+		f.live = false
+		f.samples(&b, samples, grouped)
+		io.WriteString(w, ansiHome)
+		for _, line := range strings.SplitAfter(b.String(), "\n") {
+			if line == "" {
+				continue
+			}
+			io.WriteString(w, strings.TrimSuffix(line, "\n")+ansiClearLine+"\n")
+		}
+		io.WriteString(w, ansiClearDown)
+		return
+	}
+	// Counters are only read when monitoring one interface, so only there can they replace
+	// the sample table.
+	if !f.countersOnly || len(samples) != 1 || samples[0].Counters == nil {
+		printTable(w, samples, f.precision, f.decimalComma, f.showTime, f.thresholds)
+	}
+	for _, s := range samples {
+		if s.Counters != nil {
+			printCountersTable(w, s.Interface, s.Counters)
+		}
+	}
+}
+
+func (tableFormatter) record(w io.Writer, _ any, line func(io.Writer)) { line(w) }
+func (tableFormatter) samplesOnly() bool                               { return false }
+
+// tuiFormatter leaves samples to the dashboard, which receives them as a sink, and renders
+// other records as text lines for its status line.
+type tuiFormatter struct{}
+
+func (tuiFormatter) samples(io.Writer, []NetStats, bool)             {}
+func (tuiFormatter) record(w io.Writer, _ any, line func(io.Writer)) { line(w) }
+func (tuiFormatter) samplesOnly() bool                               { return false }
+
+// jsonFormatter renders every record as one JSON line.
+type jsonFormatter struct {
+	countersOnly bool
+}
+
+func (f jsonFormatter) samples(w io.Writer, samples []NetStats, grouped bool) {
+	if grouped {
+		printJSON(w, samples)
+		return
+	}
+	for _, s := range samples {
+		printJSON(w, sampleRecord(s, f.countersOnly))
+	}
+}
+
+func (jsonFormatter) record(w io.Writer, v any, _ func(io.Writer)) { printJSON(w, v) }
+func (jsonFormatter) samplesOnly() bool                            { return false }
+
+// yamlFormatter renders every record, and each tick of -i all, as one YAML document.
+type yamlFormatter struct {
+	countersOnly bool
+}
+
+func (f yamlFormatter) samples(w io.Writer, samples []NetStats, grouped bool) {
+	if grouped {
+		printYAML(w, samples)
+		return
+	}
+	for _, s := range samples {
+		printYAML(w, sampleRecord(s, f.countersOnly))
+	}
+}
+
+func (yamlFormatter) record(w io.Writer, v any, _ func(io.Writer)) { printYAML(w, v) }
+func (yamlFormatter) samplesOnly() bool                            { return false }
+
+// csvFormatter renders samples as CSV rows.
+type csvFormatter struct {
+	comma     rune
+	precision int
+}
+
+func (f csvFormatter) samples(w io.Writer, samples []NetStats, _ bool) {
+	rows := make([][]string, len(samples))
+	for i, s := range samples {
+		rows[i] = csvRow(s, f.precision)
+	}
+	printCSV(w, f.comma, rows...)
+}
+
+func (csvFormatter) record(w io.Writer, _ any, line func(io.Writer)) { line(w) }
+func (csvFormatter) samplesOnly() bool                               { return true }
+
+// sampleRecord returns the structured record of a sample, which is only its counters with
+// -counters-only.
+func sampleRecord(s NetStats, countersOnly bool) any {
+	if countersOnly && s.Counters != nil {
+		return counterRecord{Interface: s.Interface, Counters: *s.Counters, recordID: s.recordID}
+	}
+	return s
+}
+
+// printYAML prints a record as a YAML document to w. The record is marshaled to JSON first, so
+// field names, omitted fields and custom encodings match the JSON output exactly.
+func printYAML(w io.Writer, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logErrorf("Error marshaling to YAML: %v", err)
+		return
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		logErrorf("Error marshaling to YAML: %v", err)
+		return
+	}
+	blockStyle(&doc)
+
+	io.WriteString(w, "---\n")
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	enc.Encode(&doc) // Write errors surface through w
+	enc.Close()
+}
+
+// blockStyle clears the flow and quoting styles that parsing JSON leaves on n and its
+// descendants, so they encode as block YAML. Strings that would read back as another type
+// are still quoted by the encoder.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files of the tests")
+
+// goldenSamples returns the samples of one tick of two interfaces with fixed figures.
+func goldenSamples() []NetStats {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	var units netstats.Units
+	deltas := []netstats.Delta{
+		{Interface: "eth0", Sent: 1536, Recv: 3 << 20, TotalSent: 10 << 20, TotalRecv: 5 << 30, Interval: 1},
+		{Interface: "wlan0", Sent: 0, Recv: 512, TotalSent: 2048, TotalRecv: 4096, Interval: 1},
+	}
+	samples := make([]NetStats, len(deltas))
+	for i, d := range deltas {
+		samples[i] = NetStats{
+			Stats:     units.Stats(d, 2),
+			Timestamp: Timestamp(at),
+			raw:       newRawFigures(d.Sent, d.Recv, d.Interval, d.TotalSent, d.TotalRecv),
+			recordID:  recordID{Seq: uint64(i + 1), SessionID: "0123456789abcdef"},
+		}
+	}
+	return samples
+}
+
+func TestFormatGolden(t *testing.T) {
+	formatters := map[string]formatter{
+		"table":  tableFormatter{precision: 2},
+		"json":   jsonFormatter{},
+		"yaml":   yamlFormatter{},
+		"csv":    csvFormatter{comma: ',', precision: 2},
+		"influx": influxFormatter{tags: ",host=lab", precision: 2},
+	}
+	for name, f := range formatters {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+			f.samples(&b, goldenSamples(), false)
+			checkGolden(t, filepath.Join("testdata", "format", name+".golden"), b.Bytes())
+		})
+	}
+}
+
+// checkGolden compares got with the golden file at path, rewriting it with -update.
+func checkGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s:\n%s\nwant:\n%s", path, got, want)
+	}
+}
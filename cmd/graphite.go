@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	stdnet "net"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Limits of the Graphite sink.
+const (
+	graphiteQueue      = 64              // Ticks of metrics waiting to be sent
+	graphiteTimeout    = 5 * time.Second // Bound on connecting and on each write
+	graphiteMinBackoff = time.Second     // First wait after a failed connection
+	graphiteMaxBackoff = time.Minute     // Longest wait between connection attempts
+)
+
+// graphitePathChars matches the characters sanitized out of interface names in metric paths,
+// including the dots of VLAN interfaces such as eth0.100.
+var graphitePathChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// graphitePrefixPattern is the form of -graphite-prefix.
+var graphitePrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// graphiteSink sends the samples of every tick to a Carbon server in the plaintext protocol.
+// The connection is made lazily by its own goroutine and remade with backoff when it breaks,
+// so the sampling loop never waits on it; metrics that cannot be sent are dropped.
+type graphiteSink struct {
+	addr    string
+	prefix  string
+	fields  sampleFields      // Figures sent of each sample
+	ticks   chan graphiteTick // Rendered metrics of each tick
+	dropped atomic.Int64      // Ticks dropped because the queue was full
+}
+
+// graphiteTick is the rendered metrics of one tick, with the record of their delivery.
+type graphiteTick struct {
+	data []byte
+	d    *sinkStats
+}
+
+// errGraphiteQueueFull is the reason of ticks dropped before they could be queued.
+var errGraphiteQueueFull = errors.New("the send queue was full")
+
+// newGraphiteSink starts a sink sending to addr, such as carbon:2003, under prefix.
+func newGraphiteSink(addr, prefix string) *graphiteSink {
+	g := &graphiteSink{addr: addr, prefix: prefix, fields: allFields, ticks: make(chan graphiteTick, graphiteQueue)}
+	go g.run()
+	return g
+}
+
+// graphitePath returns the metric path component of an interface name.
+func graphitePath(name string) string {
+	return graphitePathChars.ReplaceAllString(name, "_")
+}
+
+// send queues the metrics of one tick's samples without waiting.
+func (g *graphiteSink) send(samples []NetStats, d *sinkStats) {
+	var b bytes.Buffer
+	for _, s := range samples {
+		path := g.prefix + "." + graphitePath(s.Interface) + "."
+		ts := strconv.FormatInt(time.Time(s.Timestamp).Unix(), 10)
+		if g.fields&fieldRates != 0 {
+			fmt.Fprintf(&b, "%ssent_bps %s %s\n", path, strconv.FormatFloat(s.raw.sentBps, 'f', -1, 64), ts)
+			fmt.Fprintf(&b, "%srecv_bps %s %s\n", path, strconv.FormatFloat(s.raw.recvBps, 'f', -1, 64), ts)
+		}
+		if g.fields&fieldTotals != 0 {
+			fmt.Fprintf(&b, "%stotal_sent %d %s\n", path, s.raw.totalSent, ts)
+			fmt.Fprintf(&b, "%stotal_recv %d %s\n", path, s.raw.totalRecv, ts)
+		}
+	}
+	select {
+	case g.ticks <- graphiteTick{b.Bytes(), d}:
+	default:
+		g.dropped.Add(1)
+		d.drop(errGraphiteQueueFull)
+	}
+}
+
+// run sends queued metrics, connecting when needed. A tick whose write fails is sent once
+// more over a new connection before it is dropped.
+func (g *graphiteSink) run() {
+	defer handlePanic()
+	throttle := logThrottle{level: levelWarn}
+	var conn stdnet.Conn
+	var retryAt time.Time
+	backoff := graphiteMinBackoff
+
+	// connect dials the server unless it failed recently, backing off after each failure.
+	connect := func() error {
+		if time.Now().Before(retryAt) {
+			return fmt.Errorf("Graphite at %s is unreachable, next attempt at %s", g.addr, retryAt.Format(time.TimeOnly))
+		}
+		var err error
+		if conn, err = stdnet.DialTimeout("tcp", g.addr, graphiteTimeout); err != nil {
+			throttle.logf("Graphite at %s is unreachable, dropping metrics until it is back: %v", g.addr, err)
+			retryAt = time.Now().Add(backoff)
+			backoff = min(2*backoff, graphiteMaxBackoff)
+			return err
+		}
+		throttle.reset()
+		logInfof("Connected to Graphite at %s", g.addr)
+		backoff = graphiteMinBackoff
+		return nil
+	}
+
+	for tick := range g.ticks {
+		if n := g.dropped.Swap(0); n > 0 {
+			throttle.logf("Graphite at %s is too slow, dropped %d ticks of metrics", g.addr, n)
+		}
+		for attempt := 0; ; attempt++ {
+			if conn == nil {
+				if err := connect(); err != nil {
+					tick.d.drop(err)
+					break
+				}
+			}
+			conn.SetWriteDeadline(time.Now().Add(graphiteTimeout))
+			_, err := conn.Write(tick.data)
+			if err == nil {
+				tick.d.delivered()
+				break
+			}
+			conn.Close()
+			conn = nil
+			if attempt > 0 {
+				throttle.logf("Lost the connection to Graphite at %s again, dropping metrics: %v", g.addr, err)
+				tick.d.drop(err)
+				break
+			}
+			throttle.logf("Lost the connection to Graphite at %s, reconnecting: %v", g.addr, err)
+			tick.d.retry(err)
+		}
+	}
+}
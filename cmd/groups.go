@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// groupNamePattern is the form of -group names.
+var groupNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ifaceGroup is a named set of interfaces, such as wan=ppp0,wwan0, whose traffic is also reported
+// as one sample summing its members.
+type ifaceGroup struct {
+	name      string
+	members   []string
+	totalSent uint64 // Bytes the members sent since monitoring started
+	totalRecv uint64 // Accumulated per tick, so totals survive members coming and going
+}
+
+// groupsValue is a repeatable flag value collecting -group definitions.
+type groupsValue []string
+
+func (v *groupsValue) String() string {
+	return strings.Join(*v, " ")
+}
+
+func (v *groupsValue) Set(s string) error {
+	*v = append(*v, s)
+	return nil
+}
+
+// parseGroups parses -group definitions of the form name=if1,if2. An interface may only belong
+// to several groups when overlap is set.
+func parseGroups(defs []string, overlap bool) ([]*ifaceGroup, error) {
+	var groups []*ifaceGroup
+	owner := make(map[string]string)
+	for _, def := range defs {
+		name, list, ok := strings.Cut(def, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !groupNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("Invalid group %q, expected name=interface,interface such as wan=ppp0,wwan0", def)
+		}
+		if slices.ContainsFunc(groups, func(g *ifaceGroup) bool { return g.name == name }) {
+			return nil, fmt.Errorf("Group %s is defined twice", name)
+		}
+		g := &ifaceGroup{name: name}
+		for _, member := range strings.Split(list, ",") {
+			member = strings.TrimSpace(member)
+			switch {
+			case member == "":
+				return nil, fmt.Errorf("Invalid group %q, expected name=interface,interface such as wan=ppp0,wwan0", def)
+			case slices.Contains(g.members, member):
+				return nil, fmt.Errorf("Interface %s is listed twice in group %s", member, name)
+			case owner[member] != "" && !overlap:
+				return nil, fmt.Errorf("Interface %s belongs to groups %s and %s; pass -group-overlap to allow this", member, owner[member], name)
+			}
+			owner[member] = name
+			g.members = append(g.members, member)
+		}
+		groups = append(groups, g)
+	}
+	for _, g := range groups {
+		if owner[g.name] != "" {
+			return nil, fmt.Errorf("Group %s has the name of an interface", g.name)
+		}
+	}
+	return groups, nil
+}
+
+// groupMembers returns every interface belonging to a group, in order of first appearance.
+func groupMembers(groups []*ifaceGroup) []string {
+	var names []string
+	for _, g := range groups {
+		for _, m := range g.members {
+			if !slices.Contains(names, m) {
+				names = append(names, m)
+			}
+		}
+	}
+	return names
+}
+
+// groupSamples returns one sample per group, summing the byte deltas of the members read this
+// tick, taken over interval seconds. Members that are missing contribute nothing.
+func (nm *NetworkMonitor) groupSamples(deltas map[string]uint64Pair, interval float64, now time.Time) []NetStats {
+	samples := make([]NetStats, 0, len(nm.groups))
+	for _, g := range nm.groups {
+		var sent, recv uint64
+		for _, m := range g.members {
+			sent += deltas[m].sent
+			recv += deltas[m].recv
+		}
+		g.totalSent += sent
+		g.totalRecv += recv
+		samples = append(samples, NetStats{
+			Stats: netstats.Stats{
+				Interface:  g.name,
+				SentSpeed:  nm.units.Speed(sent, interval, nm.precision),
+				RecvSpeed:  nm.units.Speed(recv, interval, nm.precision),
+				TotalSent:  nm.units.Usage(g.totalSent, nm.precision),
+				TotalRecv:  nm.units.Usage(g.totalRecv, nm.precision),
+				TotalUsage: nm.units.Usage(g.totalSent+g.totalRecv, nm.precision),
+			},
+			Timestamp: Timestamp(now),
+			Members:   g.members,
+			raw:       newRawFigures(sent, recv, interval, g.totalSent, g.totalRecv),
+		})
+	}
+	return samples
+}
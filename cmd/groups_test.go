@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroupSamples(t *testing.T) {
+	src := newFakeSource()
+	names := []string{"eth0", "eth1", "wlan0"}
+	for _, name := range names {
+		src.set(name, 0, 0)
+	}
+	nm, buf := newTestMonitor(t, "eth0,eth1,wlan0", src, "-i", "eth0,eth1,wlan0", "-group", "wan=eth0,eth1", "-f", "json")
+	nm.multi = names
+	groups, err := parseGroups([]string{"wan=eth0,eth1"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nm.groups = groups
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each tick eth0 sends 100 bytes and receives 50, eth1 sends 200 and wlan0, outside the
+	// group, sends 50.
+	start := time.Now()
+	var moved uint64
+	for i := 1; i <= 4; i++ {
+		moved += 100
+		src.set("eth0", moved, moved/2)
+		src.set("wlan0", moved/2, 0)
+		switch i {
+		case 2:
+			src.remove("eth1") // A missing member contributes nothing
+		default:
+			src.set("eth1", 2*moved, 0) // Back at tick 3, it starts a new baseline
+		}
+		tick(t, nm, start.Add(time.Duration(i)*time.Second))
+	}
+
+	var got []jsonSample
+	for _, s := range decodeSamples(t, buf) {
+		if s.Interface == "wan" {
+			got = append(got, s)
+		}
+	}
+	want := []struct{ sent, recv, totalSent float64 }{
+		{300, 50, 300},
+		{100, 50, 400},
+		{100, 50, 500},
+		{300, 50, 800}, // Totals carry on across the member coming and going
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d group samples, want %d: %s", len(got), len(want), buf)
+	}
+	for i, w := range want {
+		if s := got[i]; s.SentSpeed.Value != w.sent || s.RecvSpeed.Value != w.recv || s.TotalSent.Value != w.totalSent {
+			t.Errorf("tick %d: wan sent %v recv %v total %v, want %v, %v and %v",
+				i+1, s.SentSpeed.Value, s.RecvSpeed.Value, s.TotalSent.Value, w.sent, w.recv, w.totalSent)
+		}
+	}
+}
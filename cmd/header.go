@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/host"
+)
+
+// recordHeader is the type of the stream header record.
+const recordHeader = "header"
+
+// Header describes the context of a record stream: who produced it, where, and with which
+// settings. It is the first record of a session, and its session ID joins later records to it.
+type Header struct {
+	Type          string    `json:"type"`
+	Timestamp     Timestamp `json:"timestamp"`
+	Hostname      string    `json:"hostname"`
+	OS            string    `json:"os"`
+	Kernel        string    `json:"kernel,omitempty"`
+	Version       string    `json:"version"`       // Release tag of the monitor
+	Interfaces    []string  `json:"interfaces"`    // Monitored interfaces
+	ConfigDigest  string    `json:"configDigest"`  // SHA-256 of the effective configuration
+	Schema        string    `json:"schema"`        // Shape of sample records: sample, counters-only, report, pair or multi
+	SchemaVersion string    `json:"schemaVersion"` // Version of the record schema, see migrate
+	Units         string    `json:"units"`         // Unit system of scaled values
+	recordID
+}
+
+// configDigest returns a digest of the effective configuration, so streams produced with the same
+// settings can be recognized without comparing every flag.
+func configDigest(cfg monitorConfig) string {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// headerRecord returns the stream header record, numbered as the next record and scrubbed
+// with -redact.
+func (nm *NetworkMonitor) headerRecord() Header {
+	h := Header{
+		Type:          recordHeader,
+		Timestamp:     Timestamp(time.Now()),
+		OS:            runtime.GOOS + "/" + runtime.GOARCH,
+		Version:       version,
+		Interfaces:    slices.Clone(nm.monitoredInterfaces()),
+		ConfigDigest:  nm.configDigest,
+		Schema:        "sample",
+		SchemaVersion: schemaVersion,
+		Units:         "binary", // Multiples of 1024: KiB, MiB, GiB
+		recordID:      nm.nextID(),
+	}
+	if nm.units.SI {
+		h.Units = "si" // Multiples of 1000: KB, MB, GB
+	}
+	if nm.units.Bits {
+		h.Units = "bits" // Speeds in multiples of 1000 bits per second, totals as for binary
+		if nm.units.SI {
+			h.Units = "bits-si" // The same, with totals as for si
+		}
+	}
+	switch {
+	case nm.pair[0] != "":
+		h.Schema = "pair"
+	case len(nm.multi) > 0 || nm.allInterfaces:
+		h.Schema = "multi"
+	case nm.countersOnly:
+		h.Schema = "counters-only"
+	case nm.report != nil:
+		h.Schema = "report"
+	}
+
+	var err error
+	if h.Hostname, err = os.Hostname(); err != nil {
+		logWarnf("Error reading hostname: %v", err)
+	}
+	if h.Kernel, err = host.KernelVersion(); err != nil {
+		logDebugf("Error reading kernel version: %v", err)
+	}
+	if nm.redact != nil {
+		h.Hostname = redactedText
+		for i, name := range h.Interfaces {
+			h.Interfaces[i] = nm.redact.pseudonym(name)
+		}
+	}
+	return h
+}
+
+// emitHeader writes the stream header record. CSV output carries it in writeCSVHeader instead.
+func (nm *NetworkMonitor) emitHeader() {
+	h := nm.headerRecord()
+	err := nm.writeRecord(h, func(w io.Writer) {
+		fmt.Fprintf(w, "[%s] %s: session %s on %s (%s %s), %s %s, interfaces %s, config %s\n",
+			h.Timestamp, h.Type, h.SessionID, h.Hostname, h.OS, h.Kernel, progName(), h.Version,
+			strings.Join(h.Interfaces, ", "), h.ConfigDigest)
+	})
+	if err != nil {
+		logErrorf("Error writing output: %v", err)
+	}
+}
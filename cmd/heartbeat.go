@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// recordHeartbeat is the type of heartbeat records.
+const recordHeartbeat = "heartbeat"
+
+// Heartbeat is a proof-of-life record emitted while no samples are being written, so
+// downstream systems can tell a quiet link from a dead monitor. It never affects totals.
+type Heartbeat struct {
+	Type          string    `json:"type"`
+	Timestamp     Timestamp `json:"timestamp"`
+	Interface     string    `json:"interface"`
+	UptimeSeconds float64   `json:"uptimeSeconds"`
+	LastSampleSeq uint64    `json:"lastSampleSeq"` // Sequence number of the last emitted sample, 0 if none
+	recordID
+}
+
+// checkHeartbeat emits a heartbeat when no sample has been emitted for a full heartbeat period.
+func (nm *NetworkMonitor) checkHeartbeat(now time.Time) {
+	if now.Sub(nm.lastSampleAt) < nm.heartbeat {
+		return
+	}
+
+	hb := Heartbeat{
+		Type:          recordHeartbeat,
+		Timestamp:     Timestamp(now),
+		Interface:     nm.interfaceName,
+		UptimeSeconds: netstats.Round(now.Sub(nm.session.start).Seconds(), nm.precision),
+		LastSampleSeq: nm.lastSampleSeq,
+		recordID:      nm.nextID(),
+	}
+	err := nm.writeRecord(hb, func(w io.Writer) {
+		fmt.Fprintf(w, "[%s] %s %s: up %v, last sample #%d\n",
+			hb.Timestamp, hb.Interface, hb.Type, now.Sub(nm.session.start).Round(time.Second), hb.LastSampleSeq)
+	})
+	if err != nil {
+		logErrorf("Error writing output: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	src := newFakeSource()
+	src.set("eth0", 1000, 2000)
+	nm, buf := newTestMonitor(t, "eth0", src, "-f", "json", "-heartbeat", "5s", "-suppress-zero")
+	clk := &fakeClock{mono: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	nm.clock = clk
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each second is one tick; the heartbeat timer is checked at the seconds listed.
+	checks := map[int]bool{3: true, 5: true, 8: true, 10: true, 12: true}
+	for sec := 1; sec <= 12; sec++ {
+		clk.advance(time.Second)
+		if sec == 7 {
+			src.set("eth0", 9000, 2000) // The only traffic, which ends the silence
+		}
+		tick(t, nm, clk.now())
+		if checks[sec] {
+			nm.checkHeartbeat(clk.now())
+		}
+	}
+	if sent, recv := nm.sampler.Totals(); sent != 8000 || recv != 0 {
+		t.Errorf("totals = %d/%d, want 8000/0: heartbeats must not count", sent, recv)
+	}
+
+	type record struct {
+		Type          string  `json:"type"`
+		UptimeSeconds float64 `json:"uptimeSeconds"`
+		LastSampleSeq uint64  `json:"lastSampleSeq"`
+		Seq           uint64  `json:"seq"`
+	}
+	var got []record
+	sc := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for sc.Scan() {
+		var r record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", sc.Text(), err)
+		}
+		if r.Type == "" || r.Type == recordHeartbeat {
+			got = append(got, r)
+		}
+	}
+
+	// Heartbeats come once no sample was emitted for 5s: at 5s before any sample, and at 12s,
+	// 5s after the sample of 7s, naming it as the last one.
+	if len(got) != 3 {
+		t.Fatalf("got %d samples and heartbeats, want 3: %s", len(got), buf)
+	}
+	sample := got[1]
+	if sample.Type != "" || sample.Seq == 0 {
+		t.Fatalf("record 1 = %+v, want the sample of 7s", sample)
+	}
+	want := []record{
+		{Type: recordHeartbeat, UptimeSeconds: 5},
+		{Type: recordHeartbeat, UptimeSeconds: 12, LastSampleSeq: sample.Seq},
+	}
+	for i, g := range []record{got[0], got[2]} {
+		if w := want[i]; g.Type != w.Type || g.UptimeSeconds != w.UptimeSeconds || g.LastSampleSeq != w.LastSampleSeq {
+			t.Errorf("heartbeat %d = up %vs, last sample %d; want up %vs, last sample %d",
+				i, g.UptimeSeconds, g.LastSampleSeq, w.UptimeSeconds, w.LastSampleSeq)
+		}
+	}
+}
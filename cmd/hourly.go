@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// recordHourlySummary is the type of hourly summary records.
+const recordHourlySummary = "hourly-summary"
+
+// HourlySummary covers one wall-clock hour of monitoring, for correlation with runbooks that
+// work in hourly buckets. The first and last hours of a run are usually partial.
+type HourlySummary struct {
+	Type                   string    `json:"type"`
+	Interface              string    `json:"interface"`
+	Start                  Timestamp `json:"start"`
+	End                    Timestamp `json:"end"`
+	Partial                bool      `json:"partial"` // Whether monitoring did not cover the whole hour
+	SentBytes              uint64    `json:"sentBytes"`
+	RecvBytes              uint64    `json:"recvBytes"`
+	AvgSentBytesPerSecond  float64   `json:"avgSentBytesPerSecond"`
+	AvgRecvBytesPerSecond  float64   `json:"avgRecvBytesPerSecond"`
+	PeakSentBytesPerSecond float64   `json:"peakSentBytesPerSecond"`
+	PeakRecvBytesPerSecond float64   `json:"peakRecvBytesPerSecond"`
+	InterfaceErrors        *uint64   `json:"interfaceErrors,omitempty"` // Kernel errin plus errout during the hour, nil if the platform does not count them
+	InterfaceDrops         *uint64   `json:"interfaceDrops,omitempty"`  // Kernel dropin plus dropout during the hour, nil likewise
+	LinkEvents             int       `json:"linkEvents"`                // Config, address and route change events
+	recordID
+}
+
+// hourlyTracker accumulates the figures of the current hour.
+type hourlyTracker struct {
+	start    time.Time // Start of the covered period
+	partial  bool      // Whether the period started after the top of the hour
+	sent     uint64
+	recv     uint64
+	peakSent float64
+	peakRecv float64
+	errors   uint64
+	drops    uint64
+	events   int
+}
+
+// nextHour returns the start of the wall-clock hour following t, in t's location.
+func nextHour(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+}
+
+// newHourlyTracker starts tracking at t.
+func newHourlyTracker(t time.Time) *hourlyTracker {
+	return &hourlyTracker{start: t, partial: !t.Equal(nextHour(t).Add(-time.Hour))}
+}
+
+// add records one sample: its byte deltas over interval seconds and the growth of the kernel
+// error and drop counters.
+func (h *hourlyTracker) add(sent, recv, errors, drops uint64, interval float64) {
+	h.sent += sent
+	h.recv += recv
+	h.peakSent = max(h.peakSent, float64(sent)/interval)
+	h.peakRecv = max(h.peakRecv, float64(recv)/interval)
+	h.errors += errors
+	h.drops += drops
+}
+
+// emitHourlySummary writes the summary of the period ending at end and starts a new one.
+// partial marks a period cut short by shutdown.
+func (nm *NetworkMonitor) emitHourlySummary(end time.Time, partial bool) error {
+	h := nm.hourly
+	s := HourlySummary{
+		Type:                   recordHourlySummary,
+		Interface:              nm.interfaceName,
+		Start:                  Timestamp(h.start),
+		End:                    Timestamp(end),
+		Partial:                h.partial || partial,
+		SentBytes:              h.sent,
+		RecvBytes:              h.recv,
+		PeakSentBytesPerSecond: netstats.Round(h.peakSent, nm.precision),
+		PeakRecvBytesPerSecond: netstats.Round(h.peakRecv, nm.precision),
+		LinkEvents:             h.events,
+		recordID:               nm.nextID(),
+	}
+	if nm.capabilities.available(counterGroupErrors) {
+		errors, drops := h.errors, h.drops
+		s.InterfaceErrors, s.InterfaceDrops = &errors, &drops
+	}
+	if d := end.Sub(h.start).Seconds(); d > 0 {
+		s.AvgSentBytesPerSecond = netstats.Round(float64(h.sent)/d, nm.precision)
+		s.AvgRecvBytesPerSecond = netstats.Round(float64(h.recv)/d, nm.precision)
+	}
+	if nm.redact != nil {
+		s.SentBytes = uint64(sigFigs(float64(s.SentBytes), 2))
+		s.RecvBytes = uint64(sigFigs(float64(s.RecvBytes), 2))
+	}
+	*h = hourlyTracker{start: end}
+
+	return nm.writeRecord(s, func(w io.Writer) {
+		quantity := func(value float64, unit string) string {
+			return formatQuantity(value, unit, nm.precision, nm.decimalComma)
+		}
+		sent, recv := nm.units.Usage(s.SentBytes, nm.precision), nm.units.Usage(s.RecvBytes, nm.precision)
+		peakSent := nm.units.Speed(uint64(s.PeakSentBytesPerSecond), 1, nm.precision)
+		peakRecv := nm.units.Speed(uint64(s.PeakRecvBytesPerSecond), 1, nm.precision)
+		partial := ""
+		if s.Partial {
+			partial = " (partial)"
+		}
+		faults := "errors and drops not counted"
+		if s.InterfaceErrors != nil {
+			faults = fmt.Sprintf("%d errors, %d drops", *s.InterfaceErrors, *s.InterfaceDrops)
+		}
+		fmt.Fprintf(w, "[%s] %s %s: %s to %s%s, sent %s (peak %s), received %s (peak %s), %s, %d link events\n",
+			s.End, s.Interface, s.Type, s.Start, s.End, partial,
+			quantity(sent.Value, sent.Unit), quantity(peakSent.Value, peakSent.Unit),
+			quantity(recv.Value, recv.Unit), quantity(peakRecv.Value, peakRecv.Unit),
+			faults, s.LinkEvents)
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestHourlySummary(t *testing.T) {
+	src := newFakeSource()
+	src.set("eth0", 0, 0)
+	nm, buf := newTestMonitor(t, "eth0", src, "-f", "json", "-hourly-summary")
+	clk := &fakeClock{mono: time.Date(2024, 5, 1, 12, 20, 0, 0, time.UTC)}
+	nm.clock = clk
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+	nm.hourly = newHourlyTracker(nm.session.start) // Monitoring starts 20 minutes into the hour
+
+	var sent, recv uint64
+	move := func(s, r uint64) {
+		clk.advance(time.Second)
+		sent, recv = sent+s, recv+r
+		src.set("eth0", sent, recv)
+		tick(t, nm, clk.now())
+	}
+	move(1200, 600)
+	move(2400, 0)
+	nm.emitEvent(Event{Type: eventConfigChange, Timestamp: Timestamp(clk.now()), Interface: "eth0"}) // A link event
+	nm.emitEvent(Event{Type: eventAnnotation, Timestamp: Timestamp(clk.now()), Interface: "eth0"})   // Not one
+	if err := nm.emitHourlySummary(time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC), false); err != nil {
+		t.Fatal(err)
+	}
+	move(600, 3000)
+	if err := nm.emitHourlySummary(time.Date(2024, 5, 1, 13, 30, 0, 0, time.UTC), true); err != nil { // Shutdown
+		t.Fatal(err)
+	}
+
+	type hourly struct {
+		Start                  string  `json:"start"`
+		End                    string  `json:"end"`
+		Partial                bool    `json:"partial"`
+		SentBytes              uint64  `json:"sentBytes"`
+		RecvBytes              uint64  `json:"recvBytes"`
+		AvgSentBytesPerSecond  float64 `json:"avgSentBytesPerSecond"`
+		AvgRecvBytesPerSecond  float64 `json:"avgRecvBytesPerSecond"`
+		PeakSentBytesPerSecond float64 `json:"peakSentBytesPerSecond"`
+		PeakRecvBytesPerSecond float64 `json:"peakRecvBytesPerSecond"`
+		LinkEvents             int     `json:"linkEvents"`
+	}
+	var got []hourly
+	sc := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for sc.Scan() {
+		var r struct {
+			Type string `json:"type"`
+			hourly
+		}
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", sc.Text(), err)
+		}
+		if r.Type == recordHourlySummary {
+			got = append(got, r.hourly)
+		}
+	}
+
+	want := []hourly{
+		// 40 minutes from the start of monitoring to the top of the hour.
+		{"2024-05-01T12:20:00Z", "2024-05-01T13:00:00Z", true, 3600, 600, 1.5, 0.25, 2400, 600, 1},
+		// 30 minutes from the top of the hour to shutdown.
+		{"2024-05-01T13:00:00Z", "2024-05-01T13:30:00Z", true, 600, 3000, 0.33, 1.67, 600, 3000, 0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d hourly summaries, want %d: %s", len(got), len(want), buf)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("hourly summary %d = %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestNewHourlyTrackerPartial(t *testing.T) {
+	for _, tt := range []struct {
+		start   time.Time
+		partial bool
+	}{
+		{time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC), false},
+		{time.Date(2024, 5, 1, 13, 0, 1, 0, time.UTC), true},
+		{time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC), true},
+	} {
+		if h := newHourlyTracker(tt.start); h.partial != tt.partial {
+			t.Errorf("tracker starting %v partial = %v, want %v", tt.start, h.partial, tt.partial)
+		}
+	}
+	if got, want := nextHour(time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)), time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("nextHour = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	stdnet "net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// influxMeasurement is the measurement name of samples in the InfluxDB line protocol.
+const influxMeasurement = "netstats"
+
+// influxDialTimeout bounds connecting to the -influx-addr target at startup.
+const influxDialTimeout = 5 * time.Second
+
+// influxEscaper escapes tag keys and values for the InfluxDB line protocol.
+var influxEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+
+// parseInfluxTags parses -tags, such as "env=prod,host=web1", into the escaped tag set
+// appended to every line, including its leading comma.
+func parseInfluxTags(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	var b strings.Builder
+	for _, part := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || key == "" || value == "" {
+			return "", fmt.Errorf("Invalid tag %q, expected key=value such as env=prod", part)
+		}
+		if key == "interface" {
+			return "", fmt.Errorf("Tag %q is set by the monitor", key)
+		}
+		b.WriteString("," + influxEscaper.Replace(key) + "=" + influxEscaper.Replace(value))
+	}
+	return b.String(), nil
+}
+
+// parseInfluxAddr validates an -influx-addr target such as udp://127.0.0.1:8094.
+func parseInfluxAddr(s string) (network, addr string, err error) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Port() == "" {
+		return "", "", fmt.Errorf("Invalid InfluxDB target %q, expected udp://host:port or tcp://host:port", s)
+	}
+	return u.Scheme, u.Host, nil
+}
+
+// dialInflux connects to the -influx-addr target. Each line is written in one call, so over
+// UDP every sample is one datagram.
+func dialInflux(s string) (stdnet.Conn, error) {
+	network, addr, err := parseInfluxAddr(s)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := stdnet.DialTimeout(network, addr, influxDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to %s: %v", s, err)
+	}
+	return conn, nil
+}
+
+// influxFormatter renders samples in the InfluxDB line protocol, such as
+// netstats,interface=eth0 sent_bps=1234.5,recv_bps=678.9,total_sent=123456i,total_recv=654321i 1700000000000000000
+type influxFormatter struct {
+	tags      string // Escaped static tags, with a leading comma
+	precision int
+}
+
+func (f influxFormatter) samples(w io.Writer, samples []NetStats, _ bool) {
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s,interface=%s%s sent_bps=%s,recv_bps=%s,total_sent=%di,total_recv=%di %d\n",
+			influxMeasurement, influxEscaper.Replace(s.Interface), f.tags,
+			strconv.FormatFloat(s.raw.sentBps, 'f', f.precision, 64),
+			strconv.FormatFloat(s.raw.recvBps, 'f', f.precision, 64),
+			s.raw.totalSent, s.raw.totalRecv, time.Time(s.Timestamp).UnixNano())
+	}
+}
+
+func (influxFormatter) record(w io.Writer, _ any, line func(io.Writer)) { line(w) }
+func (influxFormatter) samplesOnly() bool                               { return true }
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Limits of the webhook sink.
+const (
+	webhookQueue        = 64              // Deliveries waiting to be posted
+	webhookTimeout      = 5 * time.Second // Bound on each request
+	webhookCloseTimeout = 5 * time.Second // Bound on posting what is still queued at exit
+)
+
+// rateKeys and totalKeys are the JSON fields of a sample left out without fieldRates and
+// fieldTotals respectively.
+var (
+	rateKeys  = []string{"sentSpeed", "recvSpeed", "sentMin", "sentMax", "recvMin", "recvMax"}
+	totalKeys = []string{"totalSent", "totalRecv", "totalUsage"}
+)
+
+// sampleJSON returns the JSON record of a sample holding only the figures of fields.
+func sampleJSON(s NetStats, fields sampleFields) ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil || fields == allFields {
+		return data, err
+	}
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	if fields&fieldRates == 0 {
+		for _, k := range rateKeys {
+			delete(record, k)
+		}
+	}
+	if fields&fieldTotals == 0 {
+		for _, k := range totalKeys {
+			delete(record, k)
+		}
+	}
+	return json.Marshal(record)
+}
+
+// fileSink appends the records it receives to a file as JSON lines, whatever the output
+// format. Writes are local and not queued.
+type fileSink struct {
+	w      io.Writer
+	fields sampleFields
+}
+
+// send writes one line per sample of the tick.
+func (f *fileSink) send(samples []NetStats, d *sinkStats) {
+	var b bytes.Buffer
+	for _, s := range samples {
+		data, err := sampleJSON(s, f.fields)
+		if err != nil {
+			d.drop(err)
+			return
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+	f.write(b.Bytes(), d)
+}
+
+// sendRecord writes one line holding the record.
+func (f *fileSink) sendRecord(data []byte, d *sinkStats) {
+	f.write(append(data, '\n'), d)
+}
+
+func (f *fileSink) write(data []byte, d *sinkStats) {
+	if _, err := f.w.Write(data); err != nil {
+		d.drop(err)
+		return
+	}
+	d.delivered()
+}
+
+// webhookSink posts the records it receives as JSON: the samples of a tick as one array, and
+// every other record on its own. Requests are made by a goroutine of its own, so the sampling
+// loop never waits on the server; deliveries that find the queue full are dropped.
+type webhookSink struct {
+	url     string
+	display string // URL without credentials, for log lines
+	fields  sampleFields
+	client  *http.Client
+	queue   chan webhookDelivery
+	done    chan struct{} // Closed when everything queued has been posted
+	dropped atomic.Int64  // Deliveries dropped because the queue was full
+}
+
+// webhookDelivery is one request body, with the record of its delivery.
+type webhookDelivery struct {
+	body []byte
+	d    *sinkStats
+}
+
+// errWebhookQueueFull is the reason of deliveries dropped before they could be queued.
+var errWebhookQueueFull = errors.New("the send queue was full")
+
+// newWebhookSink starts a sink posting to rawURL.
+func newWebhookSink(rawURL string, fields sampleFields) *webhookSink {
+	w := &webhookSink{
+		url:     rawURL,
+		display: redactedURL(rawURL),
+		fields:  fields,
+		client:  &http.Client{Timeout: webhookTimeout},
+		queue:   make(chan webhookDelivery, webhookQueue),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// send queues the samples of one tick as a JSON array.
+func (w *webhookSink) send(samples []NetStats, d *sinkStats) {
+	records := make([]json.RawMessage, 0, len(samples))
+	for _, s := range samples {
+		data, err := sampleJSON(s, w.fields)
+		if err != nil {
+			d.drop(err)
+			return
+		}
+		records = append(records, data)
+	}
+	body, err := json.Marshal(records)
+	if err != nil {
+		d.drop(err)
+		return
+	}
+	w.enqueue(body, d)
+}
+
+// sendRecord queues one record.
+func (w *webhookSink) sendRecord(data []byte, d *sinkStats) {
+	w.enqueue(data, d)
+}
+
+func (w *webhookSink) enqueue(body []byte, d *sinkStats) {
+	select {
+	case w.queue <- webhookDelivery{body, d}:
+	default:
+		w.dropped.Add(1)
+		d.drop(errWebhookQueueFull)
+	}
+}
+
+// run posts queued deliveries. A delivery fails on a network error or a status other than
+// 2xx, and is dropped without retrying.
+func (w *webhookSink) run() {
+	defer handlePanic()
+	defer close(w.done)
+	throttle := logThrottle{level: levelWarn}
+	for delivery := range w.queue {
+		if n := w.dropped.Swap(0); n > 0 {
+			throttle.logf("Webhook %s is too slow, dropped %d deliveries", w.display, n)
+		}
+		if err := w.post(delivery.body); err != nil {
+			throttle.logf("Webhook %s failed, dropping the delivery: %v", w.display, err)
+			delivery.d.drop(err)
+			continue
+		}
+		throttle.reset()
+		delivery.d.delivered()
+	}
+}
+
+func (w *webhookSink) post(body []byte) error {
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+// secretParamWords mark query parameters whose values redactedURL masks, such as token,
+// api_key or X-Amz-Signature.
+var secretParamWords = []string{"token", "secret", "key", "pass", "auth", "sig", "credential"}
+
+// redactedURL returns rawURL with its userinfo and the values of secret-looking query
+// parameters masked, for logs, the sink records and shared output such as config print. The
+// whole userinfo is masked, as the user name alone is often a token.
+func redactedURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if u.User != nil {
+		u.User = url.User("xxxxx")
+	}
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		name, _, _ := strings.Cut(param, "=")
+		if lower := strings.ToLower(name); slices.ContainsFunc(secretParamWords, func(w string) bool { return strings.Contains(lower, w) }) {
+			params[i] = name + "=xxxxx"
+		}
+	}
+	u.RawQuery = strings.Join(params, "&")
+	return u.String()
+}
+
+// close posts what is still queued, waiting at most webhookCloseTimeout.
+func (w *webhookSink) close() {
+	close(w.queue)
+	select {
+	case <-w.done:
+	case <-time.After(webhookCloseTimeout):
+		logWarnf("Webhook %s did not take the last deliveries in time", w.display)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/shirou/gopsutil/v4/net"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// InterfaceInfo describes a network interface as printed by the list subcommand.
+type InterfaceInfo struct {
+	SchemaVersion string   `json:"schemaVersion"` // Version of this object's schema, see listSchemaVersion
+	Name          string   `json:"name"`
+	Index         int      `json:"index"`
+	State         string   `json:"state"` // up or down
+	MTU           int      `json:"mtu"`
+	MAC           string   `json:"mac"`
+	Addresses     []string `json:"addresses"`
+	BytesSent     uint64   `json:"bytesSent"` // Cumulative since boot, or since the counters were reset
+	BytesRecv     uint64   `json:"bytesRecv"`
+}
+
+// runList implements the list subcommand, printing every network interface with its index,
+// state, MTU, hardware address, addresses and byte counters. With --names it prints just the
+// names, one per line, as shell completion does.
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	format := fs.String("format", "table", "Output format: json or table")
+	names := fs.Bool("names", false, "Print only the interface names, one per line")
+	addFlagAliases(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if !slices.Contains(textFormats, *format) {
+		return fmt.Errorf("Invalid output format: %s", *format)
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return fmt.Errorf("error listing interfaces: %v", err)
+	}
+	if *names {
+		for _, iface := range ifaces {
+			fmt.Println(iface.Name)
+		}
+		return nil
+	}
+
+	counters := make(map[string]net.IOCountersStat)
+	if stats, err := net.IOCounters(true); err == nil {
+		for _, s := range stats {
+			counters[s.Name] = s
+		}
+	} else {
+		logWarnf("Error reading interface counters: %v", err)
+	}
+
+	infos := interfaceInfos(ifaces, counters)
+	if *format == "json" {
+		printJSON(os.Stdout, infos)
+		return nil
+	}
+	printInterfaceTable(infos)
+	return nil
+}
+
+// interfaceInfos describes the interfaces with their byte counters, in the order given.
+func interfaceInfos(ifaces []net.InterfaceStat, counters map[string]net.IOCountersStat) []InterfaceInfo {
+	infos := make([]InterfaceInfo, 0, len(ifaces))
+	for _, iface := range ifaces {
+		info := InterfaceInfo{
+			SchemaVersion: listSchemaVersion,
+			Name:          iface.Name,
+			Index:         iface.Index,
+			State:         "down",
+			MTU:           iface.MTU,
+			MAC:           iface.HardwareAddr,
+			Addresses:     []string{},
+			BytesSent:     counters[iface.Name].BytesSent,
+			BytesRecv:     counters[iface.Name].BytesRecv,
+		}
+		if slices.Contains(iface.Flags, "up") {
+			info.State = "up"
+		}
+		for _, addr := range iface.Addrs {
+			info.Addresses = append(info.Addresses, addr.Addr)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// printInterfaceTable prints the interfaces as a table, with byte counters in scaled units.
+func printInterfaceTable(infos []InterfaceInfo) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Index", "Interface", "State", "MTU", "MAC", "Addresses", "Total Sent", "Total Recv"})
+	for _, info := range infos {
+		sent := netstats.CalculateUsage(info.BytesSent, 2)
+		recv := netstats.CalculateUsage(info.BytesRecv, 2)
+		table.Append([]string{
+			strconv.Itoa(info.Index),
+			info.Name,
+			info.State,
+			strconv.Itoa(info.MTU),
+			info.MAC,
+			strings.Join(info.Addresses, "\n"),
+			formatQuantity(sent.Value, sent.Unit, 2, false),
+			formatQuantity(recv.Value, recv.Unit, 2, false),
+		})
+	}
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(true)
+	table.SetRowLine(true)
+	table.Render()
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// TestListJSONGolden pins the list -f json schema, so a renamed field fails here as well as
+// in selftest.
+func TestListJSONGolden(t *testing.T) {
+	ifaces := []net.InterfaceStat{
+		{Index: 1, Name: "lo", MTU: 65536, Flags: []string{"up", "loopback"}, Addrs: net.InterfaceAddrList{{Addr: "127.0.0.1/8"}, {Addr: "::1/128"}}},
+		{Index: 2, Name: "eth0", MTU: 1500, HardwareAddr: "aa:bb:cc:dd:ee:ff", Flags: []string{"up", "broadcast", "multicast"}, Addrs: net.InterfaceAddrList{{Addr: "192.0.2.10/24"}}},
+		{Index: 3, Name: "wlan0", MTU: 1500, HardwareAddr: "aa:bb:cc:dd:ee:00", Flags: []string{"broadcast", "multicast"}},
+	}
+	counters := map[string]net.IOCountersStat{
+		"lo":   {Name: "lo", BytesSent: 4096, BytesRecv: 4096},
+		"eth0": {Name: "eth0", BytesSent: 10 << 20, BytesRecv: 5 << 30},
+	}
+
+	var b bytes.Buffer
+	printJSON(&b, interfaceInfos(ifaces, counters))
+	checkGolden(t, filepath.Join("testdata", "list", "list.golden"), b.Bytes())
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// errLockHeld is returned by tryLockFile when another process holds the lock.
+var errLockHeld = errors.New("lock is held by another process")
+
+// instanceLock is an advisory lock guarding a state file against a second monitor instance.
+// The lock file next to the state file records the PID of its holder.
+type instanceLock struct {
+	f    *os.File
+	path string
+}
+
+// acquireLock locks the state file at path for this process. The operating system releases the
+// lock when its holder exits, even on a crash. If the lock is taken by a PID that does not exist
+// here, as when instances in different PID namespaces share a volume or the lock file lives on
+// a network file system, force replaces the lock file.
+func acquireLock(path string, force bool) (*instanceLock, error) {
+	lockPath := path + ".lock"
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		err = tryLockFile(f)
+		if err == nil {
+			if err := writeLockPID(f); err != nil {
+				f.Close()
+				return nil, err
+			}
+			return &instanceLock{f: f, path: lockPath}, nil
+		}
+		if !errors.Is(err, errLockHeld) {
+			f.Close()
+			return nil, fmt.Errorf("error locking %s: %v", lockPath, err)
+		}
+
+		pid := readLockPID(f)
+		f.Close()
+		alive := pid <= 0 || processAlive(pid)
+		switch {
+		case alive:
+			return nil, fmt.Errorf("%s is in use by another instance (PID %d); stop it or use a different state file", path, pid)
+		case !force:
+			return nil, fmt.Errorf("%s is locked by PID %d, which no longer exists; if no other instance uses the file, rerun with -force-unlock", path, pid)
+		case attempt > 0:
+			return nil, fmt.Errorf("error breaking the stale lock %s", lockPath)
+		}
+		logWarnf("Breaking the stale lock of PID %d on %s", pid, path)
+		if err := os.Remove(lockPath); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// writeLockPID records this process as the holder of the locked file f.
+func writeLockPID(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
+
+// readLockPID returns the holder PID recorded in f, or 0 if there is none.
+func readLockPID(f *os.File) int {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 32))
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// release unlocks the state file. The lock file itself stays, so no other instance can race
+// with its removal.
+func (l *instanceLock) release() {
+	if l != nil {
+		l.f.Close()
+	}
+}
+
+// lockStateFiles locks every state file the configuration persists to.
+func lockStateFiles(cfg *monitorConfig) ([]*instanceLock, error) {
+	var locks []*instanceLock
+	for _, path := range []string{cfg.BaselineFile, cfg.RedactMap} {
+		if path == "" {
+			continue
+		}
+		l, err := acquireLock(path, cfg.ForceUnlock)
+		if err != nil {
+			for _, l := range locks {
+				l.release()
+			}
+			return nil, err
+		}
+		locks = append(locks, l)
+	}
+	return locks, nil
+}
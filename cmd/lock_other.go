@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package main
+
+import "os"
+
+// tryLockFile does nothing on platforms without file locking.
+func tryLockFile(f *os.File) error {
+	return nil
+}
+
+// processAlive assumes processes are alive where they cannot be checked.
+func processAlive(pid int) bool {
+	return true
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// logLevel orders log messages by severity.
+type logLevel int
+
+// Supported log levels, from most to least verbose.
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// logLevelNames maps --log-level values to levels.
+var logLevelNames = map[string]logLevel{
+	"debug": levelDebug,
+	"info":  levelInfo,
+	"warn":  levelWarn,
+	"error": levelError,
+}
+
+// minLogLevel is the least severe level that is logged, set from --log-level at startup.
+var minLogLevel = levelInfo
+
+// throttleReportInterval is the minimum time between "repeated N times" reports of a throttled message.
+const throttleReportInterval = time.Minute
+
+// parseLogLevel interprets a --log-level value.
+func parseLogLevel(s string) (logLevel, error) {
+	level, ok := logLevelNames[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("Invalid log level %q. Allowed values: debug, info, warn, error", s)
+	}
+	return level, nil
+}
+
+// logf logs a message at the given level if it is enabled.
+func logf(level logLevel, format string, args ...any) {
+	if level >= minLogLevel {
+		log.Printf(format, args...)
+	}
+}
+
+func logDebugf(format string, args ...any) { logf(levelDebug, format, args...) }
+func logInfof(format string, args ...any)  { logf(levelInfo, format, args...) }
+func logWarnf(format string, args ...any)  { logf(levelWarn, format, args...) }
+func logErrorf(format string, args ...any) { logf(levelError, format, args...) }
+
+// logThrottle collapses consecutive identical messages into a periodic
+// "previous message repeated N times" line so a persistent failure does not flood the log.
+type logThrottle struct {
+	level      logLevel  // Level the messages are logged at
+	last       string    // Last message logged in full
+	repeats    int       // Repetitions of last not yet reported
+	lastReport time.Time // When last was logged or its repetitions were last reported
+}
+
+// logf logs a message, suppressing it if it repeats the previous one.
+func (t *logThrottle) logf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	now := time.Now()
+
+	if msg == t.last {
+		t.repeats++
+		if now.Sub(t.lastReport) >= throttleReportInterval {
+			t.flush(now)
+		}
+		return
+	}
+
+	t.flush(now)
+	t.last = msg
+	t.lastReport = now
+	logf(t.level, "%s", msg)
+}
+
+// flush reports any pending repetitions of the last message.
+func (t *logThrottle) flush(now time.Time) {
+	if t.repeats > 0 {
+		logf(t.level, "previous message repeated %d times", t.repeats)
+		t.repeats = 0
+	}
+	t.lastReport = now
+}
+
+// reset ends the current run of repeated messages, reporting any pending repetitions.
+// It is called once the condition behind the messages has cleared.
+func (t *logThrottle) reset() {
+	t.flush(time.Now())
+	t.last = ""
+}
@@ -1,238 +1,626 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
-	"math"
 	"os"
 	"os/signal"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/shirou/gopsutil/v4/net"
-)
 
-// Constants for unit conversions using binary (1024-based) prefixes
-const (
-	KB                   = 1024.0
-	MB                   = KB * 1024
-	GB                   = MB * 1024
-	unrealBytesPerSecond = GB * 100
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
 )
 
+// unrealBytesPerSecond is the plausibility ceiling used when the link speed is unknown.
+const unrealBytesPerSecond = netstats.GB * 100
+
 // NetStats represents comprehensive network statistics for a specific network interface.
 type NetStats struct {
-	Interface  string `json:"interface"`
-	SentSpeed  Speed  `json:"sentSpeed"`
-	RecvSpeed  Speed  `json:"recvSpeed"`
-	TotalSent  Usage  `json:"totalSent"`
-	TotalRecv  Usage  `json:"totalRecv"`
-	TotalUsage Usage  `json:"totalUsage"`
+	netstats.Stats
+	Timestamp    Timestamp         `json:"timestamp"`         // When the counters were read, the instant speeds are computed to
+	SentMin      *Speed            `json:"sentMin,omitempty"` // Slowest sample of a report window
+	SentMax      *Speed            `json:"sentMax,omitempty"` // Fastest sample of a report window
+	RecvMin      *Speed            `json:"recvMin,omitempty"`
+	RecvMax      *Speed            `json:"recvMax,omitempty"`
+	Implausible  bool              `json:"implausible,omitempty"` // Rates clamped to the plausibility ceiling, left out of totals
+	Meta         *InterfaceMeta    `json:"meta,omitempty"`
+	Baseline     *BaselineMultiple `json:"baseline,omitempty"`     // Rates relative to the usual rates of this hour of day
+	RateOfChange *RateOfChange     `json:"rateOfChange,omitempty"` // How fast the smoothed rates change, with -rate-of-change
+	Counters     *Counters         `json:"counters,omitempty"`
+	Softnet      *Softnet          `json:"softnet,omitempty"`      // Kernel softirq drops since the previous sample, with -softnet
+	Qdisc        *QdiscStats       `json:"qdisc,omitempty"`        // Root qdisc statistics as of the last metadata refresh, with -qdisc
+	Probes       []ProbeResult     `json:"probes,omitempty"`       // Latency probes since the previous sample, one per -probe target
+	QuietHours   *bool             `json:"inQuietHours,omitempty"` // Whether the sample was taken in -quiet-hours, set only with them
+	Plan         *PlanUsage        `json:"plan,omitempty"`
+	Monitor      *SelfStats        `json:"monitor,omitempty"`
+	Members      []string          `json:"members,omitempty"`         // Interfaces summed into a -group sample
+	Interval     float64           `json:"intervalSeconds,omitempty"` // Seconds the rates cover, with per-interface intervals
+	raw          rawFigures        // Unscaled speeds and totals, for CSV output
+	age          *time.Duration    // Time since the sample was taken, for tables with per-interface intervals
+	recordID
 }
 
-// Speed describes network transfer speed with a numerical value and its unit.
-type Speed struct {
-	Value float64 `json:"value"`
-	Unit  string  `json:"unit"`
-}
-
-// Usage represents network data transfer amount with a numerical value and its unit.
-type Usage struct {
-	Value float64 `json:"value"`
-	Unit  string  `json:"unit"`
-}
+// Speed and Usage are the scaled values of the netstats library.
+type (
+	Speed = netstats.Speed
+	Usage = netstats.Usage
+)
 
 // NetworkMonitor manages the collection and processing of network interface statistics.
 type NetworkMonitor struct {
-	interfaceName   string         // Name of the network interface being monitored
-	refreshInterval int            // Time between statistical updates in seconds
-	precision       int            // Number of decimal places for rounding numerical values
-	format          string         // Output format ("json" or "table")
-	interrupt       chan os.Signal // Channel to handle interrupt signals
-	stats           NetStats       // Most recent network statistics
-	mu              sync.RWMutex   // Mutex for thread-safe access to stats
+	selector          interfaceSelector                       // Identifier the interface was requested by
+	interfaceName     string                                  // Current name of the network interface being monitored
+	source            counterSource                           // Supplier of the cumulative I/O counters
+	refreshInterval   time.Duration                           // Time between statistical updates
+	precision         int                                     // Number of decimal places for rounding numerical values
+	units             netstats.Units                          // Units speeds are reported in
+	format            string                                  // Output format ("table", "json", "yaml", "csv", "influx" or "tui")
+	formatter         formatter                               // Renderer of the output format
+	influxTags        string                                  // Escaped -tags of -f influx lines, with a leading comma
+	showMeta          bool                                    // Whether to include interface metadata in each sample
+	decimalComma      bool                                    // Whether human-facing output uses a comma decimal separator
+	showTime          bool                                    // Whether tables get a column with the sample time
+	csvDelimiter      rune                                    // Field separator of CSV output
+	interrupt         chan os.Signal                          // Channel to handle interrupt signals
+	stats             NetStats                                // Most recent network statistics
+	meta              map[string]*InterfaceMeta               // Last observed metadata per interface
+	route             *defaultRoute                           // Last observed default route, nil until first read
+	routeErrLogged    bool                                    // Whether a default route read failure was already logged
+	session           sessionTracker                          // Figures accumulated for the session summary
+	errThrottle       logThrottle                             // Collapses repeated collection errors in the log
+	out               *outputWriter                           // Destination of formatted samples and events
+	counters          bool                                    // Whether samples include the absolute kernel counters
+	suppressZero      bool                                    // Whether idle samples are left out of the output
+	zeroEpsilon       uint64                                  // Largest per-direction byte delta still considered idle
+	quiet             quietPeriod                             // Samples suppressed since traffic was last seen
+	heartbeat         time.Duration                           // Longest silence before a heartbeat record, 0 to disable
+	lastTick          time.Time                               // Start of the previous tick, from nm.clock
+	clock             clock                                   // Tells the time of ticks, injected by tests
+	lastSampleAt      time.Time                               // When the last sample was emitted, or monitoring started
+	sessionID         string                                  // Random identifier of this monitoring session
+	seq               uint64                                  // Sequence number of the last emitted record
+	lastSampleSeq     uint64                                  // Sequence number of the last emitted sample
+	selfStats         *selfStatsCollector                     // Collector of the monitor's own resource use, nil if disabled
+	maxErrors         int                                     // Consecutive read failures tolerated before giving up, 0 for no limit
+	consecutiveErrors int                                     // Read failures since the last successful read
+	pair              [2]string                               // Interfaces compared in -pair mode, empty otherwise
+	multi             []string                                // Interfaces monitored together from a -i list, empty otherwise
+	allInterfaces     bool                                    // Whether every (matching) interface is monitored, multi holding those present at start
+	groups            []*ifaceGroup                           // Interface groups reported next to their members, with -group
+	match             func(string) bool                       // Selects the interfaces of a -i pattern or --match-regex, nil for all
+	exclude           func(string) bool                       // Interfaces left out by --exclude, nil for none
+	skipLoopback      bool                                    // Whether loopback interfaces are left out of -i all
+	loopback          loopbackCache                           // Loopback flag per interface name
+	pairFactor        float64                                 // Asymmetry factor at which a pair is flagged
+	pairSustain       int                                     // Consecutive asymmetric samples before an event
+	pairSustained     int                                     // Consecutive asymmetric samples so far
+	resolve           func(interfaceSelector) (string, error) // Finds the current name of a stable selector
+	sampler           *netstats.Sampler                       // Baselines, deltas and totals of the monitored interfaces
+	lastReadAt        time.Time                               // When the counters were last read successfully
+	staggerSlots      int                                     // Slots each interval is split into by -stagger, 0 to read every interface each tick
+	intervals         map[string]time.Duration                // Per-interface intervals of a -i list, nil when all share -interval
+	step              time.Duration                           // Tick serving every per-interface interval
+	latest            map[string]NetStats                     // Latest sample per interface, for tables with per-interface intervals
+	tickNo            int                                     // Ticks since sampling started, 0 while the initial readings are taken
+	absent            map[string]bool                         // Interfaces of a -i list that could not be read at the last tick
+	hourly            *hourlyTracker                          // Figures of the current hour, nil unless -hourly-summary is set
+	hourlySummary     bool                                    // Whether hourly summary records are emitted
+	lastCounters      *net.IOCountersStat                     // Latest successful counter reading, for the exit push
+	configDigest      string                                  // Digest of the effective configuration, set when a header is emitted
+	maxPlausibleRate  float64                                 // Configured plausibility ceiling in bytes per second, 0 to derive it
+	recent            []NetStats                              // Latest samples for crash bundles, guarded by mu
+	baseline          *baselineTracker                        // Learned hour-of-day rates, nil unless -baseline-file is set
+	redact            *redactor                               // Scrubs identifying details from the output, nil unless -redact is set
+	softnet           softnetCounters                         // Softnet counters at the previous sample, nil unless -softnet is set and supported
+	qdiscEnabled      bool                                    // Whether qdisc statistics are collected
+	qdisc             map[string]*QdiscStats                  // Latest qdisc statistics per interface
+	prober            *prober                                 // Latency probes of -probe, nil if not set
+	count             int                                     // Samples emitted before monitoring stops, 0 for no limit
+	emitted           int                                     // Samples emitted so far
+	duration          time.Duration                           // Time after which monitoring stops, 0 for no limit
+	quietHours        *quietHours                             // Windows of -quiet-hours, nil if not set
+	baseInterval      time.Duration                           // Sampling interval outside quiet hours
+	capabilities      counterCapabilities                     // Counter fields the platform populates for the interface
+	paused            bool                                    // Whether sample output is paused by a control command
+	resetPending      bool                                    // Whether totals restart from zero at the next sample
+	report            *reportWindow                           // Samples of the current report window, nil when every sample is emitted
+	batch             *sampleBatch                            // Pending JSON batch, nil when batching is off
+	plan              *ispPlan                                // Subscribed plan rates to compare against, nil if unset
+	plateaus          [3]plateauDetector                      // Shaping detectors for download, upload and their sum
+	countersOnly      bool                                    // Whether samples carry only the counters, without derived fields
+	warmup            int                                     // Number of initial samples collected but not emitted
+	warmupRemaining   int                                     // Warm-up samples still to be suppressed
+	warmupExclude     bool                                    // Whether warm-up traffic is left out of totals and summaries
+	mu                sync.RWMutex                            // Mutex for thread-safe access to stats
+	tick              []NetStats                              // Samples of the latest tick when monitoring several interfaces, guarded by mu
+	sinks             []trackedSink                           // Metrics servers the samples of every tick are sent to, such as -graphite
+	sinkRegistry      *sinkRegistry                           // Delivery records of the sinks, served on /sinks
+	strictSinks       bool                                    // Whether a sink dropping over sinkBudget of its deliveries fails monitoring
+	sinkBudget        float64                                 // Share of its deliveries a sink may drop within the window
+	color             bool                                    // Whether text event lines are colored for a terminal
+	thresholds        *speedThresholds                        // Levels at which speeds are colored per direction, nil when not coloring
+	live              bool                                    // Whether tables are redrawn in place on the terminal
+	features          []FeatureCheck                          // Outcome of the privilege probe for the requested features
+	tui               *tuiDashboard                           // Dashboard of -f tui, nil for other formats
+	controlOut        io.Writer                               // Destination of control command acknowledgments
+	annotator         *annotator                              // Receiver of external annotations, nil unless -listen or -annotate-fifo is set
+	failover          *failoverWatch                          // Switches between the interfaces of -failover-watch, nil without it
+	shifts            *shiftWatch                             // Rate-of-change state of -rate-of-change and -alert, nil without them
+	continuesFile     bool                                    // Whether output is appended to an -o file that already holds data
 }
 
 // NewNetworkMonitor creates and initializes a new NetworkMonitor instance.
-func NewNetworkMonitor(iface string, interval, precision int, format string) *NetworkMonitor {
-	return &NetworkMonitor{
+func NewNetworkMonitor(sel interfaceSelector, iface string, src counterSource, cfg monitorConfig) *NetworkMonitor {
+	var stdout io.Writer = os.Stdout
+	if cfg.Quiet {
+		stdout = io.Discard
+	}
+	nm := &NetworkMonitor{
+		selector:        sel,
 		interfaceName:   iface,
-		refreshInterval: interval,
-		precision:       precision,
-		format:          format,
+		source:          src,
+		refreshInterval: cfg.Interval,
+		baseInterval:    cfg.Interval,
+		precision:       cfg.Precision,
+		format:          cfg.Format,
+		showMeta:        cfg.ShowMeta,
+		decimalComma:    cfg.DecimalComma,
+		showTime:        cfg.ShowTime,
 		interrupt:       make(chan os.Signal, 1),
+		errThrottle:     logThrottle{level: levelError},
+		out:             newOutputWriter(stdout, cfg.BufferSamples, cfg.BufferFlush),
+		counters:        cfg.Counters || cfg.CountersOnly,
+		countersOnly:    cfg.CountersOnly,
+		warmup:          cfg.Warmup,
+		warmupRemaining: cfg.Warmup,
+		warmupExclude:   cfg.WarmupExclude,
+		heartbeat:       cfg.Heartbeat,
+		maxErrors:       cfg.MaxErrors,
+		sinkRegistry:    newSinkRegistry(cfg.SinkBudgetWindow),
+		strictSinks:     cfg.StrictSinks,
+		sinkBudget:      cfg.SinkDropBudget / 100,
+		hourlySummary:   cfg.HourlySummary,
+		pairFactor:      cfg.PairFactor,
+		pairSustain:     cfg.PairSustain,
+		units:           netstats.Units{Bits: cfg.Bits, SI: cfg.SI},
+		sessionID:       newSessionID(),
+		suppressZero:    cfg.SuppressZero,
+		zeroEpsilon:     uint64(cfg.ZeroEpsilon),
+		skipLoopback:    cfg.SkipLoopback,
+		qdiscEnabled:    cfg.Qdisc,
+		count:           cfg.Count,
+		duration:        cfg.Duration,
+		loopback:        make(loopbackCache),
+		meta:            make(map[string]*InterfaceMeta),
+		qdisc:           make(map[string]*QdiscStats),
+		absent:          make(map[string]bool),
+		resolve:         resolveInterface,
+		clock:           systemClock{},
+	}
+	if cfg.Stagger > 0 {
+		nm.staggerSlots = int(cfg.Interval / cfg.Stagger)
+	}
+	nm.sampler = netstats.NewSampler(netstats.Options{Elapsed: nm.elapsed, Ceiling: nm.plausibleCeiling})
+	if cfg.Once {
+		nm.count = 1
+	}
+	if cfg.JSONArray {
+		nm.out.array = true
+	}
+	if cfg.QuietHours != "" {
+		nm.quietHours, _ = parseQuietHours(cfg.QuietHours, cfg.QuietHoursTZ)
+		nm.session.quietHours = &QuietHoursSummary{}
+	}
+	if cfg.Exclude != "" {
+		nm.exclude, _ = parseInterfacePattern(cfg.Exclude, "")
+	}
+	if cfg.Probe != "" {
+		nm.prober, _ = newProber(cfg.Probe, cfg.Interval)
+	}
+	if cfg.SelfStats {
+		nm.selfStats = newSelfStatsCollector()
+	}
+	if cfg.Softnet {
+		if c, err := readSoftnet(); err != nil {
+			logWarnf("Softnet statistics are left out: %v", err)
+		} else {
+			nm.softnet, nm.session.softnet = c, &Softnet{}
+		}
+	}
+	if report := time.Duration(cfg.ReportInterval) * time.Second; report > cfg.Interval {
+		nm.report = &reportWindow{size: int(report / cfg.Interval)}
+	}
+	nm.csvDelimiter, _ = parseCSVDelimiter(cfg.CSVDelimiter)
+	nm.units.Fixed, _ = parseFixedUnit(cfg.Unit, cfg.Bits, cfg.SI) // Validated with the configuration
+	if rules, _ := parseShiftRules(cfg.Alerts); len(rules) > 0 || cfg.RateOfChange {
+		nm.shifts = newShiftWatch(cfg.RateOfChange, rules, float64(cfg.AlertMinRate))
+	}
+	nm.influxTags, _ = parseInfluxTags(cfg.Tags)
+	terminal := isTerminal(os.Stdout) && !cfg.Quiet && cfg.Output == ""
+	nm.color = terminal && !colorDisabled(cfg)
+	nm.live = cfg.Live && terminal
+	if nm.color {
+		plan, _ := parsePlan(cfg.Plan) // Validated with the configuration, nil without -plan
+		nm.thresholds = newSpeedThresholds(cfg, plan)
+	}
+	nm.controlOut = os.Stderr
+	nm.formatter = newFormatter(nm)
+	nm.maxPlausibleRate = float64(cfg.MaxPlausibleRate)
+	if cfg.Header {
+		nm.configDigest = configDigest(cfg)
 	}
+	if cfg.Batch > 1 {
+		nm.batch = &sampleBatch{size: cfg.Batch, maxAge: cfg.BatchMaxAge}
+	}
+	if cfg.Plan != "" {
+		nm.plan, _ = parsePlan(cfg.Plan)
+		nm.plateaus = [3]plateauDetector{
+			{direction: "download", planBits: nm.plan.Down},
+			{direction: "upload", planBits: nm.plan.Up},
+			{direction: "combined traffic", planBits: nm.plan.Total},
+		}
+	}
+	return nm
+}
+
+// readCounters retrieves the I/O statistics of the monitored interface. When the interface
+// was selected by a stable identifier and its name disappeared, the identifier is resolved
+// again so monitoring follows the device across renames.
+func (nm *NetworkMonitor) readCounters() (net.IOCountersStat, error) {
+	io, err := nm.source.counters(nm.interfaceName)
+	if err == nil || !errors.Is(err, errInterfaceNotFound) || !nm.selector.stable() {
+		return io, err
+	}
+
+	name, resolveErr := nm.resolve(nm.selector)
+	if resolveErr != nil {
+		return io, err
+	}
+	if name != nm.interfaceName {
+		logInfof("Interface %s renamed from %s to %s", nm.selector, nm.interfaceName, name)
+		nm.sampler.Rename(nm.interfaceName, name)
+		delete(nm.meta, nm.interfaceName)
+		delete(nm.qdisc, nm.interfaceName)
+		nm.interfaceName = name
+	}
+
+	return nm.source.counters(nm.interfaceName)
 }
 
-// round calculates a floating-point number rounded to a specified number of decimal places.
-func round(value float64, precision int) float64 {
-	multiplier := math.Pow(10, float64(precision))
-	return math.Round(value*multiplier) / multiplier
+// elapsed returns the time covered by a reading taken at now since the previous one taken at
+// prev. Speeds are computed over it rather than the nominal interval, so late ticks, a busy
+// system and failed reads do not skew them. Both readings carry the monotonic clock, which
+// excludes a suspend, when no traffic flows. Artificial sources supply their own step length.
+func (nm *NetworkMonitor) elapsed(prev, now time.Time) time.Duration {
+	if d, ok := sourceSpan(nm.source); ok && d > 0 {
+		return d
+	}
+	if d := now.Sub(prev); d > 0 {
+		return d
+	}
+	return nm.refreshInterval
 }
 
-// calculateSpeed determines the most appropriate unit for network transfer speed (B/s, KB/s, MB/s, GB/s).
-func calculateSpeed(bytes uint64, interval int, precision int) Speed {
-	speed := float64(bytes) / float64(interval)
+// formatQuantity renders a value with its unit for human-facing output, using a comma as the
+// decimal separator when decimalComma is set.
+func formatQuantity(value float64, unit string, precision int, decimalComma bool) string {
+	s := strconv.FormatFloat(value, 'f', precision, 64)
+	if decimalComma {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s + " " + unit
+}
 
-	switch {
-	case speed >= GB:
-		return Speed{
-			Value: round(speed/GB, precision),
-			Unit:  "GB/s",
+// printTable prints the network statistics of one or more interfaces in a tabular format to w,
+// one row per interface.
+func printTable(w io.Writer, rows []NetStats, precision int, decimalComma, showTime bool, thresholds *speedThresholds) {
+	table := tablewriter.NewWriter(w)
+	header := []string{"Interface", "Sent Speed", "Recv Speed", "Total Sent", "Total Recv", "Total Usage"}
+	if showTime {
+		header = append([]string{"Time"}, header...)
+	}
+	if p := rows[0].Plan; p != nil {
+		if p.DownPercent != nil {
+			header = append(header, "Down % Plan", "Up % Plan")
+		}
+		if p.TotalPercent != nil {
+			header = append(header, "Total % Plan")
+		}
+	}
+	if rows[0].Baseline != nil {
+		header = append(header, "Sent × Normal", "Recv × Normal")
+	}
+	for _, p := range rows[0].Probes {
+		header = append(header, "RTT "+p.Target, "Loss "+p.Target)
+	}
+	if rows[0].age != nil {
+		header = append(header, "Age")
+	}
+	table.SetHeader(header)
+	for _, stats := range rows {
+		name := stats.Interface
+		if stats.Members != nil {
+			name += " (group)"
 		}
-	case speed >= MB:
-		return Speed{
-			Value: round(speed/MB, precision),
-			Unit:  "MB/s",
+		row := []string{
+			name,
+			formatQuantity(stats.SentSpeed.Value, stats.SentSpeed.Unit, precision, decimalComma),
+			formatQuantity(stats.RecvSpeed.Value, stats.RecvSpeed.Unit, precision, decimalComma),
+			formatQuantity(stats.TotalSent.Value, stats.TotalSent.Unit, precision, decimalComma),
+			formatQuantity(stats.TotalRecv.Value, stats.TotalRecv.Unit, precision, decimalComma),
+			formatQuantity(stats.TotalUsage.Value, stats.TotalUsage.Unit, precision, decimalComma),
 		}
-	case speed >= KB:
-		return Speed{
-			Value: round(speed/KB, precision),
-			Unit:  "KB/s",
+		if showTime {
+			row = append([]string{stats.Timestamp.String()}, row...)
 		}
-	default:
-		return Speed{
-			Value: round(speed, precision),
-			Unit:  "B/s",
+		if p := stats.Plan; p != nil {
+			if p.DownPercent != nil {
+				row = append(row,
+					formatQuantity(*p.DownPercent, "%", precision, decimalComma),
+					formatQuantity(*p.UpPercent, "%", precision, decimalComma))
+			}
+			if p.TotalPercent != nil {
+				row = append(row, formatQuantity(*p.TotalPercent, "%", precision, decimalComma))
+			}
 		}
-	}
-}
-
-// calculateUsage determines the most appropriate unit for network data transfer (B, KB, MB, GB).
-func calculateUsage(bytes uint64, precision int) Usage {
-	usage := float64(bytes)
-
-	switch {
-	case usage >= GB:
-		return Usage{
-			Value: round(usage/GB, precision),
-			Unit:  "GB",
+		if stats.Baseline != nil {
+			row = append(row,
+				formatQuantity(stats.Baseline.SentMultiple, "×", precision, decimalComma),
+				formatQuantity(stats.Baseline.RecvMultiple, "×", precision, decimalComma))
 		}
-	case usage >= MB:
-		return Usage{
-			Value: round(usage/MB, precision),
-			Unit:  "MB",
+		for _, p := range stats.Probes {
+			rtt, loss := "-", "-"
+			if p.RTTMs != nil {
+				rtt = formatQuantity(*p.RTTMs, "ms", precision, decimalComma)
+			}
+			if p.Loss != nil {
+				loss = formatQuantity(*p.Loss*100, "%", precision, decimalComma)
+			}
+			row = append(row, rtt, loss)
 		}
-	case usage >= KB:
-		return Usage{
-			Value: round(usage/KB, precision),
-			Unit:  "KB",
+		if stats.age != nil {
+			row = append(row, stats.age.Round(time.Second).String())
 		}
-	default:
-		return Usage{
-			Value: round(usage, precision),
-			Unit:  "B",
+		if thresholds == nil {
+			table.Append(row)
+			continue
 		}
+		colors := make([]tablewriter.Colors, len(row))
+		speed := 1 // Sent Speed, after Time when shown
+		if showTime {
+			speed++
+		}
+		colors[speed] = thresholds.sent.colors(stats.raw.sentBps)
+		colors[speed+1] = thresholds.recv.colors(stats.raw.recvBps)
+		table.Rich(row, colors)
 	}
+
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(true)
+	table.SetRowLine(true)
+
+	table.Render()
 }
 
-// getInterfaceIOCounters retrieves network I/O statistics for a specific network interface.
-func getInterfaceIOCounters(ifaceName string) (net.IOCountersStat, error) {
-	netIO, err := net.IOCounters(true)
+// printJSON prints a record (network statistics or event) in JSON format to w.
+// Records that fail to marshal are logged and skipped so w only ever receives valid JSON lines.
+func printJSON(w io.Writer, v any) {
+	jsonData, err := json.Marshal(v)
 	if err != nil {
-		return net.IOCountersStat{}, err
+		logErrorf("Error marshaling to JSON: %v", err)
+		return
 	}
+	fmt.Fprintln(w, string(jsonData))
+}
 
-	for _, io := range netIO {
-		if io.Name == ifaceName {
-			return io, nil
+// writeRecord writes a record other than a sample, such as an event, and flushes it: as JSON,
+// or as the line rendered by line in table mode. CSV output holds samples only, so there the
+// line goes to stderr. Sinks that receive the record get it whatever the format.
+func (nm *NetworkMonitor) writeRecord(record any, line func(w io.Writer)) error {
+	nm.sendSinkRecord(record)
+	if nm.formatter.samplesOnly() {
+		var b bytes.Buffer
+		line(&b)
+		if nm.redact != nil {
+			_, err := os.Stderr.Write(nm.redact.text(b.Bytes()))
+			return err
 		}
+		_, err := os.Stderr.Write(b.Bytes())
+		return err
 	}
-
-	return net.IOCountersStat{}, fmt.Errorf("interface not found: %s", ifaceName)
+	if err := nm.out.writeRecord(func(w io.Writer) { nm.formatter.record(w, record, line) }); err != nil {
+		return err
+	}
+	return nm.out.Flush()
 }
 
-// printTable prints the network statistics in a tabular format to the console.
-func printTable(stats NetStats, precision int) {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Interface", "Sent Speed", "Recv Speed", "Total Sent", "Total Recv", "Total Usage"})
-
-	table.Append([]string{
-		stats.Interface,
-		fmt.Sprintf("%.*f %s", precision, stats.SentSpeed.Value, stats.SentSpeed.Unit),
-		fmt.Sprintf("%.*f %s", precision, stats.RecvSpeed.Value, stats.RecvSpeed.Unit),
-		fmt.Sprintf("%.*f %s", precision, stats.TotalSent.Value, stats.TotalSent.Unit),
-		fmt.Sprintf("%.*f %s", precision, stats.TotalRecv.Value, stats.TotalRecv.Unit),
-		fmt.Sprintf("%.*f %s", precision, stats.TotalUsage.Value, stats.TotalUsage.Unit),
+// writeSamples writes the samples of one tick through the current formatter.
+func (nm *NetworkMonitor) writeSamples(samples []NetStats) error {
+	return nm.out.writeSample(func(w io.Writer) {
+		nm.formatter.samples(w, samples, nm.allInterfaces)
 	})
+}
 
-	table.SetAlignment(tablewriter.ALIGN_LEFT)
-	table.SetBorder(true)
-	table.SetRowLine(true)
-
-	table.Render()
+// countReached records an emitted sample and reports whether the -count limit is reached.
+func (nm *NetworkMonitor) countReached() bool {
+	nm.emitted++
+	nm.session.limitReached = nm.count > 0 && nm.emitted >= nm.count
+	return nm.session.limitReached
 }
 
-// printJSON prints the network statistics in JSON format to the console.
-func printJSON(stats NetStats) {
-	jsonData, err := json.Marshal(stats)
-	if err != nil {
-		log.Printf("Error marshaling to JSON: %v", err)
+// deadline returns a channel that fires when the -duration limit expires, or nil without a limit.
+// Collection loops call it after creating their ticker, so a tick due at the deadline comes first.
+func (nm *NetworkMonitor) deadline() <-chan time.Time {
+	if nm.duration <= 0 {
+		return nil
 	}
-	fmt.Println(string(jsonData))
+	return time.After(nm.duration)
 }
 
-// collectStats continuously gathers and processes network statistics.
-func (nm *NetworkMonitor) collectStats() error {
-	initialNetIO, err := getInterfaceIOCounters(nm.interfaceName)
-	if err != nil {
-		return fmt.Errorf("error getting initial network stats: %v", err)
+// collectStats continuously gathers and processes network statistics. Every mode, from a
+// single interface to -pair, a -i list and -i all, shares this loop: the sampler keeps the
+// baselines and totals of each interface, and sampleTick passes every tick through the same
+// pipeline.
+func (nm *NetworkMonitor) collectStats() (err error) {
+	if err := nm.startSampling(); err != nil {
+		return err
+	}
+	nm.refreshMetadata()
+	if nm.configDigest != "" && nm.format != "csv" {
+		nm.emitHeader()
+	}
+	if nm.format == "csv" {
+		if err := nm.writeCSVHeader(); err != nil {
+			return outputError(err)
+		}
 	}
 
-	totalSentStart := initialNetIO.BytesSent
-	totalRecvStart := initialNetIO.BytesRecv
-	prevNetIO := initialNetIO
-
-	ticker := time.NewTicker(time.Duration(nm.refreshInterval) * time.Second)
+	ticker := time.NewTicker(nm.tickPeriod())
 	defer ticker.Stop()
+	deadline := nm.deadline()
+
+	// Quiet hours with their own interval switch it at every window boundary.
+	var quietTimer *time.Timer
+	var quietC <-chan time.Time
+	if nm.quietHours != nil && nm.quietHours.hasIntervals() {
+		now := nm.clock.now()
+		nm.applyQuietInterval(now, ticker)
+		quietTimer = time.NewTimer(time.Until(nm.quietHours.nextBoundary(now)))
+		defer quietTimer.Stop()
+		quietC = quietTimer.C
+	}
+
+	if nm.prober != nil {
+		nm.prober.start()
+		defer nm.prober.close()
+	}
+
+	metaTicker := time.NewTicker(metadataRefreshInterval)
+	defer metaTicker.Stop()
+
+	// Buffered output is always flushed on shutdown, and checked for expiry on a timer so a
+	// quiet stream does not hold records past the age limit. The array of -f json-array is
+	// closed here too, after the records emitted on shutdown, so an interrupted run still
+	// parses as one document.
+	defer func() {
+		if ferr := nm.out.closeArray(); ferr != nil && err == nil {
+			err = outputError(ferr)
+		}
+	}()
+	var flushC <-chan time.Time
+	if nm.out.maxAge > 0 {
+		flushTicker := time.NewTicker(nm.out.maxAge)
+		defer flushTicker.Stop()
+		flushC = flushTicker.C
+	}
+
+	var commands <-chan string
+	if nm.tui != nil {
+		commands = nm.tui.commands // The dashboard owns stdin
+	} else {
+		commands = readCommands()
+	}
+	var heartbeatC <-chan time.Time
+	if nm.heartbeat > 0 {
+		heartbeatTicker := time.NewTicker(nm.heartbeat)
+		defer heartbeatTicker.Stop()
+		heartbeatC = heartbeatTicker.C
+	}
+
+	// Hourly summaries are emitted at each wall-clock hour boundary, and for the partial last
+	// hour on shutdown.
+	var hourTimer *time.Timer
+	var hourlyC <-chan time.Time
+	if nm.hourlySummary {
+		nm.hourly = newHourlyTracker(nm.session.start)
+		hourTimer = time.NewTimer(time.Until(nextHour(nm.session.start)))
+		defer hourTimer.Stop()
+		hourlyC = hourTimer.C
+		defer func() {
+			if herr := nm.emitHourlySummary(nm.clock.now(), true); herr != nil && err == nil {
+				err = outputError(herr)
+			}
+		}()
+	}
+
+	// The hour in progress still counts towards the baselines if enough of it was sampled.
+	if nm.baseline != nil {
+		defer nm.baseline.fold()
+	}
+
+	// A partial batch is emitted on shutdown, before the output buffer is flushed.
+	defer func() {
+		if ferr := nm.flushBatch(); ferr != nil && err == nil {
+			err = outputError(ferr)
+		}
+	}()
+	var batchC <-chan time.Time
+	if nm.batch != nil && nm.batch.maxAge > 0 {
+		batchTicker := time.NewTicker(nm.batch.maxAge)
+		defer batchTicker.Stop()
+		batchC = batchTicker.C
+	}
 
 	for {
 		select {
 		case <-ticker.C:
-			currentNetIO, err := getInterfaceIOCounters(nm.interfaceName)
-			if err != nil {
-				log.Printf("Error getting network stats: %v", err)
-				continue
+			if done, err := nm.sampleTick(nm.clock.now()); done || err != nil {
+				return err
 			}
 
-			tmpSentBytes := currentNetIO.BytesSent - prevNetIO.BytesSent
-			tmpRecvBytes := currentNetIO.BytesRecv - prevNetIO.BytesRecv
+		case <-metaTicker.C:
+			nm.refreshMetadata()
 
-			if tmpSentBytes > unrealBytesPerSecond || tmpRecvBytes > unrealBytesPerSecond {
-				return fmt.Errorf("unrealistic network usage detected, exiting")
+		case <-flushC:
+			if err := nm.out.flushExpired(); err != nil {
+				return outputError(err)
 			}
 
-			sentBytes := tmpSentBytes
-			recvBytes := tmpRecvBytes
+		case line := <-commands:
+			nm.handleCommand(line, ticker)
 
-			totalSent := currentNetIO.BytesSent - totalSentStart
-			totalRecv := currentNetIO.BytesRecv - totalRecvStart
+		case now := <-heartbeatC:
+			nm.checkHeartbeat(now)
 
-			stats := NetStats{
-				Interface:  nm.interfaceName,
-				SentSpeed:  calculateSpeed(sentBytes, nm.refreshInterval, nm.precision),
-				RecvSpeed:  calculateSpeed(recvBytes, nm.refreshInterval, nm.precision),
-				TotalSent:  calculateUsage(totalSent, nm.precision),
-				TotalRecv:  calculateUsage(totalRecv, nm.precision),
-				TotalUsage: calculateUsage(totalSent+totalRecv, nm.precision),
+		case now := <-hourlyC:
+			// The timer may fire slightly early or late; the record ends at the boundary itself.
+			boundary := nextHour(now.Add(-time.Minute))
+			if err := nm.emitHourlySummary(boundary, false); err != nil {
+				return outputError(err)
 			}
+			hourTimer.Reset(time.Until(nextHour(boundary)))
 
-			nm.mu.Lock()
-			nm.stats = stats
-			nm.mu.Unlock()
-
-			if nm.format == "table" {
-				printTable(stats, nm.precision)
-			} else {
-				printJSON(stats)
+		case now := <-batchC:
+			if nm.batch.expired(now) {
+				if err := nm.flushBatch(); err != nil {
+					return outputError(err)
+				}
 			}
 
-			prevNetIO = currentNetIO
+		case now := <-quietC:
+			nm.applyQuietInterval(now, ticker)
+			quietTimer.Reset(time.Until(nm.quietHours.nextBoundary(now)))
+
+		case <-deadline:
+			logDebugf("Duration of %v elapsed, stopping", nm.duration)
+			nm.session.limitReached = true
+			return nil
+
+		case label := <-nm.annotations():
+			nm.emitAnnotation(label)
 
 		case <-nm.interrupt:
 			return nil
@@ -240,39 +628,802 @@ func (nm *NetworkMonitor) collectStats() error {
 	}
 }
 
-func main() {
-	runtime.GOMAXPROCS(runtime.NumCPU())
+// startSampling takes the initial readings the first samples are computed from and starts the
+// session.
+func (nm *NetworkMonitor) startSampling() error {
+	readAt := nm.clock.now()
+	readings, failed, err := nm.readTick()
+	for _, ferr := range failed {
+		if err == nil {
+			err = ferr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error getting initial network stats: %w", err)
+	}
+	nm.sampler.Tick(readings, readAt)
+	nm.lastReadAt = readAt
+	nm.session.start = nm.clock.now()
+	nm.lastTick, nm.lastSampleAt = nm.session.start, nm.session.start
+	return nil
+}
 
-	interfaceName := flag.String("i", "", "Network interface to monitor (required)")
-	refreshInterval := flag.Int("t", 1, "Refresh interval in seconds")
-	precision := flag.Int("p", 2, "Precision for rounding numbers")
-	format := flag.String("f", "table", "Output format: json or table")
-	flag.Parse()
+// sampleTick reads the counters of the monitored interfaces once and passes the samples of the
+// tick through the pipeline: plausibility checks, warm-up, the session figures, enrichment,
+// sinks and output. It reports whether monitoring is done, because the source ran out of
+// readings, the -count limit was reached or an error ends it.
+func (nm *NetworkMonitor) sampleTick(tickStart time.Time) (bool, error) {
+	nm.checkClockStep(tickStart)
+	nm.tickNo++
+	readings, failed, err := nm.readTick()
+	if errors.Is(err, errSourceExhausted) {
+		nm.session.endOfInput = true
+		return true, nil
+	}
+	if err != nil {
+		nm.session.errors++
+		nm.consecutiveErrors++
+		if nm.maxErrors > 0 && nm.consecutiveErrors >= nm.maxErrors {
+			return true, &runtimeError{
+				err:      fmt.Errorf("reading counters failed %d times in a row: %w", nm.consecutiveErrors, err),
+				exitCode: exitCollection,
+			}
+		}
+		nm.errThrottle.logf("Error getting network stats: %v", err)
+		return false, nil
+	}
+	nm.consecutiveErrors = 0
+	nm.errThrottle.reset()
+	nm.trackPresence(readings, failed)
+	readDuration := time.Since(tickStart)
+	interval := nm.elapsed(nm.lastReadAt, tickStart).Seconds()
+	nm.lastReadAt = tickStart
 
-	if *interfaceName == "" {
-		flag.Usage()
-		fmt.Print("\n")
-		log.Fatal("Error: the -i (interface) flag is required.\n" +
-			"Usage: ./zag-netStats -i <interface_name> -t <interval> -p <precision> -f <format>")
+	if nm.resetPending {
+		nm.resetTotals()
+		nm.resetPending = false
+	}
+	deltas := nm.sampler.Tick(readings, tickStart)
+	if len(deltas) == 0 {
+		return false, nil
+	}
+	if nm.staggerSlots > 0 || nm.intervals != nil {
+		interval = deltas[0].Interval // Interfaces read this tick were last read their interval ago
+	}
+	if !nm.several() {
+		nm.lastCounters = &deltas[0].Cur
+		nm.checkCapabilities(deltas[0].Prev, deltas[0].Cur, tickStart)
 	}
 
-	if *precision < 0 || *precision > 6 {
-		log.Fatal("Precision must be between 0 and 6 decimal places")
+	// Implausible deltas, such as garbage from a driver bug or a counter reset, are left out of
+	// totals and aggregates. Their samples are still emitted, flagged and with rates clamped
+	// to the ceiling, so the gap is visible.
+	plausible := make([]bool, len(deltas))
+	moved := make(map[string]uint64Pair, len(deltas)) // Plausible deltas, for groups, pairs and failover
+	var sent, recv, faults, drops uint64
+	for i, d := range deltas {
+		if plausible[i] = nm.checkPlausible(d, tickStart); !plausible[i] {
+			limit := uint64(nm.plausibleCeiling(d.Interface) * d.Interval)
+			deltas[i].Sent, deltas[i].Recv = min(d.Sent, limit), min(d.Recv, limit)
+			continue
+		}
+		moved[d.Interface] = uint64Pair{d.Sent, d.Recv}
+		sent += d.Sent
+		recv += d.Recv
+		faults += netstats.Growth(d.Prev.Errin+d.Prev.Errout, d.Cur.Errin+d.Cur.Errout)
+		drops += netstats.Growth(d.Prev.Dropin+d.Prev.Dropout, d.Cur.Dropin+d.Cur.Dropout)
 	}
+	implausible := len(moved) == 0 // Nothing of the tick counts
+	totalSent, totalRecv := nm.sampler.Totals()
 
-	if *refreshInterval <= 0 || *refreshInterval > 3600 {
-		log.Fatal("Refresh interval must be between 1 and 3600 seconds")
+	// Warm-up samples stabilize the baselines but are not emitted. Unless excluded, their
+	// traffic still counts towards totals and the session summary.
+	if nm.warmupRemaining > 0 {
+		nm.warmupRemaining--
+		if nm.warmupExclude {
+			nm.resetTotals()
+			nm.session.start = nm.clock.now()
+		} else {
+			nm.session.setTotals(totalSent, totalRecv)
+		}
+		if nm.warmupRemaining == 0 {
+			logDebugf("Warm-up complete: suppressed %d samples", nm.warmup)
+		}
+		return false, nil
 	}
 
-	if *format != "json" && *format != "table" {
-		log.Fatal("Invalid output format. Allowed values: json, table")
+	if implausible {
+		nm.session.setTotals(totalSent, totalRecv)
+	} else {
+		nm.session.addSample(sent, recv, totalSent, totalRecv, interval)
+	}
+	var quiet *bool
+	if nm.quietHours != nil {
+		in := nm.quietHours.window(tickStart) != nil
+		if in && !implausible {
+			nm.session.quietHours.add(sent, recv)
+		}
+		quiet = &in
+	}
+	if nm.hourly != nil && !implausible {
+		nm.hourly.add(sent, recv, faults, drops, interval)
+	}
+	if nm.plan != nil && !implausible {
+		nm.detectShaping(sent, recv, interval)
+	}
+
+	// With a report interval, which needs a single interface, samples are aggregated and only
+	// complete windows are emitted. The summary and shaping detection above still see every
+	// sample.
+	var window reportWindow
+	if nm.report != nil {
+		if implausible || !nm.report.add(sent, recv, interval) {
+			return false, nil
+		}
+		window = nm.report.take()
+		deltas[0].Sent, deltas[0].Recv, deltas[0].Interval = window.sent, window.recv, window.elapsed
 	}
 
-	monitor := NewNetworkMonitor(*interfaceName, *refreshInterval, *precision, *format)
+	// Machine-wide figures are taken once per tick and carried by each of its samples.
+	var softnet *Softnet
+	if nm.softnet != nil {
+		softnet = nm.sampleSoftnet()
+	}
+	var probes []ProbeResult
+	if nm.prober != nil {
+		probes = nm.prober.take(nm.precision)
+	}
+	var self *SelfStats
+	if nm.selfStats != nil {
+		self = nm.selfStats.collect(time.Since(tickStart))
+	}
+
+	samples := make([]NetStats, 0, len(deltas)+len(nm.groups))
+	for i, d := range deltas {
+		stats := NetStats{
+			Stats:       nm.units.Stats(d, nm.precision),
+			Timestamp:   Timestamp(tickStart),
+			Implausible: !plausible[i],
+			QuietHours:  quiet,
+			Softnet:     softnet,
+			Probes:      probes,
+			Monitor:     self,
+			raw:         newRawFigures(d.Sent, d.Recv, d.Interval, d.TotalSent, d.TotalRecv),
+		}
+		if nm.intervals != nil {
+			stats.Interval = netstats.Round(d.Interval, nm.precision)
+		}
+		if window.samples > 0 {
+			speed := func(bps float64) *Speed {
+				s := nm.units.Speed(uint64(bps), 1, nm.precision)
+				return &s
+			}
+			stats.SentMin, stats.SentMax = speed(window.minSent), speed(window.maxSent)
+			stats.RecvMin, stats.RecvMax = speed(window.minRecv), speed(window.maxRecv)
+		}
+		if nm.showMeta {
+			stats.Meta = nm.meta[d.Interface]
+		}
+		if nm.counters {
+			stats.Counters = newCounters(d.Cur, tickStart)
+			if !nm.several() {
+				stats.Counters.Unavailable = nm.capabilities.unavailableFields()
+			}
+		}
+		if nm.qdiscEnabled {
+			stats.Qdisc = nm.qdisc[d.Interface]
+		}
+		if nm.baseline != nil && plausible[i] {
+			stats.Baseline = nm.baseline.add(d.Sent, d.Recv, d.Interval, tickStart, nm.precision)
+		}
+		if nm.plan != nil {
+			stats.Plan = nm.plan.usage(float64(d.Sent)/d.Interval, float64(d.Recv)/d.Interval, nm.precision)
+		}
+		if nm.shifts != nil && plausible[i] {
+			nm.trackShifts(&stats, tickStart)
+		}
+		samples = append(samples, stats)
+	}
+	for _, g := range nm.groupSamples(moved, interval, tickStart) {
+		g.QuietHours = quiet
+		if nm.shifts != nil {
+			nm.trackShifts(&g, tickStart)
+		}
+		samples = append(samples, g)
+	}
+	if nm.redact != nil {
+		for i := range samples {
+			nm.redact.stats(&samples[i])
+		}
+	}
+
+	nm.mu.Lock()
+	if nm.several() {
+		nm.tick = samples
+	} else {
+		nm.stats = samples[0]
+	}
+	for _, s := range samples {
+		if len(nm.recent) == crashRecentSamples {
+			nm.recent = append(nm.recent[:0], nm.recent[1:]...)
+		}
+		nm.recent = append(nm.recent, s)
+	}
+	nm.mu.Unlock()
+	if nm.failover != nil {
+		nm.observeFailover(moved, interval, tickStart)
+	}
+	var pair *PairStats
+	if nm.pair[0] != "" {
+		pair = nm.pairRecord(samples, moved, tickStart)
+	}
+
+	// Idle ticks still count towards totals and the summary above, but are only accounted
+	// for in the traffic-resumed event that ends the quiet period. Samples withheld while
+	// paused or idle still reach the sinks, without a record ID.
+	emit := !nm.paused && !(nm.suppressZero && nm.suppressSample(sent, recv, interval, tickStart))
+	if emit && pair == nil {
+		for i := range samples {
+			samples[i].recordID = nm.nextID()
+		}
+	}
+	nm.sendSinks(samples)
+	if err := nm.checkSinkBudget(); err != nil {
+		return true, err
+	}
+	if !emit {
+		return false, nil
+	}
+
+	records := make([]any, 0, len(samples))
+	if pair != nil {
+		pair.recordID = nm.nextID()
+		nm.lastSampleSeq = pair.Seq
+		records = append(records, *pair)
+	} else {
+		for _, s := range samples {
+			nm.lastSampleSeq = s.Seq
+			records = append(records, sampleRecord(s, nm.countersOnly))
+		}
+	}
+	nm.lastSampleAt = tickStart
 
+	switch {
+	case nm.batch != nil:
+		for _, record := range records {
+			if nm.batch.add(record, tickStart) {
+				if err = nm.flushBatch(); err != nil {
+					break
+				}
+			}
+		}
+	case pair != nil:
+		err = nm.out.writeSample(func(w io.Writer) {
+			nm.formatter.record(w, *pair, func(w io.Writer) { printPairTable(w, *pair, nm.precision, nm.decimalComma) })
+		})
+	case nm.intervals != nil && nm.format == "table":
+		err = nm.writeSamples(nm.agedRows(samples, tickStart))
+	default:
+		err = nm.writeSamples(samples)
+	}
+	if err != nil {
+		return true, outputError(err)
+	}
+
+	logDebugf("Tick: counters read in %v, sample processed in %v", readDuration, time.Since(tickStart))
+	if pair != nil {
+		nm.checkAsymmetry(*pair, tickStart)
+	}
+	return nm.countReached(), nil
+}
+
+// resetTotals restarts the totals of every interface and group from zero.
+func (nm *NetworkMonitor) resetTotals() {
+	nm.sampler.ResetTotals()
+	for _, g := range nm.groups {
+		g.totalSent, g.totalRecv = 0, 0
+	}
+}
+
+// subcommand describes a named mode of operation selected by the first argument.
+type subcommand struct {
+	Name    string                    // Name used on the command line
+	Summary string                    // One-line description
+	run     func(args []string) error // Implementation receiving the remaining arguments
+}
+
+// subcommandList returns the available subcommands in the order they are documented.
+func subcommandList() []subcommand {
+	return []subcommand{
+		{Name: "monitor", Summary: "Monitor a network interface (default)", run: runMonitor},
+		{Name: "list", Summary: "List network interfaces", run: runList},
+		{Name: "config", Summary: "Print the effective monitor configuration", run: runConfig},
+		{Name: "completion", Summary: "Print a shell completion script (bash, zsh, fish)", run: runCompletion},
+		{Name: "bench", Summary: "Measure the monitor's own per-sample overhead", run: runBench},
+		{Name: "migrate", Summary: "Convert recorded samples between schema versions", run: runMigrate},
+		{Name: "compare", Summary: "Compare saved session summaries or recordings", run: runCompare},
+		{Name: "report", Summary: "Roll hourly summaries up into daily or monthly traffic", run: runReport},
+		{Name: "selftest", Summary: "Check the counter source, output formats, state files and sinks", run: runSelftest},
+		{Name: "self-update", Summary: "Update to the latest release", run: runSelfUpdate},
+	}
+}
+
+// findSubcommand returns the subcommand with the given name.
+func findSubcommand(name string) (subcommand, bool) {
+	for _, cmd := range subcommandList() {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return subcommand{}, false
+}
+
+// unknownSubcommand returns the startup error for a first argument naming no subcommand,
+// listing those of subcommandList.
+func unknownSubcommand(name string) *startupError {
+	var names []string
+	for _, cmd := range subcommandList() {
+		names = append(names, cmd.Name)
+	}
+	return &startupError{
+		Code:     "unknown_subcommand",
+		Message:  fmt.Sprintf("Unknown subcommand %q. Available subcommands: %s", name, strings.Join(names, ", ")),
+		exitCode: exitUsage,
+	}
+}
+
+// setupMonitor parses and validates the monitor flags and resolves the interface, returning
+// a monitor ready to start. Parsed settings are stored in cfg even when validation fails.
+func setupMonitor(cfg *monitorConfig, args []string) (*NetworkMonitor, error) {
+	fs := newMonitorFlagSet("monitor", cfg)
+	if err := parseFlags(fs, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, newStartupError(errCodeInvalidFlag, exitUsage, err)
+	}
+	if err := applyProfile(fs, cfg.Profile); err != nil {
+		return nil, newStartupError(errCodeInvalidValue, exitUsage, err)
+	}
+
+	if cfg.PrintDefault {
+		name, err := detectDefaultInterface()
+		if err != nil {
+			return nil, newStartupError(errCodeInterfaceNotFound, exitInterfaceNotFound, err)
+		}
+		fmt.Println(name)
+		return nil, exitStatus(exitOK)
+	}
+
+	if cfg.FailoverWatch != "" {
+		if cfg.Interface != "" {
+			return nil, newStartupError(errCodeInvalidValue, exitUsage, errors.New("-failover-watch replaces -i; leave -i out"))
+		}
+		primary, backup, err := parseFailoverWatch(cfg.FailoverWatch)
+		if err != nil {
+			return nil, newStartupError(errCodeInvalidValue, exitUsage, err)
+		}
+		cfg.Interface = primary + "," + backup
+	}
+	if cfg.Interface == "" && len(cfg.Groups) > 0 {
+		if groups, err := parseGroups(cfg.Groups, cfg.GroupOverlap); err == nil {
+			cfg.Interface = strings.Join(groupMembers(groups), ",")
+		}
+	}
+	if cfg.Interface == "" && cfg.Pair == "" && cfg.MatchRegex == "" && isLiveSourceSpec(cfg.Source) {
+		if name, err := detectDefaultInterface(); err == nil {
+			logInfof("No -i given, monitoring %s, which carries the default route", name)
+			cfg.Interface = name
+		}
+	}
+	if cfg.Interface == "" && cfg.Pair == "" && cfg.MatchRegex == "" && isLiveSourceSpec(cfg.Source) {
+		fs.Usage()
+		fmt.Fprint(os.Stderr, "\n")
+		return nil, &startupError{
+			Code: errCodeMissingInterface,
+			Message: "Error: the -i (interface) flag is required.\n" +
+				"Usage: ./zag-netStats -i <interface_name> -t <interval> -p <precision> -f <format>",
+			exitCode: exitUsage,
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, newStartupError(errCodeInvalidValue, exitUsage, err)
+	}
+	timestampFormat, _ = parseTimeFormat(cfg.TimeFormat)
+	timestampFormat.utc = cfg.UTC
+	minLogLevel, _ = parseLogLevel(cfg.LogLevel)
+
+	source, err := parseCounterSource(cfg.Source, cfg.Interval)
+	if err != nil {
+		return nil, newStartupError(errCodeInvalidValue, exitUsage, err)
+	}
+	if r, ok := source.(*replaySource); ok {
+		cfg.Interval = r.interval()
+	}
+	if cfg.Interface == aggregateName {
+		lister, ok := source.(counterLister)
+		if !ok {
+			return nil, newStartupError(errCodeInvalidValue, exitUsage, errors.New("Monitoring the total of all interfaces needs a source that can list them, such as the kernel"))
+		}
+		agg := &aggregateSource{lister: lister, includeLoopback: cfg.IncludeLoopback, loopback: make(loopbackCache)}
+		if cfg.Exclude != "" {
+			agg.exclude, _ = parseInterfacePattern(cfg.Exclude, "")
+		}
+		source = agg
+	}
+	if cfg.RecordRaw != "" {
+		rec, err := newRecordingSource(source, cfg.RecordRaw)
+		if err != nil {
+			return nil, newStartupError(errCodeInvalidValue, exitUsage, err)
+		}
+		source = rec
+	}
+	if cfg.Pair != "" {
+		pair, _ := parsePair(cfg.Pair)
+		for _, name := range pair {
+			if _, err := source.counters(name); err != nil {
+				return nil, interfaceError(err, name)
+			}
+		}
+		nm := NewNetworkMonitor(interfaceSelector{kind: selectorName, value: cfg.Pair}, cfg.Pair, source, *cfg)
+		nm.pair = pair
+		return nm, nil
+	}
+
+	if cfg.Interface == "all" || cfg.MatchRegex != "" || isInterfacePattern(cfg.Interface) {
+		lister, ok := source.(counterLister)
+		if !ok {
+			return nil, newStartupError(errCodeInvalidValue, exitUsage, errors.New("Monitoring all interfaces or a pattern needs a source that can list them, such as the kernel"))
+		}
+		stats, err := lister.allCounters()
+		if err != nil {
+			return nil, newStartupError(errCodeInvalidValue, exitUsage, err)
+		}
+		name := cfg.Interface
+		if cfg.MatchRegex != "" {
+			name = cfg.MatchRegex
+		}
+		sel := interfaceSelector{kind: selectorName, value: name}
+		nm := NewNetworkMonitor(sel, name, source, *cfg)
+		nm.allInterfaces = true
+		if cfg.Interface != "all" {
+			nm.match, _ = parseInterfacePattern(cfg.Interface, cfg.MatchRegex)
+		}
+		for _, io := range stats {
+			if (!nm.skipLoopback || !nm.loopback.is(io.Name)) && (nm.match == nil || nm.match(io.Name)) && !nm.excluded(io.Name) {
+				nm.multi = append(nm.multi, io.Name)
+			}
+		}
+		if len(nm.multi) == 0 {
+			logWarnf("No interface matches %s yet, waiting for one to appear", name)
+		}
+		nm.groups, _ = parseGroups(cfg.Groups, cfg.GroupOverlap)
+		return nm, nil
+	}
+
+	if strings.Contains(cfg.Interface, ",") {
+		sels, every, _ := parseInterfaceList(cfg.Interface)
+		names := make([]string, len(sels))
+		for i, sel := range sels {
+			name, err := resolveInterface(sel)
+			if err == nil {
+				_, err = source.counters(name)
+			}
+			if err != nil {
+				return nil, interfaceError(err, sel.nameValue())
+			}
+			names[i] = name
+		}
+		sel := interfaceSelector{kind: selectorName, value: cfg.Interface}
+		nm := NewNetworkMonitor(sel, strings.Join(names, ","), source, *cfg)
+		nm.multi = names
+		nm.setIntervals(every)
+		if cfg.FailoverWatch != "" {
+			nm.failover = newFailoverWatch(names[0], names[1], float64(cfg.FailoverIdle), float64(cfg.FailoverActive))
+		}
+		nm.groups, _ = parseGroups(cfg.Groups, cfg.GroupOverlap)
+		for _, m := range groupMembers(nm.groups) {
+			if !slices.Contains(names, m) {
+				return nil, newStartupError(errCodeInvalidValue, exitUsage, fmt.Errorf("Group member %s is not among the monitored interfaces", m))
+			}
+		}
+		return nm, nil
+	}
+
+	if len(cfg.Groups) > 0 {
+		return nil, newStartupError(errCodeInvalidValue, exitUsage, errors.New("-group needs several interfaces: a -i list, a pattern or all"))
+	}
+
+	if !isKernelSource(source) {
+		if cfg.Interface == "" {
+			cfg.Interface = "synthetic"
+			if r, ok := source.(*replaySource); ok {
+				cfg.Interface = r.records[0].Counters.Name
+			}
+		}
+		sel := interfaceSelector{kind: selectorName, value: cfg.Interface}
+		nm := NewNetworkMonitor(sel, cfg.Interface, source, *cfg)
+		if err := nm.loadBaseline(cfg.BaselineFile); err != nil {
+			return nil, err
+		}
+		return nm, nil
+	}
+
+	selector, err := parseInterfaceSelector(cfg.Interface)
+	if err != nil {
+		return nil, newStartupError(errCodeInvalidValue, exitUsage, err)
+	}
+
+	ifaceName, err := resolveInterface(selector)
+	if err == nil {
+		_, err = netstats.ReadCounters(ifaceName)
+	}
+	if err != nil {
+		return nil, interfaceError(err, selector.nameValue())
+	}
+
+	nm := NewNetworkMonitor(selector, ifaceName, source, *cfg)
+	if err := nm.loadBaseline(cfg.BaselineFile); err != nil {
+		return nil, err
+	}
+	return nm, nil
+}
+
+// runMonitor implements the monitor subcommand, the default when no subcommand is given.
+func runMonitor(args []string) error {
+	var cfg monitorConfig
+	monitor, err := setupMonitor(&cfg, args)
+	if err != nil {
+		reportStartupError(cfg.Format, err)
+		return err
+	}
+	locks, err := lockStateFiles(&cfg)
+	if err != nil {
+		err = newStartupError(errCodeLocked, exitFailure, err)
+		reportStartupError(cfg.Format, err)
+		return err
+	}
+	defer func() {
+		for _, l := range locks {
+			l.release()
+		}
+	}()
+
+	if cfg.Redact {
+		r, err := newRedactor(cfg.RedactMap, monitor.monitoredInterfaces())
+		if err != nil {
+			err = newStartupError(errCodeInvalidValue, exitUsage, err)
+			reportStartupError(cfg.Format, err)
+			return err
+		}
+		monitor.redact, monitor.out.redact = r, r
+	}
+	if cfg.StrictSchema {
+		schema, err := compileRecordSchema()
+		if err != nil {
+			err = newStartupError(errCodeInvalidValue, exitFailure, err)
+			reportStartupError(cfg.Format, err)
+			return err
+		}
+		monitor.out.schema = schema
+	}
+	monitor.features = checkPrivileges(cfg, readPrivileges())
+	if len(monitor.features) > 0 {
+		printFeatureChecks(os.Stderr, monitor.features)
+	}
+	if cfg.Strict {
+		if err := featuresUnavailable(monitor.features); err != nil {
+			err = newStartupError(errCodePermission, exitPermission, fmt.Errorf("refusing to start under -strict: %v", err))
+			reportStartupError(cfg.Format, err)
+			return err
+		}
+	}
+	if cfg.Realtime || cfg.Nice != 0 || cfg.PinCPU >= 0 {
+		sched := applyScheduling(cfg.Realtime, cfg.Nice, cfg.PinCPU)
+		if monitor.selfStats != nil {
+			monitor.selfStats.sched = sched
+		}
+	}
+	setCrashState(&cfg, monitor)
 	signal.Notify(monitor.interrupt, os.Interrupt, syscall.SIGTERM)
+	if cfg.Output != "" {
+		f, size, err := openOutputFile(cfg.Output, cfg.Append, cfg.Sync)
+		if err != nil {
+			err = newStartupError(errCodeOutput, exitOutput, err)
+			reportStartupError(cfg.Format, err)
+			return err
+		}
+		var file io.WriteCloser = f
+		if cfg.MaxFileSize > 0 {
+			r := newRotatingFile(f, cfg.Output, size, int64(cfg.MaxFileSize), cfg.MaxFiles, cfg.Sync)
+			if cfg.Format == "csv" {
+				var b bytes.Buffer
+				printCSV(&b, monitor.csvDelimiter, csvHeader)
+				r.header = b.Bytes()
+			}
+			file = r
+		}
+		defer file.Close()
+		monitor.out.w = file
+		if cfg.Tee {
+			monitor.out.w = io.MultiWriter(os.Stdout, file)
+		}
+		monitor.continuesFile = size > 0
+	}
+	if cfg.InfluxAddr != "" {
+		conn, err := dialInflux(cfg.InfluxAddr)
+		if err != nil {
+			err = newStartupError(errCodeConnect, exitFailure, err)
+			reportStartupError(cfg.Format, err)
+			return err
+		}
+		defer conn.Close()
+		monitor.out.w = conn
+	}
+	if cfg.TeeFile != "" {
+		monitor.out.w = newDebugTee(monitor.out.w, cfg.TeeFile)
+		if c, ok := monitor.out.w.(io.Closer); ok {
+			defer c.Close()
+		}
+	}
+	if cfg.Format == "tui" {
+		tui, err := startTUI(monitor)
+		if err != nil {
+			err = newStartupError(errCodeInvalidValue, exitUsage, err)
+			reportStartupError(cfg.Format, err)
+			return err
+		}
+		defer tui.close() // Also on early returns; closing again is harmless
+		monitor.tui = tui
+		monitor.addSink("tui", "", tui)
+		monitor.out.w, monitor.controlOut = tui, tui
+	}
+	if cfg.OutputQueue > 0 {
+		monitor.out.startQueue(cfg.OutputQueue)
+		if monitor.selfStats != nil {
+			monitor.selfStats.queue = monitor.out.queue
+		}
+	}
+	if cfg.Listen != "" || cfg.AnnotateFIFO != "" {
+		monitor.annotator = newAnnotator()
+	}
+	if cfg.AnnotateFIFO != "" {
+		if err := monitor.annotator.startAnnotationFIFO(cfg.AnnotateFIFO); err != nil {
+			err = newStartupError(errCodeInvalidValue, exitFailure, err)
+			reportStartupError(cfg.Format, err)
+			return err
+		}
+	}
+	if cfg.Listen != "" {
+		exp, err := startExporter(cfg.listenAddrs(), monitor)
+		if err != nil {
+			err = newStartupError(errCodeListen, exitFailure, err)
+			reportStartupError(cfg.Format, err)
+			return err
+		}
+		defer exp.close()
+	}
+	if cfg.Graphite != "" {
+		monitor.addSink("graphite", cfg.Graphite, newGraphiteSink(cfg.Graphite, cfg.GraphitePrefix))
+	}
+	if cfg.Statsd != "" {
+		sink, err := newStatsdSink(cfg.Statsd, cfg.StatsdTags)
+		if err != nil {
+			err = newStartupError(errCodeConnect, exitFailure, err)
+			reportStartupError(cfg.Format, err)
+			return err
+		}
+		defer sink.close()
+		monitor.addSink("statsd", cfg.Statsd, sink)
+	}
+	specs, _ := parseSinkSpecs(cfg.Sinks) // Validated with the configuration
+	for _, spec := range specs {
+		sink, closeSink, err := startSink(spec, cfg)
+		if err != nil && spec.kind == "file" {
+			err = newStartupError(errCodeOutput, exitOutput, err)
+			reportStartupError(cfg.Format, err)
+			return err
+		} else if err != nil {
+			err = newStartupError(errCodeConnect, exitFailure, err)
+			reportStartupError(cfg.Format, err)
+			return err
+		}
+		defer closeSink()
+		monitor.addFilteredSink(spec.kind, redactedURL(spec.target), spec.types, sink)
+	}
 
-	if err := monitor.collectStats(); err != nil {
-		log.Fatalf("Network monitoring error: %v", err)
+	err = monitor.collectStats()
+	if c, ok := monitor.source.(io.Closer); ok {
+		c.Close()
+	}
+	if cerr := monitor.out.closeArray(); cerr != nil && err == nil {
+		err = outputError(cerr)
+	}
+	if verr := monitor.out.schemaViolation(); verr != nil && err == nil {
+		err = outputError(verr)
+	}
+	if monitor.tui != nil {
+		monitor.tui.close()
 	}
+	if monitor.failover != nil {
+		monitor.failover.logFailovers()
+	}
+	if q := monitor.out.queue; q != nil && q.droppedRecords() > 0 {
+		logWarnf("%d samples were not displayed because the output could not keep up; totals include them", q.droppedRecords())
+	}
+	// A summary is written even when the initial read failed, with no samples and the error.
+	if cfg.SummaryJSON != "" || cfg.ExitSummary || cfg.PushGateway != "" || monitor.sinksReceive(typeSummary) {
+		summary := monitor.session.summary(monitor.interfaceName, monitor.clock.now(), cfg.Precision, err)
+		summary.UnavailableCounters = monitor.capabilities.missing
+		if monitor.failover != nil {
+			summary.Failovers = monitor.failover.cycles
+		}
+		if monitor.plan != nil {
+			summary.Plan = monitor.plan.summarize(summary, monitor.session.peakTotal, cfg.Precision)
+		}
+		if monitor.redact != nil {
+			monitor.redact.summary(&summary)
+		}
+		monitor.sendSinkRecord(summary)
+		if cfg.ExitSummary {
+			monitor.printExitSummary(os.Stderr, summary)
+		}
+		if cfg.SummaryJSON != "" {
+			if serr := monitor.out.schema.checkValue(summary); serr != nil {
+				logErrorf("Session summary not written: %v", serr)
+				if err == nil {
+					err = outputError(serr)
+				}
+			} else if werr := writeSummaryJSON(cfg.SummaryJSON, summary); werr != nil {
+				logErrorf("Error writing session summary: %v", werr)
+			}
+		}
+		if cfg.PushGateway != "" {
+			// Both were validated in setupMonitor.
+			base, _ := parsePushGateway(cfg.PushGateway)
+			grouping, _ := parsePushGrouping(cfg.PushGrouping)
+			push := monitor.sinkRegistry.register("pushgateway", base.Redacted())
+			push.attempt()
+			if perr := pushToGateway(base, cfg.PushJob, grouping, summary, monitor.lastCounters); perr != nil {
+				push.drop(perr)
+				if cfg.StrictPush && err == nil {
+					err = outputError(fmt.Errorf("error pushing to Pushgateway: %w", perr))
+				} else {
+					logErrorf("Error pushing to Pushgateway: %v", perr)
+				}
+			} else {
+				push.delivered()
+			}
+		}
+	}
+	monitor.sinkRegistry.logHealth()
+
+	if err != nil {
+		return fmt.Errorf("Network monitoring error: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	defer handlePanic()
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	// stdout carries only formatted samples and events in the selected format; logs, warnings
+	// and usage text always go to stderr.
+	log.SetOutput(os.Stderr)
+
+	// Arguments that look like flags (or no arguments at all) select the monitor subcommand,
+	// so invocations such as "zag-netStats -i eth0" keep working unchanged.
+	args := os.Args[1:]
+	run := runMonitor
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, ok := findSubcommand(args[0])
+		if !ok {
+			exit(unknownSubcommand(args[0]))
+		}
+		run, args = cmd.run, args[1:]
+	}
+
+	exit(run(args))
 }
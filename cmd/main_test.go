@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStdoutCarriesOnlyRecords runs the binary, played by the test binary calling main, on an
+// interface that does not exist and checks that stdout holds nothing but JSON lines.
+func TestStdoutCarriesOnlyRecords(t *testing.T) {
+	if args := os.Getenv("ZNS_TEST_MAIN_ARGS"); args != "" {
+		os.Args = append([]string{"zns"}, strings.Fields(args)...)
+		main()
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStdoutCarriesOnlyRecords$")
+	cmd.Env = append(os.Environ(), "ZNS_TEST_MAIN_ARGS=-i nosuchif0 -f json -c 2")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatal("monitoring a missing interface succeeded")
+	}
+	if stderr.Len() == 0 {
+		t.Error("no error was logged to stderr")
+	}
+	sc := bufio.NewScanner(&stdout)
+	lines := 0
+	for sc.Scan() {
+		lines++
+		if !json.Valid(sc.Bytes()) {
+			t.Errorf("stdout carries a line that is not JSON: %q", sc.Text())
+		}
+	}
+	if lines == 0 {
+		t.Error("stdout lacks the JSON error record")
+	}
+}
+
+func TestSpeedsUseMeasuredTime(t *testing.T) {
+	src := newFakeSource()
+	src.set("eth0", 0, 0)
+	nm, buf := newTestMonitor(t, "eth0", measuredSource{src}, "-f", "json", "-t", "500ms")
+	clk := &fakeClock{mono: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	nm.clock = clk
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each tick moves the given bytes after the given time, whatever the nominal interval.
+	ticks := []struct {
+		after time.Duration
+		bytes uint64
+		want  float64 // Sent speed in B/s
+	}{
+		{500 * time.Millisecond, 512, 1024}, // On time: 512 bytes in 500ms
+		{750 * time.Millisecond, 768, 1024}, // A late tick covers the time it really waited
+		{10 * time.Second, 10240, 1024},     // A suspend or stall is not divided by the interval
+		{100 * time.Millisecond, 50, 500},
+	}
+	var sent uint64
+	for _, tk := range ticks {
+		clk.advance(tk.after)
+		sent += tk.bytes
+		src.set("eth0", sent, 0)
+		tick(t, nm, clk.now())
+	}
+
+	samples := decodeSamples(t, buf)
+	if len(samples) != len(ticks) {
+		t.Fatalf("got %d samples, want %d: %s", len(samples), len(ticks), buf)
+	}
+	for i, s := range samples {
+		bps := s.SentSpeed.Value
+		if s.SentSpeed.Unit == "KiB/s" {
+			bps *= 1024
+		}
+		if bps != ticks[i].want {
+			t.Errorf("sample %d sent %v %s, want %v B/s", i, s.SentSpeed.Value, s.SentSpeed.Unit, ticks[i].want)
+		}
+	}
+}
+
+func TestUnknownSubcommandListsTheTable(t *testing.T) {
+	msg := unknownSubcommand("lsit").Message
+	for _, cmd := range subcommandList() {
+		if !strings.Contains(msg, cmd.Name) {
+			t.Errorf("message %q does not offer %s", msg, cmd.Name)
+		}
+	}
+}
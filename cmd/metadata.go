@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// metadataRefreshInterval is how often interface metadata (MTU, link settings, addresses) is re-read.
+const metadataRefreshInterval = 10 * time.Second
+
+// InterfaceMeta describes the slowly-changing properties of the monitored interface.
+type InterfaceMeta struct {
+	MTU          int      `json:"mtu"`                    // Maximum transmission unit in bytes
+	SpeedMbps    int      `json:"speedMbps,omitempty"`    // Negotiated link speed, 0 when unknown
+	Duplex       string   `json:"duplex,omitempty"`       // Duplex mode ("full", "half"), empty when unknown
+	HardwareAddr string   `json:"hardwareAddr,omitempty"` // Hardware (MAC) address
+	Addresses    []string `json:"addresses"`              // Assigned addresses in CIDR notation, sorted
+	Gateway      string   `json:"gateway,omitempty"`      // System default gateway address
+	DefaultRoute bool     `json:"defaultRoute"`           // Whether the default route points at this interface
+}
+
+// configChange records a single link configuration value that changed between two reads.
+type configChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// addressChange lists the addresses that appeared on or disappeared from an interface.
+type addressChange struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// readInterfaceMeta retrieves the current metadata of the named interface.
+func readInterfaceMeta(ifaceName string) (InterfaceMeta, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return InterfaceMeta{}, err
+	}
+	return findInterfaceMeta(ifaces, ifaceName)
+}
+
+// findInterfaceMeta returns the metadata of the named interface among ifaces.
+func findInterfaceMeta(ifaces []net.InterfaceStat, ifaceName string) (InterfaceMeta, error) {
+	for _, iface := range ifaces {
+		if iface.Name == ifaceName {
+			addrs := make([]string, 0, len(iface.Addrs))
+			for _, a := range iface.Addrs {
+				addrs = append(addrs, a.Addr)
+			}
+			slices.Sort(addrs)
+
+			speed, duplex := readLinkSpeedDuplex(ifaceName)
+			return InterfaceMeta{
+				MTU:          iface.MTU,
+				SpeedMbps:    speed,
+				Duplex:       duplex,
+				HardwareAddr: iface.HardwareAddr,
+				Addresses:    addrs,
+			}, nil
+		}
+	}
+
+	return InterfaceMeta{}, fmt.Errorf("%w: %s", errInterfaceNotFound, ifaceName)
+}
+
+// diffLinkConfig lists the link configuration values that differ between two metadata reads.
+// Values that became unknown are not reported, since drivers report them intermittently.
+func diffLinkConfig(prev, cur InterfaceMeta) []configChange {
+	var changes []configChange
+
+	if prev.MTU != cur.MTU {
+		changes = append(changes, configChange{Field: "mtu", Old: prev.MTU, New: cur.MTU})
+	}
+	if prev.SpeedMbps != cur.SpeedMbps && cur.SpeedMbps > 0 {
+		changes = append(changes, configChange{Field: "speed", Old: prev.SpeedMbps, New: cur.SpeedMbps})
+	}
+	if prev.Duplex != cur.Duplex && cur.Duplex != "" {
+		changes = append(changes, configChange{Field: "duplex", Old: prev.Duplex, New: cur.Duplex})
+	}
+
+	return changes
+}
+
+// diffAddresses compares two sorted address lists.
+func diffAddresses(prev, cur []string) addressChange {
+	change := addressChange{Added: []string{}, Removed: []string{}}
+
+	for _, a := range cur {
+		if _, found := slices.BinarySearch(prev, a); !found {
+			change.Added = append(change.Added, a)
+		}
+	}
+	for _, a := range prev {
+		if _, found := slices.BinarySearch(cur, a); !found {
+			change.Removed = append(change.Removed, a)
+		}
+	}
+
+	return change
+}
+
+// describeConfigChanges renders configuration changes as a short human-readable message.
+func describeConfigChanges(changes []configChange) string {
+	parts := make([]string, len(changes))
+	for i, c := range changes {
+		parts[i] = fmt.Sprintf("%s changed from %v to %v", c.Field, c.Old, c.New)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeAddressChange renders an address change as a short human-readable message.
+func describeAddressChange(change addressChange) string {
+	var parts []string
+	if len(change.Added) > 0 {
+		parts = append(parts, "added "+strings.Join(change.Added, ", "))
+	}
+	if len(change.Removed) > 0 {
+		parts = append(parts, "removed "+strings.Join(change.Removed, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// describeRouteChange renders a default route move as a short human-readable message.
+func describeRouteChange(prev, cur defaultRoute) string {
+	switch {
+	case prev.Interface == "":
+		return "default route added via " + cur.Interface
+	case cur.Interface == "":
+		return "default route removed from " + prev.Interface
+	default:
+		return fmt.Sprintf("default route moved from %s to %s", prev.Interface, cur.Interface)
+	}
+}
+
+// refreshMetadata re-reads the metadata of the monitored interfaces, and their qdisc statistics
+// if enabled, and emits events for any changes. Artificial counter sources have no metadata, so
+// it does nothing for them.
+func (nm *NetworkMonitor) refreshMetadata() {
+	if !isKernelSource(nm.source) {
+		return
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		logWarnf("Error reading interface metadata: %v", err)
+		return
+	}
+
+	route, routeErr := readDefaultRoute()
+	if routeErr != nil {
+		if !nm.routeErrLogged {
+			logWarnf("Error reading default route: %v", routeErr)
+			nm.routeErrLogged = true
+		}
+	} else {
+		if nm.route != nil && nm.route.Interface != route.Interface {
+			nm.emitEvent(Event{
+				Type:      eventRouteChange,
+				Timestamp: Timestamp(time.Now()),
+				Interface: nm.interfaceName,
+				Message:   describeRouteChange(*nm.route, route),
+				Details:   map[string]string{"old": nm.route.Interface, "new": route.Interface},
+			})
+		}
+		nm.route = &route
+	}
+
+	for _, name := range nm.currentInterfaces() {
+		if nm.qdiscEnabled {
+			nm.refreshQdisc(name)
+		}
+		meta, err := findInterfaceMeta(ifaces, name)
+		if err != nil {
+			logWarnf("Error reading interface metadata: %v", err)
+			continue
+		}
+		if routeErr == nil {
+			meta.Gateway = route.Gateway
+			meta.DefaultRoute = route.Interface == name
+		}
+		nm.updateMeta(name, meta)
+	}
+}
+
+// updateMeta stores the latest metadata of an interface and emits events for what changed
+// since the previous read.
+func (nm *NetworkMonitor) updateMeta(name string, meta InterfaceMeta) {
+	if prev := nm.meta[name]; prev != nil {
+		now := Timestamp(time.Now())
+
+		if changes := diffLinkConfig(*prev, meta); len(changes) > 0 {
+			nm.session.configChanges++
+			nm.emitEvent(Event{
+				Type:      eventConfigChange,
+				Timestamp: now,
+				Interface: name,
+				Message:   describeConfigChanges(changes),
+				Details:   changes,
+			})
+		}
+
+		if change := diffAddresses(prev.Addresses, meta.Addresses); len(change.Added)+len(change.Removed) > 0 {
+			nm.emitEvent(Event{
+				Type:      eventAddressChange,
+				Timestamp: now,
+				Interface: name,
+				Message:   describeAddressChange(change),
+				Details:   change,
+			})
+		}
+	}
+
+	nm.meta[name] = &meta
+}
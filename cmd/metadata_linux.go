@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readLinkSpeedDuplex reads the negotiated link speed (Mbit/s) and duplex mode from sysfs.
+// Interfaces without a physical link (loopback, tunnels) report 0 and an empty duplex.
+func readLinkSpeedDuplex(ifaceName string) (int, string) {
+	speed := 0
+	if data, err := os.ReadFile(filepath.Join(sysClassNet, ifaceName, "speed")); err == nil {
+		if v, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && v > 0 {
+			speed = v
+		}
+	}
+
+	duplex := ""
+	if data, err := os.ReadFile(filepath.Join(sysClassNet, ifaceName, "duplex")); err == nil {
+		if v := strings.TrimSpace(string(data)); v != "unknown" {
+			duplex = v
+		}
+	}
+
+	return speed, duplex
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// readLinkSpeedDuplex reports link speed and duplex as unknown on platforms without sysfs.
+func readLinkSpeedDuplex(ifaceName string) (int, string) {
+	return 0, ""
+}
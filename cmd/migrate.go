@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// jsonField is one member of a JSON object, kept in its original order.
+type jsonField struct {
+	name  string
+	value json.RawMessage
+}
+
+// migration converts records between two schema versions.
+type migration struct {
+	steps     []schemaRevision // Revisions crossed, in the order they are applied
+	upgrade   bool
+	to        string
+	seq       uint64 // Sequence number given to the current record, for fields added in v2
+	sessionID string // Session ID given to every record of the run
+}
+
+// runMigrate implements the migrate subcommand. It streams recorded NDJSON samples from the
+// named files, or stdin, to stdout, converting each record from one schema version to another.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	from := fs.String("from", "", "Schema version of the input records, e.g. v1 (required)")
+	to := fs.String("to", schemaVersion, "Schema version to convert to")
+	onError := fs.String("on-error", "abort", "What to do with malformed lines: abort, or skip them with a warning")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *from == "" {
+		return errors.New("The -from schema version is required")
+	}
+	if *onError != "abort" && *onError != "skip" {
+		return fmt.Errorf("Invalid -on-error value %q, expected abort or skip", *onError)
+	}
+	m, err := newMigration(*from, *to)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		if err := m.migrateFile(out, name, *onError == "skip"); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// newMigration prepares the conversion of records from one schema version to another.
+func newMigration(from, to string) (*migration, error) {
+	i, err := schemaIndex(from)
+	if err != nil {
+		return nil, err
+	}
+	j, err := schemaIndex(to)
+	if err != nil {
+		return nil, err
+	}
+	m := &migration{upgrade: j >= i, to: to, sessionID: newSessionID()}
+	if m.upgrade {
+		m.steps = schemaRevisions[i+1 : j+1]
+	} else {
+		for k := i; k > j; k-- {
+			m.steps = append(m.steps, schemaRevisions[k])
+		}
+	}
+	return m, nil
+}
+
+// migrateFile converts the records of one file, "-" standing for stdin. Lines are read one at
+// a time, so files of any size can be migrated.
+func (m *migration) migrateFile(w io.Writer, name string, skip bool) error {
+	in := io.Reader(os.Stdin)
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	r := bufio.NewReader(in)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			converted, cerr := m.migrateLine(data)
+			switch {
+			case cerr == nil:
+				if _, err := w.Write(append(converted, '\n')); err != nil {
+					return err
+				}
+			case skip:
+				logWarnf("%s:%d: skipped: %v", name, line, cerr)
+			default:
+				return fmt.Errorf("%s:%d: %v", name, line, cerr)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// migrateLine converts one NDJSON line: a record, or an array of records as emitted for
+// several interfaces at once.
+func (m *migration) migrateLine(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var records []json.RawMessage
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for i, rec := range records {
+			converted, err := m.migrateRecord(rec)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(converted)
+		}
+		buf.WriteByte(']')
+		return buf.Bytes(), nil
+	}
+	return m.migrateRecord(data)
+}
+
+// migrateRecord applies every revision step to one record, keeping its field order.
+func (m *migration) migrateRecord(data []byte) ([]byte, error) {
+	fields, err := parseJSONObject(data)
+	if err != nil {
+		return nil, err
+	}
+	m.seq++
+	sample := hasField(fields, "sentSpeed")
+
+	for _, step := range m.steps {
+		added, removed := step.added, step.removed
+		if !m.upgrade {
+			added, removed = removed, added
+		}
+		for old, name := range step.renamed {
+			if !m.upgrade {
+				old, name = name, old
+			}
+			for i := range fields {
+				if fields[i].name == old {
+					fields[i].name = name
+				}
+			}
+		}
+		for _, f := range removed {
+			if f.samples && !sample {
+				continue
+			}
+			for i := 0; i < len(fields); i++ {
+				if fields[i].name == f.name {
+					fields = append(fields[:i], fields[i+1:]...)
+					i--
+				}
+			}
+		}
+		for _, f := range added {
+			if (!f.samples || sample) && !hasField(fields, f.name) {
+				fields = append(fields, jsonField{f.name, f.fill(m)})
+			}
+		}
+	}
+	for i := range fields {
+		if fields[i].name == "schemaVersion" {
+			fields[i].value = jsonString(m.to)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(jsonString(f.name))
+		buf.WriteByte(':')
+		buf.Write(f.value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// parseJSONObject splits a JSON object into its members, in order.
+func parseJSONObject(data []byte) ([]jsonField, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errors.New("invalid JSON: expected an object")
+	}
+	var fields []jsonField
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		fields = append(fields, jsonField{tok.(string), value})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	if dec.More() {
+		return nil, errors.New("invalid JSON: trailing data after the object")
+	}
+	return fields, nil
+}
+
+// hasField reports whether fields has a member with the given name.
+func hasField(fields []jsonField, name string) bool {
+	for _, f := range fields {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// parseInterfaceList splits a comma-separated -i value, such as "eth0,wlan0", into its
+// selectors. A single interface gives a list of one. An entry may end in its own interval, as
+// in "eth0:1s,vlan10:60s"; every holds it, or 0 for the global interval.
+func parseInterfaceList(s string) (sels []interfaceSelector, every []time.Duration, err error) {
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, nil, fmt.Errorf("Invalid interface list %q, expected names such as eth0,wlan0", s)
+		}
+		part, d := cutInterval(part)
+		sel, err := parseInterfaceSelector(part)
+		if err != nil {
+			return nil, nil, err
+		}
+		if seen[sel.String()] {
+			return nil, nil, fmt.Errorf("Interface %s is listed twice", sel)
+		}
+		if d != 0 && !validInterval(d) {
+			return nil, nil, fmt.Errorf("Interval %v of %s must be between %v and 1h", d, sel, netstats.MinInterval)
+		}
+		seen[sel.String()] = true
+		sels = append(sels, sel)
+		every = append(every, d)
+	}
+	return sels, every, nil
+}
+
+// cutInterval splits the interval off an entry of a -i list such as "eth0:1s". Entries
+// without one, including hardware addresses, are returned unchanged with 0.
+func cutInterval(s string) (string, time.Duration) {
+	i := strings.LastIndex(s, ":")
+	if i <= 0 {
+		return s, 0
+	}
+	d, err := time.ParseDuration(s[i+1:])
+	if err != nil || d == 0 {
+		return s, 0
+	}
+	return s[:i], d
+}
+
+// intervalStep returns the tick that serves every interval: their greatest common divisor.
+func intervalStep(global time.Duration, every []time.Duration) time.Duration {
+	step := global
+	for _, d := range every {
+		for d != 0 {
+			step, d = d, step%d
+		}
+	}
+	return step
+}
+
+// isInterfacePattern reports whether a -i value is a glob pattern, such as "eth0.*", rather
+// than interface names.
+func isInterfacePattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// parseInterfacePattern builds the matcher of interface names selected by a comma-separated
+// list of glob patterns or by a regular expression; a name matches if any of them does.
+func parseInterfacePattern(globs, expr string) (func(name string) bool, error) {
+	if expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid interface regex %q: %v", expr, err)
+		}
+		return re.MatchString, nil
+	}
+	patterns := strings.Split(globs, ",")
+	for i, p := range patterns {
+		patterns[i] = strings.TrimSpace(p)
+		if _, err := path.Match(patterns[i], ""); err != nil || patterns[i] == "" {
+			return nil, fmt.Errorf("Invalid interface pattern %q", p)
+		}
+	}
+	return func(name string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// excluded reports whether -exclude leaves the named interface out.
+func (nm *NetworkMonitor) excluded(name string) bool {
+	return nm.exclude != nil && nm.exclude(name)
+}
+
+// several reports whether more than one interface is monitored, which rules out the features
+// that follow a single interface.
+func (nm *NetworkMonitor) several() bool {
+	return nm.allInterfaces || len(nm.multi) > 0 || nm.pair[0] != ""
+}
+
+// currentInterfaces returns the interfaces monitored now: with -i all or a pattern those
+// present at the last tick, in name order, otherwise the configured ones.
+func (nm *NetworkMonitor) currentInterfaces() []string {
+	if !nm.allInterfaces {
+		return nm.monitoredInterfaces()
+	}
+	names := nm.sampler.Interfaces()
+	sort.Strings(names)
+	return names
+}
+
+// readTick returns the current readings of the monitored interfaces in output order, each named
+// after its interface. With -i all or a pattern these are whatever matching interfaces exist
+// now; with a list, interfaces that cannot be read are returned in failed instead, and err is
+// only set when none could. Both interfaces of -pair must be read.
+func (nm *NetworkMonitor) readTick() (readings []net.IOCountersStat, failed map[string]error, err error) {
+	switch {
+	case nm.allInterfaces:
+		all, err := nm.source.(counterLister).allCounters()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, io := range all {
+			if (nm.skipLoopback && nm.loopback.is(io.Name)) || (nm.match != nil && !nm.match(io.Name)) || nm.excluded(io.Name) || !nm.due(io.Name) {
+				continue
+			}
+			readings = append(readings, io)
+		}
+		return readings, nil, nil
+
+	case len(nm.multi) > 0:
+		for _, name := range nm.multi {
+			if !nm.due(name) {
+				continue
+			}
+			io, rerr := nm.source.counters(name)
+			if rerr != nil {
+				if failed == nil {
+					failed = make(map[string]error)
+				}
+				failed[name] = rerr
+				err = rerr
+				continue
+			}
+			io.Name = name
+			readings = append(readings, io)
+		}
+		if len(readings) > 0 {
+			err = nil
+		}
+		return readings, failed, err
+
+	case nm.pair[0] != "":
+		for _, name := range nm.pair {
+			io, err := nm.source.counters(name)
+			if err != nil {
+				return nil, nil, err
+			}
+			io.Name = name
+			readings = append(readings, io)
+		}
+		return readings, nil, nil
+	}
+
+	io, err := nm.readCounters()
+	if err != nil {
+		return nil, nil, err
+	}
+	io.Name = nm.interfaceName
+	return []net.IOCountersStat{io}, nil, nil
+}
+
+// trackPresence follows interfaces coming and going. An interface of a list that cannot be
+// read is skipped with a warning while the others carry on; when it returns, its totals
+// restart. With -i all or a pattern, which is re-evaluated each tick, new interfaces are picked
+// up with a fresh baseline and vanished ones drop out of the output.
+func (nm *NetworkMonitor) trackPresence(readings []net.IOCountersStat, failed map[string]error) {
+	for name, err := range failed {
+		nm.session.errors++
+		if !nm.absent[name] {
+			logWarnf("Error reading %s, monitoring the other interfaces: %v", name, err)
+			nm.absent[name] = true
+			nm.sampler.Forget(name)
+		}
+	}
+	seen := make(map[string]bool, len(readings))
+	for _, io := range readings {
+		seen[io.Name] = true
+		switch {
+		case nm.absent[io.Name]:
+			logInfof("Interface %s is back, its totals restart from zero", io.Name)
+			delete(nm.absent, io.Name)
+		case !nm.sampler.Known(io.Name):
+			logInfof("Interface %s appeared, monitoring it from now on", io.Name)
+		}
+	}
+	if nm.allInterfaces {
+		for _, name := range nm.sampler.Interfaces() {
+			if !seen[name] && nm.due(name) {
+				logInfof("Interface %s disappeared", name)
+				nm.sampler.Forget(name)
+			}
+		}
+	}
+}
+
+// setIntervals schedules the interfaces of the list on the intervals of its entries, 0 for the
+// global interval. Without any, every interface is read each tick.
+func (nm *NetworkMonitor) setIntervals(every []time.Duration) {
+	if !slices.ContainsFunc(every, func(d time.Duration) bool { return d != 0 }) {
+		return
+	}
+	nm.intervals = make(map[string]time.Duration, len(nm.multi))
+	for i, name := range nm.multi {
+		nm.intervals[name] = every[i]
+	}
+	nm.step = intervalStep(nm.refreshInterval, every)
+	nm.latest = make(map[string]NetStats, len(nm.multi))
+}
+
+// due reports whether the current tick reads the named interface. The initial readings cover
+// every interface. With -stagger, each interface belongs to one of the slots of the interval:
+// those of a list in turn, others by a hash of their name, so interfaces that come and go keep
+// their slot. With per-interface intervals, an interface is read every so many ticks.
+func (nm *NetworkMonitor) due(name string) bool {
+	switch {
+	case nm.tickNo == 0:
+		return true
+	case nm.staggerSlots > 0:
+		slot := (nm.tickNo - 1) % nm.staggerSlots
+		if i := slices.Index(nm.multi, name); i >= 0 && !nm.allInterfaces {
+			return i%nm.staggerSlots == slot
+		}
+		h := fnv.New32a()
+		h.Write([]byte(name))
+		return int(h.Sum32()%uint32(nm.staggerSlots)) == slot
+	case nm.intervals != nil:
+		every := nm.refreshInterval
+		if d := nm.intervals[name]; d > 0 {
+			every = d
+		}
+		if n := int(every / nm.step); n > 1 {
+			return nm.tickNo%n == 0
+		}
+	}
+	return true
+}
+
+// tickPeriod returns the time between ticks of the sampling loop: the interval, paced by a
+// replay, one slot of it with -stagger, or the common step of per-interface intervals.
+func (nm *NetworkMonitor) tickPeriod() time.Duration {
+	tick := nm.refreshInterval
+	if r, ok := nm.source.(*replaySource); ok {
+		tick = r.pace(nm.refreshInterval)
+	}
+	if nm.staggerSlots > 0 {
+		tick /= time.Duration(nm.staggerSlots)
+	}
+	if nm.step > 0 {
+		tick = nm.step
+	}
+	return tick
+}
+
+// agedRows returns the table rows of a tick with per-interface intervals: the latest sample of
+// every listed interface, marked with its age, so slow interfaces stay on screen.
+func (nm *NetworkMonitor) agedRows(samples []NetStats, now time.Time) []NetStats {
+	for _, s := range samples {
+		nm.latest[s.Interface] = s
+	}
+	rows := make([]NetStats, 0, len(nm.multi))
+	for _, name := range nm.multi {
+		s, ok := nm.latest[name]
+		if !ok {
+			continue
+		}
+		age := now.Sub(time.Time(s.Timestamp))
+		s.age = &age
+		rows = append(rows, s)
+	}
+	return rows
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStaggerReadsOneSlotPerTick(t *testing.T) {
+	src := newFakeSource()
+	names := []string{"a", "b", "c", "d"}
+	for _, name := range names {
+		src.set(name, 0, 0)
+	}
+	nm, buf := newTestMonitor(t, "a,b,c,d", src, "-i", "a,b,c,d", "-t", "2s", "--stagger", "1s", "-f", "json")
+	nm.multi = names
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 1; i <= 4; i++ {
+		for j, name := range names {
+			src.set(name, uint64(i*100*(j+1)), 0)
+		}
+		tick(t, nm, start.Add(time.Duration(i)*time.Second))
+	}
+
+	var got []string
+	for _, s := range decodeSamples(t, buf) {
+		got = append(got, s.Interface)
+	}
+	if want := []string{"a", "c", "b", "d", "a", "c", "b", "d"}; !slices.Equal(got, want) {
+		t.Errorf("sampled %v, want %v", got, want)
+	}
+
+	// Each interface's totals still add up all of its traffic.
+	sent, _ := nm.sampler.Totals()
+	if want := uint64(300*1 + 400*2 + 300*3 + 400*4); sent != want {
+		t.Errorf("total sent = %d, want %d", sent, want)
+	}
+}
+
+func TestPerInterfaceIntervals(t *testing.T) {
+	src := newFakeSource()
+	src.set("wan", 0, 0)
+	src.set("vlan", 0, 0)
+	nm, buf := newTestMonitor(t, "wan,vlan", src, "-i", "wan:1s,vlan:3s", "-t", "3s")
+	sels, every, err := parseInterfaceList("wan:1s,vlan:3s")
+	if err != nil || len(sels) != 2 {
+		t.Fatalf("parseInterfaceList = %v, %v", sels, err)
+	}
+	nm.multi = []string{"wan", "vlan"}
+	nm.setIntervals(every)
+	if nm.step != time.Second {
+		t.Fatalf("step = %v, want 1s", nm.step)
+	}
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+
+	// vlan has no rate until its first read after the initial one, then keeps its row
+	// between reads.
+	start := time.Now()
+	var rows []int
+	for i := 1; i <= 6; i++ {
+		src.set("wan", uint64(i*100), 0)
+		src.set("vlan", uint64(i*10), 0)
+		buf.Reset()
+		tick(t, nm, start.Add(time.Duration(i)*time.Second))
+		rows = append(rows, bytes.Count(buf.Bytes(), []byte("| wan"))+bytes.Count(buf.Bytes(), []byte("| vlan")))
+		if i == 1 && !bytes.Contains(buf.Bytes(), []byte("| AGE |")) {
+			t.Errorf("tick %d: the table has no Age column:\n%s", i, buf)
+		}
+	}
+	if want := []int{1, 1, 2, 2, 2, 2}; !slices.Equal(rows, want) {
+		t.Errorf("rows per tick = %v, want %v", rows, want)
+	}
+	if got := nm.latest["vlan"].TotalSent.Value; got != 60 {
+		t.Errorf("vlan total sent = %v, want 60", got)
+	}
+}
+
+func TestCutInterval(t *testing.T) {
+	tests := []struct {
+		in, name string
+		every    time.Duration
+	}{
+		{"eth0:1s", "eth0", time.Second},
+		{"vlan10:1m", "vlan10", time.Minute},
+		{"eth0", "eth0", 0},
+		{"eth0:1", "eth0:1", 0}, // An alias, not an interval
+		{"mac:02:00:00:00:00:01", "mac:02:00:00:00:00:01", 0},
+		{"mac:02:00:00:00:00:01:500ms", "mac:02:00:00:00:00:01", 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if name, every := cutInterval(tt.in); name != tt.name || every != tt.every {
+			t.Errorf("cutInterval(%q) = %q, %v; want %q, %v", tt.in, name, every, tt.name, tt.every)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// outputWriter is the destination of all formatted records. By default every record is written
+// through immediately; with buffering enabled, records are batched in memory and flushed when
+// either the sample count or the age limit is reached, which saves a write syscall per sample
+// at high sampling rates.
+type outputWriter struct {
+	w          io.Writer     // Underlying destination
+	maxSamples int           // Records buffered before a flush; 0 or 1 disables buffering
+	maxAge     time.Duration // Maximum time a buffered record may wait; 0 for no limit
+	buf        bytes.Buffer  // Records not yet written to w
+	pending    int           // Number of records in buf
+	oldest     time.Time     // When the oldest record in buf was added
+	redact     *redactor     // Scrubs every record when output is redacted, nil otherwise
+	array      bool          // Whether JSON records are joined into one array, closed by closeArray
+	opened     bool          // Whether the opening bracket of the array was written
+	closed     bool          // Whether the closing bracket of the array was written
+	queue      *outputQueue  // Writer goroutine decoupling w from the sampling loop, nil to write directly
+	schema     *recordSchema // Schema every JSON record is checked against before it is written, nil for none
+	violation  error         // First schema violation, which fails every later write
+	mu         sync.Mutex    // Serializes access from the sampling loop and flush timer
+}
+
+// isTerminal reports whether f is connected to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// openOutputFile opens the -o file, appending to it or truncating it. It also returns the size
+// of the data the file already holds, which appended output continues rather than starts.
+func openOutputFile(path string, appendTo, syncWrites bool) (*os.File, int64, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendTo {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	if syncWrites {
+		flags |= os.O_SYNC
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot open output file: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("cannot open output file: %v", err)
+	}
+	return f, info.Size(), nil
+}
+
+// newOutputWriter creates an output writer on w with the given buffering limits.
+func newOutputWriter(w io.Writer, maxSamples int, maxAge time.Duration) *outputWriter {
+	return &outputWriter{w: w, maxSamples: maxSamples, maxAge: maxAge}
+}
+
+// startQueue moves writing to w onto a goroutine behind a queue of size records.
+func (o *outputWriter) startQueue(size int) {
+	o.queue = newOutputQueue(o.w, size)
+}
+
+// buffered reports whether records are batched rather than written through.
+func (o *outputWriter) buffered() bool {
+	return o.maxSamples > 1 || o.maxAge > 0
+}
+
+// writeRecord writes one formatted record, produced by render, and flushes if a limit is reached.
+func (o *outputWriter) writeRecord(render func(w io.Writer)) error {
+	return o.write(render, false)
+}
+
+// writeSample writes a record of samples like writeRecord. Unlike other records, it may be
+// dropped when a slow destination lets the output queue fill up.
+func (o *outputWriter) writeSample(render func(w io.Writer)) error {
+	return o.write(render, true)
+}
+
+func (o *outputWriter) write(render func(w io.Writer), droppable bool) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// Redaction applies to the rendered bytes, so no output path can bypass it.
+	if o.redact != nil {
+		inner := render
+		render = func(w io.Writer) {
+			var b bytes.Buffer
+			inner(&b)
+			w.Write(o.redact.text(b.Bytes()))
+		}
+	}
+
+	// With -strict-schema a record that does not match the schema is never written, and
+	// neither is anything after it, so the run ends at the next sample.
+	if o.violation != nil {
+		return o.violation
+	}
+	if o.schema != nil {
+		var b bytes.Buffer
+		render(&b)
+		if err := o.schema.checkLines(b.Bytes()); err != nil {
+			o.violation = err
+			return err
+		}
+		render = func(w io.Writer) { w.Write(b.Bytes()) }
+	}
+
+	// In array mode every JSON line becomes an element, so streams of mixed records still
+	// form one valid document. Each element carries the separator before it, so none may be
+	// dropped.
+	if o.array {
+		droppable = false
+		inner := render
+		render = func(w io.Writer) {
+			var b bytes.Buffer
+			inner(&b)
+			rec := bytes.TrimRight(b.Bytes(), "\n")
+			if len(rec) == 0 {
+				return
+			}
+			if o.opened {
+				io.WriteString(w, ",\n")
+			} else {
+				io.WriteString(w, "[\n")
+				o.opened = true
+			}
+			w.Write(bytes.ReplaceAll(rec, []byte("\n"), []byte(",\n")))
+		}
+	}
+
+	if !o.buffered() {
+		var b bytes.Buffer
+		render(&b)
+		return o.put(b.Bytes(), droppable)
+	}
+
+	if o.pending == 0 {
+		o.oldest = time.Now()
+	}
+	render(&o.buf)
+	o.pending++
+
+	if (o.maxSamples > 0 && o.pending >= o.maxSamples) || o.expired(time.Now()) {
+		return o.flushLocked()
+	}
+	return nil
+}
+
+// schemaViolation returns the first record refused by -strict-schema, if any.
+func (o *outputWriter) schemaViolation() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.violation
+}
+
+// expired reports whether the oldest buffered record has waited longer than the age limit.
+func (o *outputWriter) expired(now time.Time) bool {
+	return o.maxAge > 0 && o.pending > 0 && now.Sub(o.oldest) >= o.maxAge
+}
+
+// flushExpired flushes the buffer if its oldest record exceeded the age limit. It is called
+// periodically so a quiet stream is still flushed on time.
+func (o *outputWriter) flushExpired() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.expired(time.Now()) {
+		return o.flushLocked()
+	}
+	return nil
+}
+
+// Flush writes all buffered records to the underlying destination.
+func (o *outputWriter) Flush() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.flushLocked()
+}
+
+func (o *outputWriter) flushLocked() error {
+	if o.pending == 0 {
+		return nil
+	}
+	err := o.put(o.buf.Bytes(), false)
+	o.buf.Reset()
+	o.pending = 0
+	return err
+}
+
+// put writes data to the destination, through the queue if there is one.
+func (o *outputWriter) put(data []byte, droppable bool) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if o.queue != nil {
+		return o.queue.put(data, droppable)
+	}
+	_, err := o.w.Write(data)
+	return err
+}
+
+// closeArray flushes the output, in array mode ends the array of JSON records, and waits until
+// every queued record is written. A run that emitted nothing still produces an empty array.
+// Later calls only flush.
+func (o *outputWriter) closeArray() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	err := o.flushLocked()
+	if err == nil && o.array && !o.closed {
+		o.closed = true
+		end := "\n]\n"
+		if !o.opened {
+			end = "[]\n"
+		}
+		err = o.put([]byte(end), false)
+	}
+	if err == nil && o.queue != nil {
+		err = o.queue.drain()
+	}
+	return err
+}
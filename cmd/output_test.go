@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestOutputRedactsRecords(t *testing.T) {
+	r, err := newRedactor("", []string{"wan-secret0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	out := newOutputWriter(&buf, 0, 0)
+	out.redact = r
+	out.writeRecord(func(w io.Writer) {
+		io.WriteString(w, `{"type":"annotation","interface":"wan-secret0","message":"wan-secret0 got 192.168.1.20"}`+"\n")
+	})
+
+	got := buf.String()
+	if strings.Contains(got, "wan-secret0") || strings.Contains(got, "192.168.1.20") {
+		t.Errorf("record not redacted: %s", got)
+	}
+	if p := r.pseudonym("wan-secret0"); !strings.Contains(got, `"interface":"`+p+`"`) {
+		t.Errorf("record lacks the pseudonym %s: %s", p, got)
+	}
+}
+
+func TestOutputSchemaCheck(t *testing.T) {
+	schema, err := compileRecordSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	out := newOutputWriter(&buf, 0, 0)
+	out.schema = schema
+	sample := func(w io.Writer) { jsonFormatter{}.samples(w, goldenSamples(), false) }
+
+	if err := out.writeSample(sample); err != nil {
+		t.Fatalf("valid samples refused: %v", err)
+	}
+	written := buf.Len()
+
+	err = out.writeRecord(func(w io.Writer) { io.WriteString(w, `{"type":"bogus","severity":3}`+"\n") })
+	var violation *schemaViolation
+	if !errors.As(err, &violation) {
+		t.Fatalf("invalid record accepted: %v", err)
+	}
+	// Nothing is written once a record was refused, valid or not.
+	if err := out.writeSample(sample); err != violation {
+		t.Errorf("write after the violation = %v, want %v", err, violation)
+	}
+	if out.schemaViolation() != violation {
+		t.Errorf("schemaViolation() = %v, want %v", out.schemaViolation(), violation)
+	}
+	if buf.Len() != written {
+		t.Errorf("wrote after the violation: %s", buf.Bytes()[written:])
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// eventAsymmetricRoute is emitted when paired interfaces show mirrored one-way traffic.
+const eventAsymmetricRoute = "asymmetric-route"
+
+// PairStats is the record emitted in -pair mode, showing both interfaces side by side.
+type PairStats struct {
+	Type       string      `json:"type"` // Always "pair"
+	Timestamp  Timestamp   `json:"timestamp"`
+	Interfaces [2]NetStats `json:"interfaces"`
+	Asymmetry  float64     `json:"asymmetry"` // Factor by which the mirrored directions exceed their counterparts
+	Symmetry   float64     `json:"symmetry"`  // 1/asymmetry: 1 when balanced, approaching 0 when fully asymmetric
+	recordID
+}
+
+// parsePair splits the value of the -pair flag into two interface names.
+func parsePair(s string) ([2]string, error) {
+	a, b, ok := strings.Cut(s, ",")
+	if !ok || a == "" || b == "" || strings.Contains(b, ",") || a == b {
+		return [2]string{}, fmt.Errorf("Invalid pair %q, expected two different interfaces such as eth0,eth1", s)
+	}
+	return [2]string{a, b}, nil
+}
+
+// pairAsymmetry returns how strongly traffic flows out of one interface of a pair and back in
+// through the other: the smaller of the two mirrored ratios, in whichever orientation is
+// larger. A byte is added to every figure so idle directions do not divide by zero.
+func pairAsymmetry(sentA, recvA, sentB, recvB uint64) float64 {
+	ratio := func(x, y uint64) float64 { return (float64(x) + 1) / (float64(y) + 1) }
+	inA := min(ratio(recvA, sentA), ratio(sentB, recvB))  // Arrives on A, leaves on B
+	outA := min(ratio(sentA, recvA), ratio(recvB, sentB)) // Leaves on A, arrives on B
+	return max(inA, outA, 1)
+}
+
+// pairRecord builds the side-by-side record of a -pair tick from the samples of its two
+// interfaces and the bytes each moved, and counts the samples the asymmetry has lasted.
+func (nm *NetworkMonitor) pairRecord(samples []NetStats, moved map[string]uint64Pair, now time.Time) *PairStats {
+	rec := &PairStats{Type: "pair", Timestamp: Timestamp(now)}
+	copy(rec.Interfaces[:], samples) // Both interfaces are read every tick, in the order of -pair
+	a, b := moved[nm.pair[0]], moved[nm.pair[1]]
+	asym := pairAsymmetry(a.sent, a.recv, b.sent, b.recv)
+	rec.Asymmetry = netstats.Round(asym, nm.precision)
+	rec.Symmetry = netstats.Round(1/asym, nm.precision)
+	if asym >= nm.pairFactor {
+		nm.pairSustained++
+	} else {
+		nm.pairSustained = 0
+	}
+	return rec
+}
+
+// checkAsymmetry emits an asymmetric-route event once the asymmetry of the pair has stayed at
+// or above the configured factor for the configured number of samples.
+func (nm *NetworkMonitor) checkAsymmetry(rec PairStats, now time.Time) {
+	if nm.pairSustained != nm.pairSustain {
+		return
+	}
+	nm.emitEvent(Event{
+		Type:      eventAsymmetricRoute,
+		Severity:  severityWarning,
+		Timestamp: Timestamp(now),
+		Interface: strings.Join(nm.pair[:], ","),
+		Message: fmt.Sprintf("traffic between %s and %s asymmetric by a factor of %.1f for %d samples",
+			nm.pair[0], nm.pair[1], rec.Asymmetry, nm.pairSustained),
+		Details: map[string]float64{"asymmetry": rec.Asymmetry, "samples": float64(nm.pairSustained)},
+	})
+}
+
+// uint64Pair holds the sent and received byte counters of one interface.
+type uint64Pair struct {
+	sent uint64
+	recv uint64
+}
+
+// printPairTable prints both interfaces of a pair record in one table to w.
+func printPairTable(w io.Writer, rec PairStats, precision int, decimalComma bool) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Interface", "Sent Speed", "Recv Speed", "Total Sent", "Total Recv", "Symmetry"})
+	for _, s := range rec.Interfaces {
+		table.Append([]string{
+			s.Interface,
+			formatQuantity(s.SentSpeed.Value, s.SentSpeed.Unit, precision, decimalComma),
+			formatQuantity(s.RecvSpeed.Value, s.RecvSpeed.Unit, precision, decimalComma),
+			formatQuantity(s.TotalSent.Value, s.TotalSent.Unit, precision, decimalComma),
+			formatQuantity(s.TotalRecv.Value, s.TotalRecv.Unit, precision, decimalComma),
+			strings.TrimSpace(formatQuantity(rec.Symmetry, "", precision, decimalComma)),
+		})
+	}
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(true)
+	table.SetRowLine(true)
+	table.Render()
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// newPDHSource reports that the pdh source needs Windows.
+func newPDHSource(string) (counterSource, error) {
+	return nil, errors.New("the pdh source is only available on Windows")
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"unsafe"
+
+	"github.com/shirou/gopsutil/v4/net"
+	"golang.org/x/sys/windows"
+)
+
+// PDH functions, loaded from pdh.dll on first use.
+var (
+	modpdh                     = windows.NewLazySystemDLL("pdh.dll")
+	procPdhOpenQueryW          = modpdh.NewProc("PdhOpenQueryW")
+	procPdhAddEnglishCounterW  = modpdh.NewProc("PdhAddEnglishCounterW")
+	procPdhCollectQueryData    = modpdh.NewProc("PdhCollectQueryData")
+	procPdhGetRawCounterArrayW = modpdh.NewProc("PdhGetRawCounterArrayW")
+	procPdhCloseQuery          = modpdh.NewProc("PdhCloseQuery")
+)
+
+// PDH status codes.
+const (
+	pdhStatusOK       = 0
+	pdhMoreData       = 0x800007D2
+	pdhCStatusNewData = 1 // Valid data, first time read
+)
+
+// pdhCounterPaths are the English paths of the Network Interface counters read for every
+// adapter, in the order of pdhSource.handles. PdhAddEnglishCounterW accepts them on localized
+// systems too. The "/sec" counters are rate counters whose raw values are the cumulative counts.
+var pdhCounterPaths = []string{
+	`\Network Interface(*)\Bytes Sent/sec`,
+	`\Network Interface(*)\Bytes Received/sec`,
+	`\Network Interface(*)\Packets Sent/sec`,
+	`\Network Interface(*)\Packets Received/sec`,
+	`\Network Interface(*)\Packets Outbound Errors`,
+	`\Network Interface(*)\Packets Received Errors`,
+	`\Network Interface(*)\Packets Outbound Discarded`,
+	`\Network Interface(*)\Packets Received Discarded`,
+}
+
+// pdhInstanceName replaces the characters PDH does not allow in instance names the way it
+// does when naming an adapter after its description.
+var pdhInstanceName = strings.NewReplacer("(", "[", ")", "]", "#", "_", "/", "_", `\`, "_")
+
+// pdhRawCounter mirrors PDH_RAW_COUNTER, with its C padding made explicit so the layout is the
+// same on 32- and 64-bit Windows.
+type pdhRawCounter struct {
+	CStatus     uint32
+	TimeStamp   windows.Filetime
+	_           uint32
+	FirstValue  int64
+	SecondValue int64
+	MultiCount  uint32
+	_           uint32
+}
+
+// pdhRawCounterItem mirrors PDH_RAW_COUNTER_ITEM_W.
+type pdhRawCounterItem struct {
+	Name     *uint16
+	_        [8 - unsafe.Sizeof(uintptr(0))]byte
+	RawValue pdhRawCounter
+}
+
+// pdhInstanceValue is the raw value of a counter for one instance.
+type pdhInstanceValue struct {
+	instance string
+	status   uint32
+	value    int64
+}
+
+// pdhSource reads counters from the Windows performance counters of the Network Interface
+// object, the system's own per-adapter figures. PDH names instances after adapter descriptions,
+// which are mapped back to the adapter names the rest of the monitor uses. Adapters that appear
+// after the query was opened are picked up by reopening it.
+type pdhSource struct {
+	query   uintptr
+	handles []uintptr         // Counter handles of pdhCounterPaths
+	names   map[string]string // Adapter name by PDH instance name
+}
+
+// newPDHSource opens the counter query of the pdh source. It takes no options.
+func newPDHSource(opts string) (counterSource, error) {
+	if opts != "" {
+		return nil, fmt.Errorf("the pdh source takes no options, got %q", opts)
+	}
+	p := &pdhSource{}
+	if err := p.open(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// open opens the query and reads the adapter names.
+func (p *pdhSource) open() error {
+	if err := procPdhOpenQueryW.Find(); err != nil {
+		return fmt.Errorf("PDH is not available: %v", err)
+	}
+	var query uintptr
+	if r, _, _ := procPdhOpenQueryW.Call(0, 0, uintptr(unsafe.Pointer(&query))); r != pdhStatusOK {
+		return fmt.Errorf("error opening a PDH query: status %#x", r)
+	}
+	counters := make([]uintptr, len(pdhCounterPaths))
+	for i, path := range pdhCounterPaths {
+		r, _, _ := procPdhAddEnglishCounterW.Call(query, uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(path))), 0, uintptr(unsafe.Pointer(&counters[i])))
+		if r != pdhStatusOK {
+			procPdhCloseQuery.Call(query)
+			return fmt.Errorf("error adding PDH counter %s: status %#x", path, r)
+		}
+	}
+	names, err := adapterInstanceNames()
+	if err != nil {
+		procPdhCloseQuery.Call(query)
+		return err
+	}
+	p.Close()
+	p.query, p.handles, p.names = query, counters, names
+	return nil
+}
+
+// adapterInstanceNames maps the PDH instance name of every adapter to its name.
+func adapterInstanceNames() (map[string]string, error) {
+	size := uint32(15 * 1024)
+	for {
+		buf := make([]byte, size)
+		first := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0]))
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC, windows.GAA_FLAG_INCLUDE_ALL_INTERFACES, 0, first, &size)
+		if errors.Is(err, windows.ERROR_BUFFER_OVERFLOW) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing adapters: %v", err)
+		}
+		names := make(map[string]string)
+		for a := first; a != nil; a = a.Next {
+			desc := windows.UTF16PtrToString(a.Description)
+			names[pdhInstanceName.Replace(desc)] = windows.UTF16PtrToString(a.FriendlyName)
+		}
+		return names, nil
+	}
+}
+
+// Close closes the query.
+func (p *pdhSource) Close() error {
+	if p.query != 0 {
+		procPdhCloseQuery.Call(p.query)
+		p.query = 0
+	}
+	return nil
+}
+
+func (*pdhSource) live() {}
+
+func (p *pdhSource) counters(iface string) (net.IOCountersStat, error) {
+	for attempt := 0; ; attempt++ {
+		stats, err := p.collect()
+		if err != nil {
+			return net.IOCountersStat{}, err
+		}
+		for _, s := range stats {
+			if s.Name == iface {
+				return s, nil
+			}
+		}
+		if attempt > 0 {
+			return net.IOCountersStat{}, fmt.Errorf("%w: %s", errInterfaceNotFound, iface)
+		}
+		// The adapter may have appeared since the query was opened.
+		if err := p.open(); err != nil {
+			return net.IOCountersStat{}, err
+		}
+	}
+}
+
+func (p *pdhSource) allCounters() ([]net.IOCountersStat, error) {
+	return p.collect()
+}
+
+// collect reads every counter of every adapter instance.
+func (p *pdhSource) collect() ([]net.IOCountersStat, error) {
+	if r, _, _ := procPdhCollectQueryData.Call(p.query); r != pdhStatusOK {
+		return nil, fmt.Errorf("error collecting PDH counters: status %#x", r)
+	}
+	byName := make(map[string]*net.IOCountersStat)
+	var order []string
+	for i, counter := range p.handles {
+		items, err := rawCounterArray(counter)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if item.status > pdhCStatusNewData {
+				continue // The instance went away or has no data yet
+			}
+			name, ok := p.names[item.instance]
+			if !ok {
+				name = item.instance
+			}
+			s := byName[name]
+			if s == nil {
+				s = &net.IOCountersStat{Name: name}
+				byName[name] = s
+				order = append(order, name)
+			}
+			v := uint64(item.value)
+			switch i {
+			case 0:
+				s.BytesSent = v
+			case 1:
+				s.BytesRecv = v
+			case 2:
+				s.PacketsSent = v
+			case 3:
+				s.PacketsRecv = v
+			case 4:
+				s.Errout = v
+			case 5:
+				s.Errin = v
+			case 6:
+				s.Dropout = v
+			case 7:
+				s.Dropin = v
+			}
+		}
+	}
+	stats := make([]net.IOCountersStat, len(order))
+	for i, name := range order {
+		stats[i] = *byName[name]
+	}
+	return stats, nil
+}
+
+// rawCounterArray returns the raw values of every instance of a wildcard counter.
+func rawCounterArray(counter uintptr) ([]pdhInstanceValue, error) {
+	var size, count uint32
+	r, _, _ := procPdhGetRawCounterArrayW.Call(counter, uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&count)), 0)
+	if r == pdhStatusOK {
+		return nil, nil
+	}
+	if r != pdhMoreData {
+		return nil, fmt.Errorf("error reading PDH counters: status %#x", r)
+	}
+	// The buffer holds the items followed by the names they point to. It is a byte slice so
+	// the garbage collector does not take the names for pointers.
+	buf := make([]byte, size)
+	r, _, _ = procPdhGetRawCounterArrayW.Call(counter, uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&count)), uintptr(unsafe.Pointer(&buf[0])))
+	if r != pdhStatusOK {
+		return nil, fmt.Errorf("error reading PDH counters: status %#x", r)
+	}
+	items := unsafe.Slice((*pdhRawCounterItem)(unsafe.Pointer(&buf[0])), count)
+	values := make([]pdhInstanceValue, count)
+	for i, item := range items {
+		values[i] = pdhInstanceValue{windows.UTF16PtrToString(item.Name), item.RawValue.CStatus, item.RawValue.FirstValue}
+	}
+	runtime.KeepAlive(buf)
+	return values, nil
+}
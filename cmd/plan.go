@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// Plateau detection settings. A direction whose rate holds within plateauTolerance of its mean
+// for plateauSamples consecutive samples, at 90-99.9% of a round rate, is reported as possibly
+// shaped. Rates below plateauMinBits are too small to tell shaping from idle noise.
+const (
+	plateauSamples   = 10
+	plateauTolerance = 0.02
+	plateauMinBits   = 1e6
+)
+
+// ispPlan holds the subscribed bandwidth of an internet plan, in bits per second. A rate left
+// at zero is not part of the plan.
+type ispPlan struct {
+	Down  float64 // Download (receive) rate
+	Up    float64 // Upload (send) rate
+	Total float64 // Combined rate of both directions, for links billed on their sum
+}
+
+// PlanUsage expresses the current rates as a percentage of the plan. Directions the plan does
+// not set are left out.
+type PlanUsage struct {
+	DownPercent  *float64 `json:"downPercent,omitempty"`
+	UpPercent    *float64 `json:"upPercent,omitempty"`
+	TotalPercent *float64 `json:"totalPercent,omitempty"`
+}
+
+// PlanSummary compares the session peak and average rates with the plan.
+type PlanSummary struct {
+	DownBitsPerSecond   *float64 `json:"downBitsPerSecond,omitempty"`
+	UpBitsPerSecond     *float64 `json:"upBitsPerSecond,omitempty"`
+	TotalBitsPerSecond  *float64 `json:"totalBitsPerSecond,omitempty"`
+	PeakDownPercent     *float64 `json:"peakDownPercent,omitempty"`
+	PeakUpPercent       *float64 `json:"peakUpPercent,omitempty"`
+	PeakTotalPercent    *float64 `json:"peakTotalPercent,omitempty"`
+	AverageDownPercent  *float64 `json:"averageDownPercent,omitempty"`
+	AverageUpPercent    *float64 `json:"averageUpPercent,omitempty"`
+	AverageTotalPercent *float64 `json:"averageTotalPercent,omitempty"`
+}
+
+// parsePlan interprets the value of the -plan flag, e.g. "down=500Mbit,up=50Mbit" or
+// "total=100Mbit".
+func parsePlan(s string) (*ispPlan, error) {
+	var p ispPlan
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("Invalid plan %q, expected down=<rate>,up=<rate> or total=<rate>", s)
+		}
+		rate, err := parseBitRate(value)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "down":
+			p.Down = rate
+		case "up":
+			p.Up = rate
+		case "total":
+			p.Total = rate
+		default:
+			return nil, fmt.Errorf("Invalid plan direction %q, expected down, up or total", key)
+		}
+	}
+	if (p.Down == 0) != (p.Up == 0) || p.Down == 0 && p.Total == 0 {
+		return nil, fmt.Errorf("Plan %q must set both down and up rates, a total rate, or all three", s)
+	}
+	return &p, nil
+}
+
+// parseBitRate parses a positive rate such as "500Mbit", in any form of netstats.ParseSpeed,
+// into bits per second.
+func parseBitRate(s string) (float64, error) {
+	bytes, err := netstats.ParseSpeed(s)
+	if err != nil || bytes <= 0 {
+		return 0, fmt.Errorf("Invalid rate %q, expected a positive rate such as 500Mbit", s)
+	}
+	return bytes * 8, nil
+}
+
+// percentOf returns the share of the plan rate, in percent, used by bytesPerSecond, or nil
+// when the plan does not set the rate.
+func percentOf(bytesPerSecond, planBits float64, precision int) *float64 {
+	if planBits == 0 {
+		return nil
+	}
+	v := netstats.Round(100*bytesPerSecond*8/planBits, precision)
+	return &v
+}
+
+// planRate returns a rate of the plan, or nil when it is not set.
+func planRate(bits float64) *float64 {
+	if bits == 0 {
+		return nil
+	}
+	return &bits
+}
+
+// usage compares rates in bytes per second with the plan.
+func (p *ispPlan) usage(sentBps, recvBps float64, precision int) *PlanUsage {
+	return &PlanUsage{
+		DownPercent:  percentOf(recvBps, p.Down, precision),
+		UpPercent:    percentOf(sentBps, p.Up, precision),
+		TotalPercent: percentOf(sentBps+recvBps, p.Total, precision),
+	}
+}
+
+// summarize compares a session summary, whose combined peak rate was peakTotal bytes per
+// second, with the plan.
+func (p *ispPlan) summarize(s SessionSummary, peakTotal float64, precision int) *PlanSummary {
+	return &PlanSummary{
+		DownBitsPerSecond:   planRate(p.Down),
+		UpBitsPerSecond:     planRate(p.Up),
+		TotalBitsPerSecond:  planRate(p.Total),
+		PeakDownPercent:     percentOf(s.PeakRecvBytesPerSecond, p.Down, precision),
+		PeakUpPercent:       percentOf(s.PeakSentBytesPerSecond, p.Up, precision),
+		PeakTotalPercent:    percentOf(peakTotal, p.Total, precision),
+		AverageDownPercent:  percentOf(s.AvgRecvBytesPerSecond, p.Down, precision),
+		AverageUpPercent:    percentOf(s.AvgSentBytesPerSecond, p.Up, precision),
+		AverageTotalPercent: percentOf(s.AvgSentBytesPerSecond+s.AvgRecvBytesPerSecond, p.Total, precision),
+	}
+}
+
+// plateauDetector watches the rate of one direction for suspicious plateaus.
+type plateauDetector struct {
+	direction string    // "download", "upload" or "combined traffic"
+	planBits  float64   // Subscribed rate, also treated as a round number
+	window    []float64 // Most recent rates in bits per second
+	reported  bool      // Whether the current plateau was already reported
+}
+
+// add records a rate in bits per second and returns a description of the plateau when one
+// has just been detected.
+func (d *plateauDetector) add(bits float64) (string, bool) {
+	d.window = append(d.window, bits)
+	if len(d.window) > plateauSamples {
+		d.window = d.window[1:]
+	}
+	if len(d.window) < plateauSamples {
+		return "", false
+	}
+
+	var sum float64
+	for _, v := range d.window {
+		sum += v
+	}
+	mean := sum / float64(len(d.window))
+	for _, v := range d.window {
+		if math.Abs(v-mean) > mean*plateauTolerance {
+			d.reported = false
+			return "", false
+		}
+	}
+
+	limit, ok := roundRateAbove(mean, d.planBits)
+	if !ok || mean < plateauMinBits {
+		d.reported = false
+		return "", false
+	}
+	if d.reported {
+		return "", false
+	}
+	d.reported = true
+	return fmt.Sprintf("%s plateaued at %.1f Mbit/s for %d samples, just below %g Mbit/s (possible shaping)",
+		d.direction, mean/1e6, len(d.window), limit/1e6), true
+}
+
+// roundRateAbove returns the round rate (1, 2, 2.5 or 5 times a power of ten, or the plan
+// rate) that bits lies just below, between 90% and 99.9% of it.
+func roundRateAbove(bits, planBits float64) (float64, bool) {
+	candidates := []float64{planBits}
+	for exp := math.Floor(math.Log10(bits)); exp <= math.Floor(math.Log10(bits))+1; exp++ {
+		for _, m := range []float64{1, 2, 2.5, 5} {
+			candidates = append(candidates, m*math.Pow(10, exp))
+		}
+	}
+	for _, c := range candidates {
+		if bits >= 0.9*c && bits < 0.999*c {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// detectShaping feeds the byte deltas of one sample, taken over interval seconds, to the
+// plateau detectors and reports plateaus below round rates. The combined rate is only watched
+// when the plan sets a total rate.
+func (nm *NetworkMonitor) detectShaping(sentBytes, recvBytes uint64, interval float64) {
+	for i, c := range []struct {
+		d     *plateauDetector
+		bytes uint64
+	}{{&nm.plateaus[0], recvBytes}, {&nm.plateaus[1], sentBytes}, {&nm.plateaus[2], sentBytes + recvBytes}} {
+		if i == 2 && nm.plan.Total == 0 {
+			continue
+		}
+		if msg, ok := c.d.add(float64(c.bytes) * 8 / interval); ok {
+			nm.emitEvent(Event{
+				Type:      eventPossibleShaping,
+				Timestamp: Timestamp(time.Now()),
+				Interface: nm.interfaceName,
+				Message:   msg,
+			})
+		}
+	}
+}
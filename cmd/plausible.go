@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// plausibleLinkFactor is how far above the negotiated link speed a rate may go before it is
+// considered implausible. It leaves headroom for drivers that report the speed loosely.
+const plausibleLinkFactor = 2
+
+// implausibleDetails lists the raw readings behind an implausible-rate event.
+type implausibleDetails struct {
+	CeilingBytesPerSecond float64 `json:"ceilingBytesPerSecond"`
+	SentBytesPerSecond    float64 `json:"sentBytesPerSecond"`
+	RecvBytesPerSecond    float64 `json:"recvBytesPerSecond"`
+	PrevBytesSent         uint64  `json:"prevBytesSent"`
+	PrevBytesRecv         uint64  `json:"prevBytesRecv"`
+	BytesSent             uint64  `json:"bytesSent"`
+	BytesRecv             uint64  `json:"bytesRecv"`
+	Reset                 bool    `json:"reset,omitempty"` // A counter went backwards, as after a driver reset or a wrap
+}
+
+// plausibleCeiling returns the highest plausible rate of an interface in bytes per second: the
+// configured -max-plausible-rate, else its link speed times plausibleLinkFactor when known, else
+// a fixed limit far beyond any real interface.
+func (nm *NetworkMonitor) plausibleCeiling(iface string) float64 {
+	if nm.maxPlausibleRate > 0 {
+		return nm.maxPlausibleRate
+	}
+	if m := nm.meta[iface]; m != nil && m.SpeedMbps > 0 {
+		return float64(m.SpeedMbps) * 1e6 / 8 * plausibleLinkFactor
+	}
+	return unrealBytesPerSecond
+}
+
+// checkPlausible reports whether a delta is plausible: its counters did not go backwards and
+// its rates stay within plausibleCeiling, which the sampler applies. An implausible delta is
+// counted and reported in an event with the raw counters involved.
+func (nm *NetworkMonitor) checkPlausible(d netstats.Delta, now time.Time) bool {
+	if !d.Reset && !d.Implausible {
+		return true
+	}
+
+	ceiling := nm.plausibleCeiling(d.Interface)
+	sentBps, recvBps := d.Rates()
+	msg := fmt.Sprintf("implausible rate (sent %.0f B/s, received %.0f B/s, ceiling %.0f B/s), sample left out of totals and aggregates",
+		sentBps, recvBps, ceiling)
+	if d.Reset {
+		msg = "counters went backwards, as after a driver reset or a counter wrap; sample left out of totals and aggregates"
+	}
+	nm.session.implausible++
+	nm.emitEvent(Event{
+		Type:      eventImplausibleRate,
+		Timestamp: Timestamp(now),
+		Interface: d.Interface,
+		Message:   msg,
+		Details: implausibleDetails{
+			CeilingBytesPerSecond: ceiling,
+			SentBytesPerSecond:    sentBps,
+			RecvBytesPerSecond:    recvBps,
+			PrevBytesSent:         d.Prev.BytesSent,
+			PrevBytesRecv:         d.Prev.BytesRecv,
+			BytesSent:             d.Cur.BytesSent,
+			BytesRecv:             d.Cur.BytesRecv,
+			Reset:                 d.Reset,
+		},
+	})
+	return false
+}
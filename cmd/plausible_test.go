@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCheckPlausible(t *testing.T) {
+	const ceiling = 1 << 20 // -max-plausible-rate 1MiB in bytes per second
+	tests := []struct {
+		name                 string
+		prevSent, prevRecv   uint64
+		sent, recv           uint64
+		implausible          bool
+		reset                bool
+		totalSent, totalRecv uint64
+	}{
+		{name: "growth", prevSent: 1000, prevRecv: 2000, sent: 1500, recv: 2600, totalSent: 500, totalRecv: 600},
+		{name: "driver reset", prevSent: 5 << 20, prevRecv: 7 << 20, sent: 100, recv: 200, implausible: true, reset: true},
+		{name: "32-bit wrap", prevSent: 4294967000, prevRecv: 1000, sent: 200, recv: 1500, implausible: true, reset: true},
+		{name: "spike", prevSent: 1000, prevRecv: 2000, sent: 1000 + 5<<20, recv: 2000, implausible: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := newFakeSource()
+			src.set("eth0", tt.prevSent, tt.prevRecv)
+			nm, buf := newTestMonitor(t, "eth0", src, "-f", "json", "-max-plausible-rate", "1MiB")
+			if err := nm.startSampling(); err != nil {
+				t.Fatal(err)
+			}
+			src.set("eth0", tt.sent, tt.recv)
+			tick(t, nm, time.Now().Add(time.Second))
+
+			want := 0
+			if tt.implausible {
+				want = 1
+			}
+			if nm.session.implausible != want {
+				t.Errorf("implausible samples = %d, want %d", nm.session.implausible, want)
+			}
+			if sent, recv := nm.sampler.Totals(); sent != tt.totalSent || recv != tt.totalRecv {
+				t.Errorf("totals = %d/%d, want %d/%d", sent, recv, tt.totalSent, tt.totalRecv)
+			}
+
+			type implausibleEvent struct {
+				Type    string             `json:"type"`
+				Details implausibleDetails `json:"details"`
+			}
+			var events []implausibleEvent
+			sc := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+			for sc.Scan() {
+				var ev implausibleEvent
+				if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+					t.Fatalf("invalid JSON line %q: %v", sc.Text(), err)
+				}
+				if ev.Type == eventImplausibleRate {
+					events = append(events, ev)
+				}
+			}
+			if len(events) != want {
+				t.Fatalf("got %d implausible-rate events, want %d: %s", len(events), want, buf)
+			}
+			if !tt.implausible {
+				return
+			}
+			d := events[0].Details
+			if d.Reset != tt.reset || d.CeilingBytesPerSecond != ceiling {
+				t.Errorf("event reset %v ceiling %v, want reset %v ceiling %v", d.Reset, d.CeilingBytesPerSecond, tt.reset, float64(ceiling))
+			}
+			if d.PrevBytesSent != tt.prevSent || d.PrevBytesRecv != tt.prevRecv || d.BytesSent != tt.sent || d.BytesRecv != tt.recv {
+				t.Errorf("event counters %d/%d -> %d/%d, want %d/%d -> %d/%d", d.PrevBytesSent, d.PrevBytesRecv,
+					d.BytesSent, d.BytesRecv, tt.prevSent, tt.prevRecv, tt.sent, tt.recv)
+			}
+			if !tt.reset && d.SentBytesPerSecond <= ceiling {
+				t.Errorf("event sent rate %v B/s, want above the ceiling", d.SentBytesPerSecond)
+			}
+		})
+	}
+}
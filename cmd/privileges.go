@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	stdnet "net"
+	"os"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// States of a requested feature after the privilege probe.
+const (
+	featureAvailable   = "available"   // The feature runs as requested
+	featureDegraded    = "degraded"    // The feature runs with a fallback, such as normal scheduling
+	featureUnavailable = "unavailable" // The feature cannot run, so startup would fail
+)
+
+// Linux capabilities checked by the privilege probe, as bit numbers of CapEff.
+const (
+	capNetBindService = 10
+	capNetRaw         = 13
+	capSysNice        = 23
+)
+
+// privileges are the effective privileges of the process, as far as the platform tells.
+type privileges struct {
+	root         bool   // Effective UID 0
+	caps         uint64 // Effective capability set, on Linux
+	capsKnown    bool   // Whether caps could be read
+	rtprio       uint64 // RLIMIT_RTPRIO: highest real-time priority allowed without CAP_SYS_NICE
+	niceLimit    uint64 // RLIMIT_NICE: 20 minus the lowest nice value allowed without CAP_SYS_NICE
+	schedSupport bool   // Whether the scheduling options are supported at all
+	lowPortLimit int    // Ports below this need CAP_NET_BIND_SERVICE, 0 when any port may be bound
+}
+
+// has reports whether the process holds capability c, which root always does where
+// capabilities cannot be read.
+func (p privileges) has(c uint) bool {
+	if !p.capsKnown {
+		return p.root
+	}
+	return p.caps&(1<<c) != 0
+}
+
+// FeatureCheck is the outcome of the privilege probe for one requested feature, printed at
+// startup and served on /status.
+type FeatureCheck struct {
+	Feature string `json:"feature"` // Flag of the feature, such as -realtime
+	State   string `json:"state"`   // available, degraded or unavailable
+	Reason  string `json:"reason"`  // What the state rests on, or what is missing
+}
+
+// checkPrivileges checks the features cfg enables that need more than an ordinary user may
+// have. Features needing no privileges are not listed.
+func checkPrivileges(cfg monitorConfig, p privileges) []FeatureCheck {
+	var checks []FeatureCheck
+	if cfg.Realtime {
+		c := FeatureCheck{Feature: "-realtime"}
+		switch {
+		case !p.schedSupport:
+			c.State, c.Reason = featureDegraded, "only supported on Linux; sampling runs at normal priority"
+		case p.has(capSysNice):
+			c.State, c.Reason = featureAvailable, "CAP_SYS_NICE"
+		case p.rtprio >= realtimePriority:
+			c.State, c.Reason = featureAvailable, fmt.Sprintf("RLIMIT_RTPRIO allows priority %d", p.rtprio)
+		default:
+			c.State, c.Reason = featureDegraded, "needs CAP_SYS_NICE or RLIMIT_RTPRIO; sampling runs at normal priority"
+		}
+		checks = append(checks, c)
+	}
+	if cfg.Nice < 0 {
+		c := FeatureCheck{Feature: "-nice"}
+		switch {
+		case !p.schedSupport:
+			c.State, c.Reason = featureDegraded, "only supported on Linux; the nice value stays unchanged"
+		case p.has(capSysNice):
+			c.State, c.Reason = featureAvailable, "CAP_SYS_NICE"
+		case p.niceLimit >= uint64(20-cfg.Nice):
+			c.State, c.Reason = featureAvailable, fmt.Sprintf("RLIMIT_NICE allows nice %d", 20-int(p.niceLimit))
+		default:
+			c.State, c.Reason = featureDegraded, fmt.Sprintf("nice %d needs CAP_SYS_NICE or RLIMIT_NICE; the nice value stays unchanged", cfg.Nice)
+		}
+		checks = append(checks, c)
+	}
+	if cfg.Probe != "" {
+		c := FeatureCheck{Feature: "-probe"}
+		if p.has(capNetRaw) {
+			c.State, c.Reason = featureAvailable, "CAP_NET_RAW allows ICMP echo"
+		} else {
+			c.State, c.Reason = featureDegraded, "ICMP echo needs CAP_NET_RAW; probing over UDP instead"
+		}
+		checks = append(checks, c)
+	}
+	if low := lowestListenPort(cfg); low >= 0 && low < p.lowPortLimit {
+		c := FeatureCheck{Feature: "-listen"}
+		switch {
+		case socketActivated():
+			c.State, c.Reason = featureAvailable, "sockets are passed by socket activation"
+		case p.has(capNetBindService):
+			c.State, c.Reason = featureAvailable, "CAP_NET_BIND_SERVICE"
+		default:
+			c.State, c.Reason = featureUnavailable, fmt.Sprintf("port %d needs CAP_NET_BIND_SERVICE", low)
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// lowestListenPort returns the lowest port among the -listen addresses, or -1 without any.
+// Port 0 picks a free port and needs no privilege.
+func lowestListenPort(cfg monitorConfig) int {
+	low := -1
+	for _, addr := range cfg.listenAddrs() {
+		_, port, _ := stdnet.SplitHostPort(addr)
+		if n, err := strconv.Atoi(port); err == nil && n > 0 && (low < 0 || n < low) {
+			low = n
+		}
+	}
+	return low
+}
+
+// socketActivated reports whether a service manager passed listening sockets to the process.
+func socketActivated() bool {
+	pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	return pid == os.Getpid() && n > 0
+}
+
+// printFeatureChecks prints the outcome of the privilege probe as a table.
+func printFeatureChecks(w io.Writer, checks []FeatureCheck) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Feature", "State", "Reason"})
+	table.SetAutoWrapText(false)
+	for _, c := range checks {
+		table.Append([]string{c.Feature, c.State, c.Reason})
+	}
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(true)
+	table.Render()
+}
+
+// featuresUnavailable returns an error naming the first feature that cannot run as requested,
+// for -strict, or nil when all can.
+func featuresUnavailable(checks []FeatureCheck) error {
+	for _, c := range checks {
+		if c.State != featureAvailable {
+			return fmt.Errorf("%s is %s: %s", c.Feature, c.State, c.Reason)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// readPrivileges reads the effective capabilities from /proc/self/status and the scheduling
+// allowances from the resource limits. What cannot be read is left at no privilege.
+func readPrivileges() privileges {
+	p := privileges{root: os.Geteuid() == 0, schedSupport: true, lowPortLimit: 1024}
+	if f, err := os.Open("/proc/self/status"); err == nil {
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if value, ok := strings.CutPrefix(sc.Text(), "CapEff:"); ok {
+				if caps, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64); err == nil {
+					p.caps, p.capsKnown = caps, true
+				}
+			}
+		}
+		f.Close()
+	}
+	var lim unix.Rlimit
+	if unix.Getrlimit(unix.RLIMIT_RTPRIO, &lim) == nil {
+		p.rtprio = lim.Cur
+	}
+	if unix.Getrlimit(unix.RLIMIT_NICE, &lim) == nil {
+		p.niceLimit = lim.Cur
+	}
+	if data, err := os.ReadFile("/proc/sys/net/ipv4/ip_unprivileged_port_start"); err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+			p.lowPortLimit = n
+		}
+	}
+	return p
+}
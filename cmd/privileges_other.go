@@ -0,0 +1,20 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+	"runtime"
+)
+
+// readPrivileges only tells root from other users outside Linux. Binding low ports needs root
+// on the BSDs; macOS and Windows let any user bind them.
+func readPrivileges() privileges {
+	p := privileges{root: os.Geteuid() == 0}
+	switch runtime.GOOS {
+	case "darwin", "windows":
+	default:
+		p.lowPortLimit = 1024
+	}
+	return p
+}
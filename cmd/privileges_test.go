@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestCheckPrivileges(t *testing.T) {
+	user := privileges{capsKnown: true, schedSupport: true, lowPortLimit: 1024}
+	admin := user
+	admin.caps = 1<<capNetBindService | 1<<capNetRaw | 1<<capSysNice
+	limited := user
+	limited.rtprio, limited.niceLimit = 10, 30 // Nice down to -10
+
+	tests := []struct {
+		name  string
+		cfg   monitorConfig
+		p     privileges
+		want  map[string]string
+		clean bool // Whether -strict lets it start
+	}{
+		{"nothing privileged", monitorConfig{Nice: 5, PinCPU: 1, Listen: ":9123"}, user, map[string]string{}, true},
+		{"user", monitorConfig{Realtime: true, Nice: -5, Probe: "1.1.1.1", Listen: ":9123,:80"}, user,
+			map[string]string{"-realtime": featureDegraded, "-nice": featureDegraded, "-probe": featureDegraded, "-listen": featureUnavailable}, false},
+		{"capabilities", monitorConfig{Realtime: true, Nice: -20, Probe: "1.1.1.1", Listen: ":80"}, admin,
+			map[string]string{"-realtime": featureAvailable, "-nice": featureAvailable, "-probe": featureAvailable, "-listen": featureAvailable}, true},
+		{"resource limits", monitorConfig{Realtime: true, Nice: -10}, limited,
+			map[string]string{"-realtime": featureAvailable, "-nice": featureAvailable}, true},
+		{"nice beyond the limit", monitorConfig{Nice: -11}, limited, map[string]string{"-nice": featureDegraded}, false},
+		{"root without capability data", monitorConfig{Probe: "1.1.1.1", Listen: ":80"}, privileges{root: true, lowPortLimit: 1024},
+			map[string]string{"-probe": featureAvailable, "-listen": featureAvailable}, true},
+		{"no scheduling support", monitorConfig{Realtime: true}, privileges{root: true},
+			map[string]string{"-realtime": featureDegraded}, false},
+		{"low ports allowed", monitorConfig{Listen: ":80"}, privileges{}, map[string]string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := checkPrivileges(tt.cfg, tt.p)
+			got := make(map[string]string, len(checks))
+			for _, c := range checks {
+				got[c.Feature] = c.State
+				if c.Reason == "" {
+					t.Errorf("%s has no reason", c.Feature)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("checks = %v, want %v", got, tt.want)
+			}
+			for feature, state := range tt.want {
+				if got[feature] != state {
+					t.Errorf("%s = %q, want %q", feature, got[feature], state)
+				}
+			}
+			if err := featuresUnavailable(checks); (err == nil) != tt.clean {
+				t.Errorf("-strict error = %v, want one: %v", err, !tt.clean)
+			}
+		})
+	}
+}
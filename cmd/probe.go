@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	stdnet "net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// defaultProbePort is the UDP port probed when raw ICMP sockets are not allowed. Like
+// traceroute's, it is unlikely to be served, so the target answers with port unreachable.
+const defaultProbePort = 33434
+
+// maxProbeTimeout bounds how long a probe waits for its answer before it counts as lost.
+const maxProbeTimeout = 5 * time.Second
+
+// ProbeResult summarizes the latency probes of one target since the previous sample.
+type ProbeResult struct {
+	Target string   `json:"target"`
+	Method string   `json:"method,omitempty"` // icmp, or udp where raw sockets are not allowed
+	RTTMs  *float64 `json:"probeRttMs"`       // Average round-trip time of answered probes, null if none was answered
+	Loss   *float64 `json:"probeLoss"`        // Fraction of probes left unanswered, null if none completed
+}
+
+// probeTarget sends one probe per interval to a host and accumulates the outcomes until they
+// are taken into a sample.
+type probeTarget struct {
+	host   string
+	port   int
+	method string
+	id     uint16 // ICMP echo identifier of this target
+	seq    uint16
+
+	mu       sync.Mutex
+	sent     int           // Probes completed since the last take
+	answered int           // Probes answered since the last take
+	rttSum   time.Duration // Round-trip times of the answered probes
+}
+
+// prober runs latency probes concurrently with counter sampling, so a probe timing out never
+// delays a sample.
+type prober struct {
+	targets  []*probeTarget
+	interval time.Duration
+	stop     chan struct{}
+}
+
+// parseProbeTargets splits a comma-separated -probe value into hosts with an optional UDP
+// port, e.g. "1.1.1.1,gateway.lan:53" or "[2606:4700::1111]".
+func parseProbeTargets(spec string) ([]*probeTarget, error) {
+	var targets []*probeTarget
+	for i, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		host, port := field, defaultProbePort
+		if h, p, err := stdnet.SplitHostPort(field); err == nil {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 1 || n > 65535 {
+				return nil, fmt.Errorf("Invalid probe port in %q", field)
+			}
+			host, port = h, n
+		} else if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			host = field[1 : len(field)-1]
+		}
+		if host == "" {
+			return nil, fmt.Errorf("Invalid probe target %q, expected a host such as 1.1.1.1", field)
+		}
+		targets = append(targets, &probeTarget{host: host, port: port, id: uint16(os.Getpid() + i)})
+	}
+	return targets, nil
+}
+
+// newProber creates a prober for the targets of spec, probing each once per interval.
+func newProber(spec string, interval time.Duration) (*prober, error) {
+	targets, err := parseProbeTargets(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &prober{targets: targets, interval: interval, stop: make(chan struct{})}, nil
+}
+
+// start launches one probe loop per target.
+func (p *prober) start() {
+	for _, t := range p.targets {
+		go func() {
+			defer handlePanic()
+			t.run(p.interval, p.stop)
+		}()
+	}
+}
+
+// close stops the probe loops. Probes in flight are abandoned rather than waited for, so
+// they never delay shutdown.
+func (p *prober) close() {
+	close(p.stop)
+}
+
+// take returns the results of every target since the previous call, RTTs in milliseconds
+// rounded to precision.
+func (p *prober) take(precision int) []ProbeResult {
+	results := make([]ProbeResult, len(p.targets))
+	for i, t := range p.targets {
+		t.mu.Lock()
+		results[i] = ProbeResult{Target: t.host, Method: t.method}
+		if t.answered > 0 {
+			rtt := netstats.Round(float64(t.rttSum)/float64(t.answered)/float64(time.Millisecond), precision)
+			results[i].RTTMs = &rtt
+		}
+		if t.sent > 0 {
+			loss := netstats.Round(float64(t.sent-t.answered)/float64(t.sent), precision)
+			results[i].Loss = &loss
+		}
+		t.sent, t.answered, t.rttSum = 0, 0, 0
+		t.mu.Unlock()
+	}
+	return results
+}
+
+// run probes the target once per interval until stop is closed. Raw ICMP is tried first; if
+// the process may not open raw sockets, UDP probes are used instead. Probes start right away
+// and time out after half an interval, so each one completes midway between two samples.
+func (t *probeTarget) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	timeout := min(interval/2, maxProbeTimeout)
+
+	var addr *stdnet.IPAddr
+	for first := true; ; first = false {
+		if !first {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+
+		if addr == nil {
+			var err error
+			if addr, err = stdnet.ResolveIPAddr("ip", t.host); err != nil {
+				logWarnf("Error resolving probe target %s: %v", t.host, err)
+				addr = nil
+				t.record(false, 0)
+				continue
+			}
+		}
+
+		rtt, err := t.probe(addr, timeout)
+		if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+			logDebugf("Probe of %s failed: %v", t.host, err)
+		}
+		t.record(err == nil, rtt)
+	}
+}
+
+// record adds the outcome of one probe.
+func (t *probeTarget) record(answered bool, rtt time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sent++
+	if answered {
+		t.answered++
+		t.rttSum += rtt
+	}
+}
+
+// probe sends one probe to addr and waits up to timeout for its answer.
+func (t *probeTarget) probe(addr *stdnet.IPAddr, timeout time.Duration) (time.Duration, error) {
+	if t.method != "udp" {
+		rtt, err := t.probeICMP(addr, timeout)
+		if !errors.Is(err, syscall.EPERM) && !errors.Is(err, syscall.EACCES) && !errors.Is(err, syscall.EPROTONOSUPPORT) {
+			t.setMethod("icmp")
+			return rtt, err
+		}
+		logInfof("Raw ICMP sockets are not allowed, probing %s over UDP port %d instead", t.host, t.port)
+		t.setMethod("udp")
+	}
+	return t.probeUDP(addr, timeout)
+}
+
+func (t *probeTarget) setMethod(method string) {
+	t.mu.Lock()
+	t.method = method
+	t.mu.Unlock()
+}
+
+// probeICMP sends an ICMP echo request and waits for the matching reply.
+func (t *probeTarget) probeICMP(addr *stdnet.IPAddr, timeout time.Duration) (time.Duration, error) {
+	network, echo, reply := "ip4:icmp", byte(8), byte(0)
+	if addr.IP.To4() == nil {
+		network, echo, reply = "ip6:ipv6-icmp", 128, 129
+	}
+	conn, err := stdnet.ListenPacket(network, "")
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	t.seq++
+	msg := make([]byte, 16)
+	msg[0] = echo
+	binary.BigEndian.PutUint16(msg[4:], t.id)
+	binary.BigEndian.PutUint16(msg[6:], t.seq)
+	if echo == 8 {
+		// The kernel computes ICMPv6 checksums itself.
+		binary.BigEndian.PutUint16(msg[2:], icmpChecksum(msg))
+	}
+
+	sent := time.Now()
+	if err := conn.SetDeadline(sent.Add(timeout)); err != nil {
+		return 0, err
+	}
+	if _, err := conn.WriteTo(msg, addr); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		// Raw sockets see every ICMP message of the host, so only our reply counts.
+		if n >= 8 && buf[0] == reply && binary.BigEndian.Uint16(buf[4:]) == t.id &&
+			binary.BigEndian.Uint16(buf[6:]) == t.seq && from.(*stdnet.IPAddr).IP.Equal(addr.IP) {
+			return time.Since(sent), nil
+		}
+	}
+}
+
+// probeUDP sends a small datagram and waits for either an answer or the port unreachable
+// message, which a connected UDP socket reports as a refused connection.
+func (t *probeTarget) probeUDP(addr *stdnet.IPAddr, timeout time.Duration) (time.Duration, error) {
+	conn, err := stdnet.DialUDP("udp", nil, &stdnet.UDPAddr{IP: addr.IP, Port: t.port, Zone: addr.Zone})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	sent := time.Now()
+	if err := conn.SetDeadline(sent.Add(timeout)); err != nil {
+		return 0, err
+	}
+	if _, err := conn.Write([]byte("zag-netstats")); err != nil {
+		return 0, err
+	}
+	_, err = conn.Read(make([]byte, 512))
+	if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+		return time.Since(sent), nil
+	}
+	return 0, err
+}
+
+// icmpChecksum computes the Internet checksum of an ICMP message.
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
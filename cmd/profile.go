@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// profiles are named bundles of flag defaults selected with -profile. Settings resolve in the
+// order built-in defaults, then the profile, then flags given on the command line.
+var profiles = map[string]map[string]string{
+	"human": {
+		"format":       "table",
+		"live":         "true",
+		"exit-summary": "true",
+		"time-format":  "rfc3339",
+		"utc":          "false",
+	},
+	"machine": {
+		"format":         "json",
+		"counters":       "true",
+		"time-format":    "rfc3339",
+		"utc":            "true",
+		"no-color":       "true",
+		"buffer-samples": "0",
+		"buffer-flush":   "0",
+	},
+}
+
+// profileNames returns the names of the available profiles, sorted.
+func profileNames() []string {
+	return slices.Sorted(maps.Keys(profiles))
+}
+
+// applyProfile sets the defaults of the named profile on every flag of fs that was not given
+// explicitly. An empty name leaves the built-in defaults in place.
+func applyProfile(fs *flag.FlagSet, name string) error {
+	if name == "" {
+		return nil
+	}
+	settings, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("Unknown profile %q. Allowed values: %s", name, strings.Join(profileNames(), ", "))
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[canonicalFlag(f.Name)] = true })
+
+	for flagName, value := range settings {
+		if explicit[flagName] {
+			continue
+		}
+		if err := fs.Set(flagName, value); err != nil {
+			return fmt.Errorf("profile %s: %v", name, err)
+		}
+	}
+	return nil
+}
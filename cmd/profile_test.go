@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// profileSettings are the settings the profiles change, as config print shows them.
+type profileSettings struct {
+	Format        string
+	Live          bool
+	ExitSummary   bool
+	Counters      bool
+	TimeFormat    string
+	UTC           bool
+	NoColor       bool
+	BufferSamples int
+	BufferFlush   time.Duration
+}
+
+func settingsOf(cfg monitorConfig) profileSettings {
+	return profileSettings{cfg.Format, cfg.Live, cfg.ExitSummary, cfg.Counters, cfg.TimeFormat, cfg.UTC, cfg.NoColor, cfg.BufferSamples, cfg.BufferFlush}
+}
+
+func TestProfiles(t *testing.T) {
+	defaults := profileSettings{Format: "table", TimeFormat: "rfc3339"}
+	human := profileSettings{Format: "table", Live: true, ExitSummary: true, TimeFormat: "rfc3339"}
+	machine := profileSettings{Format: "json", Counters: true, TimeFormat: "rfc3339", UTC: true, NoColor: true}
+	with := func(s profileSettings, f func(*profileSettings)) profileSettings {
+		f(&s)
+		return s
+	}
+
+	tests := []struct {
+		args []string
+		want profileSettings
+	}{
+		{nil, defaults},
+		{[]string{"--profile", "human"}, human},
+		{[]string{"--profile", "machine"}, machine},
+
+		// Explicit flags win over the profile, before or after it.
+		{[]string{"--profile", "machine", "--format", "csv"}, with(machine, func(s *profileSettings) { s.Format = "csv" })},
+		{[]string{"--utc=false", "--profile", "machine"}, with(machine, func(s *profileSettings) { s.UTC = false })},
+		{[]string{"--profile", "human", "--live=false", "--exit-summary=false"}, with(human, func(s *profileSettings) { s.Live, s.ExitSummary = false, false })},
+		{[]string{"--profile", "machine", "--buffer-samples", "8"}, with(machine, func(s *profileSettings) { s.BufferSamples = 8 })},
+
+		// So do the aliases of those flags.
+		{[]string{"--profile", "machine", "-f", "yaml"}, with(machine, func(s *profileSettings) { s.Format = "yaml" })},
+		{[]string{"--profile", "machine", "--ts-format", "unix"}, with(machine, func(s *profileSettings) { s.TimeFormat = "unix" })},
+		{[]string{"--profile", "human", "--time-format", "kitchen"}, with(human, func(s *profileSettings) { s.TimeFormat = "kitchen" })},
+	}
+	for _, tt := range tests {
+		cfg, err := parseConfig("test", tt.args)
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if got := settingsOf(cfg); got != tt.want {
+			t.Errorf("%q:\n got %+v\nwant %+v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestUnknownProfile(t *testing.T) {
+	_, err := parseConfig("test", []string{"--profile", "robot"})
+	if err == nil || !strings.Contains(err.Error(), "human, machine") {
+		t.Errorf("unknown profile: error %v, want one listing human, machine", err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errQdiscUnsupported is returned where queueing discipline statistics are not available.
+var errQdiscUnsupported = errors.New("qdisc statistics are only available on Linux")
+
+// QdiscStats are the statistics of an interface's root queueing discipline. A growing backlog
+// while throughput stays flat is the signature of bufferbloat.
+type QdiscStats struct {
+	Kind           string `json:"kind"`           // Queueing discipline, e.g. fq_codel or mq
+	BacklogBytes   uint32 `json:"backlogBytes"`   // Bytes queued at the time of reading
+	BacklogPackets uint32 `json:"backlogPackets"` // Packets queued at the time of reading
+	Drops          uint32 `json:"drops"`          // Packets dropped by the qdisc since it was created
+	Requeues       uint32 `json:"requeues"`       // Packets requeued since the qdisc was created
+}
+
+// parseTCQdisc parses the output of "tc -s qdisc show dev <iface>" and returns the statistics
+// of the root qdisc, the first one listed with "root":
+//
+//	qdisc fq_codel 0: root refcnt 2 limit 10240p flows 1024 ...
+//	 Sent 1234 bytes 12 pkt (dropped 0, overlimits 0 requeues 0)
+//	 backlog 0b 0p requeues 0
+func parseTCQdisc(r io.Reader) (QdiscStats, error) {
+	var q QdiscStats
+	inRoot, found := false, false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "qdisc" {
+			if found {
+				break
+			}
+			inRoot = len(fields) >= 4 && fields[3] == "root"
+			if inRoot {
+				q.Kind, found = fields[1], true
+			}
+			continue
+		}
+		if !inRoot {
+			continue
+		}
+		for i := 0; i+1 < len(fields); i++ {
+			value := strings.Trim(fields[i+1], "(),")
+			switch strings.Trim(fields[i], "(") {
+			case "dropped":
+				q.Drops = parseTCCount(value)
+			case "requeues":
+				q.Requeues = parseTCCount(value)
+			case "backlog":
+				q.BacklogBytes = parseTCCount(strings.TrimSuffix(value, "b"))
+				if i+2 < len(fields) {
+					q.BacklogPackets = parseTCCount(strings.TrimSuffix(fields[i+2], "p"))
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return QdiscStats{}, err
+	}
+	if !found {
+		return QdiscStats{}, errors.New("no root qdisc in tc output")
+	}
+	return q, nil
+}
+
+// parseTCCount parses a count printed by tc, which abbreviates large backlogs, e.g. "12Kb".
+func parseTCCount(s string) uint32 {
+	mult := 1.0
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult, s = 1e3, strings.TrimSuffix(s, "K")
+	case strings.HasSuffix(s, "M"):
+		mult, s = 1e6, strings.TrimSuffix(s, "M")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return uint32(v * mult)
+}
+
+// refreshQdisc re-reads the qdisc statistics of a monitored interface and emits a qdisc-drops
+// event when the qdisc dropped packets since the previous reading. When the statistics cannot
+// be read at all, a single warning is logged and they are left out from then on.
+func (nm *NetworkMonitor) refreshQdisc(name string) {
+	q, err := readQdisc(name)
+	if err != nil {
+		if len(nm.qdisc) == 0 {
+			logWarnf("Qdisc statistics are left out: %v", err)
+			nm.qdiscEnabled = false
+		} else {
+			nm.errThrottle.logf("Error reading qdisc statistics of %s: %v", name, err)
+		}
+		return
+	}
+
+	if prev := nm.qdisc[name]; prev != nil && prev.Kind == q.Kind && q.Drops > prev.Drops {
+		dropped := q.Drops - prev.Drops
+		nm.emitEvent(Event{
+			Type:      eventQdiscDrops,
+			Timestamp: Timestamp(time.Now()),
+			Interface: name,
+			Message:   fmt.Sprintf("%s qdisc dropped %d packets, backlog %d bytes in %d packets", q.Kind, dropped, q.BacklogBytes, q.BacklogPackets),
+			Details:   map[string]uint32{"dropped": dropped, "drops": q.Drops, "backlogBytes": q.BacklogBytes},
+		})
+	}
+	nm.qdisc[name] = &q
+}
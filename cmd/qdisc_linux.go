@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	stdnet "net"
+	"os/exec"
+	"syscall"
+)
+
+// Netlink constants of the traffic control API (linux/rtnetlink.h, linux/pkt_sched.h,
+// linux/gen_stats.h) missing from package syscall.
+const (
+	rtmNewQdisc    = 36         // RTM_NEWQDISC, the type of each qdisc in a dump
+	rtmGetQdisc    = 38         // RTM_GETQDISC
+	tcaKind        = 1          // TCA_KIND
+	tcaStats2      = 7          // TCA_STATS2
+	tcaStatsQueue  = 3          // TCA_STATS_QUEUE within TCA_STATS2
+	tcHandleRoot   = 0xFFFFFFFF // TC_H_ROOT, the parent of a root qdisc
+	tcmsgLen       = 20         // sizeof(struct tcmsg)
+	gnetQueueLen   = 20         // sizeof(struct gnet_stats_queue)
+	nlmsgAlignment = 4
+)
+
+// readQdisc reads the statistics of the root qdisc of iface over netlink, falling back to
+// parsing tc's output where the netlink request fails.
+func readQdisc(iface string) (QdiscStats, error) {
+	q, err := readQdiscNetlink(iface)
+	if err == nil {
+		return q, nil
+	}
+	out, tcErr := exec.Command("tc", "-s", "qdisc", "show", "dev", iface).Output()
+	if tcErr != nil {
+		return QdiscStats{}, fmt.Errorf("netlink: %v; tc: %v", err, tcErr)
+	}
+	return parseTCQdisc(bytes.NewReader(out))
+}
+
+// readQdiscNetlink dumps the qdiscs of iface with an RTM_GETQDISC request and returns the root one.
+func readQdiscNetlink(iface string) (QdiscStats, error) {
+	link, err := stdnet.InterfaceByName(iface)
+	if err != nil {
+		return QdiscStats{}, err
+	}
+
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return QdiscStats{}, err
+	}
+	defer syscall.Close(fd)
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return QdiscStats{}, err
+	}
+
+	req := make([]byte, syscall.NLMSG_HDRLEN+tcmsgLen)
+	binary.NativeEndian.PutUint32(req[0:], uint32(len(req)))
+	binary.NativeEndian.PutUint16(req[4:], rtmGetQdisc)
+	binary.NativeEndian.PutUint16(req[6:], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	binary.NativeEndian.PutUint32(req[8:], 1)
+	binary.NativeEndian.PutUint32(req[syscall.NLMSG_HDRLEN+4:], uint32(link.Index))
+	if err := syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return QdiscStats{}, err
+	}
+
+	buf := make([]byte, 1<<16)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return QdiscStats{}, err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return QdiscStats{}, err
+		}
+		for _, m := range msgs {
+			switch m.Header.Type {
+			case syscall.NLMSG_DONE:
+				return QdiscStats{}, errors.New("no root qdisc found")
+			case syscall.NLMSG_ERROR:
+				if len(m.Data) >= 4 {
+					if errno := int32(binary.NativeEndian.Uint32(m.Data)); errno != 0 {
+						return QdiscStats{}, syscall.Errno(-errno)
+					}
+				}
+				return QdiscStats{}, errors.New("netlink error")
+			case rtmNewQdisc:
+				if q, ok := parseQdiscMessage(m.Data, link.Index); ok {
+					return q, nil
+				}
+			}
+		}
+	}
+}
+
+// parseQdiscMessage decodes an RTM_NEWQDISC message, reporting ok only for the root qdisc of
+// the interface with the given index.
+func parseQdiscMessage(data []byte, index int) (QdiscStats, bool) {
+	if len(data) < tcmsgLen {
+		return QdiscStats{}, false
+	}
+	ifindex := int32(binary.NativeEndian.Uint32(data[4:]))
+	parent := binary.NativeEndian.Uint32(data[12:])
+	if int(ifindex) != index || parent != tcHandleRoot {
+		return QdiscStats{}, false
+	}
+
+	var q QdiscStats
+	for attrType, value := range netlinkAttrs(data[tcmsgLen:]) {
+		switch attrType {
+		case tcaKind:
+			q.Kind = string(bytes.TrimRight(value, "\x00"))
+		case tcaStats2:
+			for statType, stat := range netlinkAttrs(value) {
+				if statType == tcaStatsQueue && len(stat) >= gnetQueueLen {
+					q.BacklogPackets = binary.NativeEndian.Uint32(stat[0:]) // qlen
+					q.BacklogBytes = binary.NativeEndian.Uint32(stat[4:])
+					q.Drops = binary.NativeEndian.Uint32(stat[8:])
+					q.Requeues = binary.NativeEndian.Uint32(stat[12:])
+				}
+			}
+		}
+	}
+	return q, true
+}
+
+// netlinkAttrs iterates over the type and value of each attribute in b.
+func netlinkAttrs(b []byte) func(yield func(uint16, []byte) bool) {
+	return func(yield func(uint16, []byte) bool) {
+		for len(b) >= syscall.SizeofRtAttr {
+			l := int(binary.NativeEndian.Uint16(b[0:]))
+			if l < syscall.SizeofRtAttr || l > len(b) {
+				return
+			}
+			// The nested flag shares the type field; masking it off keeps lookups simple.
+			if !yield(binary.NativeEndian.Uint16(b[2:])&0x3FFF, b[syscall.SizeofRtAttr:l]) {
+				return
+			}
+			aligned := (l + nlmsgAlignment - 1) &^ (nlmsgAlignment - 1)
+			if aligned >= len(b) {
+				return
+			}
+			b = b[aligned:]
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// readQdisc reports qdisc statistics as unavailable outside Linux.
+func readQdisc(iface string) (QdiscStats, error) {
+	return QdiscStats{}, errQdiscUnsupported
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// quantityHelp documents the forms of size and rate flags once, below the flag list.
+const quantityHelp = "\nSizes and rates are given as " + netstats.QuantityForms + ", e.g. 512MB or 500Mbit.\n"
+
+// fixedUnits are the values of -unit with the prefix each fixes, by unit system. Binary names
+// are accepted unless -si is set; bit units are only valid, and required, with -bits.
+var fixedUnits = []struct {
+	bytes, binary, bits string
+	prefix              netstats.Prefix
+}{
+	{"B", "B", "bit", netstats.PrefixNone},
+	{"KB", "KiB", "Kbit", netstats.PrefixKilo},
+	{"MB", "MiB", "Mbit", netstats.PrefixMega},
+	{"GB", "GiB", "Gbit", netstats.PrefixGiga},
+}
+
+// parseFixedUnit interprets the value of -unit, case-insensitively, for the unit system given
+// by -bits and -si. An empty value lets every value pick its unit.
+func parseFixedUnit(s string, bits, si bool) (netstats.Prefix, error) {
+	if s == "" {
+		return netstats.PrefixAuto, nil
+	}
+	var valid []string
+	for _, u := range fixedUnits {
+		switch {
+		case bits:
+			valid = append(valid, u.bits)
+			if strings.EqualFold(s, u.bits) {
+				return u.prefix, nil
+			}
+		case si:
+			valid = append(valid, u.bytes)
+			if strings.EqualFold(s, u.bytes) {
+				return u.prefix, nil
+			}
+		default:
+			valid = append(valid, u.bytes)
+			if strings.EqualFold(s, u.bytes) || strings.EqualFold(s, u.binary) {
+				return u.prefix, nil
+			}
+		}
+	}
+	switch {
+	case bits:
+		return 0, fmt.Errorf("Invalid unit %q, expected one of %s with -bits; totals use the byte unit of the same size", s, strings.Join(valid, ", "))
+	case si:
+		return 0, fmt.Errorf("Invalid unit %q, expected one of %s with -si", s, strings.Join(valid, ", "))
+	}
+	return 0, fmt.Errorf("Invalid unit %q, expected one of %s (1024-based, also accepted as KiB, MiB, GiB; bit units need -bits)", s, strings.Join(valid, ", "))
+}
+
+// quantityUnit is a unit in which String renders a size or rate.
+type quantityUnit struct {
+	name  string
+	bytes float64 // Bytes, or bytes per second, per unit
+}
+
+// sizeUnits are the units String uses for sizes, largest first.
+var sizeUnits = []quantityUnit{
+	{"TiB", netstats.TB}, {"GiB", netstats.GB}, {"MiB", netstats.MB}, {"KiB", netstats.KB},
+}
+
+// rateBitUnits are the bit units String uses for rates that are not whole binary units.
+var rateBitUnits = []quantityUnit{
+	{"Tbit", 1e12 / 8}, {"Gbit", 1e9 / 8}, {"Mbit", 1e6 / 8}, {"Kbit", 1e3 / 8},
+}
+
+// unitString renders bytes in the largest unit of units it is a whole multiple of, so
+// that parsing the result gives bytes back exactly.
+func unitString(bytes float64, units []quantityUnit) (string, bool) {
+	for _, u := range units {
+		if n := bytes / u.bytes; n >= 1 && n == float64(int64(n)) {
+			return strconv.FormatInt(int64(n), 10) + u.name, true
+		}
+	}
+	return "", false
+}
+
+// sizeValue is a flag value holding a data amount in bytes, given as by netstats.ParseUsage.
+// Every size flag uses it, so all accept the same units.
+type sizeValue uint64
+
+func (v *sizeValue) String() string {
+	if s, ok := unitString(float64(*v), sizeUnits); ok {
+		return s
+	}
+	return strconv.FormatUint(uint64(*v), 10) + "B"
+}
+
+func (v *sizeValue) Set(s string) error {
+	n, err := netstats.ParseUsage(s)
+	if err != nil {
+		return err
+	}
+	*v = sizeValue(n)
+	return nil
+}
+
+// MarshalText records the size in the configuration as it would be typed.
+func (v sizeValue) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// rateValue is a flag value holding a rate in bytes per second, given as by
+// netstats.ParseSpeed. Every rate flag uses it, so all accept the same units.
+type rateValue float64
+
+func (v *rateValue) String() string {
+	if s, ok := unitString(float64(*v), sizeUnits); ok {
+		return s + "/s"
+	}
+	if s, ok := unitString(float64(*v), rateBitUnits); ok {
+		return s
+	}
+	return strconv.FormatFloat(float64(*v), 'f', -1, 64) + "B/s"
+}
+
+func (v *rateValue) Set(s string) error {
+	n, err := netstats.ParseSpeed(s)
+	if err != nil {
+		return err
+	}
+	*v = rateValue(n)
+	return nil
+}
+
+// MarshalText records the rate in the configuration as it would be typed.
+func (v rateValue) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// bandsValue is a flag value holding the warning and critical rates of a color band, given as
+// two rates such as "10MB/s,50MB/s".
+type bandsValue [2]rateValue
+
+func (v *bandsValue) String() string {
+	if !v.set() {
+		return ""
+	}
+	return v[0].String() + "," + v[1].String()
+}
+
+func (v *bandsValue) Set(s string) error {
+	warn, crit, ok := strings.Cut(s, ",")
+	if !ok {
+		return errors.New("expected the warning and critical rates, such as 10MB/s,50MB/s")
+	}
+	var b bandsValue
+	if err := b[0].Set(strings.TrimSpace(warn)); err != nil {
+		return err
+	}
+	if err := b[1].Set(strings.TrimSpace(crit)); err != nil {
+		return err
+	}
+	if b[1] < b[0] {
+		return fmt.Errorf("the critical rate %s is below the warning rate %s", &b[1], &b[0])
+	}
+	*v = b
+	return nil
+}
+
+// set reports whether the band was given.
+func (v *bandsValue) set() bool {
+	return v[0] > 0 || v[1] > 0
+}
+
+// MarshalText records the band in the configuration as it would be typed.
+func (v bandsValue) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
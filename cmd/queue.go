@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"sync"
+)
+
+// queuedRecord is a rendered record waiting in an outputQueue.
+type queuedRecord struct {
+	data      []byte
+	droppable bool // Whether the record is a sample that may be dropped when the queue is full
+}
+
+// outputQueue hands rendered records to a goroutine that writes them, so a slow destination
+// such as a terminal does not delay the next counter read. When the queue is full, the oldest
+// queued sample is dropped; other records, such as events, wait for room instead. Dropping
+// only affects what is displayed, as totals are computed before records are rendered.
+type outputQueue struct {
+	w       io.Writer
+	size    int
+	mu      sync.Mutex
+	cond    *sync.Cond // Signaled whenever records are queued or written
+	records []queuedRecord
+	writing bool   // Whether the writer goroutine is writing a record
+	err     error  // First write error, returned by later calls
+	dropped uint64 // Samples dropped because the queue was full
+}
+
+// newOutputQueue starts a queue of up to size records written to w in order.
+func newOutputQueue(w io.Writer, size int) *outputQueue {
+	q := &outputQueue{w: w, size: size}
+	q.cond = sync.NewCond(&q.mu)
+	go q.run()
+	return q
+}
+
+// put queues a copy of data. It returns the error of an earlier write, if any.
+func (q *outputQueue) put(data []byte, droppable bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.records) >= q.size && q.err == nil {
+		if i := slices.IndexFunc(q.records, func(r queuedRecord) bool { return r.droppable }); i >= 0 {
+			q.records = slices.Delete(q.records, i, i+1)
+			q.dropped++
+			break
+		}
+		if droppable {
+			q.dropped++ // The queue holds only records that must not be dropped
+			return nil
+		}
+		q.cond.Wait()
+	}
+	if q.err != nil {
+		return q.err
+	}
+	q.records = append(q.records, queuedRecord{data: bytes.Clone(data), droppable: droppable})
+	q.cond.Broadcast()
+	return nil
+}
+
+// run writes queued records until the process exits. After a write error, records are
+// discarded and the error is reported to callers.
+func (q *outputQueue) run() {
+	defer handlePanic()
+	q.mu.Lock()
+	for {
+		for len(q.records) == 0 {
+			q.cond.Wait()
+		}
+		r := q.records[0]
+		q.records = slices.Delete(q.records, 0, 1)
+		if q.err != nil {
+			continue
+		}
+		q.writing = true
+		q.mu.Unlock()
+		_, err := q.w.Write(r.data)
+		q.mu.Lock()
+		q.writing = false
+		if err != nil {
+			q.err = err
+		}
+		q.cond.Broadcast()
+	}
+}
+
+// drain waits until every queued record is written and returns the first write error.
+func (q *outputQueue) drain() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for (len(q.records) > 0 || q.writing) && q.err == nil {
+		q.cond.Wait()
+	}
+	return q.err
+}
+
+// droppedRecords returns the number of samples dropped so far.
+func (q *outputQueue) droppedRecords() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
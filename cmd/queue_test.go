@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// gatedWriter blocks every write until the gate is opened, reporting on started when the
+// first write begins.
+type gatedWriter struct {
+	gate    chan struct{}
+	started chan struct{}
+	buf     bytes.Buffer
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	if w.started != nil {
+		close(w.started)
+		w.started = nil
+	}
+	<-w.gate
+	return w.buf.Write(p)
+}
+
+func TestOutputQueueDropsOldestSample(t *testing.T) {
+	w := &gatedWriter{gate: make(chan struct{}), started: make(chan struct{})}
+	started := w.started
+	out := newOutputWriter(w, 0, 0)
+	out.startQueue(2)
+	write := func(s string, sample bool) {
+		t.Helper()
+		render := func(w io.Writer) { io.WriteString(w, s) }
+		var err error
+		if sample {
+			err = out.writeSample(render)
+		} else {
+			err = out.writeRecord(render)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("s1 ", true)
+	<-started // s1 is being written, so the queue is empty again
+	write("s2 ", true)
+	write("e1 ", false)
+	write("s3 ", true) // Full: s2 is dropped
+	write("s4 ", true) // Full: s3 is dropped, the event stays
+	if got := out.queue.droppedRecords(); got != 2 {
+		t.Errorf("dropped %d samples, want 2", got)
+	}
+
+	// Closing waits until everything still queued is written.
+	close(w.gate)
+	if err := out.closeArray(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.buf.String(), "s1 e1 s4 "; got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+}
+
+func TestOutputQueueKeepsEvents(t *testing.T) {
+	w := &gatedWriter{gate: make(chan struct{}), started: make(chan struct{})}
+	started := w.started
+	out := newOutputWriter(w, 0, 0)
+	out.startQueue(1)
+	out.writeRecord(func(w io.Writer) { io.WriteString(w, "e1 ") })
+	<-started
+	out.writeRecord(func(w io.Writer) { io.WriteString(w, "e2 ") })
+	out.writeSample(func(w io.Writer) { io.WriteString(w, "s1 ") }) // Only an event is queued: the sample is dropped
+
+	done := make(chan error)
+	go func() { done <- out.writeRecord(func(w io.Writer) { io.WriteString(w, "e3 ") }) }()
+	close(w.gate) // The third event waits for room instead of dropping the second
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := out.closeArray(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.buf.String(), "e1 e2 e3 "; got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+	if got := out.queue.droppedRecords(); got != 1 {
+		t.Errorf("dropped %d samples, want 1", got)
+	}
+}
+
+// slowWriter takes delay over every write, like a terminal or pipe that cannot keep up.
+type slowWriter struct {
+	delay time.Duration
+}
+
+func (w slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return len(p), nil
+}
+
+// timedSource records when the counters are read.
+type timedSource struct {
+	*fakeSource
+	reads []time.Time
+}
+
+func (s *timedSource) counters(iface string) (net.IOCountersStat, error) {
+	s.reads = append(s.reads, time.Now())
+	return s.fakeSource.counters(iface)
+}
+
+// TestOutputQueueKeepsTicksOnTime runs the sampling loop against a writer four times slower
+// than the interval. With the queue, the counters are still read on every tick; without it,
+// each read waits for the previous write.
+func TestOutputQueueKeepsTicksOnTime(t *testing.T) {
+	const interval, delay = 50 * time.Millisecond, 200 * time.Millisecond
+	gaps := func(queue bool) (shortest, longest time.Duration) {
+		src := &timedSource{fakeSource: newFakeSource()}
+		src.set("eth0", 1000, 2000)
+		nm, _ := newTestMonitor(t, "eth0", src, "-f", "json", "-interval", interval.String(), "-count", "6")
+		nm.out.w = slowWriter{delay}
+		if queue {
+			nm.out.startQueue(64)
+		}
+		if err := nm.collectStats(); err != nil {
+			t.Fatal(err)
+		}
+		if len(src.reads) < 7 {
+			t.Fatalf("counters read %d times, want the initial reading and 6 ticks", len(src.reads))
+		}
+		shortest = time.Hour
+		for i := 2; i < len(src.reads); i++ { // From the first tick on, after the initial reading
+			gap := src.reads[i].Sub(src.reads[i-1])
+			shortest, longest = min(shortest, gap), max(longest, gap)
+		}
+		return shortest, longest
+	}
+
+	if _, longest := gaps(true); longest > 3*interval {
+		t.Errorf("with the queue, ticks were up to %v apart, want about %v", longest, interval)
+	}
+	if shortest, _ := gaps(false); shortest < delay*3/4 {
+		t.Errorf("without the queue, ticks were as little as %v apart, want them held up by the %v writes", shortest, delay)
+	}
+}
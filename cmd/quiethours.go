@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// quietWindow is a daily time range of -quiet-hours. A window whose end is not after its start
+// crosses midnight, such as 22:00-02:00.
+type quietWindow struct {
+	start, end int           // Minutes since midnight
+	interval   time.Duration // Sampling interval during the window, 0 to keep the configured one
+}
+
+// contains reports whether the minute of day m falls inside the window.
+func (w quietWindow) contains(m int) bool {
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// quietHours are the daily windows, such as a backup window, whose traffic is expected. Warning
+// events inside them are counted but not emitted, and samples are tagged so reports can tell
+// them apart.
+type quietHours struct {
+	windows []quietWindow
+	loc     *time.Location // Time zone the windows are given in
+}
+
+// QuietHoursSummary accounts for the part of a session that fell into quiet hours.
+type QuietHoursSummary struct {
+	Samples          int    `json:"samples"`
+	SentBytes        uint64 `json:"sentBytes"`
+	RecvBytes        uint64 `json:"recvBytes"`
+	SuppressedEvents int    `json:"suppressedEvents"` // Warning events not emitted
+}
+
+// add accounts for a sample taken in quiet hours.
+func (s *QuietHoursSummary) add(sent, recv uint64) {
+	s.Samples++
+	s.SentBytes += sent
+	s.RecvBytes += recv
+}
+
+// parseQuietHours parses a comma-separated list of HH:MM-HH:MM ranges, each optionally followed
+// by /interval to sample at a different interval inside it, e.g. "01:00-05:00/60s,12:00-13:00".
+// tz names the time zone of the ranges; an empty name selects local time.
+func parseQuietHours(spec, tz string) (*quietHours, error) {
+	q := &quietHours{loc: time.Local}
+	if tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid quiet hours time zone %q: %v", tz, err)
+		}
+		q.loc = loc
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		span, interval, hasInterval := strings.Cut(part, "/")
+		from, to, ok := strings.Cut(span, "-")
+		if !ok {
+			return nil, fmt.Errorf("Invalid quiet hours %q, expected HH:MM-HH:MM[/interval]", part)
+		}
+		var w quietWindow
+		var err error
+		if w.start, err = parseClock(from); err != nil {
+			return nil, err
+		}
+		if w.end, err = parseClock(to); err != nil {
+			return nil, err
+		}
+		if w.start == w.end {
+			return nil, fmt.Errorf("Quiet hours %q start and end at the same time", part)
+		}
+		if hasInterval {
+			var v intervalValue
+			if err := v.Set(interval); err != nil || !validInterval(time.Duration(v)) {
+				return nil, fmt.Errorf("Invalid quiet hours interval %q, expected %v to 1h", interval, netstats.MinInterval)
+			}
+			w.interval = time.Duration(v)
+		}
+		q.windows = append(q.windows, w)
+	}
+	return q, nil
+}
+
+// parseClock parses an HH:MM time of day into minutes since midnight. 24:00 is accepted as
+// the end of the day.
+func parseClock(s string) (int, error) {
+	var h, m int
+	if n, err := fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &h, &m); err != nil || n != 2 || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("Invalid time of day %q, expected HH:MM", s)
+	}
+	return (h*60 + m) % (24 * 60), nil
+}
+
+// window returns the window containing t, or nil outside quiet hours.
+func (q *quietHours) window(t time.Time) *quietWindow {
+	t = t.In(q.loc)
+	m := t.Hour()*60 + t.Minute()
+	for i := range q.windows {
+		if q.windows[i].contains(m) {
+			return &q.windows[i]
+		}
+	}
+	return nil
+}
+
+// hasIntervals reports whether any window changes the sampling interval.
+func (q *quietHours) hasIntervals() bool {
+	for _, w := range q.windows {
+		if w.interval > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// nextBoundary returns the first window start or end after t.
+func (q *quietHours) nextBoundary(t time.Time) time.Time {
+	t = t.In(q.loc)
+	var next time.Time
+	for _, w := range q.windows {
+		for _, m := range []int{w.start, w.end} {
+			b := time.Date(t.Year(), t.Month(), t.Day(), m/60, m%60, 0, 0, q.loc)
+			if !b.After(t) {
+				b = time.Date(t.Year(), t.Month(), t.Day()+1, m/60, m%60, 0, 0, q.loc)
+			}
+			if next.IsZero() || b.Before(next) {
+				next = b
+			}
+		}
+	}
+	return next
+}
+
+// applyQuietInterval switches the sampling interval to that of the quiet window containing now,
+// or back to the configured interval outside one, like the interval control command.
+func (nm *NetworkMonitor) applyQuietInterval(now time.Time, ticker *time.Ticker) {
+	interval := nm.baseInterval
+	if w := nm.quietHours.window(now); w != nil && w.interval > 0 {
+		interval = w.interval
+	}
+	if interval == nm.refreshInterval {
+		return
+	}
+	if interval == nm.baseInterval {
+		logInfof("Quiet hours ended, sampling interval restored to %v", interval)
+	} else {
+		logInfof("Quiet hours began, sampling interval set to %v", interval)
+	}
+	nm.refreshInterval = interval
+	ticker.Reset(interval)
+}
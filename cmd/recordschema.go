@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// recordSchemaJSON is the JSON Schema of the records at schemaVersion. Update it with every
+// change of a JSON field; selftest fails when records no longer match it.
+//
+//go:embed records.schema.json
+var recordSchemaJSON []byte
+
+// schemaNode is a schema in records.schema.json. Only the keywords below are understood, and
+// compiling fails on any other, so the file cannot assert what is not checked.
+type schemaNode struct {
+	Ref                  string                 `json:"$ref"`
+	Description          string                 `json:"description"`
+	Type                 schemaTypes            `json:"type"`
+	Properties           map[string]*schemaNode `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *schemaNode            `json:"items"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+	Enum                 []any                  `json:"enum"`
+	Const                json.RawMessage        `json:"const"`
+	Minimum              *float64               `json:"minimum"`
+
+	target *schemaNode // Definition named by Ref, resolved when compiling
+}
+
+// schemaTypes is the type keyword, a single type name or a list of them.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// recordSchema is the compiled record schema, with one definition per kind of record.
+type recordSchema struct {
+	version string
+	defs    map[string]*schemaNode
+}
+
+// schemaViolation describes the first part of a record that does not match its definition.
+type schemaViolation struct {
+	kind     string // Definition the record was checked against, such as sample or event
+	field    string // Path of the offending field, empty for the record itself
+	expected string
+	actual   string // JSON of the offending value, shortened
+}
+
+func (v *schemaViolation) Error() string {
+	field := v.field
+	if field == "" {
+		field = "(record)"
+	}
+	return fmt.Sprintf("%s record violates the schema: field %s: expected %s, got %s", v.kind, field, v.expected, v.actual)
+}
+
+// compileRecordSchema parses recordSchemaJSON and resolves its references, once per run.
+func compileRecordSchema() (*recordSchema, error) {
+	var doc struct {
+		Schema        string                 `json:"$schema"`
+		ID            string                 `json:"$id"`
+		Title         string                 `json:"title"`
+		Description   string                 `json:"description"`
+		SchemaVersion string                 `json:"schemaVersion"`
+		Defs          map[string]*schemaNode `json:"$defs"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(recordSchemaJSON))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid record schema: %v", err)
+	}
+	if doc.SchemaVersion != schemaVersion {
+		return nil, fmt.Errorf("the record schema describes %s records, but the monitor emits %s", doc.SchemaVersion, schemaVersion)
+	}
+	s := &recordSchema{version: doc.SchemaVersion, defs: doc.Defs}
+	for _, def := range s.defs {
+		if err := s.resolve(def); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// resolve links the references in n to their definitions.
+func (s *recordSchema) resolve(n *schemaNode) error {
+	if n.Ref != "" {
+		name, ok := strings.CutPrefix(n.Ref, "#/$defs/")
+		if n.target = s.defs[name]; !ok || n.target == nil {
+			return fmt.Errorf("invalid record schema: unknown reference %s", n.Ref)
+		}
+	}
+	for _, p := range n.Properties {
+		if err := s.resolve(p); err != nil {
+			return err
+		}
+	}
+	if n.Items != nil {
+		return s.resolve(n.Items)
+	}
+	return nil
+}
+
+// checkLines checks every JSON line of rendered output. A nil schema checks nothing.
+func (s *recordSchema) checkLines(data []byte) error {
+	if s == nil {
+		return nil
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		// printJSON logs and skips records that cannot be marshaled, such as NaN rates.
+		return &schemaViolation{kind: "unknown", expected: "a JSON record", actual: "nothing, the record could not be encoded"}
+	}
+	for _, line := range bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n")) {
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return &schemaViolation{kind: "unknown", expected: "a JSON record", actual: shortJSON(line)}
+		}
+		if err := s.checkRecord(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkValue checks a record that is written as JSON by other means than the output, such as
+// the session summary. A nil schema checks nothing.
+func (s *recordSchema) checkValue(record any) error {
+	if s == nil {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return &schemaViolation{kind: "unknown", expected: "a JSON record", actual: "nothing, the record could not be encoded: " + err.Error()}
+	}
+	return s.checkLines(data)
+}
+
+// checkRecord checks a decoded record against the definition of its kind. The samples of a
+// batch are checked on their own in turn.
+func (s *recordSchema) checkRecord(v any) error {
+	kind := recordKind(v)
+	def := s.defs[kind]
+	if def == nil {
+		return &schemaViolation{kind: "unknown", expected: "a known kind of record", actual: shortValue(v)}
+	}
+	if err := def.check(v, ""); err != nil {
+		err.kind = kind
+		return err
+	}
+	if kind == "batch" {
+		for _, sample := range v.(map[string]any)["samples"].([]any) {
+			if err := s.checkRecord(sample); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// recordKind names the definition a decoded record is checked against.
+func recordKind(v any) string {
+	switch v := v.(type) {
+	case []any:
+		return "tick"
+	case map[string]any:
+		switch t, _ := v["type"].(string); {
+		case t == recordHeader:
+			return "header"
+		case t == recordHeartbeat:
+			return "heartbeat"
+		case t == recordHourlySummary:
+			return "hourlySummary"
+		case t == "pair":
+			return "pair"
+		case t != "" || v["severity"] != nil:
+			return "event"
+		case v["samples"] != nil && v["from"] != nil:
+			return "batch"
+		case v["exitReason"] != nil:
+			return "summary"
+		case v["sentSpeed"] != nil:
+			return "sample"
+		case v["bytesSent"] != nil:
+			return "counterRecord"
+		}
+	}
+	return ""
+}
+
+// check reports the first part of v that does not match n, at path.
+func (n *schemaNode) check(v any, path string) *schemaViolation {
+	if n.target != nil {
+		return n.target.check(v, path)
+	}
+	fail := func(expected string) *schemaViolation {
+		return &schemaViolation{field: path, expected: expected, actual: shortValue(v)}
+	}
+
+	if len(n.Type) > 0 && !slices.ContainsFunc(n.Type, func(t string) bool { return hasSchemaType(v, t) }) {
+		return fail(strings.Join(n.Type, " or "))
+	}
+	if n.Const != nil {
+		var want any
+		json.Unmarshal(n.Const, &want)
+		if !jsonEqual(v, want) {
+			return fail(string(n.Const))
+		}
+	}
+	if n.Enum != nil && !slices.ContainsFunc(n.Enum, func(e any) bool { return jsonEqual(v, e) }) {
+		return fail("one of " + shortValue(n.Enum))
+	}
+	if n.Minimum != nil {
+		if num, ok := v.(json.Number); ok {
+			if f, _ := num.Float64(); f < *n.Minimum {
+				return fail(fmt.Sprintf("at least %g", *n.Minimum))
+			}
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range n.Required {
+			if _, ok := v[name]; !ok {
+				return &schemaViolation{field: joinPath(path, name), expected: "a value", actual: "nothing, the field is missing"}
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names) // Report the same field first every time
+		for _, name := range names {
+			p, ok := n.Properties[name]
+			if !ok {
+				if n.AdditionalProperties != nil && !*n.AdditionalProperties {
+					return &schemaViolation{field: joinPath(path, name), expected: "no such field", actual: shortValue(v[name])}
+				}
+				continue
+			}
+			if err := p.check(v[name], joinPath(path, name)); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if n.MinItems != nil && len(v) < *n.MinItems {
+			return fail(fmt.Sprintf("at least %d items", *n.MinItems))
+		}
+		if n.MaxItems != nil && len(v) > *n.MaxItems {
+			return fail(fmt.Sprintf("at most %d items", *n.MaxItems))
+		}
+		if n.Items != nil {
+			for i, item := range v {
+				if err := n.Items.check(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasSchemaType reports whether a value decoded with UseNumber is of the JSON Schema type t.
+func hasSchemaType(v any, t string) bool {
+	switch v := v.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case json.Number:
+		if t == "integer" {
+			f, err := v.Float64()
+			return err == nil && f == math.Trunc(f)
+		}
+		return t == "number"
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	}
+	return false
+}
+
+// jsonEqual reports whether a decoded value equals a schema constant.
+func jsonEqual(v, want any) bool {
+	if n, ok := v.(json.Number); ok {
+		f, _ := n.Float64()
+		return want == f
+	}
+	return reflect.DeepEqual(v, want)
+}
+
+// joinPath appends a field name to a dotted path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// shortValue renders a decoded value as JSON for a violation, shortened.
+func shortValue(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return shortJSON(data)
+}
+
+// shortJSON shortens data to keep violations on one readable line.
+func shortJSON(data []byte) string {
+	const max = 120
+	if len(data) > max {
+		return string(data[:max]) + "…"
+	}
+	return string(data)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/shirou/gopsutil/v4/net"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// redactedText replaces addresses and host names in redacted output.
+const redactedText = "[redacted]"
+
+// redactPatterns match identifying values that may appear in free text, such as event messages:
+// hardware addresses and IPv4 and IPv6 addresses with an optional prefix length.
+var redactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b[0-9a-f]{2}(:[0-9a-f]{2}){5}\b`),
+	regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(/\d{1,2})?\b`),
+	regexp.MustCompile(`(?i)\b([0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b(/\d{1,3})?`),
+	regexp.MustCompile(`(?i)([0-9a-f]{1,4}:)*[0-9a-f]{0,4}::([0-9a-f]{1,4}:)*[0-9a-f]{0,4}(/\d{1,3})?`),
+}
+
+// redactor scrubs identifying details from output meant to be shared: interface names become
+// stable pseudonyms (if0, if1, ...), addresses and the host name are removed, and totals are
+// rounded to two significant figures.
+type redactor struct {
+	mapPath string            // File receiving the pseudonym mapping, empty for none
+	names   map[string]string // Real interface name to pseudonym
+	used    map[string]bool   // Pseudonyms already assigned
+	nameRE  *regexp.Regexp    // Matches every known interface name as a whole word
+	hostRE  *regexp.Regexp    // Matches the host name as a whole word, nil if unknown
+	mu      sync.Mutex
+}
+
+// newRedactor creates a redactor whose pseudonyms are taken from the mapping file at mapPath,
+// when it exists, so pseudonyms stay the same across runs. The monitored interfaces are named
+// first, then every other interface of the system so messages about them are scrubbed too.
+func newRedactor(mapPath string, monitored []string) (*redactor, error) {
+	r := &redactor{mapPath: mapPath, names: make(map[string]string), used: make(map[string]bool)}
+	if mapPath != "" {
+		data, err := os.ReadFile(mapPath)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return nil, err
+		default:
+			var mapping map[string]string // Pseudonym to real name, as written by save
+			if err := json.Unmarshal(data, &mapping); err != nil {
+				return nil, fmt.Errorf("error parsing redaction map %s: %v", mapPath, err)
+			}
+			for pseudonym, name := range mapping {
+				r.names[name] = pseudonym
+				r.used[pseudonym] = true
+			}
+		}
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		r.hostRE = regexp.MustCompile(`\b` + regexp.QuoteMeta(host) + `\b`)
+	}
+
+	names := slices.Clone(monitored)
+	if ifaces, err := net.Interfaces(); err == nil {
+		for _, iface := range ifaces {
+			names = append(names, iface.Name)
+		}
+	}
+	for _, name := range names {
+		r.pseudonym(name)
+	}
+	return r, nil
+}
+
+// pseudonym returns the stable pseudonym of an interface name, assigning the next free one
+// and updating the mapping file for names not seen before.
+func (r *redactor) pseudonym(name string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p, ok := r.names[name]; ok || name == "" {
+		return p
+	}
+	var p string
+	for n := 0; p == "" || r.used[p]; n++ {
+		p = fmt.Sprintf("if%d", n)
+	}
+	r.names[name], r.used[p] = p, true
+
+	known := make([]string, 0, len(r.names))
+	for n := range r.names {
+		known = append(known, regexp.QuoteMeta(n))
+	}
+	// Longer names first, so "eth0.100" is not scrubbed as "eth0" followed by ".100".
+	slices.SortFunc(known, func(a, b string) int { return len(b) - len(a) })
+	r.nameRE = regexp.MustCompile(`\b(` + strings.Join(known, "|") + `)\b`)
+
+	if r.mapPath != "" {
+		if err := r.saveLocked(); err != nil {
+			logWarnf("Error writing redaction map: %v", err)
+		}
+	}
+	return p
+}
+
+// saveLocked writes the mapping from pseudonyms to real names, readable only by the owner.
+func (r *redactor) saveLocked() error {
+	mapping := make(map[string]string, len(r.names))
+	for name, p := range r.names {
+		mapping[p] = name
+	}
+	data, err := json.MarshalIndent(mapping, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.mapPath, append(data, '\n'), 0o600)
+}
+
+// text scrubs rendered output: known interface names become pseudonyms, and addresses and
+// the host name are replaced. It is the last line of defense for values in free text.
+func (r *redactor) text(b []byte) []byte {
+	r.mu.Lock()
+	re := r.nameRE
+	r.mu.Unlock()
+
+	for _, p := range redactPatterns {
+		b = p.ReplaceAllLiteral(b, []byte(redactedText))
+	}
+	if r.hostRE != nil {
+		b = r.hostRE.ReplaceAllLiteral(b, []byte(redactedText))
+	}
+	if re != nil {
+		b = re.ReplaceAllFunc(b, func(name []byte) []byte { return []byte(r.pseudonym(string(name))) })
+	}
+	return b
+}
+
+// sigFigs rounds v to n significant figures.
+func sigFigs(v float64, n int) float64 {
+	if v == 0 {
+		return 0
+	}
+	scale := math.Pow(10, float64(n)-math.Ceil(math.Log10(math.Abs(v))))
+	return math.Round(v*scale) / scale
+}
+
+// usage rounds a total to two significant figures.
+func (r *redactor) usage(u netstats.Usage) netstats.Usage {
+	u.Value = sigFigs(u.Value, 2)
+	return u
+}
+
+// stats redacts a sample in place: the interface gets its pseudonym, totals are rounded and
+// identifying metadata is dropped.
+func (r *redactor) stats(s *NetStats) {
+	s.Interface = r.pseudonym(s.Interface)
+	if s.Members != nil {
+		members := make([]string, len(s.Members))
+		for i, m := range s.Members {
+			members[i] = r.pseudonym(m)
+		}
+		s.Members = members
+	}
+	s.TotalSent, s.TotalRecv, s.TotalUsage = r.usage(s.TotalSent), r.usage(s.TotalRecv), r.usage(s.TotalUsage)
+	s.raw.totalSent, s.raw.totalRecv = uint64(sigFigs(float64(s.raw.totalSent), 2)), uint64(sigFigs(float64(s.raw.totalRecv), 2))
+	if s.Meta != nil {
+		meta := *s.Meta
+		meta.HardwareAddr, meta.Addresses, meta.Gateway = "", []string{}, ""
+		s.Meta = &meta
+	}
+}
+
+// summary redacts a session summary in place.
+func (r *redactor) summary(s *SessionSummary) {
+	names := strings.Split(s.Interface, ",")
+	for i, name := range names {
+		names[i] = r.pseudonym(name)
+	}
+	s.Interface = strings.Join(names, ",")
+	s.TotalSentBytes = uint64(sigFigs(float64(s.TotalSentBytes), 2))
+	s.TotalRecvBytes = uint64(sigFigs(float64(s.TotalRecvBytes), 2))
+	if s.Error != "" {
+		s.Error = string(r.text([]byte(s.Error)))
+	}
+}
+
+// monitoredInterfaces returns the names of the interfaces being monitored.
+func (nm *NetworkMonitor) monitoredInterfaces() []string {
+	switch {
+	case nm.pair[0] != "":
+		return nm.pair[:]
+	case len(nm.multi) > 0 || nm.allInterfaces:
+		return nm.multi
+	default:
+		return []string{nm.interfaceName}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// errSourceExhausted is returned by a counter source that has no further readings.
+var errSourceExhausted = errors.New("counter source exhausted")
+
+// rawRecord is one line of a raw counter recording.
+type rawRecord struct {
+	Timestamp time.Time          `json:"timestamp"` // Time of the reading, always RFC 3339 with nanoseconds
+	Counters  net.IOCountersStat `json:"counters"`  // Every counter as returned by the source
+}
+
+// recordingSource passes readings of another source through unchanged while appending each
+// one to a JSON Lines file.
+type recordingSource struct {
+	counterSource
+	f   *os.File
+	enc *json.Encoder
+}
+
+// newRecordingSource wraps src, recording its readings to the file at path.
+func newRecordingSource(src counterSource, path string) (*recordingSource, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingSource{counterSource: src, f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (r *recordingSource) counters(iface string) (net.IOCountersStat, error) {
+	stat, err := r.counterSource.counters(iface)
+	if err != nil {
+		return stat, err
+	}
+	if err := r.enc.Encode(rawRecord{Timestamp: time.Now(), Counters: stat}); err != nil {
+		logErrorf("Error recording raw counters: %v", err)
+	}
+	return stat, nil
+}
+
+// allCounters records every reading of a source that can list all interfaces.
+func (r *recordingSource) allCounters() ([]net.IOCountersStat, error) {
+	lister, ok := r.counterSource.(counterLister)
+	if !ok {
+		return nil, errors.New("counter source cannot list interfaces")
+	}
+	stats, err := lister.allCounters()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, stat := range stats {
+		if err := r.enc.Encode(rawRecord{Timestamp: now, Counters: stat}); err != nil {
+			logErrorf("Error recording raw counters: %v", err)
+			break
+		}
+	}
+	return stats, nil
+}
+
+// Close closes the recording file.
+func (r *recordingSource) Close() error {
+	return r.f.Close()
+}
+
+// replaySource feeds a raw counter recording back through the monitor, one record per read.
+type replaySource struct {
+	records []rawRecord
+	next    int
+	speed   float64 // Playback speed relative to the original timing
+}
+
+// newReplaySource loads a recording from options of the form "<path>[,speed=N]".
+func newReplaySource(spec string) (*replaySource, error) {
+	path, rest, _ := strings.Cut(spec, ",")
+	if path == "" {
+		return nil, errors.New("replay source requires a recording path")
+	}
+	opts, err := parseSourceOptions(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &replaySource{speed: 1}
+	for key, value := range opts {
+		switch key {
+		case "speed":
+			r.speed, err = strconv.ParseFloat(value, 64)
+			if err != nil || r.speed <= 0 {
+				return nil, fmt.Errorf("invalid replay speed %q", value)
+			}
+		default:
+			return nil, fmt.Errorf("unknown replay option %q", key)
+		}
+	}
+
+	if r.records, err = readRecording(path); err != nil {
+		return nil, err
+	}
+	if len(r.records) < 2 {
+		return nil, fmt.Errorf("recording %s holds fewer than two readings", path)
+	}
+	return r, nil
+}
+
+// readRecording parses a JSON Lines recording written by -record-raw.
+func readRecording(path string) ([]rawRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []rawRecord
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec rawRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("invalid recording %s, line %d: %v", path, line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+func (r *replaySource) counters(iface string) (net.IOCountersStat, error) {
+	if r.next >= len(r.records) {
+		return net.IOCountersStat{}, errSourceExhausted
+	}
+	stat := r.records[r.next].Counters
+	r.next++
+	stat.Name = iface
+	return stat, nil
+}
+
+// interval returns the original sampling interval, taken from the spacing of the first two
+// readings and rounded to 10ms to drop the scheduling jitter of the recording.
+func (r *replaySource) interval() time.Duration {
+	d := r.records[1].Timestamp.Sub(r.records[0].Timestamp)
+	return max(d.Round(10*time.Millisecond), netstats.MinInterval)
+}
+
+// span returns the time between the latest reading and the one before it as recorded, so
+// replayed speeds match the original ones at any playback speed.
+func (r *replaySource) span() time.Duration {
+	if r.next < 2 {
+		return r.interval()
+	}
+	return r.records[r.next-1].Timestamp.Sub(r.records[r.next-2].Timestamp)
+}
+
+// pace returns how often readings are replayed for a sampling interval of interval.
+func (r *replaySource) pace(interval time.Duration) time.Duration {
+	return time.Duration(float64(interval) / r.speed)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// reportWindow aggregates the samples taken during one -report-interval into a single record.
+type reportWindow struct {
+	size    int     // Samples per report
+	samples int     // Samples collected so far
+	elapsed float64 // Seconds covered by the window
+	sent    uint64  // Bytes sent during the window
+	recv    uint64  // Bytes received during the window
+	minSent float64 // Lowest per-sample rates in bytes per second
+	minRecv float64
+	maxSent float64 // Highest per-sample rates in bytes per second
+	maxRecv float64
+}
+
+// add records the byte deltas of one sample, taken over interval seconds, and reports whether
+// the window is complete.
+func (w *reportWindow) add(sent, recv uint64, interval float64) bool {
+	sentBps, recvBps := float64(sent)/interval, float64(recv)/interval
+	if w.samples == 0 {
+		w.minSent, w.minRecv = sentBps, recvBps
+	}
+	w.samples++
+	w.elapsed += interval
+	w.sent += sent
+	w.recv += recv
+	w.minSent = min(w.minSent, sentBps)
+	w.minRecv = min(w.minRecv, recvBps)
+	w.maxSent = max(w.maxSent, sentBps)
+	w.maxRecv = max(w.maxRecv, recvBps)
+	return w.samples >= w.size
+}
+
+// take returns the completed window and starts a new one.
+func (w *reportWindow) take() reportWindow {
+	done := *w
+	*w = reportWindow{size: w.size}
+	return done
+}
+
+// reportPeriods lists the periods the report subcommand rolls hourly summaries into.
+var reportPeriods = []string{"day", "month"}
+
+// ReportPeriod is the traffic of one interface over one day or month, as printed by the
+// report subcommand. Start and End are the boundaries of the period, End being exclusive,
+// whether or not monitoring covered all of it.
+type ReportPeriod struct {
+	SchemaVersion          string    `json:"schemaVersion"` // Version of this object's schema, see reportSchemaVersion
+	Interface              string    `json:"interface"`
+	Period                 string    `json:"period"` // day or month
+	Start                  Timestamp `json:"start"`
+	End                    Timestamp `json:"end"`
+	Partial                bool      `json:"partial"` // Whether the hourly summaries leave part of the period uncovered
+	Hours                  int       `json:"hours"`   // Hourly summaries rolled up
+	SentBytes              uint64    `json:"sentBytes"`
+	RecvBytes              uint64    `json:"recvBytes"`
+	AvgSentBytesPerSecond  float64   `json:"avgSentBytesPerSecond"` // Over the covered time
+	AvgRecvBytesPerSecond  float64   `json:"avgRecvBytesPerSecond"`
+	PeakSentBytesPerSecond float64   `json:"peakSentBytesPerSecond"`
+	PeakRecvBytesPerSecond float64   `json:"peakRecvBytesPerSecond"`
+}
+
+// hourlyInput is the part of an hourly summary record the report subcommand reads.
+type hourlyInput struct {
+	Type                   string    `json:"type"`
+	Interface              string    `json:"interface"`
+	Start                  time.Time `json:"start"`
+	End                    time.Time `json:"end"`
+	Partial                bool      `json:"partial"`
+	SentBytes              uint64    `json:"sentBytes"`
+	RecvBytes              uint64    `json:"recvBytes"`
+	PeakSentBytesPerSecond float64   `json:"peakSentBytesPerSecond"`
+	PeakRecvBytesPerSecond float64   `json:"peakRecvBytesPerSecond"`
+}
+
+// runReport implements the report subcommand. It reads JSON streams written with
+// -hourly-summary, from the named files or stdin, and prints the traffic of each day or month.
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	format := fs.String("format", "table", "Output format: json or table")
+	period := fs.String("period", "day", "Period to roll the hourly summaries into: day or month")
+	utc := fs.Bool("utc", false, "Cut periods at UTC midnight instead of the offset the records carry")
+	addFlagAliases(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if !slices.Contains(textFormats, *format) {
+		return fmt.Errorf("Invalid output format: %s", *format)
+	}
+	if !slices.Contains(reportPeriods, *period) {
+		return fmt.Errorf("Invalid period %q. Allowed values: %s", *period, strings.Join(reportPeriods, ", "))
+	}
+
+	var hours []hourlyInput
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		h, err := readHourlySummaries(name)
+		if err != nil {
+			return err
+		}
+		hours = append(hours, h...)
+	}
+	if len(hours) == 0 {
+		return errors.New("no hourly-summary records found; record the stream with -hourly-summary -f json")
+	}
+
+	periods := rollUpHours(hours, *period, *utc)
+	if *format == "json" {
+		printJSON(os.Stdout, periods)
+		return nil
+	}
+	printReportTable(os.Stdout, periods)
+	return nil
+}
+
+// readHourlySummaries returns the hourly summary records of a JSON stream, skipping the other
+// records. The name - reads stdin.
+func readHourlySummaries(name string) ([]hourlyInput, error) {
+	r := io.Reader(os.Stdin)
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var hours []hourlyInput
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var probe struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &probe); err != nil {
+			return nil, fmt.Errorf("invalid JSON stream %s, line %d: %v", name, line, err)
+		}
+		if probe.Type != recordHourlySummary {
+			continue
+		}
+		var h hourlyInput
+		if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+			return nil, fmt.Errorf("invalid hourly summary in %s, line %d (timestamps must be RFC 3339): %v", name, line, err)
+		}
+		hours = append(hours, h)
+	}
+	return hours, scanner.Err()
+}
+
+// periodBounds returns the day or month holding t, in t's location.
+func periodBounds(t time.Time, period string) (start, end time.Time) {
+	if period == "month" {
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 1, 0)
+	}
+	start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// rollUpHours sums the hourly summaries into periods, ordered by interface and start. An hour
+// belongs to the period its start falls in.
+func rollUpHours(hours []hourlyInput, period string, utc bool) []ReportPeriod {
+	type key struct {
+		iface string
+		start int64
+	}
+	type rollup struct {
+		ReportPeriod
+		covered time.Duration
+	}
+	byKey := make(map[key]*rollup)
+	for _, h := range hours {
+		if utc {
+			h.Start, h.End = h.Start.UTC(), h.End.UTC()
+		}
+		start, end := periodBounds(h.Start, period)
+		k := key{h.Interface, start.Unix()}
+		r := byKey[k]
+		if r == nil {
+			r = &rollup{ReportPeriod: ReportPeriod{
+				SchemaVersion: reportSchemaVersion,
+				Interface:     h.Interface,
+				Period:        period,
+				Start:         Timestamp(start),
+				End:           Timestamp(end),
+			}}
+			byKey[k] = r
+		}
+		r.Partial = r.Partial || h.Partial
+		r.Hours++
+		r.SentBytes += h.SentBytes
+		r.RecvBytes += h.RecvBytes
+		r.PeakSentBytesPerSecond = max(r.PeakSentBytesPerSecond, h.PeakSentBytesPerSecond)
+		r.PeakRecvBytesPerSecond = max(r.PeakRecvBytesPerSecond, h.PeakRecvBytesPerSecond)
+		r.covered += h.End.Sub(h.Start)
+	}
+
+	periods := make([]ReportPeriod, 0, len(byKey))
+	for _, r := range byKey {
+		if r.covered < time.Time(r.End).Sub(time.Time(r.Start)) {
+			r.Partial = true
+		}
+		if seconds := r.covered.Seconds(); seconds > 0 {
+			r.AvgSentBytesPerSecond = netstats.Round(float64(r.SentBytes)/seconds, 2)
+			r.AvgRecvBytesPerSecond = netstats.Round(float64(r.RecvBytes)/seconds, 2)
+		}
+		periods = append(periods, r.ReportPeriod)
+	}
+	slices.SortFunc(periods, func(a, b ReportPeriod) int {
+		if c := strings.Compare(a.Interface, b.Interface); c != 0 {
+			return c
+		}
+		return time.Time(a.Start).Compare(time.Time(b.Start))
+	})
+	return periods
+}
+
+// printReportTable prints the periods as a table, with byte counts and rates in scaled units.
+// Partial periods are marked with an asterisk.
+func printReportTable(w io.Writer, periods []ReportPeriod) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Interface", "Period", "Hours", "Sent", "Received", "Avg Sent", "Avg Recv", "Peak Sent", "Peak Recv"})
+	for _, p := range periods {
+		layout := "2006-01-02"
+		if p.Period == "month" {
+			layout = "2006-01"
+		}
+		label := time.Time(p.Start).Format(layout)
+		if p.Partial {
+			label += " *"
+		}
+		usage := func(b uint64) string {
+			u := netstats.CalculateUsage(b, 2)
+			return formatQuantity(u.Value, u.Unit, 2, false)
+		}
+		speed := func(bps float64) string {
+			s := netstats.CalculateSpeed(uint64(bps), 1, 2)
+			return formatQuantity(s.Value, s.Unit, 2, false)
+		}
+		table.Append([]string{
+			p.Interface,
+			label,
+			strconv.Itoa(p.Hours),
+			usage(p.SentBytes),
+			usage(p.RecvBytes),
+			speed(p.AvgSentBytesPerSecond),
+			speed(p.AvgRecvBytesPerSecond),
+			speed(p.PeakSentBytesPerSecond),
+			speed(p.PeakRecvBytesPerSecond),
+		})
+	}
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(true)
+	table.Render()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+// TestReportJSONGolden pins the report -f json schema and the period boundaries: hours are
+// rolled into the period their start falls in, at the offset they were recorded with.
+func TestReportJSONGolden(t *testing.T) {
+	hours, err := readHourlySummaries(filepath.Join("testdata", "report", "hourly.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hours) != 5 {
+		t.Fatalf("read %d hourly summaries, want 5", len(hours))
+	}
+	for _, period := range reportPeriods {
+		t.Run(period, func(t *testing.T) {
+			var b bytes.Buffer
+			printJSON(&b, rollUpHours(hours, period, false))
+			checkGolden(t, filepath.Join("testdata", "report", period+".golden"), b.Bytes())
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	stdnet "net"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/net"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// errInterfaceNotFound is returned when no interface matches the requested name or identifier.
+var errInterfaceNotFound = netstats.ErrInterfaceNotFound
+
+// Selector kinds accepted by the -i flag.
+const (
+	selectorName = "name" // Plain interface name, e.g. "eth0"
+	selectorMAC  = "mac"  // Hardware address, e.g. "mac:aa:bb:cc:dd:ee:ff"
+	selectorPath = "path" // Sysfs device path, e.g. "path:/sys/devices/pci0000:00/0000:00:03.0"
+)
+
+// interfaceSelector identifies a network interface either by name or by a stable identifier
+// that survives renames (hardware address or sysfs device path).
+type interfaceSelector struct {
+	kind  string // One of selectorName, selectorMAC or selectorPath
+	value string // Normalized identifier value
+}
+
+// parseInterfaceSelector interprets the value of the -i flag.
+func parseInterfaceSelector(s string) (interfaceSelector, error) {
+	switch {
+	case strings.HasPrefix(s, selectorMAC+":"):
+		hw, err := stdnet.ParseMAC(strings.TrimPrefix(s, selectorMAC+":"))
+		if err != nil {
+			return interfaceSelector{}, fmt.Errorf("invalid hardware address in %q: %v", s, err)
+		}
+		return interfaceSelector{kind: selectorMAC, value: hw.String()}, nil
+	case strings.HasPrefix(s, selectorPath+":"):
+		path := strings.TrimPrefix(s, selectorPath+":")
+		if path == "" {
+			return interfaceSelector{}, fmt.Errorf("empty device path in %q", s)
+		}
+		return interfaceSelector{kind: selectorPath, value: path}, nil
+	default:
+		return interfaceSelector{kind: selectorName, value: s}, nil
+	}
+}
+
+// nameValue returns the interface name of a plain name selector, or "" for stable identifiers.
+func (s interfaceSelector) nameValue() string {
+	if s.kind == selectorName {
+		return s.value
+	}
+	return ""
+}
+
+// String returns the selector in the same form accepted by the -i flag.
+func (s interfaceSelector) String() string {
+	if s.kind == selectorName {
+		return s.value
+	}
+	return s.kind + ":" + s.value
+}
+
+// stable reports whether the selector identifies the interface independently of its name.
+func (s interfaceSelector) stable() bool {
+	return s.kind != selectorName
+}
+
+// resolveInterface returns the current name of the interface identified by the selector.
+func resolveInterface(sel interfaceSelector) (string, error) {
+	switch sel.kind {
+	case selectorMAC:
+		return resolveByMAC(sel.value)
+	case selectorPath:
+		return resolveByDevicePath(sel.value)
+	default:
+		return sel.value, nil
+	}
+}
+
+// resolveByMAC finds the interface currently holding the given hardware address.
+func resolveByMAC(mac string) (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+
+	for _, iface := range ifaces {
+		hw, err := stdnet.ParseMAC(iface.HardwareAddr)
+		if err != nil {
+			continue
+		}
+		if hw.String() == mac {
+			return iface.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: no interface with hardware address %s", errInterfaceNotFound, mac)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// sysClassNet is the sysfs directory listing the network interfaces known to the kernel.
+const sysClassNet = "/sys/class/net"
+
+// resolveByDevicePath finds the interface backed by the given sysfs device path.
+func resolveByDevicePath(path string) (string, error) {
+	want, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		want = filepath.Clean(path)
+	}
+
+	entries, err := os.ReadDir(sysClassNet)
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		device, err := filepath.EvalSymlinks(filepath.Join(sysClassNet, entry.Name(), "device"))
+		if err != nil {
+			continue // Virtual interfaces have no backing device
+		}
+		if device == want {
+			return entry.Name(), nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: no interface backed by device %s", errInterfaceNotFound, path)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+// resolveByDevicePath is only supported on Linux, where sysfs exposes device paths.
+func resolveByDevicePath(path string) (string, error) {
+	return "", fmt.Errorf("device path selectors are only supported on Linux: %s", path)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// jsonSample holds the figures of a JSON sample that tests check.
+type jsonSample struct {
+	Type      string `json:"type"`
+	Interface string `json:"interface"`
+	SentSpeed struct {
+		Value float64 `json:"value"`
+		Unit  string  `json:"unit"`
+	} `json:"sentSpeed"`
+	RecvSpeed struct {
+		Value float64 `json:"value"`
+		Unit  string  `json:"unit"`
+	} `json:"recvSpeed"`
+	TotalSent struct {
+		Value float64 `json:"value"`
+		Unit  string  `json:"unit"`
+	} `json:"totalSent"`
+	TotalRecv struct {
+		Value float64 `json:"value"`
+		Unit  string  `json:"unit"`
+	} `json:"totalRecv"`
+	Seq uint64 `json:"seq"`
+}
+
+// decodeSamples returns the samples among the JSON lines in buf, skipping events and other
+// typed records.
+func decodeSamples(t *testing.T, buf *bytes.Buffer) []jsonSample {
+	t.Helper()
+	var samples []jsonSample
+	sc := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for sc.Scan() {
+		var s jsonSample
+		if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", sc.Text(), err)
+		}
+		if s.Type == "" {
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+func TestRenameSequence(t *testing.T) {
+	src := newFakeSource()
+	src.set("eth0", 1000, 2000)
+	nm, buf := newTestMonitor(t, "eth0", src, "-f", "json")
+	nm.selector = interfaceSelector{kind: selectorMAC, value: "02:00:00:00:00:01"}
+	current := "eth0"
+	nm.resolve = func(interfaceSelector) (string, error) {
+		if current == "" {
+			return "", errors.New("no interface has that address")
+		}
+		return current, nil
+	}
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+
+	src.set("eth0", 1500, 2500)
+	tick(t, nm, start.Add(1*time.Second))
+
+	// While the device is gone, reads fail and no sample is emitted.
+	src.remove("eth0")
+	current = ""
+	tick(t, nm, start.Add(2*time.Second))
+	if nm.session.errors != 1 {
+		t.Errorf("errors after the device vanished = %d, want 1", nm.session.errors)
+	}
+
+	// It comes back renamed, and its counters carry on under the new name.
+	src.set("wan0", 1800, 3000)
+	current = "wan0"
+	tick(t, nm, start.Add(3*time.Second))
+	if nm.interfaceName != "wan0" {
+		t.Errorf("interface name = %q, want wan0", nm.interfaceName)
+	}
+
+	samples := decodeSamples(t, buf)
+	if len(samples) != 2 {
+		t.Fatalf("got %d samples, want 2: %s", len(samples), buf)
+	}
+	want := []struct {
+		iface           string
+		sent, totalSent float64
+	}{
+		{"eth0", 500, 500},
+		{"wan0", 300, 800},
+	}
+	for i, w := range want {
+		s := samples[i]
+		if s.Interface != w.iface || s.SentSpeed.Value != w.sent || s.TotalSent.Value != w.totalSent {
+			t.Errorf("sample %d = %s sent %v total %v, want %s sent %v total %v",
+				i, s.Interface, s.SentSpeed.Value, s.TotalSent.Value, w.iface, w.sent, w.totalSent)
+		}
+	}
+}
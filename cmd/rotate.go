@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// rotatingFile is an -o file that is rotated to file.1, file.2, … once it reaches a size limit.
+// It rotates between writes, and the output writer hands it whole records, so a record is never
+// split across files.
+type rotatingFile struct {
+	f        *os.File
+	path     string
+	maxSize  int64  // Size at which the file is rotated before the next write
+	maxFiles int    // Rotated files kept, the oldest being deleted
+	sync     bool   // Whether new files are opened with O_SYNC
+	size     int64  // Bytes in the current file
+	header   []byte // Written at the start of every new file, as the CSV header; nil for none
+}
+
+// newRotatingFile wraps f, opened at path and already holding size bytes.
+func newRotatingFile(f *os.File, path string, size int64, maxSize int64, maxFiles int, sync bool) *rotatingFile {
+	return &rotatingFile{f: f, path: path, size: size, maxSize: maxSize, maxFiles: maxFiles, sync: sync}
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.size >= r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the rotated files up by one, deleting the oldest, moves the current file to
+// file.1 and starts a new one.
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return fmt.Errorf("error closing %s for rotation: %v", r.path, err)
+	}
+	os.Remove(r.path + "." + strconv.Itoa(r.maxFiles))
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(r.path+"."+strconv.Itoa(i), r.path+"."+strconv.Itoa(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error rotating %s: %v", r.path, err)
+		}
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return fmt.Errorf("error rotating %s: %v", r.path, err)
+	}
+	f, _, err := openOutputFile(r.path, false, r.sync)
+	if err != nil {
+		return err
+	}
+	r.f, r.size = f, 0
+	if r.header != nil {
+		n, err := r.f.Write(r.header)
+		r.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	logInfof("Rotated %s", r.path)
+	return nil
+}
+
+// Close closes the current file.
+func (r *rotatingFile) Close() error {
+	return r.f.Close()
+}
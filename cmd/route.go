@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	stdnet "net"
+	"strconv"
+	"strings"
+)
+
+// defaultRoute describes the system's current IPv4 default route.
+type defaultRoute struct {
+	Interface string // Name of the interface carrying the default route, empty when none exists
+	Gateway   string // Gateway address, empty for on-link default routes
+}
+
+// parseProcNetRoute extracts the default route from the contents of Linux's /proc/net/route.
+// When several default routes exist the one with the lowest metric wins.
+func parseProcNetRoute(r io.Reader) (defaultRoute, error) {
+	const rtfUp = 0x1
+
+	var best defaultRoute
+	bestMetric := -1
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[0] == "Iface" {
+			continue
+		}
+		if fields[1] != "00000000" || fields[7] != "00000000" {
+			continue // Not a default route
+		}
+
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfUp == 0 {
+			continue
+		}
+		metric, err := strconv.Atoi(fields[6])
+		if err != nil {
+			continue
+		}
+
+		if bestMetric < 0 || metric < bestMetric {
+			best = defaultRoute{Interface: fields[0], Gateway: decodeProcNetAddr(fields[2])}
+			bestMetric = metric
+		}
+	}
+
+	return best, scanner.Err()
+}
+
+// decodeProcNetAddr converts a little-endian hex IPv4 address from /proc/net/route to dotted form.
+func decodeProcNetAddr(s string) string {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 4 {
+		return ""
+	}
+	ip := make(stdnet.IP, 4)
+	binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(b))
+	if ip.IsUnspecified() {
+		return ""
+	}
+	return ip.String()
+}
+
+// parseRoutePrint extracts the default route from the output of Windows' `route print -4`.
+// The interface column holds the local address of the adapter rather than its name, so the
+// returned Interface is that address and must be mapped to a name by the caller.
+func parseRoutePrint(r io.Reader) (defaultRoute, error) {
+	var best defaultRoute
+	bestMetric := -1
+	active := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "Active Routes"):
+			active = true
+			continue
+		case strings.HasPrefix(line, "Persistent Routes"):
+			active = false
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if !active || len(fields) != 5 || fields[0] != "0.0.0.0" || fields[1] != "0.0.0.0" {
+			continue
+		}
+		metric, err := strconv.Atoi(fields[4])
+		if err != nil {
+			continue
+		}
+
+		if bestMetric < 0 || metric < bestMetric {
+			gateway := fields[2]
+			if stdnet.ParseIP(gateway) == nil {
+				gateway = "" // "On-link"
+			}
+			best = defaultRoute{Interface: fields[3], Gateway: gateway}
+			bestMetric = metric
+		}
+	}
+
+	return best, scanner.Err()
+}
+
+// parseRouteGet extracts the default route from the output of BSD/macOS `route -n get default`.
+func parseRouteGet(r io.Reader) (defaultRoute, error) {
+	var route defaultRoute
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "gateway":
+			if stdnet.ParseIP(value) != nil {
+				route.Gateway = value
+			}
+		case "interface":
+			route.Interface = value
+		}
+	}
+
+	return route, scanner.Err()
+}
+
+// detectDefaultInterface returns the name of the interface carrying the default route. When
+// the routing table cannot tell, it asks the kernel which local address would be used to reach
+// a public address; dialing UDP sends no packet.
+func detectDefaultInterface() (string, error) {
+	if route, err := readDefaultRoute(); err == nil && route.Interface != "" {
+		return route.Interface, nil
+	}
+
+	conn, err := stdnet.Dial("udp4", "192.0.2.1:9") // TEST-NET-1, reserved for documentation
+	if err != nil {
+		return "", fmt.Errorf("no interface carries the default route: %w", err)
+	}
+	local := conn.LocalAddr().(*stdnet.UDPAddr).IP
+	conn.Close()
+
+	ifaces, err := stdnet.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*stdnet.IPNet); ok && ipnet.IP.Equal(local) {
+				return iface.Name, nil
+			}
+		}
+	}
+	return "", errors.New("no interface carries the default route")
+}
@@ -0,0 +1,14 @@
+package main
+
+import "os"
+
+// readDefaultRoute returns the current IPv4 default route from the kernel routing table.
+func readDefaultRoute() (defaultRoute, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return defaultRoute{}, err
+	}
+	defer f.Close()
+
+	return parseProcNetRoute(f)
+}
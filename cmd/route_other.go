@@ -0,0 +1,22 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"bytes"
+	"os/exec"
+)
+
+// readDefaultRoute returns the current IPv4 default route by parsing `route -n get default`.
+func readDefaultRoute() (defaultRoute, error) {
+	out, err := exec.Command("route", "-n", "get", "default").Output()
+	if err != nil {
+		// route exits non-zero when no default route exists
+		if _, ok := err.(*exec.ExitError); ok {
+			return defaultRoute{}, nil
+		}
+		return defaultRoute{}, err
+	}
+
+	return parseRouteGet(bytes.NewReader(out))
+}
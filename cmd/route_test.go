@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+const procNetRouteHeader = "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+
+func TestParseRoutes(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(io.Reader) (defaultRoute, error)
+		input string
+		want  defaultRoute
+	}{
+		{
+			name:  "proc lowest metric wins",
+			parse: parseProcNetRoute,
+			input: procNetRouteHeader +
+				"wlan0\t00000000\t0101A8C0\t0003\t0\t0\t600\t00000000\t0\t0\t0\n" +
+				"eth0\t00000000\t010010AC\t0003\t0\t0\t100\t00000000\t0\t0\t0\n" +
+				"eth0\t000010AC\t00000000\t0001\t0\t0\t100\t0000FFFF\t0\t0\t0\n",
+			want: defaultRoute{Interface: "eth0", Gateway: "172.16.0.1"},
+		},
+		{
+			name:  "proc route that is down",
+			parse: parseProcNetRoute,
+			input: procNetRouteHeader + "eth0\t00000000\t010010AC\t0002\t0\t0\t100\t00000000\t0\t0\t0\n",
+		},
+		{
+			name:  "proc without default route",
+			parse: parseProcNetRoute,
+			input: procNetRouteHeader + "eth0\t000010AC\t00000000\t0001\t0\t0\t100\t0000FFFF\t0\t0\t0\n",
+		},
+		{
+			name:  "proc malformed lines",
+			parse: parseProcNetRoute,
+			input: procNetRouteHeader +
+				"eth0\t00000000\n" +
+				"eth1\t00000000\t010010AC\tzz\t0\t0\t100\t00000000\t0\t0\t0\n" +
+				"eth2\t00000000\t010010AC\t0003\t0\t0\tlow\t00000000\t0\t0\t0\n" +
+				"eth3\t00000000\tnothex\t0003\t0\t0\t50\t00000000\t0\t0\t0\n",
+			want: defaultRoute{Interface: "eth3"},
+		},
+		{
+			name:  "route print lowest metric wins",
+			parse: parseRoutePrint,
+			input: `===========================================================================
+IPv4 Route Table
+===========================================================================
+Active Routes:
+Network Destination        Netmask          Gateway       Interface  Metric
+          0.0.0.0          0.0.0.0      192.168.1.1    192.168.1.20     35
+          0.0.0.0          0.0.0.0         10.0.0.1       10.0.0.15     25
+        127.0.0.0        255.0.0.0         On-link         127.0.0.1    331
+===========================================================================
+Persistent Routes:
+  Network Address          Netmask  Gateway Address  Metric
+          0.0.0.0          0.0.0.0      192.168.9.1       1
+`,
+			want: defaultRoute{Interface: "10.0.0.15", Gateway: "10.0.0.1"},
+		},
+		{
+			name:  "route print on-link",
+			parse: parseRoutePrint,
+			input: "Active Routes:\n          0.0.0.0          0.0.0.0         On-link    192.168.1.20     35\n",
+			want:  defaultRoute{Interface: "192.168.1.20"},
+		},
+		{
+			name:  "route print without default route",
+			parse: parseRoutePrint,
+			input: "Active Routes:\n        127.0.0.0        255.0.0.0         On-link         127.0.0.1    331\n",
+		},
+		{
+			name:  "route print malformed lines",
+			parse: parseRoutePrint,
+			input: "Active Routes:\n          0.0.0.0          0.0.0.0      192.168.1.1\n" +
+				"          0.0.0.0          0.0.0.0      192.168.1.1    192.168.1.20     high\n",
+		},
+		{
+			name:  "route get",
+			parse: parseRouteGet,
+			input: `   route to: default
+destination: default
+       mask: default
+    gateway: 192.168.1.1
+  interface: en0
+      flags: <UP,GATEWAY,DONE,STATIC,PRCLONING>
+`,
+			want: defaultRoute{Interface: "en0", Gateway: "192.168.1.1"},
+		},
+		{
+			name:  "route get without default route",
+			parse: parseRouteGet,
+			input: "route: writing to routing socket: not in table\n",
+		},
+		{
+			name:  "route get malformed gateway",
+			parse: parseRouteGet,
+			input: "    gateway: link#4\n  interface: utun3\n",
+			want:  defaultRoute{Interface: "utun3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// readDefaultRoute returns the current IPv4 default route by parsing `route print`.
+func readDefaultRoute() (defaultRoute, error) {
+	out, err := exec.Command("route", "print", "-4", "0.0.0.0").Output()
+	if err != nil {
+		return defaultRoute{}, err
+	}
+
+	route, err := parseRoutePrint(bytes.NewReader(out))
+	if err != nil || route.Interface == "" {
+		return route, err
+	}
+
+	// Map the adapter's local address back to its interface name.
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return defaultRoute{}, err
+	}
+	for _, iface := range ifaces {
+		for _, addr := range iface.Addrs {
+			if ip, _, _ := strings.Cut(addr.Addr, "/"); ip == route.Interface {
+				route.Interface = iface.Name
+				return route, nil
+			}
+		}
+	}
+
+	return route, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// errSchedUnsupported is returned where scheduling options cannot be applied.
+var errSchedUnsupported = errors.New("scheduling options are only supported on Linux")
+
+// realtimePriority is the SCHED_FIFO priority requested by -realtime. The lowest real-time
+// priority is enough to preempt every normal task without starving kernel threads.
+const realtimePriority = 1
+
+// Scheduling describes the scheduling state of the sampling thread, as applied by -realtime,
+// -nice and -pin-cpu.
+type Scheduling struct {
+	Policy    string `json:"policy"`              // "fifo" when real-time scheduling applies, "other" otherwise
+	Priority  int    `json:"priority,omitempty"`  // Real-time priority under the fifo policy
+	Nice      int    `json:"nice"`                // Nice value of the thread
+	PinnedCPU *int   `json:"pinnedCpu,omitempty"` // CPU the thread is pinned to, if any
+}
+
+// String describes the scheduling state for the log.
+func (s *Scheduling) String() string {
+	parts := []string{"policy " + s.Policy}
+	if s.Policy == "fifo" {
+		parts = append(parts, fmt.Sprintf("priority %d", s.Priority))
+	}
+	parts = append(parts, fmt.Sprintf("nice %d", s.Nice))
+	if s.PinnedCPU != nil {
+		parts = append(parts, fmt.Sprintf("pinned to CPU %d", *s.PinnedCPU))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// applyScheduling locks the calling goroutine, which runs the sampling loop, to its OS thread
+// and applies the requested scheduling to that thread only, so the output and push goroutines
+// keep normal priority. Options that cannot be applied are logged and left out of the returned
+// state; monitoring goes on without them.
+func applyScheduling(realtime bool, nice, cpu int) *Scheduling {
+	runtime.LockOSThread()
+	s := &Scheduling{Policy: "other"}
+	if nice != 0 {
+		if err := setThreadNice(nice); err != nil {
+			logWarnf("Cannot set nice value %d: %v", nice, err)
+		} else {
+			s.Nice = nice
+		}
+	}
+	if realtime {
+		if err := setThreadRealtime(realtimePriority); err != nil {
+			logWarnf("Cannot switch to real-time scheduling: %v", err)
+		} else {
+			s.Policy, s.Priority = "fifo", realtimePriority
+		}
+	}
+	if cpu >= 0 {
+		if err := pinThread(cpu); err != nil {
+			logWarnf("Cannot pin sampling to CPU %d: %v", cpu, err)
+		} else {
+			s.PinnedCPU = &cpu
+		}
+	}
+	logInfof("Sampling thread scheduling: %v", s)
+	return s
+}
@@ -0,0 +1,22 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+// setThreadNice sets the nice value of the calling thread. On Linux, nice values are per
+// thread, so the rest of the process is unaffected.
+func setThreadNice(nice int) error {
+	return unix.Setpriority(unix.PRIO_PROCESS, unix.Gettid(), nice)
+}
+
+// setThreadRealtime switches the calling thread to SCHED_FIFO at priority. It needs
+// CAP_SYS_NICE or an RLIMIT_RTPRIO allowance.
+func setThreadRealtime(priority int) error {
+	return unix.SchedSetAttr(0, &unix.SchedAttr{Policy: unix.SCHED_FIFO, Priority: uint32(priority)}, 0)
+}
+
+// pinThread restricts the calling thread to cpu.
+func pinThread(cpu int) error {
+	var set unix.CPUSet
+	set.Set(cpu)
+	return unix.SchedSetaffinity(0, &set)
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+// setThreadNice reports scheduling options as unsupported outside Linux.
+func setThreadNice(int) error {
+	return errSchedUnsupported
+}
+
+// setThreadRealtime reports scheduling options as unsupported outside Linux.
+func setThreadRealtime(int) error {
+	return errSchedUnsupported
+}
+
+// pinThread reports scheduling options as unsupported outside Linux.
+func pinThread(int) error {
+	return errSchedUnsupported
+}
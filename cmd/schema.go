@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// schemaVersion is the version of the record schema the monitor emits. Bump it and append a
+// revision to schemaRevisions whenever a field of the output is renamed, added or removed.
+const schemaVersion = "v3"
+
+// listSchemaVersion is the version of the interface objects printed by list -f json, carried
+// by each of them. Bump it and update listSchemaFields whenever a field of InterfaceInfo is
+// renamed, added or removed.
+const listSchemaVersion = "v1"
+
+// listSchemaFields are the fields of list -f json objects at listSchemaVersion, in order.
+// selftest fails when InterfaceInfo no longer matches them.
+var listSchemaFields = []string{"schemaVersion", "name", "index", "state", "mtu", "mac", "addresses", "bytesSent", "bytesRecv"}
+
+// reportSchemaVersion is the version of the period objects printed by report -f json, carried
+// by each of them. Bump it and update reportSchemaFields whenever a field of ReportPeriod is
+// renamed, added or removed.
+const reportSchemaVersion = "v1"
+
+// reportSchemaFields are the fields of report -f json objects at reportSchemaVersion, in order.
+var reportSchemaFields = []string{"schemaVersion", "interface", "period", "start", "end", "partial", "hours", "sentBytes", "recvBytes", "avgSentBytesPerSecond", "avgRecvBytesPerSecond", "peakSentBytesPerSecond", "peakRecvBytesPerSecond"}
+
+// schemaField is a field added or removed by a schema revision, with the value it takes in
+// records migrated across that revision.
+type schemaField struct {
+	name    string
+	fill    func(m *migration) json.RawMessage
+	samples bool // Whether only samples, recognized by their sentSpeed, are affected
+}
+
+// schemaRevision describes how one schema version differs from the previous one.
+type schemaRevision struct {
+	version string
+	renamed map[string]string // Fields renamed from the previous version, old name to new
+	added   []schemaField     // Fields introduced, filled in when upgrading
+	removed []schemaField     // Fields dropped, restored when downgrading
+}
+
+// schemaRevisions lists every schema version the monitor has emitted, oldest first.
+var schemaRevisions = []schemaRevision{
+	{version: "v1"}, // The original samples: interface, speeds and totals
+	{
+		version: "v2", // Sequence numbers and session IDs on every record
+		added: []schemaField{
+			{"seq", func(m *migration) json.RawMessage { return json.RawMessage(strconv.FormatUint(m.seq, 10)) }, false},
+			{"sessionId", func(m *migration) json.RawMessage { return jsonString(m.sessionID) }, false},
+		},
+	},
+	{
+		version: "v3", // Sample timestamps; the time of a migrated sample is unknown
+		added: []schemaField{
+			{"timestamp", func(*migration) json.RawMessage { return json.RawMessage("null") }, true},
+		},
+	},
+}
+
+// schemaIndex returns the position of version in schemaRevisions.
+func schemaIndex(version string) (int, error) {
+	for i, r := range schemaRevisions {
+		if r.version == version {
+			return i, nil
+		}
+	}
+	known := make([]string, len(schemaRevisions))
+	for i, r := range schemaRevisions {
+		known[i] = r.version
+	}
+	return 0, fmt.Errorf("Unknown schema version %q (known: %s)", version, strings.Join(known, ", "))
+}
+
+// jsonString returns the JSON encoding of s.
+func jsonString(s string) json.RawMessage {
+	b, _ := json.Marshal(s)
+	return b
+}
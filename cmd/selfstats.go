@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"os"
+	"runtime/metrics"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// selfStatsMetrics names the runtime metrics read for -self-stats.
+var selfStatsMetrics = []string{
+	"/sched/goroutines:goroutines",
+	"/gc/pauses:seconds",
+}
+
+// SelfStats describes the monitor's own resource use, so its overhead can be checked on
+// constrained devices.
+type SelfStats struct {
+	CPUSeconds         float64     `json:"cpuSeconds"`           // Process CPU time used since the previous sample
+	RSSBytes           uint64      `json:"rssBytes"`             // Resident set size
+	Goroutines         uint64      `json:"goroutines"`           // Live goroutines
+	GCPauseSeconds     float64     `json:"gcPauseSeconds"`       // Approximate stop-the-world GC pause time since the previous sample
+	TickLatencySeconds float64     `json:"tickLatencySeconds"`   // Time spent collecting this sample
+	DroppedSamples     uint64      `json:"droppedSamples"`       // Samples dropped from the full output queue since the previous sample
+	Scheduling         *Scheduling `json:"scheduling,omitempty"` // Scheduling of the sampling thread, if -realtime, -nice or -pin-cpu was given
+}
+
+// selfStatsCollector computes per-sample deltas of the monitor's resource use.
+type selfStatsCollector struct {
+	proc    *process.Process
+	samples []metrics.Sample
+	cpu     time.Duration // Process CPU time at the previous sample
+	gcPause float64       // Cumulative GC pause time at the previous sample
+	sched   *Scheduling   // Scheduling applied to the sampling thread, nil if left unchanged
+	queue   *outputQueue  // Output queue whose drops are counted, nil without one
+	dropped uint64        // Samples dropped from the output queue at the previous sample
+}
+
+// newSelfStatsCollector creates a collector for the current process.
+func newSelfStatsCollector() *selfStatsCollector {
+	c := &selfStatsCollector{samples: make([]metrics.Sample, len(selfStatsMetrics))}
+	for i, name := range selfStatsMetrics {
+		c.samples[i].Name = name
+	}
+	proc, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		logWarnf("Error opening own process for self-stats: %v", err)
+	}
+	c.proc = proc
+	c.cpu, _ = processCPUTime()
+	c.collect(0)
+	return c
+}
+
+// collect returns the resource use since the previous call. tickLatency is the time spent on
+// the current sample so far.
+func (c *selfStatsCollector) collect(tickLatency time.Duration) *SelfStats {
+	s := &SelfStats{TickLatencySeconds: tickLatency.Seconds(), Scheduling: c.sched}
+
+	if cpu, err := processCPUTime(); err == nil {
+		s.CPUSeconds = (cpu - c.cpu).Seconds()
+		c.cpu = cpu
+	}
+	if c.proc != nil {
+		if mem, err := c.proc.MemoryInfo(); err == nil {
+			s.RSSBytes = mem.RSS
+		}
+	}
+
+	if c.queue != nil {
+		dropped := c.queue.droppedRecords()
+		s.DroppedSamples = dropped - c.dropped
+		c.dropped = dropped
+	}
+
+	metrics.Read(c.samples)
+	s.Goroutines = c.samples[0].Value.Uint64()
+	pause := histogramTotal(c.samples[1].Value.Float64Histogram())
+	s.GCPauseSeconds = pause - c.gcPause
+	c.gcPause = pause
+
+	return s
+}
+
+// histogramTotal approximates the sum of a runtime histogram by weighting each bucket count
+// with the bucket's lower bound.
+func histogramTotal(h *metrics.Float64Histogram) float64 {
+	var total float64
+	for i, n := range h.Counts {
+		if lower := h.Buckets[i]; n > 0 && !math.IsInf(lower, -1) {
+			total += float64(n) * lower
+		}
+	}
+	return total
+}
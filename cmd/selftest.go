@@ -0,0 +1,694 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/olekukonko/tablewriter"
+	"github.com/shirou/gopsutil/v4/net"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// Outcomes of a selftest check.
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+	checkSkip = "skip" // Nothing to check, such as an unconfigured sink
+)
+
+// errCheckSkipped is returned by a check with nothing to check.
+var errCheckSkipped = errors.New("skipped")
+
+// selfCheck is one check of the selftest subcommand.
+type selfCheck struct {
+	name string
+	run  func() (string, error) // Returns a detail on success
+}
+
+// CheckResult is the outcome of one selftest check.
+type CheckResult struct {
+	Check  string `json:"check"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// runSelftest implements the selftest subcommand. It exercises the counter source, every output
+// format, state file persistence and the configured sinks, and exits with status 1 when a
+// check fails.
+func runSelftest(args []string) error {
+	fs := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	iface := fs.String("interface", "", "Interface whose counters are checked (default: every interface)")
+	source := fs.String("source", "", "Counter source to check, as for the monitor, e.g. synthetic:profile=wave")
+	pushGateway := fs.String("pushgateway", "", "Also check that this Prometheus Pushgateway is reachable")
+	format := fs.String("format", "table", "Output format: table or json")
+	addFlagAliases(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if !slices.Contains(textFormats, *format) {
+		return fmt.Errorf("Invalid output format: %s", *format)
+	}
+	src, err := parseCounterSource(*source, time.Second)
+	if err != nil {
+		return err
+	}
+	if *iface == "" && !isKernelSource(src) {
+		*iface = "synthetic"
+	}
+
+	results := runChecks(selfChecks(src, *iface, *pushGateway))
+	if *format == "json" {
+		printJSON(os.Stdout, results)
+	} else {
+		printCheckTable(results)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Status == checkFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		logErrorf("%d of %d checks failed", failed, len(results))
+		return exitStatus(exitFailure)
+	}
+	return nil
+}
+
+// selfChecks returns the checks of the pipeline reading counters from src. iface names the
+// interface to read, or is empty for every interface; pushGateway is the sink URL to reach, if any.
+func selfChecks(src counterSource, iface, pushGateway string) []selfCheck {
+	checks := []selfCheck{
+		{name: "counters", run: func() (string, error) { return checkCounters(src, iface) }},
+	}
+	for _, f := range outputFormats {
+		checks = append(checks, selfCheck{name: "format " + f, run: func() (string, error) { return checkFormat(f) }})
+	}
+	checks = append(checks,
+		selfCheck{name: "quantity flags", run: checkQuantityFlags},
+		selfCheck{name: "list schema", run: checkListSchema},
+		selfCheck{name: "report schema", run: checkReportSchema},
+		selfCheck{name: "record schema", run: checkRecordSchema},
+		selfCheck{name: "combined direction", run: checkCombinedDirection},
+		selfCheck{name: "unit steps", run: checkUnitSteps},
+		selfCheck{name: "bit speeds", run: checkBitSpeeds},
+		selfCheck{name: "sink budget", run: checkSinkBudget},
+		selfCheck{name: "rate shifts", run: checkRateShifts},
+		selfCheck{name: "state file", run: checkStateFile},
+		selfCheck{name: "pushgateway", run: func() (string, error) { return checkPushGateway(pushGateway) }},
+	)
+	return checks
+}
+
+// runChecks runs checks in order and returns their results.
+func runChecks(checks []selfCheck) []CheckResult {
+	results := make([]CheckResult, 0, len(checks))
+	for _, c := range checks {
+		detail, err := c.run()
+		r := CheckResult{Check: c.name, Status: checkPass, Detail: detail}
+		switch {
+		case errors.Is(err, errCheckSkipped):
+			r.Status = checkSkip
+		case err != nil:
+			r.Status, r.Detail = checkFail, err.Error()
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// printCheckTable prints check results as a table to stdout.
+func printCheckTable(results []CheckResult) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Check", "Status", "Detail"})
+	for _, r := range results {
+		table.Append([]string{r.Check, strings.ToUpper(r.Status), r.Detail})
+	}
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(true)
+	table.Render()
+}
+
+// checkCounters reads the counters twice and verifies that none went backwards.
+func checkCounters(src counterSource, iface string) (string, error) {
+	read := func() ([]net.IOCountersStat, error) {
+		if iface != "" {
+			io, err := src.counters(iface)
+			return []net.IOCountersStat{io}, err
+		}
+		lister, ok := src.(counterLister)
+		if !ok {
+			return nil, errors.New("source cannot list interfaces, pass -i")
+		}
+		return lister.allCounters()
+	}
+	first, err := read()
+	if err != nil {
+		return "", fmt.Errorf("first read failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	second, err := read()
+	if err != nil {
+		return "", fmt.Errorf("second read failed: %v", err)
+	}
+
+	prev := make(map[string]net.IOCountersStat, len(first))
+	for _, io := range first {
+		prev[io.Name] = io
+	}
+	compared := 0
+	for _, cur := range second {
+		p, ok := prev[cur.Name]
+		if !ok {
+			continue
+		}
+		compared++
+		for _, c := range []struct {
+			field         string
+			before, after uint64
+		}{
+			{"bytesSent", p.BytesSent, cur.BytesSent},
+			{"bytesRecv", p.BytesRecv, cur.BytesRecv},
+			{"packetsSent", p.PacketsSent, cur.PacketsSent},
+			{"packetsRecv", p.PacketsRecv, cur.PacketsRecv},
+		} {
+			if c.after < c.before {
+				return "", fmt.Errorf("%s %s went backwards from %d to %d", cur.Name, c.field, c.before, c.after)
+			}
+		}
+	}
+	if compared == 0 {
+		return "", errors.New("no interface was read twice")
+	}
+	return fmt.Sprintf("%d interfaces read twice, counters monotonic", compared), nil
+}
+
+// checkFormat renders a synthetic sample in format and parses the result back.
+func checkFormat(format string) (string, error) {
+	start := net.IOCountersStat{Name: "selftest0"}
+	prev := net.IOCountersStat{Name: "selftest0", BytesSent: 1000, BytesRecv: 4000}
+	cur := net.IOCountersStat{Name: "selftest0", BytesSent: 3048, BytesRecv: 1052576}
+	s := NetStats{
+		Stats:     netstats.Sample("selftest0", start, prev, cur, 1, 2),
+		Timestamp: Timestamp(time.Now()),
+		raw:       newRawFigures(2048, 1048576, 1, 3048, 1052576),
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "table":
+		printTable(&buf, []NetStats{s}, 2, false, true, nil)
+		for _, want := range []string{"selftest0", "2.00 KiB/s", "1.00 MiB/s"} {
+			if !strings.Contains(buf.String(), want) {
+				return "", fmt.Errorf("table lacks %q", want)
+			}
+		}
+		return fmt.Sprintf("%d lines rendered", strings.Count(buf.String(), "\n")), nil
+	case "json", "ndjson":
+		printJSON(&buf, s)
+		var got struct {
+			Interface string `json:"interface"`
+			SentSpeed Speed  `json:"sentSpeed"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			return "", fmt.Errorf("output is not valid JSON: %v", err)
+		}
+		if got.Interface != "selftest0" || got.SentSpeed != (Speed{Value: 2, Unit: "KiB/s"}) {
+			return "", fmt.Errorf("unexpected sample %s", strings.TrimSpace(buf.String()))
+		}
+		return "sample parses back", nil
+	case "json-array":
+		out := &outputWriter{w: &buf, array: true}
+		for range 2 {
+			if err := out.writeRecord(func(w io.Writer) { printJSON(w, s) }); err != nil {
+				return "", err
+			}
+		}
+		if err := out.closeArray(); err != nil {
+			return "", err
+		}
+		var got []json.RawMessage
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			return "", fmt.Errorf("output is not one JSON array: %v", err)
+		}
+		if len(got) != 2 {
+			return "", fmt.Errorf("array has %d elements, wrote 2", len(got))
+		}
+		return "two samples parse back as one array", nil
+	case "yaml":
+		printYAML(&buf, s)
+		var got struct {
+			Interface string `yaml:"interface"`
+			SentSpeed Speed  `yaml:"sentSpeed"`
+		}
+		if err := yaml.Unmarshal(buf.Bytes(), &got); err != nil {
+			return "", fmt.Errorf("output is not valid YAML: %v", err)
+		}
+		if got.Interface != "selftest0" || got.SentSpeed != (Speed{Value: 2, Unit: "KiB/s"}) {
+			return "", fmt.Errorf("unexpected document %s", strings.TrimSpace(buf.String()))
+		}
+		return "document parses back", nil
+	case "csv":
+		printCSV(&buf, ',', csvHeader, csvRow(s, 2))
+		records, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			return "", fmt.Errorf("output is not valid CSV: %v", err)
+		}
+		if len(records) != 2 || len(records[1]) != len(csvHeader) || records[1][2] != "2048.00" {
+			return "", fmt.Errorf("unexpected rows %q", records)
+		}
+		return "header and row parse back", nil
+	case "influx":
+		influxFormatter{tags: ",env=selftest", precision: 2}.samples(&buf, []NetStats{s}, false)
+		want := "netstats,interface=selftest0,env=selftest sent_bps=2048.00,recv_bps=1048576.00,total_sent=3048i,total_recv=1052576i " +
+			strconv.FormatInt(time.Time(s.Timestamp).UnixNano(), 10) + "\n"
+		if buf.String() != want {
+			return "", fmt.Errorf("unexpected line %q", buf.String())
+		}
+		return "line has the expected tags, fields and timestamp", nil
+	case "tui":
+		screen := tcell.NewSimulationScreen("UTF-8")
+		if err := screen.Init(); err != nil {
+			return "", err
+		}
+		defer screen.Fini()
+		screen.SetSize(80, 24)
+		t := &tuiDashboard{screen: screen, precision: 2, panels: make(map[string]*tuiPanel)}
+		t.add([]NetStats{s})
+		t.draw()
+		cells, width, _ := screen.GetContents()
+		var text strings.Builder
+		for i, c := range cells {
+			if i > 0 && i%width == 0 {
+				text.WriteByte('\n')
+			}
+			text.WriteString(string(c.Runes))
+		}
+		for _, want := range []string{"selftest0", "↑ 2.00 KiB/s", "↓ 1.00 MiB/s"} {
+			if !strings.Contains(text.String(), want) {
+				return "", fmt.Errorf("dashboard lacks %q", want)
+			}
+		}
+		return "panel drawn on a simulated screen", nil
+	default:
+		return "", fmt.Errorf("no check for format %s", format)
+	}
+}
+
+// quantityExamples are values every size and rate flag must accept, with the bytes or bytes
+// per second they stand for.
+var quantityExamples = []struct {
+	size, rate string
+	bytes      float64
+}{
+	{"0", "0", 0},
+	{"1500", "1500B/s", 1500},
+	{"1KB", "1KiB/s", 1024},
+	{"1.5MiB", "1.5MB/s", 1.5 * 1024 * 1024},
+	{"800Mbit", "800Mbit", 1e8},
+	{"2GB", "2gib/s", 2 * 1024 * 1024 * 1024},
+}
+
+// checkQuantityFlags verifies that every size and rate flag of the monitor parses the
+// examples, and that its String form parses back to the same value.
+func checkQuantityFlags() (string, error) {
+	var cfg monitorConfig
+	fs := newMonitorFlagSet("selftest", &cfg)
+	var checked int
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		switch v := f.Value.(type) {
+		case *sizeValue, *rateValue:
+			checked++
+			for _, ex := range quantityExamples {
+				in := ex.size
+				if _, ok := v.(*rateValue); ok {
+					in = ex.rate
+				}
+				if err = f.Value.Set(in); err != nil {
+					err = fmt.Errorf("-%s rejects %q: %v", f.Name, in, err)
+					return
+				}
+				got := quantityBytes(f.Value)
+				if got != ex.bytes {
+					err = fmt.Errorf("-%s reads %q as %v bytes, want %v", f.Name, in, got, ex.bytes)
+					return
+				}
+				if err = f.Value.Set(f.Value.String()); err != nil || quantityBytes(f.Value) != got {
+					err = fmt.Errorf("-%s does not read back its own form %q", f.Name, f.Value.String())
+					return
+				}
+			}
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d flags accept and round-trip %d forms", checked, len(quantityExamples)), nil
+}
+
+// quantityBytes returns the bytes, or bytes per second, held by a size or rate flag.
+func quantityBytes(v flag.Value) float64 {
+	switch v := v.(type) {
+	case *sizeValue:
+		return float64(*v)
+	case *rateValue:
+		return float64(*v)
+	}
+	return 0
+}
+
+// checkListSchema verifies that list -f json objects have exactly the fields documented for
+// listSchemaVersion, so a renamed field cannot slip out without a version bump.
+func checkListSchema() (string, error) {
+	return checkObjectFields(InterfaceInfo{}, listSchemaVersion, listSchemaFields)
+}
+
+// checkReportSchema does the same for report -f json objects and reportSchemaVersion.
+func checkReportSchema() (string, error) {
+	return checkObjectFields(ReportPeriod{}, reportSchemaVersion, reportSchemaFields)
+}
+
+// checkObjectFields compares the JSON fields of v, in order, with those of its schema version.
+func checkObjectFields(v any, version string, want []string) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.Token() // Opening brace
+	var fields []string
+	for dec.More() {
+		key, _ := dec.Token()
+		fields = append(fields, key.(string))
+		var skip json.RawMessage
+		dec.Decode(&skip)
+	}
+	if !slices.Equal(fields, want) {
+		return "", fmt.Errorf("fields %v differ from schema %s %v", fields, version, want)
+	}
+	return fmt.Sprintf("%d fields match schema %s", len(fields), version), nil
+}
+
+// checkRecordSchema checks a record of every kind, with every optional field set, against
+// the schema of -strict-schema, and that a mistyped field is caught.
+func checkRecordSchema() (string, error) {
+	schema, err := compileRecordSchema()
+	if err != nil {
+		return "", err
+	}
+	now := Timestamp(time.Now())
+	f, n, yes := 1.5, 2, true
+	sample := NetStats{
+		Stats:     netstats.Stats{Interface: "selftest0", SentSpeed: netstats.CalculateSpeed(2048, 1, 2)},
+		Timestamp: now,
+		SentMin:   &Speed{}, SentMax: &Speed{}, RecvMin: &Speed{}, RecvMax: &Speed{},
+		Implausible:  true,
+		Meta:         &InterfaceMeta{MTU: 1500, SpeedMbps: 1000, Duplex: "full", HardwareAddr: "00:11:22:33:44:55", Addresses: []string{"192.0.2.1/24"}, Gateway: "192.0.2.254"},
+		Baseline:     &BaselineMultiple{SentMultiple: f, RecvMultiple: f},
+		RateOfChange: &RateOfChange{SentChange: -f, RecvChange: f},
+		Counters:     &Counters{Timestamp: now, Unavailable: []string{counterGroupErrors}},
+		Softnet:      &Softnet{},
+		Qdisc:        &QdiscStats{Kind: "fq_codel"},
+		Probes:       []ProbeResult{{Target: "192.0.2.254", Method: "icmp", RTTMs: &f, Loss: &f}, {Target: "192.0.2.253"}},
+		QuietHours:   &yes,
+		Plan:         &PlanUsage{DownPercent: &f, UpPercent: &f, TotalPercent: &f},
+		Monitor:      &SelfStats{Scheduling: &Scheduling{Policy: "fifo", Priority: 1, PinnedCPU: &n}},
+		Members:      []string{"eth0", "wwan0"},
+		recordID:     recordID{Seq: 1, SessionID: "0123456789abcdef"},
+	}
+	records := []any{
+		sample,
+		[]NetStats{sample, sample},
+		counterRecord{Interface: "selftest0", Counters: Counters{Timestamp: now}},
+		Event{Type: eventAnnotation, Severity: severityInfo, Timestamp: now, Interface: "selftest0", Message: "selftest", Details: map[string]bool{"bytes": true}},
+		Heartbeat{Type: recordHeartbeat, Timestamp: now, Interface: "selftest0"},
+		Header{Type: recordHeader, Timestamp: now, Kernel: "6.1", Interfaces: []string{"selftest0"}, SchemaVersion: schemaVersion},
+		HourlySummary{Type: recordHourlySummary, Start: now, End: now, InterfaceErrors: new(uint64), InterfaceDrops: new(uint64)},
+		PairStats{Type: "pair", Timestamp: now, Interfaces: [2]NetStats{sample, sample}},
+		BatchEnvelope{Interface: "selftest0", From: now, To: now, Samples: []any{sample, counterRecord{Counters: Counters{Timestamp: now}}}},
+		SessionSummary{Start: now, End: now, ExitReason: "limit", Error: "selftest",
+			Plan:    &PlanSummary{DownBitsPerSecond: &f, UpBitsPerSecond: &f, TotalBitsPerSecond: &f, PeakDownPercent: &f, PeakUpPercent: &f, PeakTotalPercent: &f, AverageDownPercent: &f, AverageUpPercent: &f, AverageTotalPercent: &f},
+			Softnet: &Softnet{}, QuietHours: &QuietHoursSummary{}, UnavailableCounters: []string{counterGroupPackets},
+			Failovers: []FailoverCycle{{Kind: eventFailover, Stopped: now, Resumed: now}}},
+	}
+	for _, r := range records {
+		if err := schema.checkValue(r); err != nil {
+			return "", err
+		}
+	}
+
+	mistyped := []byte(`{"interface":"selftest0","sentSpeed":{"value":"fast","unit":"B/s"},"recvSpeed":{"value":0,"unit":"B/s"},"totalSent":{"value":0,"unit":"B"},"totalRecv":{"value":0,"unit":"B"},"totalUsage":{"value":0,"unit":"B"},"timestamp":null}`)
+	if schema.checkLines(mistyped) == nil {
+		return "", errors.New("a sample with a string speed passed the schema")
+	}
+	return fmt.Sprintf("%d kinds of %s records match the schema", len(records), schema.version), nil
+}
+
+// checkCombinedDirection verifies that the total direction of a plan adds up the per-direction
+// figures, within the rounding of each.
+func checkCombinedDirection() (string, error) {
+	p := &ispPlan{Down: 8e6, Up: 8e6, Total: 8e6}
+	for _, r := range [][2]float64{{0, 0}, {125000, 375000}, {333333.3, 666.7}, {1e9, 1}} {
+		u := p.usage(r[0], r[1], 2)
+		if sum := *u.DownPercent + *u.UpPercent; math.Abs(*u.TotalPercent-sum) > 0.01 {
+			return "", fmt.Errorf("total %v%% of %v B/s differs from the sum %v%% of the directions", *u.TotalPercent, r, sum)
+		}
+	}
+	var st sessionTracker
+	st.addSample(1000, 0, 1000, 0, 1)
+	st.addSample(0, 1000, 1000, 1000, 1)
+	if st.peakTotal != 1000 {
+		return "", fmt.Errorf("combined peak %v B/s of alternating directions, expected 1000", st.peakTotal)
+	}
+	return "plan and peak totals match the per-direction figures", nil
+}
+
+// unitStepExamples are sizes around the steps of binary and -si units, with how each reports
+// them.
+var unitStepExamples = []struct {
+	bytes      uint64
+	binary, si netstats.Usage
+}{
+	{999, netstats.Usage{Value: 999, Unit: "B"}, netstats.Usage{Value: 999, Unit: "B"}},
+	{1000, netstats.Usage{Value: 1000, Unit: "B"}, netstats.Usage{Value: 1, Unit: "KB"}},
+	{1024, netstats.Usage{Value: 1, Unit: "KiB"}, netstats.Usage{Value: 1.02, Unit: "KB"}},
+	{1500000, netstats.Usage{Value: 1.43, Unit: "MiB"}, netstats.Usage{Value: 1.5, Unit: "MB"}},
+	{1 << 30, netstats.Usage{Value: 1, Unit: "GiB"}, netstats.Usage{Value: 1.07, Unit: "GB"}},
+	{1 << 42, netstats.Usage{Value: 4096, Unit: "GiB"}, netstats.Usage{Value: 4398.05, Unit: "GB"}},
+}
+
+// checkUnitSteps verifies that sizes and speeds change units at whole powers of 1024, or of
+// 1000 with -si, and carry the matching unit names, unless -unit fixes one.
+func checkUnitSteps() (string, error) {
+	for _, ex := range unitStepExamples {
+		for _, c := range []struct {
+			units netstats.Units
+			want  netstats.Usage
+		}{{netstats.Units{}, ex.binary}, {netstats.Units{SI: true}, ex.si}} {
+			if got := c.units.Usage(ex.bytes, 2); got != c.want {
+				return "", fmt.Errorf("%d bytes are reported as %g %s, expected %g %s", ex.bytes, got.Value, got.Unit, c.want.Value, c.want.Unit)
+			}
+			speed := c.units.Speed(ex.bytes, 1, 2)
+			if speed.Value != c.want.Value || speed.Unit != c.want.Unit+"/s" {
+				return "", fmt.Errorf("%d B/s is reported as %g %s, expected %g %s/s", ex.bytes, speed.Value, speed.Unit, c.want.Value, c.want.Unit)
+			}
+		}
+	}
+	// -unit keeps the unit it fixes on either side of a step.
+	fixed := netstats.Units{Fixed: netstats.PrefixMega}
+	for _, ex := range []struct {
+		bytes uint64
+		want  netstats.Speed
+	}{{1003520, netstats.Speed{Value: 0.96, Unit: "MiB/s"}}, {1 << 30, netstats.Speed{Value: 1024, Unit: "MiB/s"}}} {
+		if got := fixed.Speed(ex.bytes, 1, 2); got != ex.want {
+			return "", fmt.Errorf("%d B/s is reported as %g %s with -unit MB, expected %g %s", ex.bytes, got.Value, got.Unit, ex.want.Value, ex.want.Unit)
+		}
+	}
+	return fmt.Sprintf("%d sizes in binary and SI units, fixed units", len(unitStepExamples)), nil
+}
+
+// bitSpeedExamples are byte rates around the steps of -bits, with the speed they are reported as.
+var bitSpeedExamples = []struct {
+	bytes uint64
+	want  netstats.Speed
+}{
+	{0, netstats.Speed{Value: 0, Unit: "bit/s"}},
+	{124, netstats.Speed{Value: 992, Unit: "bit/s"}},
+	{125, netstats.Speed{Value: 1, Unit: "Kbit/s"}},
+	{124999, netstats.Speed{Value: 999.99, Unit: "Kbit/s"}},
+	{125000, netstats.Speed{Value: 1, Unit: "Mbit/s"}},
+	{124999999, netstats.Speed{Value: 1000, Unit: "Mbit/s"}},
+	{125000000, netstats.Speed{Value: 1, Unit: "Gbit/s"}},
+}
+
+// checkBitSpeeds verifies that -bits switches units at whole powers of 1000 bits, and that
+// the speeds it reports parse back as rate flags.
+func checkBitSpeeds() (string, error) {
+	u := netstats.Units{Bits: true}
+	for _, ex := range bitSpeedExamples {
+		got := u.Speed(ex.bytes, 1, 2)
+		if got != ex.want {
+			return "", fmt.Errorf("%d B/s is reported as %g %s, expected %g %s", ex.bytes, got.Value, got.Unit, ex.want.Value, ex.want.Unit)
+		}
+		form := fmt.Sprintf("%g%s", got.Value, got.Unit)
+		if v, err := netstats.ParseSpeed(form); err != nil || math.Abs(v-float64(ex.bytes)) > float64(ex.bytes)/1000 {
+			return "", fmt.Errorf("%q does not parse back to %d B/s", form, ex.bytes)
+		}
+	}
+	return fmt.Sprintf("%d rates around the unit steps", len(bitSpeedExamples)), nil
+}
+
+// checkSinkBudget verifies that -strict-sinks judges drops within the rolling window only,
+// and leaves a sink alone until it attempted enough deliveries.
+func checkSinkBudget() (string, error) {
+	r := newSinkRegistry(time.Minute)
+	s := r.register("selftest", "")
+	now := time.Now()
+	for i := range sinkBudgetMinDeliveries - 1 {
+		s.attempt()
+		if i < 2 {
+			s.drop(errors.New("selftest"))
+		} else {
+			s.delivered()
+		}
+	}
+	if err := r.overBudget(0.1, now); err != nil {
+		return "", fmt.Errorf("a sink with only %d deliveries was judged: %v", sinkBudgetMinDeliveries-1, err)
+	}
+	s.attempt()
+	s.delivered()
+	if err := r.overBudget(0.1, now); err == nil {
+		return "", fmt.Errorf("2 drops in %d deliveries passed a 10%% budget", sinkBudgetMinDeliveries)
+	}
+	if err := r.overBudget(0.1, now.Add(time.Minute+time.Second)); err != nil {
+		return "", fmt.Errorf("drops older than the window still count: %v", err)
+	}
+	if h := s.health(now); h.Attempted != sinkBudgetMinDeliveries || h.Pending != 0 {
+		return "", fmt.Errorf("%d finished deliveries are reported as %d attempted, %d pending", sinkBudgetMinDeliveries, h.Attempted, h.Pending)
+	}
+	return "drops judged within the window only", nil
+}
+
+// checkRateShifts feeds a drop from 900 to 50 Mbit/s to an -alert rule once at a high rate and
+// once at a trickle, and verifies that only the first fires, once.
+func checkRateShifts() (string, error) {
+	rule, err := parseShiftRule("delta(recv_speed, 10s) < -80%")
+	if err != nil {
+		return "", err
+	}
+	for _, c := range []struct {
+		scale float64
+		want  int
+	}{{1, 1}, {1e-4, 0}} {
+		w := newShiftWatch(true, []*shiftRule{rule}, 1e6/8)
+		start := time.Now()
+		fired := 0
+		for i := range 30 {
+			rate := 900e6 / 8 * c.scale
+			if i >= 20 {
+				rate = 50e6 / 8 * c.scale
+			}
+			roc, shifts := w.observe("selftest0", start.Add(time.Duration(i)*time.Second), 0, rate)
+			if i > 0 && roc == nil {
+				return "", fmt.Errorf("no rate of change at sample %d", i)
+			}
+			if i == 21 && roc.RecvChange >= 0 {
+				return "", fmt.Errorf("a falling rate changes by %g B/s per second", roc.RecvChange)
+			}
+			fired += len(shifts)
+		}
+		if fired != c.want {
+			return "", fmt.Errorf("a drop to %g B/s fired %d events, expected %d", 50e6/8*c.scale, fired, c.want)
+		}
+	}
+	return "a sudden drop fires once, a trickle never", nil
+}
+
+// checkStateFile writes a baseline file and a lock in a temporary directory and reads them back.
+func checkStateFile() (string, error) {
+	dir, err := os.MkdirTemp("", "zag-netstats-selftest-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "baselines.json")
+
+	b, err := loadBaselines(path, "selftest0")
+	if err != nil {
+		return "", err
+	}
+	want := hourBaseline{Sent: 1024, Recv: 4096, Hours: 1}
+	b.hours[3] = want
+	if err := b.save(); err != nil {
+		return "", fmt.Errorf("write failed: %v", err)
+	}
+	if b, err = loadBaselines(path, "selftest0"); err != nil {
+		return "", fmt.Errorf("read failed: %v", err)
+	}
+	if b.hours[3] != want {
+		return "", fmt.Errorf("read back %+v, wrote %+v", b.hours[3], want)
+	}
+
+	l, err := acquireLock(path, false)
+	if err != nil {
+		return "", fmt.Errorf("lock failed: %v", err)
+	}
+	l.release()
+	return "baseline file written, read back and locked", nil
+}
+
+// checkPushGateway verifies that the Pushgateway at rawURL answers its readiness endpoint.
+func checkPushGateway(rawURL string) (string, error) {
+	if rawURL == "" {
+		return "not configured", errCheckSkipped
+	}
+	base, err := parsePushGateway(rawURL)
+	if err != nil {
+		return "", err
+	}
+	target := *base
+	target.User = nil
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/-/ready"
+	req, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if base.User != nil {
+		password, _ := base.User.Password()
+		req.SetBasicAuth(base.User.Username(), password)
+	}
+	client := http.Client{Timeout: pushTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("%s answered %s", target.Redacted(), resp.Status)
+	}
+	return target.Redacted() + " is ready", nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// recordID identifies an emitted record. Sequence numbers increase by one for every record of
+// a monitoring session, whatever its type or output format, so consumers can detect lost
+// records; the random session ID tells a monitor restart apart from a wrapped sequence. Both
+// are omitted from records nested in another record.
+type recordID struct {
+	Seq       uint64 `json:"seq,omitempty"`
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+// newSessionID returns a random identifier for a monitoring session.
+func newSessionID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		logWarnf("Error generating session ID: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// nextID returns the identifier of the next emitted record.
+func (nm *NetworkMonitor) nextID() recordID {
+	nm.seq++
+	return recordID{Seq: nm.seq, SessionID: nm.sessionID}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// decodeIDs returns the record IDs of the JSON lines in buf, whatever their type.
+func decodeIDs(t *testing.T, buf *bytes.Buffer) []recordID {
+	t.Helper()
+	var ids []recordID
+	sc := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for sc.Scan() {
+		var id recordID
+		if err := json.Unmarshal(sc.Bytes(), &id); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", sc.Text(), err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestRecordIDs(t *testing.T) {
+	run := func() []recordID {
+		src := newFakeSource()
+		src.set("eth0", 1000, 2000)
+		nm, buf := newTestMonitor(t, "eth0", src, "-f", "json", "-header")
+		if err := nm.startSampling(); err != nil {
+			t.Fatal(err)
+		}
+		nm.emitHeader()
+		start := time.Now()
+
+		src.set("eth0", 1500, 2500)
+		tick(t, nm, start.Add(1*time.Second))
+		nm.emitEvent(Event{Type: eventAnnotation, Timestamp: Timestamp(start), Message: "deploy"})
+
+		// Totals restarting, from the reset command or an interface that vanished and came
+		// back, do not restart the sequence.
+		nm.resetTotals()
+		src.set("eth0", 1600, 2600)
+		tick(t, nm, start.Add(2*time.Second))
+		src.remove("eth0")
+		tick(t, nm, start.Add(3*time.Second))
+		src.set("eth0", 1700, 2700)
+		tick(t, nm, start.Add(4*time.Second))
+		return decodeIDs(t, buf)
+	}
+
+	first := run()
+	if len(first) < 5 {
+		t.Fatalf("got %d records, want at least 5", len(first))
+	}
+	for i, id := range first {
+		if id.Seq != uint64(i+1) || id.SessionID != first[0].SessionID {
+			t.Errorf("record %d has seq %d of session %q, want seq %d of session %q", i, id.Seq, id.SessionID, i+1, first[0].SessionID)
+		}
+	}
+	if len(first[0].SessionID) != 16 {
+		t.Errorf("session ID %q, want 16 hex digits", first[0].SessionID)
+	}
+
+	// A restarted monitor starts a new session, so consumers can tell it from a lost record.
+	second := run()
+	if second[0].Seq != 1 || second[0].SessionID == first[0].SessionID {
+		t.Errorf("restarted monitor begins with seq %d of session %q, want seq 1 of a session other than %q",
+			second[0].Seq, second[0].SessionID, first[0].SessionID)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// Rate-of-change settings. Rates are smoothed with an exponential average giving the latest
+// sample shiftSmoothing of the weight, and the rate of change is the slope of the smoothed
+// rate over the last shiftSlopeSamples samples, so a single noisy sample moves neither much.
+const (
+	shiftSmoothing    = 0.5
+	shiftSlopeSamples = 5
+)
+
+// eventRateShift is emitted when an -alert rule on the change of a rate starts to hold.
+const eventRateShift = "rate-shift"
+
+// shiftRulePattern is the form of an -alert rule, such as delta(recv_speed, 10s) < -80%.
+var shiftRulePattern = regexp.MustCompile(`^\s*delta\(\s*([a-z_]+)\s*,\s*([^)\s]+)\s*\)\s*(<=|>=|<|>)\s*(\S+)\s*$`)
+
+// RateOfChange is how fast the smoothed rates of a sample change, with -rate-of-change.
+type RateOfChange struct {
+	SentChange float64 `json:"sentChange"` // Bytes per second gained per second, negative when slowing down
+	RecvChange float64 `json:"recvChange"`
+}
+
+// RateShift details a rate-shift event.
+type RateShift struct {
+	Rule          string  `json:"rule"`
+	Metric        string  `json:"metric"`        // sent_speed, recv_speed or total_speed
+	WindowSeconds float64 `json:"windowSeconds"` // Time between the two rates compared
+	From          float64 `json:"from"`          // Smoothed rate at the start of the window, in bytes per second
+	To            float64 `json:"to"`            // Smoothed rate now
+	ChangePercent float64 `json:"changePercent"` // Change relative to From, 0 when From is 0
+}
+
+// alertsValue is a repeatable flag value collecting -alert rules.
+type alertsValue []string
+
+func (v *alertsValue) String() string {
+	return strings.Join(*v, "; ")
+}
+
+func (v *alertsValue) Set(s string) error {
+	*v = append(*v, s)
+	return nil
+}
+
+// shiftRule is a parsed -alert rule. It holds when the change of the smoothed rate of metric
+// over window compares to threshold as op says.
+type shiftRule struct {
+	text      string
+	metric    string // sent_speed, recv_speed or total_speed
+	window    time.Duration
+	op        string
+	threshold float64 // Share of the earlier rate when relative, else bytes per second
+	relative  bool
+}
+
+// parseShiftRule interprets an -alert rule such as "delta(recv_speed, 10s) < -80%" or
+// "delta(sent_speed, 5s) > 50Mbit".
+func parseShiftRule(s string) (*shiftRule, error) {
+	m := shiftRulePattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("Invalid alert %q, expected delta(metric, window) op value such as delta(recv_speed, 10s) < -80%%", s)
+	}
+	r := &shiftRule{text: strings.TrimSpace(s), metric: m[1], op: m[3]}
+	switch r.metric {
+	case "sent_speed", "recv_speed", "total_speed":
+	default:
+		return nil, fmt.Errorf("Invalid alert %q: unknown metric %s, expected sent_speed, recv_speed or total_speed", s, r.metric)
+	}
+	window, err := time.ParseDuration(m[2])
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("Invalid alert %q: window %s is not a positive duration such as 10s", s, m[2])
+	}
+	r.window = window
+
+	value, negative := strings.CutPrefix(m[4], "-")
+	if num, ok := strings.CutSuffix(value, "%"); ok {
+		r.relative = true
+		r.threshold, err = strconv.ParseFloat(num, 64)
+		r.threshold /= 100
+	} else {
+		r.threshold, err = netstats.ParseSpeed(value)
+	}
+	if err != nil || strings.HasPrefix(value, "+") {
+		return nil, fmt.Errorf("Invalid alert %q: %s is neither a percentage such as -80%% nor a rate such as 50Mbit", s, m[4])
+	}
+	if negative {
+		r.threshold = -r.threshold
+	}
+	return r, nil
+}
+
+// parseShiftRules parses every -alert rule.
+func parseShiftRules(defs []string) ([]*shiftRule, error) {
+	rules := make([]*shiftRule, 0, len(defs))
+	for _, def := range defs {
+		r, err := parseShiftRule(def)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// holds reports whether a change compares to the threshold of r.
+func (r *shiftRule) holds(change float64) bool {
+	switch r.op {
+	case "<":
+		return change < r.threshold
+	case "<=":
+		return change <= r.threshold
+	case ">":
+		return change > r.threshold
+	}
+	return change >= r.threshold
+}
+
+// shiftPoint is the smoothed rates of one sample, in bytes per second.
+type shiftPoint struct {
+	at         time.Time
+	sent, recv float64
+}
+
+// metric returns the rate of p that an -alert metric names.
+func (p shiftPoint) metric(name string) float64 {
+	switch name {
+	case "sent_speed":
+		return p.sent
+	case "recv_speed":
+		return p.recv
+	}
+	return p.sent + p.recv
+}
+
+// shiftTracker follows the smoothed rates of one interface for -rate-of-change and -alert.
+type shiftTracker struct {
+	history []shiftPoint // Oldest first, covering the longest rule window and the slope
+	holding []bool       // Whether each rule held at the previous sample
+}
+
+// shiftWatch holds the rate-of-change state of every monitored interface.
+type shiftWatch struct {
+	field    bool // Whether samples carry their RateOfChange
+	rules    []*shiftRule
+	minRate  float64       // Rate, in bytes per second, below which relative rules are not judged
+	span     time.Duration // Longest rule window
+	trackers map[string]*shiftTracker
+}
+
+func newShiftWatch(field bool, rules []*shiftRule, minRate float64) *shiftWatch {
+	w := &shiftWatch{field: field, rules: rules, minRate: minRate, trackers: make(map[string]*shiftTracker)}
+	for _, r := range rules {
+		w.span = max(w.span, r.window)
+	}
+	return w
+}
+
+// observe adds the rates of a sample of iface, in bytes per second, taken at now. It returns
+// the rate of change, nil on the first sample of the interface, and the rules that started to
+// hold with their details.
+func (w *shiftWatch) observe(iface string, now time.Time, sentBps, recvBps float64) (*RateOfChange, []RateShift) {
+	t := w.trackers[iface]
+	if t == nil {
+		t = &shiftTracker{holding: make([]bool, len(w.rules))}
+		w.trackers[iface] = t
+	}
+	p := shiftPoint{at: now, sent: sentBps, recv: recvBps}
+	if n := len(t.history); n > 0 {
+		last := t.history[n-1]
+		p.sent = last.sent + shiftSmoothing*(sentBps-last.sent)
+		p.recv = last.recv + shiftSmoothing*(recvBps-last.recv)
+	}
+	t.history = append(t.history, p)
+
+	// Keep the latest point at or before the start of the longest window, which rules compare
+	// against, and enough points for the slope.
+	keep := 0
+	for keep+1 < len(t.history)-shiftSlopeSamples && !t.history[keep+1].at.After(now.Add(-w.span)) {
+		keep++
+	}
+	t.history = t.history[keep:]
+
+	var roc *RateOfChange
+	if n := len(t.history); n > 1 {
+		first := t.history[max(0, n-shiftSlopeSamples)]
+		if dt := now.Sub(first.at).Seconds(); dt > 0 {
+			roc = &RateOfChange{SentChange: (p.sent - first.sent) / dt, RecvChange: (p.recv - first.recv) / dt}
+		}
+	}
+
+	var shifts []RateShift
+	for i, r := range w.rules {
+		ref, ok := t.before(now.Add(-r.window))
+		holds := false
+		var s RateShift
+		if ok {
+			from, to := ref.metric(r.metric), p.metric(r.metric)
+			s = RateShift{Rule: r.text, Metric: r.metric, WindowSeconds: now.Sub(ref.at).Seconds(), From: from, To: to}
+			if from > 0 {
+				s.ChangePercent = (to - from) / from * 100
+			}
+			if r.relative {
+				// A relative change of a trickle is noise: 2 KB/s to 200 B/s is -90%.
+				holds = from > 0 && from >= w.minRate && r.holds((to-from)/from)
+			} else {
+				holds = r.holds(to - from)
+			}
+		}
+		if holds && !t.holding[i] {
+			shifts = append(shifts, s)
+		}
+		t.holding[i] = holds
+	}
+	if !w.field {
+		roc = nil
+	}
+	return roc, shifts
+}
+
+// before returns the latest point at or before at, if any.
+func (t *shiftTracker) before(at time.Time) (shiftPoint, bool) {
+	for i := len(t.history) - 1; i >= 0; i-- {
+		if !t.history[i].at.After(at) {
+			return t.history[i], true
+		}
+	}
+	return shiftPoint{}, false
+}
+
+// trackShifts adds the rate of change to a sample and emits an event for every -alert rule
+// that started to hold.
+func (nm *NetworkMonitor) trackShifts(s *NetStats, now time.Time) {
+	roc, shifts := nm.shifts.observe(s.Interface, now, s.raw.sentBps, s.raw.recvBps)
+	if roc != nil {
+		roc.SentChange = netstats.Round(roc.SentChange, nm.precision)
+		roc.RecvChange = netstats.Round(roc.RecvChange, nm.precision)
+	}
+	s.RateOfChange = roc
+	for _, shift := range shifts {
+		shift.WindowSeconds = netstats.Round(shift.WindowSeconds, nm.precision)
+		shift.From = netstats.Round(shift.From, nm.precision)
+		shift.To = netstats.Round(shift.To, nm.precision)
+		shift.ChangePercent = netstats.Round(shift.ChangePercent, nm.precision)
+		from := nm.units.Speed(uint64(shift.From), 1, nm.precision)
+		to := nm.units.Speed(uint64(shift.To), 1, nm.precision)
+		nm.emitEvent(Event{
+			Type:      eventRateShift,
+			Severity:  severityWarning,
+			Timestamp: Timestamp(now),
+			Interface: s.Interface,
+			Message: fmt.Sprintf("%s changed %+.0f%% within %gs, from %g %s to %g %s (%s)",
+				shift.Metric, shift.ChangePercent, shift.WindowSeconds, from.Value, from.Unit, to.Value, to.Unit, shift.Rule),
+			Details: shift,
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Settings of sink delivery tracking.
+const (
+	sinkWindowBuckets       = 60 // Buckets of the rolling window of -sink-budget-window
+	sinkBudgetMinDeliveries = 10 // Deliveries in the window before -strict-sinks judges a sink
+)
+
+// sampleSink receives the samples of every tick next to the output format, such as a metrics
+// server. Several sinks may be active at once; send is called from the sampling loop and must
+// not block on the network. The sink reports what became of the delivery to d, at once or
+// later from a goroutine of its own.
+type sampleSink interface {
+	send(samples []NetStats, d *sinkStats)
+}
+
+// recordSink is a sink that also receives records other than samples, such as events, as the
+// JSON the output would hold. Like send, sendRecord must not block on the network.
+type recordSink interface {
+	sampleSink
+	sendRecord(data []byte, d *sinkStats)
+}
+
+// trackedSink is a sink registered with the monitor's sink registry. The monitor only holds
+// sinks in this form, so every delivery is counted whichever sink it goes to.
+type trackedSink struct {
+	sink  sampleSink
+	types recordType // Records the sink receives
+	stats *sinkStats
+}
+
+// addSink registers s under name, such as graphite, and target, the address it sends to. The
+// sink receives the samples of every tick.
+func (nm *NetworkMonitor) addSink(name, target string, s sampleSink) {
+	nm.addFilteredSink(name, target, typeSample, s)
+}
+
+// addFilteredSink registers s to receive the records of types only. Types other than samples
+// need a recordSink.
+func (nm *NetworkMonitor) addFilteredSink(name, target string, types recordType, s sampleSink) {
+	nm.sinks = append(nm.sinks, trackedSink{sink: s, types: types, stats: nm.sinkRegistry.register(name, target)})
+}
+
+// sendSinks passes the samples of one tick to every sink that receives samples.
+func (nm *NetworkMonitor) sendSinks(samples []NetStats) {
+	for _, t := range nm.sinks {
+		if t.types&typeSample == 0 {
+			continue
+		}
+		t.stats.attempt()
+		t.sink.send(samples, t.stats)
+	}
+}
+
+// sinksReceive reports whether any sink receives records of type typ.
+func (nm *NetworkMonitor) sinksReceive(typ recordType) bool {
+	return slices.ContainsFunc(nm.sinks, func(t trackedSink) bool { return t.types&typ != 0 })
+}
+
+// sendSinkRecord passes a record other than a sample to the sinks that receive its type. The
+// record is marshaled once, and scrubbed like the output with -redact.
+func (nm *NetworkMonitor) sendSinkRecord(v any) {
+	typ, ok := recordTypeOf(v)
+	if !ok || !nm.sinksReceive(typ) {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		logErrorf("Error marshaling to JSON: %v", err)
+		return
+	}
+	if nm.redact != nil {
+		data = nm.redact.text(data)
+	}
+	for _, t := range nm.sinks {
+		if rs, ok := t.sink.(recordSink); ok && t.types&typ != 0 {
+			t.stats.attempt()
+			rs.sendRecord(data, t.stats)
+		}
+	}
+}
+
+// checkSinkBudget fails monitoring under -strict-sinks once a sink dropped more of its
+// deliveries than -sink-drop-budget allows.
+func (nm *NetworkMonitor) checkSinkBudget() error {
+	if !nm.strictSinks {
+		return nil
+	}
+	if err := nm.sinkRegistry.overBudget(nm.sinkBudget, time.Now()); err != nil {
+		return outputError(err)
+	}
+	return nil
+}
+
+// sinkStats counts the deliveries of one sink. A delivery is the samples of one tick, or the
+// single push of -pushgateway; each ends up delivered or dropped, possibly after retries.
+// Outcomes may be reported from any goroutine.
+type sinkStats struct {
+	name   string
+	target string
+
+	mu          sync.Mutex
+	attempted   uint64
+	succeeded   uint64
+	retried     uint64
+	dropped     uint64
+	lastError   string
+	lastErrorAt time.Time
+	lastSuccess time.Time
+	window      sinkWindow
+}
+
+// attempt counts a delivery handed to the sink.
+func (s *sinkStats) attempt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempted++
+	s.window.add(time.Now(), 1, 0)
+}
+
+// delivered counts a delivery that reached its target.
+func (s *sinkStats) delivered() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.succeeded++
+	s.lastSuccess = time.Now()
+}
+
+// retry counts another attempt at a delivery that failed with err.
+func (s *sinkStats) retry(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retried++
+	s.lastError, s.lastErrorAt = err.Error(), time.Now()
+}
+
+// drop counts a delivery given up on because of err.
+func (s *sinkStats) drop(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.dropped++
+	s.lastError, s.lastErrorAt = err.Error(), now
+	s.window.add(now, 0, 1)
+}
+
+// SinkHealth is the delivery record of one sink, served on /sinks and logged at exit.
+type SinkHealth struct {
+	Name            string     `json:"name"`
+	Target          string     `json:"target,omitempty"`
+	Attempted       uint64     `json:"attempted"`
+	Succeeded       uint64     `json:"succeeded"`
+	Retried         uint64     `json:"retried"`
+	Dropped         uint64     `json:"dropped"`
+	Pending         uint64     `json:"pending"`         // Deliveries without an outcome yet, such as queued ones
+	WindowDropRatio float64    `json:"windowDropRatio"` // Share of the deliveries of the last -sink-budget-window that were dropped
+	LastError       string     `json:"lastError,omitempty"`
+	LastErrorAt     *Timestamp `json:"lastErrorAt,omitempty"`
+	LastSuccess     *Timestamp `json:"lastSuccess,omitempty"`
+}
+
+// health returns the delivery record of the sink at now.
+func (s *sinkStats) health(now time.Time) SinkHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := SinkHealth{
+		Name:            s.name,
+		Target:          s.target,
+		Attempted:       s.attempted,
+		Succeeded:       s.succeeded,
+		Retried:         s.retried,
+		Dropped:         s.dropped,
+		WindowDropRatio: s.window.dropRatio(now),
+		LastError:       s.lastError,
+	}
+	if done := s.succeeded + s.dropped; done < s.attempted {
+		h.Pending = s.attempted - done
+	}
+	if !s.lastErrorAt.IsZero() {
+		t := Timestamp(s.lastErrorAt)
+		h.LastErrorAt = &t
+	}
+	if !s.lastSuccess.IsZero() {
+		t := Timestamp(s.lastSuccess)
+		h.LastSuccess = &t
+	}
+	return h
+}
+
+// sinkWindow counts deliveries and drops over a rolling window, in sinkWindowBuckets buckets
+// of equal length, so its size does not grow with the rate of deliveries.
+type sinkWindow struct {
+	span    time.Duration // Length of one bucket
+	buckets [sinkWindowBuckets]struct {
+		slot               int64 // Start of the bucket, in spans since the epoch
+		attempted, dropped uint64
+	}
+}
+
+func newSinkWindow(window time.Duration) sinkWindow {
+	return sinkWindow{span: max(window/sinkWindowBuckets, time.Millisecond)}
+}
+
+// add counts attempted deliveries and dropped ones at now.
+func (w *sinkWindow) add(now time.Time, attempted, dropped uint64) {
+	slot := now.UnixNano() / int64(w.span)
+	b := &w.buckets[slot%sinkWindowBuckets]
+	if b.slot != slot {
+		b.slot, b.attempted, b.dropped = slot, 0, 0
+	}
+	b.attempted += attempted
+	b.dropped += dropped
+}
+
+// totals returns the deliveries attempted and dropped within the window ending at now.
+func (w *sinkWindow) totals(now time.Time) (attempted, dropped uint64) {
+	slot := now.UnixNano() / int64(w.span)
+	for _, b := range w.buckets {
+		if b.slot > slot-sinkWindowBuckets && b.slot <= slot {
+			attempted += b.attempted
+			dropped += b.dropped
+		}
+	}
+	return attempted, dropped
+}
+
+// dropRatio returns the share of the deliveries within the window that were dropped. A drop
+// may be reported after the window moved past its attempt, so the ratio is capped at 1.
+func (w *sinkWindow) dropRatio(now time.Time) float64 {
+	attempted, dropped := w.totals(now)
+	if attempted == 0 {
+		return 0
+	}
+	return min(1, float64(dropped)/float64(attempted))
+}
+
+// sinkRegistry holds the delivery records of every sink of a session.
+type sinkRegistry struct {
+	window time.Duration // Span of the drop ratio, -sink-budget-window
+
+	mu    sync.Mutex
+	sinks []*sinkStats
+}
+
+func newSinkRegistry(window time.Duration) *sinkRegistry {
+	return &sinkRegistry{window: window}
+}
+
+// register adds a sink and returns the record its deliveries are counted in.
+func (r *sinkRegistry) register(name, target string) *sinkStats {
+	s := &sinkStats{name: name, target: target, window: newSinkWindow(r.window)}
+	r.mu.Lock()
+	r.sinks = append(r.sinks, s)
+	r.mu.Unlock()
+	return s
+}
+
+// health returns the delivery records of the sinks, in order of registration.
+func (r *sinkRegistry) health() []SinkHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	hs := make([]SinkHealth, len(r.sinks))
+	for i, s := range r.sinks {
+		hs[i] = s.health(now)
+	}
+	return hs
+}
+
+// overBudget returns an error naming the first sink that dropped more than budget, a share of
+// its deliveries, within the window ending at now. Sinks with fewer than
+// sinkBudgetMinDeliveries deliveries in the window are not judged yet.
+func (r *sinkRegistry) overBudget(budget float64, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.sinks {
+		s.mu.Lock()
+		attempted, dropped := s.window.totals(now)
+		lastError := s.lastError
+		s.mu.Unlock()
+		if attempted >= sinkBudgetMinDeliveries && float64(dropped) > budget*float64(attempted) {
+			return fmt.Errorf("sink %s dropped %d of %d deliveries in the last %s, over the budget of %g%%: %s",
+				s.name, dropped, attempted, r.window, budget*100, lastError)
+		}
+	}
+	return nil
+}
+
+// logHealth logs one line per sink with its deliveries when monitoring ends.
+func (r *sinkRegistry) logHealth() {
+	for _, h := range r.health() {
+		name := h.Name
+		if h.Target != "" {
+			name += " (" + h.Target + ")"
+		}
+		line := fmt.Sprintf("Sink %s: %d of %d deliveries succeeded, %d retried, %d dropped, %d pending",
+			name, h.Succeeded, h.Attempted, h.Retried, h.Dropped, h.Pending)
+		if h.Dropped == 0 {
+			logInfof("%s", line)
+			continue
+		}
+		logWarnf("%s; last error at %s: %s", line, h.LastErrorAt, h.LastError)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	stdnet "net"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// recordType is a type of record a sink can receive, combined into sets as a bit mask.
+type recordType uint8
+
+const (
+	typeSample    recordType = 1 << iota // Samples of every tick
+	typeEvent                            // Events, including alerts and annotations
+	typeHeartbeat                        // Heartbeats of -heartbeat
+	typeSummary                          // Hourly summaries and the session summary
+)
+
+// recordTypeNames maps the names of the types= filter of -sink to record types.
+var recordTypeNames = map[string]recordType{
+	"sample": typeSample, "samples": typeSample,
+	"event": typeEvent, "events": typeEvent,
+	"heartbeat": typeHeartbeat, "heartbeats": typeHeartbeat,
+	"summary": typeSummary, "summaries": typeSummary,
+}
+
+// recordTypeOf returns the type of a record other than a sample, or false for records no
+// sink receives, such as capability reports.
+func recordTypeOf(v any) (recordType, bool) {
+	switch v.(type) {
+	case Event:
+		return typeEvent, true
+	case Heartbeat:
+		return typeHeartbeat, true
+	case HourlySummary, SessionSummary:
+		return typeSummary, true
+	}
+	return 0, false
+}
+
+// sampleFields selects the figures of samples a sink receives: rates, totals or both.
+type sampleFields uint8
+
+const (
+	fieldRates  sampleFields = 1 << iota // Send and receive rates
+	fieldTotals                          // Bytes moved since monitoring started
+
+	allFields = fieldRates | fieldTotals
+)
+
+// sampleFieldNames maps the names of the fields= filter of -sink to sample figures.
+var sampleFieldNames = map[string]sampleFields{"rates": fieldRates, "totals": fieldTotals}
+
+// sinkSpec is a parsed -sink definition: the kind of sink, where it sends to and which
+// records and figures it receives.
+type sinkSpec struct {
+	text   string // The definition as given, for error messages
+	kind   string // graphite, statsd, file or webhook
+	target string // Address, path or URL, without the filter parameters
+	types  recordType
+	fields sampleFields
+}
+
+// sinksValue is a repeatable flag value collecting -sink definitions.
+type sinksValue []string
+
+func (v *sinksValue) String() string {
+	return strings.Join(*v, " ")
+}
+
+func (v *sinksValue) Set(s string) error {
+	*v = append(*v, s)
+	return nil
+}
+
+// parseSinkSpec parses a -sink definition of the form kind:target?types=...&fields=..., such
+// as "statsd:127.0.0.1:8125?fields=rates" or "webhook:https://x/hook?types=event". The filter
+// parameters of a webhook are taken out of its URL; other parameters stay part of it.
+func parseSinkSpec(text string) (sinkSpec, error) {
+	spec := sinkSpec{text: text, types: typeSample | typeEvent | typeHeartbeat | typeSummary, fields: allFields}
+	kind, target, ok := strings.Cut(text, ":")
+	if !ok || target == "" {
+		return spec, fmt.Errorf("Invalid sink %q, expected kind:target such as file:/var/log/netstats.jsonl", text)
+	}
+	spec.kind = kind
+
+	var params url.Values
+	if kind == "webhook" {
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return spec, fmt.Errorf("Invalid sink %q: expected an http or https URL", text)
+		}
+		query := u.Query()
+		params = url.Values{"types": query["types"], "fields": query["fields"]}
+		query.Del("types")
+		query.Del("fields")
+		u.RawQuery = query.Encode()
+		spec.target = u.String()
+	} else {
+		target, rawQuery, _ := strings.Cut(target, "?")
+		var err error
+		if params, err = url.ParseQuery(rawQuery); err != nil {
+			return spec, fmt.Errorf("Invalid sink %q: %v", text, err)
+		}
+		for name := range params {
+			if name != "types" && name != "fields" {
+				return spec, fmt.Errorf("Invalid sink %q: unknown parameter %q, expected types or fields", text, name)
+			}
+		}
+		spec.target = target
+	}
+
+	switch kind {
+	case "graphite", "statsd":
+		if _, _, err := stdnet.SplitHostPort(spec.target); err != nil {
+			return spec, fmt.Errorf("Invalid sink %q: expected host:port", text)
+		}
+		spec.types = typeSample
+	case "file":
+		if spec.target == "" {
+			return spec, fmt.Errorf("Invalid sink %q: expected a file path", text)
+		}
+	case "webhook":
+	default:
+		return spec, fmt.Errorf("Invalid sink %q: unknown kind %q, expected graphite, statsd, file or webhook", text, kind)
+	}
+
+	if list := params.Get("types"); list != "" {
+		var types recordType
+		for _, name := range strings.Split(list, ",") {
+			k, ok := recordTypeNames[strings.TrimSpace(name)]
+			if !ok {
+				return spec, fmt.Errorf("Invalid sink %q: unknown record type %q, expected sample, event, heartbeat or summary", text, name)
+			}
+			types |= k
+		}
+		if types&^spec.types != 0 {
+			return spec, fmt.Errorf("Invalid sink %q: a %s sink only carries samples", text, kind)
+		}
+		spec.types = types
+	}
+	if list := params.Get("fields"); list != "" {
+		var fields sampleFields
+		for _, name := range strings.Split(list, ",") {
+			f, ok := sampleFieldNames[strings.TrimSpace(name)]
+			if !ok {
+				return spec, fmt.Errorf("Invalid sink %q: unknown field %q, expected rates or totals", text, name)
+			}
+			fields |= f
+		}
+		if spec.types&typeSample == 0 {
+			return spec, fmt.Errorf("Invalid sink %q: fields select figures of samples, which it does not receive", text)
+		}
+		spec.fields = fields
+	}
+	return spec, nil
+}
+
+// parseSinkSpecs parses every -sink definition, rejecting a target given twice.
+func parseSinkSpecs(defs []string) ([]sinkSpec, error) {
+	specs := make([]sinkSpec, 0, len(defs))
+	for _, def := range defs {
+		spec, err := parseSinkSpec(def)
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(specs, func(s sinkSpec) bool { return s.kind == spec.kind && s.target == spec.target }) {
+			return nil, fmt.Errorf("Sink %s:%s is defined twice", spec.kind, spec.target)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// startSink starts the sink of spec and returns it with the function closing it once
+// monitoring ends. Metric sinks take the settings of their own flags, such as -graphite-prefix.
+func startSink(spec sinkSpec, cfg monitorConfig) (sampleSink, func(), error) {
+	switch spec.kind {
+	case "graphite":
+		g := newGraphiteSink(spec.target, cfg.GraphitePrefix)
+		g.fields = spec.fields
+		return g, func() {}, nil
+	case "statsd":
+		d, err := newStatsdSink(spec.target, cfg.StatsdTags)
+		if err != nil {
+			return nil, nil, err
+		}
+		d.fields = spec.fields
+		return d, d.close, nil
+	case "file":
+		f, _, err := openOutputFile(spec.target, true, cfg.Sync)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot write sink file: %v", err)
+		}
+		s := &fileSink{w: f, fields: spec.fields}
+		return s, func() { f.Close() }, nil
+	default:
+		w := newWebhookSink(spec.target, spec.fields)
+		return w, w.close, nil
+	}
+}
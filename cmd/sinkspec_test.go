@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseSinkSpec(t *testing.T) {
+	tests := []struct {
+		def    string
+		target string
+		types  recordType
+		fields sampleFields
+		err    string
+	}{
+		{def: "statsd:127.0.0.1:8125?fields=rates", target: "127.0.0.1:8125", types: typeSample, fields: fieldRates},
+		{def: "graphite:carbon:2003", target: "carbon:2003", types: typeSample, fields: allFields},
+		{def: "file:/var/log/ns.jsonl", target: "/var/log/ns.jsonl", types: typeSample | typeEvent | typeHeartbeat | typeSummary, fields: allFields},
+		{def: "file:/tmp/ns.jsonl?types=samples,summaries&fields=totals", target: "/tmp/ns.jsonl", types: typeSample | typeSummary, fields: fieldTotals},
+		{def: "webhook:https://x/hook?token=abc&types=event", target: "https://x/hook?token=abc", types: typeEvent, fields: allFields},
+		{def: "statsd:127.0.0.1:8125?types=event", err: `"statsd:127.0.0.1:8125?types=event": a statsd sink only carries samples`},
+		{def: "file:/tmp/x?types=alert", err: `"file:/tmp/x?types=alert": unknown record type "alert"`},
+		{def: "file:/tmp/x?types=event&fields=rates", err: `"file:/tmp/x?types=event&fields=rates": fields select figures of samples`},
+		{def: "webhook:/hook", err: `"webhook:/hook": expected an http or https URL`},
+		{def: "kafka:broker:9092", err: `"kafka:broker:9092": unknown kind "kafka"`},
+	}
+	for _, tt := range tests {
+		spec, err := parseSinkSpec(tt.def)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseSinkSpec(%q) error = %v, want one containing %s", tt.def, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSinkSpec(%q): %v", tt.def, err)
+			continue
+		}
+		if spec.target != tt.target || spec.types != tt.types || spec.fields != tt.fields {
+			t.Errorf("parseSinkSpec(%q) = %q types %b fields %b, want %q types %b fields %b",
+				tt.def, spec.target, spec.types, spec.fields, tt.target, tt.types, tt.fields)
+		}
+	}
+}
+
+// recordingSink keeps the types of the records it receives.
+type recordingSink struct {
+	received []string
+}
+
+func (r *recordingSink) send(samples []NetStats, d *sinkStats) {
+	r.received = append(r.received, "sample")
+	d.delivered()
+}
+
+func (r *recordingSink) sendRecord(data []byte, d *sinkStats) {
+	var record struct {
+		Type       string `json:"type"`
+		ExitReason string `json:"exitReason"`
+	}
+	json.Unmarshal(data, &record)
+	if record.Type == "" && record.ExitReason != "" {
+		record.Type = "summary"
+	}
+	r.received = append(r.received, record.Type)
+	d.delivered()
+}
+
+func TestSinkRecordTypes(t *testing.T) {
+	src := newFakeSource()
+	src.set("eth0", 0, 0)
+	nm, _ := newTestMonitor(t, "eth0", src, "-f", "json", "--heartbeat", "1s")
+	everything, events, samples := &recordingSink{}, &recordingSink{}, &recordingSink{}
+	nm.addFilteredSink("file", "all", typeSample|typeEvent|typeHeartbeat|typeSummary, everything)
+	nm.addFilteredSink("webhook", "events", typeEvent, events)
+	nm.addFilteredSink("file", "samples", typeSample, samples)
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+
+	src.set("eth0", 1000, 1000)
+	tick(t, nm, start.Add(time.Second))
+	nm.checkHeartbeat(start.Add(3 * time.Second))
+	nm.emitEvent(Event{Type: eventPaused, Timestamp: Timestamp(start), Interface: "eth0", Message: "sample output paused"})
+	nm.sendSinkRecord(nm.session.summary("eth0", start.Add(5*time.Second), 2, nil))
+
+	for _, tt := range []struct {
+		name string
+		sink *recordingSink
+		want string
+	}{
+		{"unfiltered", everything, "sample heartbeat paused summary"},
+		{"types=event", events, "paused"},
+		{"types=sample", samples, "sample"},
+	} {
+		if got := strings.Join(tt.sink.received, " "); got != tt.want {
+			t.Errorf("%s sink received %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookSinkOnlyPostsEvents(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	spec, err := parseSinkSpec("webhook:" + srv.URL + "/hook?types=event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := newFakeSource()
+	src.set("eth0", 0, 0)
+	nm, _ := newTestMonitor(t, "eth0", src, "-f", "json", "--heartbeat", "1s")
+	sink, closeSink, err := startSink(spec, monitorConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nm.addFilteredSink(spec.kind, spec.target, spec.types, sink)
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	src.set("eth0", 1000, 1000)
+	tick(t, nm, start.Add(time.Second))
+	nm.checkHeartbeat(start.Add(5 * time.Second))
+	nm.emitEvent(Event{Type: eventResumed, Timestamp: Timestamp(start), Interface: "eth0", Message: "sample output resumed"})
+	closeSink()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 || !strings.Contains(bodies[0], `"type":"resumed"`) {
+		t.Errorf("webhook received %q, want only the resumed event", bodies)
+	}
+	if h := nm.sinkRegistry.health(); len(h) != 1 || h[0].Attempted != 1 || h[0].Succeeded != 1 {
+		t.Errorf("sink health = %+v, want one attempted and delivered event", h)
+	}
+}
+
+func TestFileSinkRecordIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sink.jsonl")
+	spec, err := parseSinkSpec("file:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := newFakeSource()
+	src.set("eth0", 0, 0)
+	nm, buf := newTestMonitor(t, "eth0", src, "-f", "json")
+	sink, closeSink, err := startSink(spec, monitorConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nm.addFilteredSink(spec.kind, spec.target, spec.types, sink)
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	src.set("eth0", 1000, 1000)
+	tick(t, nm, start.Add(time.Second))
+	nm.emitEvent(Event{Type: eventAnnotation, Timestamp: Timestamp(start), Interface: "eth0", Message: "deploy"})
+	src.set("eth0", 2000, 2000)
+	tick(t, nm, start.Add(2*time.Second))
+	nm.paused = true
+	src.set("eth0", 3000, 3000)
+	tick(t, nm, start.Add(3*time.Second)) // Reaches the sink, but is not emitted
+	closeSink()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The sink carries the IDs of the records on stdout, in the same order, and the sample
+	// withheld while paused without one.
+	var numbered []recordID
+	withheld := 0
+	for _, id := range decodeIDs(t, bytes.NewBuffer(data)) {
+		if id == (recordID{}) {
+			withheld++
+			continue
+		}
+		numbered = append(numbered, id)
+	}
+	if stdout := decodeIDs(t, buf); len(stdout) < 3 || !slices.Equal(numbered, stdout) {
+		t.Errorf("sink record IDs %+v, want those on stdout %+v", numbered, stdout)
+	}
+	if withheld != 1 {
+		t.Errorf("sink got %d records without an ID, want the paused sample:\n%s", withheld, data)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// errSoftnetUnsupported is returned where the kernel's softnet statistics are not available.
+var errSoftnetUnsupported = errors.New("softnet statistics are only available on Linux")
+
+// Softnet counts packets the kernel dropped or deferred during softirq processing over one
+// sample, summed across CPUs. Drops here cost traffic without showing in interface counters.
+type Softnet struct {
+	Dropped  uint64 `json:"dropped"`  // Packets dropped because a CPU's backlog was full
+	Squeezed uint64 `json:"squeezed"` // Times processing stopped with work left (time_squeeze)
+}
+
+// softnetCPU holds the cumulative counters of one CPU's softnet_stat line.
+type softnetCPU struct {
+	dropped  uint32
+	squeezed uint32
+}
+
+// softnetCounters are the cumulative softnet counters per CPU.
+type softnetCounters map[int]softnetCPU
+
+// parseSoftnet parses /proc/net/softnet_stat: one line per CPU of hexadecimal columns, of which
+// the second is dropped packets and the third time_squeeze. The number of columns has grown
+// across kernel versions; since Linux 5.10 the thirteenth is the CPU index, which identifies
+// lines when offline CPUs are omitted. Older kernels list every CPU, so lines are numbered.
+func parseSoftnet(r io.Reader) (softnetCounters, error) {
+	counters := make(softnetCounters)
+	scanner := bufio.NewScanner(r)
+	for line := 0; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("softnet_stat line %d has %d columns, expected at least 3", line+1, len(fields))
+		}
+		values := make([]uint32, len(fields))
+		for i, f := range fields {
+			v, err := strconv.ParseUint(f, 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("softnet_stat line %d: invalid column %q", line+1, f)
+			}
+			values[i] = uint32(v)
+		}
+		cpu := line
+		if len(values) >= 13 {
+			cpu = int(values[12])
+		}
+		counters[cpu] = softnetCPU{dropped: values[1], squeezed: values[2]}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
+
+// delta returns the softnet events between two readings. The counters are 32 bits wide per
+// CPU, so wraparound is handled per CPU; CPUs that came online meanwhile are skipped until
+// they have a previous reading.
+func (cur softnetCounters) delta(prev softnetCounters) Softnet {
+	var d Softnet
+	for cpu, c := range cur {
+		p, ok := prev[cpu]
+		if !ok {
+			continue
+		}
+		d.Dropped += uint64(c.dropped - p.dropped)
+		d.Squeezed += uint64(c.squeezed - p.squeezed)
+	}
+	return d
+}
+
+// sampleSoftnet returns the softnet events since the previous sample and adds them to the
+// session figures. A failed read leaves the sample without them.
+func (nm *NetworkMonitor) sampleSoftnet() *Softnet {
+	cur, err := readSoftnet()
+	if err != nil {
+		nm.errThrottle.logf("Error reading softnet statistics: %v", err)
+		return nil
+	}
+	d := cur.delta(nm.softnet)
+	nm.softnet = cur
+	nm.session.softnet.Dropped += d.Dropped
+	nm.session.softnet.Squeezed += d.Squeezed
+	return &d
+}
@@ -0,0 +1,13 @@
+package main
+
+import "os"
+
+// readSoftnet reads the kernel's per-CPU softnet counters.
+func readSoftnet() (softnetCounters, error) {
+	f, err := os.Open("/proc/net/softnet_stat")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseSoftnet(f)
+}
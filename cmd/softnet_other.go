@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// readSoftnet reports softnet statistics as unavailable outside Linux.
+func readSoftnet() (softnetCounters, error) {
+	return nil, errSoftnetUnsupported
+}
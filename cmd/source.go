@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// counterSource supplies the cumulative I/O counters the monitor samples.
+type counterSource interface {
+	counters(iface string) (net.IOCountersStat, error) // Returns the current counters of the interface
+}
+
+// steppedSource is implemented by artificial sources whose readings do not follow real time.
+// span returns the time covered by the latest reading, which speeds are computed over instead
+// of the time measured between reads.
+type steppedSource interface {
+	span() time.Duration
+}
+
+// sourceSpan returns the span of the latest reading of src, possibly through a recorder, and
+// whether src supplies one.
+func sourceSpan(src counterSource) (time.Duration, bool) {
+	if r, ok := src.(*recordingSource); ok {
+		src = r.counterSource
+	}
+	if s, ok := src.(steppedSource); ok {
+		return s.span(), true
+	}
+	return 0, false
+}
+
+// kernelSource reads counters from the operating system. It is the default source.
+type kernelSource struct{}
+
+func (kernelSource) counters(iface string) (net.IOCountersStat, error) {
+	return netstats.ReadCounters(iface)
+}
+
+// counterLister is implemented by sources that can read every interface at once, as needed
+// to monitor all interfaces.
+type counterLister interface {
+	allCounters() ([]net.IOCountersStat, error) // Returns the current counters of every interface
+}
+
+func (kernelSource) allCounters() ([]net.IOCountersStat, error) {
+	return net.IOCounters(true)
+}
+
+// aggregateName is the interface name of the machine-wide aggregate, monitored with -i total.
+const aggregateName = "total"
+
+// aggregateSource sums the counters of every interface into a single reading named "total",
+// so the whole machine is sampled like one interface. Summing raw byte counters before any
+// unit conversion keeps rounding error from accumulating.
+type aggregateSource struct {
+	lister          counterLister
+	includeLoopback bool              // Whether loopback traffic counts towards the total
+	exclude         func(string) bool // Interfaces left out of the total, nil for none
+	loopback        loopbackCache
+}
+
+func (a *aggregateSource) counters(string) (net.IOCountersStat, error) {
+	stats, err := a.lister.allCounters()
+	if err != nil {
+		return net.IOCountersStat{}, err
+	}
+	total := net.IOCountersStat{Name: aggregateName}
+	for _, io := range stats {
+		if (!a.includeLoopback && a.loopback.is(io.Name)) || (a.exclude != nil && a.exclude(io.Name)) {
+			continue
+		}
+		total.BytesSent += io.BytesSent
+		total.BytesRecv += io.BytesRecv
+		total.PacketsSent += io.PacketsSent
+		total.PacketsRecv += io.PacketsRecv
+		total.Errin += io.Errin
+		total.Errout += io.Errout
+		total.Dropin += io.Dropin
+		total.Dropout += io.Dropout
+		total.Fifoin += io.Fifoin
+		total.Fifoout += io.Fifoout
+	}
+	return total, nil
+}
+
+// loopbackCache remembers which interfaces are loopback devices. Flags are looked up again
+// only when an interface not seen before appears.
+type loopbackCache map[string]bool
+
+func (c loopbackCache) is(name string) bool {
+	if loopback, ok := c[name]; ok {
+		return loopback
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return name == "lo"
+	}
+	for _, iface := range ifaces {
+		c[iface.Name] = slices.Contains(iface.Flags, "loopback")
+	}
+	return c[name]
+}
+
+// liveSource is implemented by sources reading the real interfaces of this machine, as
+// opposed to synthetic or recorded counters.
+type liveSource interface {
+	live()
+}
+
+func (kernelSource) live() {}
+
+// isKernelSource reports whether src reads real interfaces, possibly through a recorder.
+func isKernelSource(src counterSource) bool {
+	if r, ok := src.(*recordingSource); ok {
+		src = r.counterSource
+	}
+	_, ok := src.(liveSource)
+	return ok
+}
+
+// isLiveSourceSpec reports whether a -source value selects a source reading real interfaces.
+func isLiveSourceSpec(spec string) bool {
+	return spec == "" || spec == "kernel" || spec == "pdh"
+}
+
+// parseCounterSource interprets the value of the -source flag. An empty value selects the
+// kernel; otherwise the value has the form kind[:options]. interval is the sampling interval,
+// which artificial sources use as their step length.
+func parseCounterSource(spec string, interval time.Duration) (counterSource, error) {
+	if spec == "" || spec == "kernel" {
+		return kernelSource{}, nil
+	}
+
+	kind, opts, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "synthetic":
+		return newSyntheticSource(opts, interval)
+	case "replay":
+		return newReplaySource(opts)
+	case "pdh":
+		return newPDHSource(opts)
+	default:
+		return nil, fmt.Errorf("unknown counter source %q (supported: kernel, synthetic, replay, pdh)", kind)
+	}
+}
+
+// parseSourceOptions splits a comma-separated list of key=value source options.
+func parseSourceOptions(s string) (map[string]string, error) {
+	opts := make(map[string]string)
+	if s == "" {
+		return opts, nil
+	}
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid source option %q, expected key=value", field)
+		}
+		opts[key] = value
+	}
+	return opts, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// fakeSource serves counters set by a test. Every reading spans one second, so rates do not
+// depend on how fast the test runs.
+type fakeSource struct {
+	readings map[string]net.IOCountersStat
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{readings: make(map[string]net.IOCountersStat)}
+}
+
+// set makes iface report the given cumulative byte counters.
+func (f *fakeSource) set(iface string, sent, recv uint64) {
+	f.readings[iface] = net.IOCountersStat{Name: iface, BytesSent: sent, BytesRecv: recv}
+}
+
+// remove makes iface disappear, as after a rename or unplugging.
+func (f *fakeSource) remove(iface string) {
+	delete(f.readings, iface)
+}
+
+func (f *fakeSource) counters(iface string) (net.IOCountersStat, error) {
+	io, ok := f.readings[iface]
+	if !ok {
+		return io, fmt.Errorf("%w: %s", errInterfaceNotFound, iface)
+	}
+	return io, nil
+}
+
+func (f *fakeSource) allCounters() ([]net.IOCountersStat, error) {
+	all := make([]net.IOCountersStat, 0, len(f.readings))
+	for _, io := range f.readings {
+		all = append(all, io)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all, nil
+}
+
+func (f *fakeSource) span() time.Duration { return time.Second }
+
+// newTestMonitor returns a monitor of iface on src configured by the monitor flags in args,
+// writing its records to the returned buffer.
+func newTestMonitor(t *testing.T, iface string, src counterSource, args ...string) (*NetworkMonitor, *bytes.Buffer) {
+	t.Helper()
+	var cfg monitorConfig
+	fs := newMonitorFlagSet("test", &cfg)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	sel, err := parseInterfaceSelector(iface)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nm := NewNetworkMonitor(sel, iface, src, cfg)
+	var buf bytes.Buffer
+	nm.out.w = &buf
+	return nm, &buf
+}
+
+// tick runs one sampling tick at now, failing the test on an error.
+func tick(t *testing.T, nm *NetworkMonitor, now time.Time) (done bool) {
+	t.Helper()
+	done, err := nm.sampleTick(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return done
+}
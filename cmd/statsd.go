@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	stdnet "net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Settings of the StatsD sink.
+const (
+	statsdPrefix       = "zag.netstats" // Prefix of every metric name
+	statsdMaxDatagram  = 1432           // Largest datagram, fitting a common MTU after headers
+	statsdErrorPeriod  = time.Minute    // Interval of the warning counting failed sends
+	statsdWriteTimeout = time.Second    // Bound on one send, should the socket buffer be full
+)
+
+// statsdTagChars replaces the characters that end a DogStatsD tag or packet.
+var statsdTagChars = strings.NewReplacer(",", "_", "|", "_", "\n", "_", " ", "_")
+
+// statsdSink sends the samples of every tick to a StatsD server over UDP: rates as gauges and
+// the bytes moved since the previous tick as counters. With tags, the interface is a
+// DogStatsD tag rather than part of the metric name.
+type statsdSink struct {
+	conn       stdnet.Conn
+	tags       bool
+	fields     sampleFields         // Figures sent of each sample: rates as gauges, totals as counters
+	prev       map[string][2]uint64 // Totals sent and received at the previous tick, per interface
+	failed     int                  // Datagrams that could not be sent since the last warning
+	lastReport time.Time            // When failed sends were last reported
+}
+
+// newStatsdSink returns a sink sending to addr, such as 127.0.0.1:8125. Only the address is
+// resolved here; UDP has no connection to fail.
+func newStatsdSink(addr string, tags bool) (*statsdSink, error) {
+	conn, err := stdnet.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot send to StatsD at %s: %v", addr, err)
+	}
+	return &statsdSink{conn: conn, tags: tags, fields: allFields, prev: make(map[string][2]uint64), lastReport: time.Now()}, nil
+}
+
+// send writes the metrics of one tick's samples. The tick counts as dropped when any of its
+// datagrams could not be sent.
+func (d *statsdSink) send(samples []NetStats, delivery *sinkStats) {
+	var datagram, line bytes.Buffer
+	var sendErr error
+	for _, s := range samples {
+		prev := d.prev[s.Interface]
+		d.prev[s.Interface] = [2]uint64{s.raw.totalSent, s.raw.totalRecv}
+		for _, m := range []struct {
+			name, kind, value string
+			field             sampleFields
+		}{
+			{"sent_bps", "g", strconv.FormatFloat(s.raw.sentBps, 'f', -1, 64), fieldRates},
+			{"recv_bps", "g", strconv.FormatFloat(s.raw.recvBps, 'f', -1, 64), fieldRates},
+			{"sent_bytes", "c", strconv.FormatUint(counterDelta(s.raw.totalSent, prev[0]), 10), fieldTotals},
+			{"recv_bytes", "c", strconv.FormatUint(counterDelta(s.raw.totalRecv, prev[1]), 10), fieldTotals},
+		} {
+			if d.fields&m.field == 0 {
+				continue
+			}
+			line.Reset()
+			if d.tags {
+				fmt.Fprintf(&line, "%s.%s:%s|%s|#interface:%s", statsdPrefix, m.name, m.value, m.kind, statsdTagValue(s.Interface))
+			} else {
+				fmt.Fprintf(&line, "%s.%s.%s:%s|%s", statsdPrefix, graphitePath(s.Interface), m.name, m.value, m.kind)
+			}
+			if datagram.Len() > 0 && datagram.Len()+1+line.Len() > statsdMaxDatagram {
+				if err := d.write(datagram.Bytes()); err != nil {
+					sendErr = err
+				}
+				datagram.Reset()
+			}
+			if datagram.Len() > 0 {
+				datagram.WriteByte('\n')
+			}
+			datagram.Write(line.Bytes())
+		}
+	}
+	if datagram.Len() > 0 {
+		if err := d.write(datagram.Bytes()); err != nil {
+			sendErr = err
+		}
+	}
+	if sendErr != nil {
+		delivery.drop(sendErr)
+	} else {
+		delivery.delivered()
+	}
+	if time.Since(d.lastReport) >= statsdErrorPeriod {
+		d.reportFailures()
+	}
+}
+
+// reportFailures logs the datagrams that could not be sent since the last report.
+func (d *statsdSink) reportFailures() {
+	if d.failed > 0 {
+		logWarnf("Could not send %d StatsD datagrams since %s", d.failed, d.lastReport.Format(time.TimeOnly))
+	}
+	d.failed, d.lastReport = 0, time.Now()
+}
+
+// close reports outstanding failures and closes the socket.
+func (d *statsdSink) close() {
+	d.reportFailures()
+	d.conn.Close()
+}
+
+// write sends one datagram, counting it when it fails.
+func (d *statsdSink) write(datagram []byte) error {
+	d.conn.SetWriteDeadline(time.Now().Add(statsdWriteTimeout))
+	_, err := d.conn.Write(datagram)
+	if err != nil {
+		if d.failed == 0 {
+			logDebugf("StatsD send failed: %v", err)
+		}
+		d.failed++
+	}
+	return err
+}
+
+// counterDelta returns the bytes moved since a total of prev, or all of cur when the totals
+// restarted from zero.
+func counterDelta(cur, prev uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// statsdTagValue returns name with the characters that delimit DogStatsD tags replaced.
+func statsdTagValue(name string) string {
+	return statsdTagChars.Replace(name)
+}
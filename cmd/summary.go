@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// Exit reasons reported in the session summary.
+const (
+	exitReasonInterrupt = "interrupt" // Stopped by SIGINT or SIGTERM
+	exitReasonError     = "error"     // Stopped by a monitoring error
+	exitReasonEnd       = "end"       // The counter source ran out of readings
+	exitReasonLimit     = "limit"     // The -count or -duration limit was reached
+)
+
+// SessionSummary describes a complete monitoring session. Byte figures are raw, unscaled values.
+type SessionSummary struct {
+	Interface              string             `json:"interface"`
+	Start                  Timestamp          `json:"start"`
+	End                    Timestamp          `json:"end"`
+	DurationSeconds        float64            `json:"durationSeconds"`
+	Samples                int                `json:"samples"`
+	TotalSentBytes         uint64             `json:"totalSentBytes"`
+	TotalRecvBytes         uint64             `json:"totalRecvBytes"`
+	AvgSentBytesPerSecond  float64            `json:"avgSentBytesPerSecond"`
+	AvgRecvBytesPerSecond  float64            `json:"avgRecvBytesPerSecond"`
+	PeakSentBytesPerSecond float64            `json:"peakSentBytesPerSecond"`
+	PeakRecvBytesPerSecond float64            `json:"peakRecvBytesPerSecond"`
+	Errors                 int                `json:"errors"`
+	ConfigChanges          int                `json:"configChanges"`
+	ImplausibleSamples     int                `json:"implausibleSamples"` // Samples left out of totals and aggregates
+	ExitReason             string             `json:"exitReason"`
+	ExitCode               int                `json:"exitCode"` // Exit status of the process, see --help
+	Error                  string             `json:"error,omitempty"`
+	Plan                   *PlanSummary       `json:"plan,omitempty"`
+	Softnet                *Softnet           `json:"softnet,omitempty"`             // Softirq drops over the run, with -softnet
+	QuietHours             *QuietHoursSummary `json:"quietHours,omitempty"`          // Share of the run in -quiet-hours
+	UnavailableCounters    []string           `json:"unavailableCounters,omitempty"` // Counter groups the platform left at zero: packets, errors
+	Failovers              []FailoverCycle    `json:"failovers,omitempty"`           // Switches between the -failover-watch interfaces
+}
+
+// sessionTracker accumulates the figures reported in the session summary.
+type sessionTracker struct {
+	start         time.Time
+	samples       int
+	totalSent     uint64
+	totalRecv     uint64
+	peakSent      float64
+	peakRecv      float64
+	peakTotal     float64 // Highest combined rate of one sample, not the sum of the peaks above
+	errors        int
+	configChanges int
+	implausible   int                // Samples with implausible rates, left out of the figures above
+	softnet       *Softnet           // Softnet events of all samples, nil unless collected
+	endOfInput    bool               // Whether the session ended because the counter source was exhausted
+	limitReached  bool               // Whether the session ended at the -count or -duration limit
+	quietHours    *QuietHoursSummary // Figures of the samples in quiet hours, nil unless set
+}
+
+// addSample records one sample's byte deltas over the given interval and the running totals.
+func (st *sessionTracker) addSample(sentBytes, recvBytes, totalSent, totalRecv uint64, interval float64) {
+	st.samples++
+	st.totalSent = totalSent
+	st.totalRecv = totalRecv
+	st.peakSent = max(st.peakSent, float64(sentBytes)/interval)
+	st.peakRecv = max(st.peakRecv, float64(recvBytes)/interval)
+	st.peakTotal = max(st.peakTotal, float64(sentBytes+recvBytes)/interval)
+}
+
+// setTotals records the running totals without counting a sample, as during warm-up.
+func (st *sessionTracker) setTotals(totalSent, totalRecv uint64) {
+	st.totalSent = totalSent
+	st.totalRecv = totalRecv
+}
+
+// summary builds the session summary as of end.
+func (st *sessionTracker) summary(iface string, end time.Time, precision int, exitErr error) SessionSummary {
+	start := st.start
+	if start.IsZero() {
+		start = end // Monitoring never started, as when the initial read failed
+	}
+	duration := end.Sub(start).Seconds()
+	s := SessionSummary{
+		Interface:              iface,
+		Start:                  Timestamp(start),
+		End:                    Timestamp(end),
+		DurationSeconds:        netstats.Round(duration, precision),
+		Samples:                st.samples,
+		TotalSentBytes:         st.totalSent,
+		TotalRecvBytes:         st.totalRecv,
+		PeakSentBytesPerSecond: netstats.Round(st.peakSent, precision),
+		PeakRecvBytesPerSecond: netstats.Round(st.peakRecv, precision),
+		Errors:                 st.errors,
+		ConfigChanges:          st.configChanges,
+		ImplausibleSamples:     st.implausible,
+		ExitReason:             exitReasonInterrupt,
+	}
+	if st.softnet != nil {
+		softnet := *st.softnet
+		s.Softnet = &softnet
+	}
+	if st.quietHours != nil {
+		quiet := *st.quietHours
+		s.QuietHours = &quiet
+	}
+	if st.endOfInput {
+		s.ExitReason = exitReasonEnd
+	}
+	if st.limitReached {
+		s.ExitReason = exitReasonLimit
+	}
+	if duration > 0 {
+		s.AvgSentBytesPerSecond = netstats.Round(float64(st.totalSent)/duration, precision)
+		s.AvgRecvBytesPerSecond = netstats.Round(float64(st.totalRecv)/duration, precision)
+	}
+	if exitErr != nil {
+		s.ExitReason = exitReasonError
+		s.Error = exitErr.Error()
+		s.ExitCode = exitCode(exitErr)
+	}
+	return s
+}
+
+// printExitSummary writes the summary for people reading the terminal, in the units and
+// decimal separator of the table output.
+func (nm *NetworkMonitor) printExitSummary(w io.Writer, s SessionSummary) {
+	total := func(bytes uint64) string {
+		u := nm.units.Usage(bytes, nm.precision)
+		return formatQuantity(u.Value, u.Unit, nm.precision, nm.decimalComma)
+	}
+	rate := func(bytesPerSecond float64) string {
+		sp := nm.units.Speed(uint64(bytesPerSecond), 1, nm.precision)
+		return formatQuantity(sp.Value, sp.Unit, nm.precision, nm.decimalComma)
+	}
+	duration := time.Duration(s.DurationSeconds * float64(time.Second))
+	if duration >= time.Minute {
+		duration = duration.Round(time.Second)
+	} else {
+		duration = duration.Round(100 * time.Millisecond) // Short runs, such as -c 2 at 100ms
+	}
+	fmt.Fprintf(w, "Session summary of %s: %v, %d samples, ended by %s\n", s.Interface, duration, s.Samples, s.ExitReason)
+	fmt.Fprintf(w, "  Sent:     %s, average %s, peak %s\n", total(s.TotalSentBytes), rate(s.AvgSentBytesPerSecond), rate(s.PeakSentBytesPerSecond))
+	fmt.Fprintf(w, "  Received: %s, average %s, peak %s\n", total(s.TotalRecvBytes), rate(s.AvgRecvBytesPerSecond), rate(s.PeakRecvBytesPerSecond))
+	if s.Errors > 0 || s.ImplausibleSamples > 0 {
+		fmt.Fprintf(w, "  Errors:   %d read errors, %d implausible samples\n", s.Errors, s.ImplausibleSamples)
+	}
+	if s.Error != "" {
+		fmt.Fprintf(w, "  Stopped by: %s (exit code %d)\n", s.Error, s.ExitCode)
+	}
+}
+
+// writeSummaryJSON writes the summary as a single JSON document to target, which is either a
+// file descriptor number (such as 2 for stderr) or a file path.
+func writeSummaryJSON(target string, s SessionSummary) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("error marshaling summary: %v", err)
+	}
+	data = append(data, '\n')
+
+	if fd, err := strconv.Atoi(target); err == nil {
+		f := os.NewFile(uintptr(fd), "fd"+target)
+		if f == nil {
+			return fmt.Errorf("invalid summary file descriptor %d", fd)
+		}
+		_, err = f.Write(data)
+		return err
+	}
+
+	return os.WriteFile(target, data, 0o644)
+}
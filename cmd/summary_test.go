@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummaryWithoutStart(t *testing.T) {
+	var st sessionTracker // The initial read failed, so monitoring never started
+	end := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	readErr := &runtimeError{err: errors.New("error getting initial network stats: no such device"), exitCode: exitCollection}
+	s := st.summary("eth0", end, 2, readErr)
+
+	if s.Samples != 0 || s.DurationSeconds != 0 || time.Time(s.Start) != end {
+		t.Errorf("summary covers %d samples over %vs from %v, want none from the end", s.Samples, s.DurationSeconds, s.Start)
+	}
+	if s.ExitReason != exitReasonError || s.ExitCode != exitCollection || s.Error != readErr.Error() {
+		t.Errorf("summary ends with %s, code %d, %q; want error, %d, %q", s.ExitReason, s.ExitCode, s.Error, exitCollection, readErr)
+	}
+
+	schema, err := compileRecordSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.checkValue(s); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSessionSummaryTotals(t *testing.T) {
+	src := newFakeSource()
+	src.set("eth0", 0, 0)
+	nm, _ := newTestMonitor(t, "eth0", src, "-f", "json", "-max-plausible-rate", "1MiB")
+	clk := &fakeClock{mono: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	nm.clock = clk
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bytes sent and received in each one-second tick.
+	var sent, recv uint64
+	for _, tk := range [][2]uint64{
+		{1000, 500},
+		{3000, 0},
+		{5 << 20, 0}, // Implausible: neither counted as a sample nor in the totals
+		{0, 2000},
+	} {
+		clk.advance(time.Second)
+		sent, recv = sent+tk[0], recv+tk[1]
+		src.set("eth0", sent, recv)
+		tick(t, nm, clk.now())
+	}
+	clk.advance(time.Second)
+	src.remove("eth0") // A failed read counts as an error
+	tick(t, nm, clk.now())
+
+	clk.advance(3 * time.Second)
+	s := nm.session.summary("eth0", clk.now(), 2, nil)
+	want := SessionSummary{
+		Interface:              "eth0",
+		Start:                  Timestamp(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)),
+		End:                    Timestamp(clk.now()),
+		DurationSeconds:        8,
+		Samples:                3,
+		TotalSentBytes:         4000,
+		TotalRecvBytes:         2500,
+		AvgSentBytesPerSecond:  500,
+		AvgRecvBytesPerSecond:  312.5,
+		PeakSentBytesPerSecond: 3000,
+		PeakRecvBytesPerSecond: 2000,
+		Errors:                 1,
+		ImplausibleSamples:     1,
+		ExitReason:             exitReasonInterrupt,
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("summary = %+v\nwant %+v", s, want)
+	}
+}
+
+func TestSessionSummaryWarmup(t *testing.T) {
+	for _, exclude := range []bool{false, true} {
+		src := newFakeSource()
+		src.set("eth0", 0, 0)
+		args := []string{"-f", "json", "-warmup", "2"}
+		if exclude {
+			args = append(args, "-warmup-exclude")
+		}
+		nm, buf := newTestMonitor(t, "eth0", src, args...)
+		clk := &fakeClock{mono: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+		nm.clock = clk
+		if err := nm.startSampling(); err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= 4; i++ {
+			clk.advance(time.Second)
+			src.set("eth0", uint64(i)*1000, 0)
+			tick(t, nm, clk.now())
+		}
+
+		// Warm-up samples are never emitted or counted, but their traffic stays in the totals
+		// unless excluded, and excluding them moves the start of the session past them.
+		s := nm.session.summary("eth0", clk.now(), 2, nil)
+		wantSent, wantStart := uint64(4000), time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		if exclude {
+			wantSent, wantStart = 2000, wantStart.Add(2*time.Second)
+		}
+		if n := len(decodeSamples(t, buf)); n != 2 || s.Samples != 2 {
+			t.Errorf("exclude %v: emitted %d samples, summary counts %d; want 2", exclude, n, s.Samples)
+		}
+		if s.TotalSentBytes != wantSent || time.Time(s.Start) != wantStart {
+			t.Errorf("exclude %v: summary sent %d from %v, want %d from %v", exclude, s.TotalSentBytes, time.Time(s.Start), wantSent, wantStart)
+		}
+	}
+}
+
+func TestPrintExitSummary(t *testing.T) {
+	nm, _ := newTestMonitor(t, "eth0", newFakeSource(), "-decimal-comma")
+	s := SessionSummary{
+		Interface:              "eth0",
+		DurationSeconds:        90.4,
+		Samples:                90,
+		TotalSentBytes:         3 << 20,
+		TotalRecvBytes:         1536,
+		AvgSentBytesPerSecond:  34952.53,
+		AvgRecvBytesPerSecond:  17,
+		PeakSentBytesPerSecond: 1 << 20,
+		PeakRecvBytesPerSecond: 512,
+		ExitReason:             exitReasonInterrupt,
+	}
+	var b strings.Builder
+	nm.printExitSummary(&b, s)
+	want := "Session summary of eth0: 1m30s, 90 samples, ended by interrupt\n" +
+		"  Sent:     3,00 MiB, average 34,13 KiB/s, peak 1,00 MiB/s\n" +
+		"  Received: 1,50 KiB, average 17,00 B/s, peak 512,00 B/s\n"
+	if b.String() != want {
+		t.Errorf("exit summary:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// quietPeriod accumulates the samples suppressed by -suppress-zero.
+type quietPeriod struct {
+	samples int     // Number of suppressed samples
+	seconds float64 // Time covered by the suppressed samples
+	sent    uint64  // Bytes sent during the period
+	recv    uint64  // Bytes received during the period
+}
+
+// quietDetails is the payload of a traffic-resumed event.
+type quietDetails struct {
+	QuietSeconds      float64 `json:"quietSeconds"`
+	SuppressedSamples int     `json:"suppressedSamples"`
+	SentBytes         uint64  `json:"sentBytes"`
+	RecvBytes         uint64  `json:"recvBytes"`
+}
+
+// suppressSample reports whether a sample whose byte deltas cover interval seconds is idle and
+// must not be emitted. When traffic resumes after a quiet period, a traffic-resumed event is
+// emitted first.
+func (nm *NetworkMonitor) suppressSample(sentBytes, recvBytes uint64, interval float64, now time.Time) bool {
+	if sentBytes <= nm.zeroEpsilon && recvBytes <= nm.zeroEpsilon {
+		nm.quiet.samples++
+		nm.quiet.seconds += interval
+		nm.quiet.sent += sentBytes
+		nm.quiet.recv += recvBytes
+		return true
+	}
+
+	if q := nm.quiet; q.samples > 0 {
+		quiet := time.Duration(q.seconds * float64(time.Second)).Round(time.Millisecond)
+		nm.emitEvent(Event{
+			Type:      eventTrafficResumed,
+			Timestamp: Timestamp(now),
+			Interface: nm.interfaceName,
+			Message: fmt.Sprintf("traffic resumed after %v quiet (%d samples suppressed, %d bytes sent, %d bytes received)",
+				quiet, q.samples, q.sent, q.recv),
+			Details: quietDetails{
+				QuietSeconds:      quiet.Seconds(),
+				SuppressedSamples: q.samples,
+				SentBytes:         q.sent,
+				RecvBytes:         q.recv,
+			},
+		})
+		nm.quiet = quietPeriod{}
+	}
+	return false
+}
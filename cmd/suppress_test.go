@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSuppressZero(t *testing.T) {
+	src := newFakeSource()
+	src.set("eth0", 0, 0)
+	nm, buf := newTestMonitor(t, "eth0", src, "-f", "json", "-suppress-zero", "-zero-epsilon", "100")
+	if err := nm.startSampling(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+
+	// Bytes sent each second; only the first and last tick move more than -zero-epsilon.
+	var sent uint64
+	for i, d := range []uint64{500, 0, 0, 50, 0, 700} {
+		sent += d
+		src.set("eth0", sent, 0)
+		tick(t, nm, start.Add(time.Duration(i+1)*time.Second))
+	}
+	if total, _ := nm.sampler.Totals(); total != 1250 {
+		t.Errorf("total sent = %d, want 1250: idle ticks still count", total)
+	}
+
+	type record struct {
+		Type      string `json:"type"`
+		SentSpeed struct {
+			Value float64 `json:"value"`
+		} `json:"sentSpeed"`
+		Details quietDetails `json:"details"`
+	}
+	var got []record
+	sc := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for sc.Scan() {
+		var r record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", sc.Text(), err)
+		}
+		if r.Type == "" || r.Type == eventTrafficResumed {
+			got = append(got, r)
+		}
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d samples and traffic-resumed events, want 3: %s", len(got), buf)
+	}
+	if got[0].Type != "" || got[0].SentSpeed.Value != 500 || got[2].Type != "" || got[2].SentSpeed.Value != 700 {
+		t.Errorf("samples = %+v and %+v, want 500 and 700 B/s sent", got[0], got[2])
+	}
+	// The quiet period is summed up before the sample that ends it.
+	want := quietDetails{QuietSeconds: 4, SuppressedSamples: 4, SentBytes: 50}
+	if got[1].Type != eventTrafficResumed || got[1].Details != want {
+		t.Errorf("record 1 = %s %+v, want %s %+v", got[1].Type, got[1].Details, eventTrafficResumed, want)
+	}
+	if nm.quiet != (quietPeriod{}) {
+		t.Errorf("quiet period %+v left over after traffic resumed", nm.quiet)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+
+	"github.com/ShadowZagrosDev/Zag-NetStats/pkg/netstats"
+)
+
+// Profiles of the synthetic counter source.
+const (
+	profileConstant = "constant" // Steady rates
+	profileWave     = "wave"     // Sine wave between zero and the configured rates
+	profileBurst    = "burst"    // Full rate for the first quarter of each period, idle otherwise
+	profileWalk     = "walk"     // Seeded random walk around the configured rates
+	profileScript   = "script"   // Per-step rates read from a JSON file, repeated when exhausted
+)
+
+// bytesPerPacket is the packet size used to derive synthetic packet counters.
+const bytesPerPacket = 1000
+
+// scriptStep is one entry of a synthetic script file. Rates are in bytes per second; a step
+// with Reset set zeroes the counters, as a driver reload or counter wrap would.
+type scriptStep struct {
+	Sent  float64 `json:"sent"`
+	Recv  float64 `json:"recv"`
+	Reset bool    `json:"reset"`
+}
+
+// syntheticSource produces deterministic artificial counters. Every read advances the source by
+// one step of the sampling interval, so a given configuration always yields the same sequence.
+type syntheticSource struct {
+	profile  string
+	sentRate float64      // Base send rate in bytes per second
+	recvRate float64      // Base receive rate in bytes per second
+	period   int          // Steps per wave or burst cycle
+	interval float64      // Seconds represented by one step
+	script   []scriptStep // Steps of the script profile
+	rng      *rand.Rand   // Random source of the walk profile
+	walk     [2]float64   // Current walk rates (sent, recv)
+	step     int
+	stat     net.IOCountersStat
+}
+
+// newSyntheticSource creates a synthetic source from options such as
+// "profile=wave,sent=131072,recv=1048576,period=60,seed=1,file=steps.json".
+func newSyntheticSource(spec string, interval time.Duration) (*syntheticSource, error) {
+	opts, err := parseSourceOptions(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &syntheticSource{
+		profile:  profileConstant,
+		sentRate: 128 * netstats.KB,
+		recvRate: netstats.MB,
+		period:   60,
+		interval: interval.Seconds(),
+	}
+	seed := int64(1)
+
+	for key, value := range opts {
+		switch key {
+		case "profile":
+			s.profile = value
+		case "sent", "recv":
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil || rate < 0 {
+				return nil, fmt.Errorf("invalid synthetic %s rate %q", key, value)
+			}
+			if key == "sent" {
+				s.sentRate = rate
+			} else {
+				s.recvRate = rate
+			}
+		case "period":
+			s.period, err = strconv.Atoi(value)
+			if err != nil || s.period <= 0 {
+				return nil, fmt.Errorf("invalid synthetic period %q", value)
+			}
+		case "seed":
+			seed, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid synthetic seed %q", value)
+			}
+		case "file":
+			if s.script, err = readScript(value); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown synthetic option %q", key)
+		}
+	}
+
+	switch s.profile {
+	case profileConstant, profileWave, profileBurst:
+	case profileWalk:
+		s.rng = rand.New(rand.NewSource(seed))
+		s.walk = [2]float64{s.sentRate, s.recvRate}
+	case profileScript:
+		if len(s.script) == 0 {
+			return nil, fmt.Errorf("synthetic profile %q requires a non-empty file=<path>", profileScript)
+		}
+	default:
+		return nil, fmt.Errorf("unknown synthetic profile %q (supported: constant, wave, burst, walk, script)", s.profile)
+	}
+
+	return s, nil
+}
+
+// readScript loads the steps of a synthetic script file.
+func readScript(path string) ([]scriptStep, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var steps []scriptStep
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("invalid synthetic script %s: %v", path, err)
+	}
+	return steps, nil
+}
+
+func (s *syntheticSource) counters(iface string) (net.IOCountersStat, error) {
+	sent, recv, reset := s.rates()
+	s.step++
+
+	if reset {
+		s.stat = net.IOCountersStat{}
+	}
+	s.stat.Name = iface
+	s.advance(&s.stat.BytesSent, &s.stat.PacketsSent, sent)
+	s.advance(&s.stat.BytesRecv, &s.stat.PacketsRecv, recv)
+
+	return s.stat, nil
+}
+
+// span returns the sampling interval, the time every step represents.
+func (s *syntheticSource) span() time.Duration {
+	return time.Duration(s.interval * float64(time.Second))
+}
+
+// rates returns the send and receive rates of the current step and whether the counters
+// reset before it.
+func (s *syntheticSource) rates() (sent, recv float64, reset bool) {
+	switch s.profile {
+	case profileWave:
+		f := 0.5 + 0.5*math.Sin(2*math.Pi*float64(s.step)/float64(s.period))
+		return s.sentRate * f, s.recvRate * f, false
+	case profileBurst:
+		if s.step%s.period < max(s.period/4, 1) {
+			return s.sentRate, s.recvRate, false
+		}
+		return 0, 0, false
+	case profileWalk:
+		base := [2]float64{s.sentRate, s.recvRate}
+		for i := range s.walk {
+			s.walk[i] += base[i] * (s.rng.Float64() - 0.5) * 0.2
+			s.walk[i] = min(max(s.walk[i], 0), 2*base[i])
+		}
+		return s.walk[0], s.walk[1], false
+	case profileScript:
+		st := s.script[s.step%len(s.script)]
+		return st.Sent, st.Recv, st.Reset
+	default:
+		return s.sentRate, s.recvRate, false
+	}
+}
+
+// advance adds one step of traffic at rate bytes per second to the byte and packet counters.
+func (s *syntheticSource) advance(bytes, packets *uint64, rate float64) {
+	n := uint64(rate * s.interval)
+	*bytes += n
+	*packets += (n + bytesPerPacket - 1) / bytesPerPacket
+}
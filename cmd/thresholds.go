@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/olekukonko/tablewriter"
+)
+
+// colorBand holds the rates, in bytes per second, above which speeds of one direction are
+// colored: yellow above warn and red above crit. Zero leaves a level unset.
+type colorBand struct {
+	warn float64
+	crit float64
+}
+
+// speedThresholds are the color bands of each direction, from -color-bands-sent and
+// -color-bands-recv or else -warn-speed and -crit-speed for both.
+type speedThresholds struct {
+	sent colorBand
+	recv colorBand
+}
+
+// newSpeedThresholds returns the color bands of cfg, or nil when none are set. When the bands
+// of only one direction are given, those of the other are scaled by the up and down rates of
+// plan, or the same without one.
+func newSpeedThresholds(cfg monitorConfig, plan *ispPlan) *speedThresholds {
+	sent := colorBand{float64(cfg.ColorBandsSent[0]), float64(cfg.ColorBandsSent[1])}
+	recv := colorBand{float64(cfg.ColorBandsRecv[0]), float64(cfg.ColorBandsRecv[1])}
+	upPerDown := 1.0
+	if plan != nil && plan.Down > 0 && plan.Up > 0 {
+		upPerDown = plan.Up / plan.Down
+	}
+	switch {
+	case cfg.ColorBandsSent.set() && cfg.ColorBandsRecv.set():
+	case cfg.ColorBandsRecv.set():
+		sent = recv.scaled(upPerDown)
+	case cfg.ColorBandsSent.set():
+		recv = sent.scaled(1 / upPerDown)
+	case cfg.WarnSpeed > 0 || cfg.CritSpeed > 0:
+		sent = colorBand{float64(cfg.WarnSpeed), float64(cfg.CritSpeed)}
+		recv = sent
+	default:
+		return nil
+	}
+	return &speedThresholds{sent: sent, recv: recv}
+}
+
+// scaled returns the band with both levels multiplied by f.
+func (b colorBand) scaled(f float64) colorBand {
+	return colorBand{b.warn * f, b.crit * f}
+}
+
+// colors returns the colors of a table speed cell showing bytesPerSecond: red above the
+// critical level, yellow above the warning level and none below.
+func (b colorBand) colors(bytesPerSecond float64) tablewriter.Colors {
+	switch {
+	case b.crit > 0 && bytesPerSecond > b.crit:
+		return tablewriter.Colors{tablewriter.FgRedColor, tablewriter.Bold}
+	case b.warn > 0 && bytesPerSecond > b.warn:
+		return tablewriter.Colors{tablewriter.FgYellowColor}
+	}
+	return tablewriter.Colors{}
+}
+
+// style returns the dashboard style of a speed showing bytesPerSecond: red or yellow as in
+// tables, and normal otherwise.
+func (b colorBand) style(normal tcell.Style, bytesPerSecond float64) tcell.Style {
+	switch {
+	case b.crit > 0 && bytesPerSecond > b.crit:
+		return normal.Foreground(tcell.ColorRed).Bold(true)
+	case b.warn > 0 && bytesPerSecond > b.warn:
+		return normal.Foreground(tcell.ColorYellow)
+	}
+	return normal
+}
+
+// colorDisabled reports whether the user turned colors off with -no-color or the NO_COLOR
+// convention (https://no-color.org).
+func colorDisabled(cfg monitorConfig) bool {
+	return cfg.NoColor || os.Getenv("NO_COLOR") != ""
+}
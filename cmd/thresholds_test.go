@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestSpeedThresholdsPerDirection(t *testing.T) {
+	mb := float64(1 << 20)
+	tests := []struct {
+		name       string
+		args       []string
+		sent, recv colorBand
+	}{
+		{"both", []string{"--color-bands-recv", "10MB/s,50MB/s", "--color-bands-sent", "500KB/s,2MB/s"},
+			colorBand{500 << 10, 2 * mb}, colorBand{10 * mb, 50 * mb}},
+		{"recv scaled by the plan", []string{"--color-bands-recv", "10MB/s,50MB/s", "--plan", "down=400Mbit,up=20Mbit"},
+			colorBand{mb / 2, 2.5 * mb}, colorBand{10 * mb, 50 * mb}},
+		{"sent scaled by the plan", []string{"--color-bands-sent", "1MB/s,2MB/s", "--plan", "down=100Mbit,up=10Mbit"},
+			colorBand{mb, 2 * mb}, colorBand{10 * mb, 20 * mb}},
+		{"recv without a plan", []string{"--color-bands-recv", "10MB/s,50MB/s"},
+			colorBand{10 * mb, 50 * mb}, colorBand{10 * mb, 50 * mb}},
+		{"warn and crit for both", []string{"--warn-speed", "1MB/s", "--crit-speed", "2MB/s"},
+			colorBand{mb, 2 * mb}, colorBand{mb, 2 * mb}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg monitorConfig
+			fs := newMonitorFlagSet("test", &cfg)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+			plan, _ := parsePlan(cfg.Plan)
+			th := newSpeedThresholds(cfg, plan)
+			if th == nil || !near(th.sent, tt.sent) || !near(th.recv, tt.recv) {
+				t.Errorf("bands = %+v, want sent %+v recv %+v", th, tt.sent, tt.recv)
+			}
+		})
+	}
+	if th := newSpeedThresholds(monitorConfig{}, nil); th != nil {
+		t.Errorf("bands without flags = %+v, want none", th)
+	}
+}
+
+// near reports whether the levels of two bands agree to within a millionth.
+func near(a, b colorBand) bool {
+	close := func(x, y float64) bool { return x-y < 1e-6*y && y-x < 1e-6*y }
+	return close(a.warn, b.warn) && close(a.crit, b.crit)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeFormatPresets maps the named --time-format presets to Go layouts. The unix presets
+// have no layout and render as integer counts instead.
+var timeFormatPresets = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"kitchen":     time.Kitchen,
+	"unix":        "",
+	"unixmilli":   "",
+	"unixms":      "",
+}
+
+// timeFormatHelp documents the accepted --time-format values.
+const timeFormatHelp = "Timestamp format: rfc3339, rfc3339nano, unix, unixmilli (or unixms), kitchen or a Go layout such as \"2006-01-02 15:04:05\""
+
+// timeFormat renders wall-clock timestamps in every output.
+type timeFormat struct {
+	name   string // Preset name, or empty for a raw layout
+	layout string // Go layout; empty for the unix presets
+	utc    bool   // Whether times are converted to UTC before formatting
+}
+
+// timestampFormat is the format applied to all emitted timestamps, set from --time-format at startup.
+var timestampFormat = timeFormat{name: "rfc3339", layout: time.RFC3339}
+
+// parseTimeFormat interprets a --time-format value. Raw layouts are validated by formatting a
+// sentinel time and parsing it back, which rejects strings without any time elements.
+func parseTimeFormat(s string) (timeFormat, error) {
+	if layout, ok := timeFormatPresets[strings.ToLower(s)]; ok {
+		return timeFormat{name: strings.ToLower(s), layout: layout}, nil
+	}
+
+	sentinel := time.Date(2001, 2, 3, 4, 5, 6, 7008009, time.UTC)
+	formatted := sentinel.Format(s)
+	if formatted == s {
+		return timeFormat{}, fmt.Errorf("invalid time format %q: not a preset and contains no layout elements", s)
+	}
+	if _, err := time.Parse(s, formatted); err != nil {
+		return timeFormat{}, fmt.Errorf("invalid time format %q: %v", s, err)
+	}
+
+	return timeFormat{layout: s}, nil
+}
+
+// numeric reports whether timestamps render as integers rather than strings.
+func (tf timeFormat) numeric() bool {
+	return tf.layout == ""
+}
+
+// format renders t according to the time format.
+func (tf timeFormat) format(t time.Time) string {
+	if tf.utc {
+		t = t.UTC()
+	}
+	switch tf.name {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixmilli", "unixms":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	default:
+		return t.Format(tf.layout)
+	}
+}
+
+// Timestamp is a wall-clock instant rendered with the configured --time-format.
+type Timestamp time.Time
+
+// String renders the timestamp for human-readable output.
+func (t Timestamp) String() string {
+	return timestampFormat.format(time.Time(t))
+}
+
+// MarshalJSON renders the timestamp as a JSON string, or as a number for the unix presets.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	s := timestampFormat.format(time.Time(t))
+	if timestampFormat.numeric() {
+		return []byte(s), nil
+	}
+	return []byte(strconv.Quote(s)), nil
+}
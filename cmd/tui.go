@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// tuiHistory is how many samples of each interface the sparklines show.
+const tuiHistory = 60
+
+// tuiLogLines is how many log lines are kept while the dashboard owns the terminal, to be
+// printed once it is closed.
+const tuiLogLines = 20
+
+// sparkRunes are the bar heights of a sparkline, lowest first.
+var sparkRunes = []rune("▁▂▃▄▅▆▇█")
+
+// tuiPanel is the state of one interface on the dashboard.
+type tuiPanel struct {
+	latest     NetStats
+	sent, recv []float64 // Recent rates in bytes per second, oldest first
+}
+
+// tuiDashboard is the full-screen dashboard of -f tui. It is a sample sink: the sampling loop
+// hands it each tick without waiting, and a goroutine of its own draws and reads keys, so a
+// slow terminal never delays sampling.
+type tuiDashboard struct {
+	screen    tcell.Screen
+	ticks     chan tuiTick     // Latest tick not yet drawn
+	commands  chan string      // Control commands for the sampling loop, such as reset
+	interrupt chan<- os.Signal // Stops the monitor, as SIGINT does
+	precision int
+	comma     bool
+	reset     bool             // Whether r resets the totals, which it does when monitoring one interface
+	bands     *speedThresholds // Levels at which speeds are colored, nil for none
+	panels    map[string]*tuiPanel
+	order     []string // Interfaces in order of appearance
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu     sync.Mutex
+	logs   []string // Log lines written while the dashboard is shown
+	closed bool     // Whether the terminal was given back, so lines go to stderr again
+}
+
+// tuiTick is the samples of one tick waiting to be drawn, with the record of their delivery.
+type tuiTick struct {
+	samples []NetStats
+	d       *sinkStats
+}
+
+// errTUISuperseded is the reason of ticks replaced by the next one before they were drawn.
+var errTUISuperseded = errors.New("the next tick arrived before it was drawn")
+
+// startTUI takes over the terminal and starts drawing the samples nm sends to it.
+func startTUI(nm *NetworkMonitor) (*tuiDashboard, error) {
+	if !isTerminal(os.Stdout) || !isTerminal(os.Stdin) {
+		return nil, errors.New("-f tui needs a terminal; use -f table or -f json when output is redirected")
+	}
+	screen, err := tcell.NewScreen()
+	if err == nil {
+		err = screen.Init()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot start the dashboard: %v", err)
+	}
+	t := &tuiDashboard{
+		screen:    screen,
+		ticks:     make(chan tuiTick, 1),
+		commands:  make(chan string, 1),
+		interrupt: nm.interrupt,
+		precision: nm.precision,
+		comma:     nm.decimalComma,
+		reset:     len(nm.multi) == 0 && !nm.allInterfaces,
+		bands:     nm.thresholds,
+		panels:    make(map[string]*tuiPanel),
+		done:      make(chan struct{}),
+	}
+	log.SetOutput(t)
+	setCrashCleanup(t.restore)
+	go t.run()
+	return t, nil
+}
+
+// Write captures a log line while the dashboard is shown. The latest is drawn in the status
+// line.
+func (t *tuiDashboard) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return os.Stderr.Write(p)
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if len(t.logs) == tuiLogLines {
+			t.logs = t.logs[1:]
+		}
+		t.logs = append(t.logs, line)
+	}
+	return len(p), nil
+}
+
+func (t *tuiDashboard) send(samples []NetStats, d *sinkStats) {
+	select {
+	case t.ticks <- tuiTick{samples, d}:
+	default:
+		// The previous tick was not drawn yet; replace it with this one.
+		select {
+		case old := <-t.ticks:
+			old.d.drop(errTUISuperseded)
+		default:
+		}
+		t.ticks <- tuiTick{samples, d}
+	}
+}
+
+// run draws ticks and handles keys and resizes until the screen is finalized.
+func (t *tuiDashboard) run() {
+	defer handlePanic()
+	events := make(chan tcell.Event)
+	go func() {
+		defer handlePanic()
+		for {
+			ev := t.screen.PollEvent()
+			if ev == nil {
+				close(events)
+				return
+			}
+			events <- ev
+		}
+	}()
+	t.draw()
+	for {
+		select {
+		case tick := <-t.ticks:
+			t.add(tick.samples)
+			t.draw()
+			tick.d.delivered()
+		case ev, ok := <-events:
+			if !ok {
+				close(t.done)
+				return
+			}
+			switch ev := ev.(type) {
+			case *tcell.EventResize:
+				t.screen.Sync()
+				t.draw()
+			case *tcell.EventKey:
+				t.key(ev)
+			}
+		}
+	}
+}
+
+// key handles a key press: q, Esc or Ctrl-C quit and r resets the totals where supported.
+func (t *tuiDashboard) key(ev *tcell.EventKey) {
+	switch {
+	case ev.Key() == tcell.KeyEscape || ev.Key() == tcell.KeyCtrlC || ev.Rune() == 'q' || ev.Rune() == 'Q':
+		select {
+		case t.interrupt <- os.Interrupt:
+		default:
+		}
+	case t.reset && (ev.Rune() == 'r' || ev.Rune() == 'R'):
+		select {
+		case t.commands <- "reset":
+		default:
+		}
+	}
+}
+
+// add records the samples of a tick in the panels.
+func (t *tuiDashboard) add(samples []NetStats) {
+	for _, s := range samples {
+		p := t.panels[s.Interface]
+		if p == nil {
+			p = &tuiPanel{}
+			t.panels[s.Interface] = p
+			t.order = append(t.order, s.Interface)
+		}
+		p.latest = s
+		p.sent = appendHistory(p.sent, s.raw.sentBps)
+		p.recv = appendHistory(p.recv, s.raw.recvBps)
+	}
+}
+
+// appendHistory appends v to the rates in h, keeping the last tuiHistory.
+func appendHistory(h []float64, v float64) []float64 {
+	if len(h) == tuiHistory {
+		h = append(h[:0], h[1:]...)
+	}
+	return append(h, v)
+}
+
+// draw redraws the whole screen: a panel of four lines per interface, the totals and a status
+// line with the keys and the latest log line.
+func (t *tuiDashboard) draw() {
+	s := t.screen
+	s.Clear()
+	width, height := s.Size()
+	bold := tcell.StyleDefault.Bold(true)
+	sentStyle := tcell.StyleDefault.Foreground(tcell.ColorGreen)
+	recvStyle := tcell.StyleDefault.Foreground(tcell.ColorBlue)
+
+	y := 0
+	if len(t.order) == 0 {
+		t.text(0, y, width, bold, "Waiting for the first sample…")
+	}
+	for _, name := range t.order {
+		if y+4 > height-2 {
+			break
+		}
+		p := t.panels[name]
+		t.text(0, y, width, bold, name)
+		sentSpeed, recvSpeed := sentStyle.Bold(true), recvStyle.Bold(true)
+		if t.bands != nil {
+			sentSpeed = t.bands.sent.style(sentSpeed, p.latest.raw.sentBps)
+			recvSpeed = t.bands.recv.style(recvSpeed, p.latest.raw.recvBps)
+		}
+		t.text(2, y+1, width, sentSpeed, "↑ "+formatQuantity(p.latest.SentSpeed.Value, p.latest.SentSpeed.Unit, t.precision, t.comma))
+		t.text(width/2, y+1, width, recvSpeed, "↓ "+formatQuantity(p.latest.RecvSpeed.Value, p.latest.RecvSpeed.Unit, t.precision, t.comma))
+		t.text(2, y+2, width, sentStyle, sparkline(p.sent, width-2))
+		t.text(2, y+3, width, recvStyle, sparkline(p.recv, width-2))
+		y += 5
+	}
+
+	var totals []string
+	for _, name := range t.order {
+		l := t.panels[name].latest
+		totals = append(totals, fmt.Sprintf("%s ↑ %s ↓ %s", name,
+			formatQuantity(l.TotalSent.Value, l.TotalSent.Unit, t.precision, t.comma),
+			formatQuantity(l.TotalRecv.Value, l.TotalRecv.Unit, t.precision, t.comma)))
+	}
+	t.text(0, height-2, width, bold, strings.Join(totals, "   "))
+	status := "q quit"
+	if t.reset {
+		status += "  r reset totals"
+	}
+	t.mu.Lock()
+	if len(t.logs) > 0 {
+		status += "  │ " + t.logs[len(t.logs)-1]
+	}
+	t.mu.Unlock()
+	t.text(0, height-1, width, tcell.StyleDefault.Reverse(true), status+strings.Repeat(" ", max(0, width-len([]rune(status)))))
+	s.Show()
+}
+
+// text draws str from column x of row y, cut off at the screen width.
+func (t *tuiDashboard) text(x, y, width int, style tcell.Style, str string) {
+	for _, r := range str {
+		if x >= width {
+			return
+		}
+		t.screen.SetContent(x, y, r, nil, style)
+		x++
+	}
+}
+
+// sparkline renders the last width rates of h as bars scaled to their maximum.
+func sparkline(h []float64, width int) string {
+	if len(h) > width {
+		h = h[len(h)-width:]
+	}
+	var peak float64
+	for _, v := range h {
+		peak = max(peak, v)
+	}
+	var b strings.Builder
+	for _, v := range h {
+		i := 0
+		if peak > 0 {
+			i = min(len(sparkRunes)-1, int(v/peak*float64(len(sparkRunes)-1)+0.5))
+		}
+		b.WriteRune(sparkRunes[i])
+	}
+	return b.String()
+}
+
+// restore gives the terminal back and sends log output to stderr again.
+func (t *tuiDashboard) restore() {
+	t.screen.Fini()
+	log.SetOutput(os.Stderr)
+}
+
+// close restores the terminal and prints the log lines written while the dashboard was shown.
+// Later lines go straight to stderr. Only the first call has an effect.
+func (t *tuiDashboard) close() {
+	t.closeOnce.Do(func() {
+		setCrashCleanup(nil)
+		t.restore()
+		<-t.done
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.closed = true
+		var b bytes.Buffer
+		for _, line := range t.logs {
+			b.WriteString(line + "\n")
+		}
+		io.Copy(os.Stderr, &b)
+	})
+}
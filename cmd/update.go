@@ -0,0 +1,301 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// version is the release tag of this build, set at build time with
+// -ldflags "-X main.version=v1.2.3". Development builds report "dev".
+var version = "dev"
+
+// releasesURL is the GitHub API endpoint describing the latest release. Tests point it at a
+// local server.
+var releasesURL = "https://api.github.com/repos/ShadowZagrosDev/Zag-NetStats/releases/latest"
+
+// release is the subset of the GitHub release API response used by self-update.
+type release struct {
+	TagName string         `json:"tag_name"`
+	Body    string         `json:"body"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+// releaseAsset is a downloadable file attached to a release.
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// updateClient performs all self-update HTTP requests.
+var updateClient = &http.Client{Timeout: 2 * time.Minute}
+
+// renameFile renames files while the executable is replaced. Tests swap it to make a rename fail.
+var renameFile = os.Rename
+
+// runSelfUpdate implements the self-update subcommand. It replaces the running executable with
+// the binary from the latest release for the current OS/arch after verifying its SHA-256 checksum.
+// With --check-only it only reports whether an update exists, exiting 0 if one does and 1 if not.
+func runSelfUpdate(args []string) error {
+	fs := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	checkOnly := fs.Bool("check-only", false, "Only report whether an update exists (exit 0 if so, 1 if up to date)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rel, err := fetchLatestRelease()
+	if err != nil {
+		return fmt.Errorf("error checking for updates: %v", err)
+	}
+
+	if compareVersions(rel.TagName, version) <= 0 {
+		fmt.Printf("%s is up to date (%s)\n", progName(), version)
+		if *checkOnly {
+			return exitStatus(exitFailure)
+		}
+		return nil
+	}
+
+	fmt.Printf("Update available: %s -> %s\n", version, rel.TagName)
+	if *checkOnly {
+		return nil
+	}
+
+	assetName := fmt.Sprintf("zag-netStats-%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+	archive, err := downloadVerified(rel, assetName)
+	if err != nil {
+		return err
+	}
+
+	binary, err := extractBinary(archive)
+	if err != nil {
+		return fmt.Errorf("error extracting %s: %v", assetName, err)
+	}
+
+	backup, err := replaceExecutable(binary)
+	if err != nil {
+		return fmt.Errorf("error replacing executable: %v", err)
+	}
+
+	fmt.Printf("Updated to %s (previous version saved as %s)\n", rel.TagName, backup)
+	if notes := strings.TrimSpace(rel.Body); notes != "" {
+		fmt.Printf("\nChanges in %s:\n%s\n", rel.TagName, notes)
+	}
+	return nil
+}
+
+// fetchLatestRelease retrieves the description of the latest published release.
+func fetchLatestRelease() (release, error) {
+	req, err := http.NewRequest(http.MethodGet, releasesURL, nil)
+	if err != nil {
+		return release{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "zag-netStats/"+version)
+
+	resp, err := updateClient.Do(req)
+	if err != nil {
+		return release{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return release{}, fmt.Errorf("unexpected response from %s: %s", releasesURL, resp.Status)
+	}
+
+	var rel release
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return release{}, fmt.Errorf("error decoding release: %v", err)
+	}
+	return rel, nil
+}
+
+// findAsset returns the release asset with the given name.
+func findAsset(rel release, name string) (releaseAsset, bool) {
+	for _, a := range rel.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return releaseAsset{}, false
+}
+
+// download fetches the contents of a URL.
+func download(url string) ([]byte, error) {
+	resp, err := updateClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// downloadVerified downloads the named asset and checks it against the SHA-256 checksum
+// published alongside it as <name>.sha256. Assets without a checksum are rejected.
+func downloadVerified(rel release, name string) ([]byte, error) {
+	asset, ok := findAsset(rel, name)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no build for %s/%s", rel.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+	sumAsset, ok := findAsset(rel, name+".sha256")
+	if !ok {
+		return nil, fmt.Errorf("release %s publishes no checksum for %s, refusing to update", rel.TagName, name)
+	}
+
+	sumData, err := download(sumAsset.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading checksum: %v", err)
+	}
+	fields := strings.Fields(string(sumData))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty checksum file %s", sumAsset.Name)
+	}
+	want, err := hex.DecodeString(fields[0])
+	if err != nil || len(want) != sha256.Size {
+		return nil, fmt.Errorf("malformed checksum file %s", sumAsset.Name)
+	}
+
+	data, err := download(asset.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading %s: %v", name, err)
+	}
+	if got := sha256.Sum256(data); !bytes.Equal(got[:], want) {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %x, got %x", name, want, got)
+	}
+	return data, nil
+}
+
+// extractBinary returns the executable contained in a release archive.
+func extractBinary(archive []byte) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range zr.File {
+		base := strings.TrimSuffix(filepath.Base(f.Name), ".exe")
+		if f.FileInfo().IsDir() || base != "zag-netStats" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+
+	return nil, errors.New("archive contains no zag-netStats executable")
+}
+
+// replaceExecutable atomically swaps the running executable for binary, keeping the previous
+// executable next to it with an ".old" suffix. It returns the path of that backup.
+func replaceExecutable(binary []byte) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return "", err
+	}
+	return replaceFile(exe, binary)
+}
+
+// replaceFile swaps the executable at exe for binary like replaceExecutable. When the new
+// binary cannot be moved into place, the backup is renamed back, so exe is never left missing.
+func replaceFile(exe string, binary []byte) (string, error) {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return "", err
+	}
+
+	// Write next to the executable so the final rename stays on the same filesystem.
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".zag-netStats-update-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0o111); err != nil {
+		return "", err
+	}
+
+	// Renaming the running executable is permitted on every supported OS, unlike overwriting it.
+	backup := exe + ".old"
+	os.Remove(backup)
+	if err := renameFile(exe, backup); err != nil {
+		return "", err
+	}
+	if err := renameFile(tmp.Name(), exe); err != nil {
+		renameFile(backup, exe)
+		return "", err
+	}
+	return backup, nil
+}
+
+// compareVersions compares two release tags of the form v1.2.3, returning -1, 0 or 1.
+// Tags that do not parse (such as "dev") sort before every release.
+func compareVersions(a, b string) int {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+	switch {
+	case !okA && !okB:
+		return 0
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseVersion splits a v1.2.3 tag into its numeric components, ignoring any pre-release suffix.
+func parseVersion(tag string) ([3]int, bool) {
+	var parts [3]int
+	core, _, _ := strings.Cut(strings.TrimPrefix(tag, "v"), "-")
+	fields := strings.Split(core, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// releaseArchive returns a release archive holding an executable with the given contents.
+func releaseArchive(t *testing.T, binary string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	w, err := zw.Create("zag-netStats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(binary))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// serveRelease starts a server publishing a release with the archive, and with the checksum
+// file unless sum is empty, and points releasesURL at it.
+func serveRelease(t *testing.T, archive []byte, sum string) {
+	t.Helper()
+	name := "zag-netStats-" + runtime.GOOS + "-" + runtime.GOARCH + ".zip"
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	rel := release{TagName: "v9.0.0", Assets: []releaseAsset{{Name: name, URL: srv.URL + "/" + name}}}
+	if sum != "" {
+		rel.Assets = append(rel.Assets, releaseAsset{Name: name + ".sha256", URL: srv.URL + "/" + name + ".sha256"})
+	}
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, r *http.Request) { json.NewEncoder(w).Encode(rel) })
+	mux.HandleFunc("/"+name, func(w http.ResponseWriter, r *http.Request) { w.Write(archive) })
+	mux.HandleFunc("/"+name+".sha256", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(sum + "  " + name + "\n")) })
+
+	url := releasesURL
+	releasesURL = srv.URL + "/latest"
+	t.Cleanup(func() { releasesURL = url })
+}
+
+func TestSelfUpdateVerifiesChecksum(t *testing.T) {
+	archive := releaseArchive(t, "new binary")
+	sum := sha256.Sum256(archive)
+	other := sha256.Sum256([]byte("tampered"))
+	tests := []struct {
+		name    string
+		sum     string
+		wantErr string
+	}{
+		{"matching checksum", hex.EncodeToString(sum[:]), ""},
+		{"checksum mismatch", hex.EncodeToString(other[:]), "checksum mismatch"},
+		{"no checksum", "", "publishes no checksum"},
+		{"malformed checksum", "not-hex", "malformed checksum"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serveRelease(t, archive, tt.sum)
+			rel, err := fetchLatestRelease()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := downloadVerified(rel, "zag-netStats-"+runtime.GOOS+"-"+runtime.GOARCH+".zip")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			binary, err := extractBinary(data)
+			if err != nil || string(binary) != "new binary" {
+				t.Errorf("extracted %q, %v; want the new binary", binary, err)
+			}
+		})
+	}
+}
+
+func TestReplaceFileKeepsBackup(t *testing.T) {
+	exe := filepath.Join(t.TempDir(), "zag-netStats")
+	if err := os.WriteFile(exe, []byte("old binary"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := replaceFile(exe, []byte("new binary"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup != exe+".old" {
+		t.Errorf("backup = %s, want %s.old", backup, exe)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "new binary" {
+		t.Errorf("executable holds %q, want the new binary", data)
+	}
+	if data, _ := os.ReadFile(backup); string(data) != "old binary" {
+		t.Errorf("backup holds %q, want the old binary", data)
+	}
+	if info, err := os.Stat(exe); err != nil || info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("new executable is not executable: %v %v", info.Mode(), err)
+	}
+}
+
+func TestReplaceFileRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "zag-netStats")
+	if err := os.WriteFile(exe, []byte("old binary"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	// Moving the new binary into place fails after the old one was moved to the backup.
+	renameFile = func(from, to string) error {
+		if strings.HasPrefix(filepath.Base(from), ".zag-netStats-update-") {
+			return errors.New("disk on fire")
+		}
+		return os.Rename(from, to)
+	}
+	t.Cleanup(func() { renameFile = os.Rename })
+
+	if _, err := replaceFile(exe, []byte("new binary")); err == nil {
+		t.Fatal("replacing succeeded despite the failed rename")
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "old binary" {
+		t.Errorf("executable holds %q after the rollback, want the old binary", data)
+	}
+	// Neither the backup nor the temporary file is left behind.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("directory holds %q, want only the executable", names)
+	}
+}
@@ -0,0 +1,163 @@
+// Package netstats measures the throughput of a network interface. It computes per-interval
+// speeds and running totals from the kernel's cumulative I/O counters and returns them as
+// values, leaving presentation to the caller.
+//
+// A minimal program:
+//
+//	m, err := netstats.NewMonitor("eth0", netstats.DefaultOptions())
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	err = m.Run(ctx, func(s netstats.Stats) error {
+//		fmt.Println(s.RecvSpeed.Value, s.RecvSpeed.Unit)
+//		return nil
+//	})
+package netstats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// ErrInterfaceNotFound is returned when no interface matches the requested name.
+var ErrInterfaceNotFound = errors.New("interface not found")
+
+// Stats holds the figures of one sample of a network interface.
+type Stats struct {
+	Interface  string `json:"interface"`
+	SentSpeed  Speed  `json:"sentSpeed"`
+	RecvSpeed  Speed  `json:"recvSpeed"`
+	TotalSent  Usage  `json:"totalSent"`
+	TotalRecv  Usage  `json:"totalRecv"`
+	TotalUsage Usage  `json:"totalUsage"`
+}
+
+// CounterFunc returns the cumulative I/O counters of the named interface.
+type CounterFunc func(iface string) (net.IOCountersStat, error)
+
+// Options configures a Monitor.
+type Options struct {
+	Interval  time.Duration // Time between samples, at least MinInterval
+	Precision int           // Decimal places of rounded values
+	Counters  CounterFunc   // Source of the counters, nil for the kernel (ReadCounters)
+	Units     Units         // Units of the speeds and totals, binary bytes by default
+
+	// Elapsed returns the time covered by a reading taken at now since one taken at prev, nil
+	// for the time measured between them.
+	Elapsed func(prev, now time.Time) time.Duration
+	// Ceiling returns the highest plausible rate of an interface in bytes per second, nil for
+	// no limit. Deltas above it are flagged Implausible and left out of the totals.
+	Ceiling func(iface string) float64
+}
+
+// DefaultOptions returns the options the command-line tool uses by default: one sample per
+// second, rounded to two decimal places, read from the kernel.
+func DefaultOptions() Options {
+	return Options{Interval: time.Second, Precision: 2}
+}
+
+// MinInterval is the shortest supported time between samples. Below it, most kernels update
+// the counters too coarsely for meaningful rates.
+const MinInterval = 50 * time.Millisecond
+
+// Monitor samples one network interface.
+type Monitor struct {
+	iface string
+	opts  Options
+}
+
+// NewMonitor creates a monitor of the named interface, failing with ErrInterfaceNotFound when
+// its counters cannot be found.
+func NewMonitor(iface string, opts Options) (*Monitor, error) {
+	if opts.Interval < MinInterval {
+		return nil, fmt.Errorf("interval %v is shorter than %v", opts.Interval, MinInterval)
+	}
+	if opts.Counters == nil {
+		opts.Counters = ReadCounters
+	}
+	if _, err := opts.Counters(iface); err != nil {
+		return nil, err
+	}
+	return &Monitor{iface: iface, opts: opts}, nil
+}
+
+// Run samples the interface every interval and passes each sample to fn until ctx is done,
+// which returns nil, or a counter read or fn fails, which returns that error. Totals count
+// from the start of Run. Speeds are computed over the time measured between two readings, so a
+// late tick does not inflate them.
+func (m *Monitor) Run(ctx context.Context, fn func(Stats) error) error {
+	s := NewSampler(m.opts)
+	if err := m.tick(s, time.Now(), nil); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			if err := m.tick(s, now, fn); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// tick reads the counters into s and passes the resulting sample, if any, to fn.
+func (m *Monitor) tick(s *Sampler, now time.Time, fn func(Stats) error) error {
+	cur, err := m.opts.Counters(m.iface)
+	if err != nil {
+		return err
+	}
+	cur.Name = m.iface
+	for _, d := range s.Tick([]net.IOCountersStat{cur}, now) {
+		if err := fn(m.opts.Units.Stats(d, m.opts.Precision)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sample computes the figures of one sample from three readings of the counters: at the start
+// of the totals, at the previous sample and now, interval seconds later. Counters that went
+// backwards, as after a driver reset, count as no traffic.
+func Sample(iface string, start, prev, cur net.IOCountersStat, interval float64, precision int) Stats {
+	return Units{}.Sample(iface, start, prev, cur, interval, precision)
+}
+
+// Sample computes the figures of one sample like the function Sample, in the units of u.
+func (u Units) Sample(iface string, start, prev, cur net.IOCountersStat, interval float64, precision int) Stats {
+	totalSent := Growth(start.BytesSent, cur.BytesSent)
+	totalRecv := Growth(start.BytesRecv, cur.BytesRecv)
+	return Stats{
+		Interface:  iface,
+		SentSpeed:  u.Speed(Growth(prev.BytesSent, cur.BytesSent), interval, precision),
+		RecvSpeed:  u.Speed(Growth(prev.BytesRecv, cur.BytesRecv), interval, precision),
+		TotalSent:  u.Usage(totalSent, precision),
+		TotalRecv:  u.Usage(totalRecv, precision),
+		TotalUsage: u.Usage(totalSent+totalRecv, precision),
+	}
+}
+
+// ReadCounters retrieves the kernel's I/O counters of the named interface.
+func ReadCounters(iface string) (net.IOCountersStat, error) {
+	netIO, err := net.IOCounters(true)
+	if err != nil {
+		return net.IOCountersStat{}, err
+	}
+
+	for _, io := range netIO {
+		if io.Name == iface {
+			return io, nil
+		}
+	}
+
+	return net.IOCountersStat{}, fmt.Errorf("%w: %s", ErrInterfaceNotFound, iface)
+}
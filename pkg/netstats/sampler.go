@@ -0,0 +1,156 @@
+package netstats
+
+import (
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// Delta is the traffic of one interface between two readings of its counters, as computed by
+// a Sampler.
+type Delta struct {
+	Interface   string
+	Prev, Cur   net.IOCountersStat // Readings the delta lies between
+	Sent, Recv  uint64             // Bytes moved between the readings
+	TotalSent   uint64             // Bytes moved since the totals started
+	TotalRecv   uint64
+	Interval    float64 // Seconds between the readings
+	Reset       bool    // A counter went backwards, as after a driver reset or a wrap; Sent and Recv are 0
+	Implausible bool    // A rate exceeded Options.Ceiling; the delta is left out of the totals
+}
+
+// Rates returns the sent and received rates of d in bytes per second.
+func (d Delta) Rates() (sent, recv float64) {
+	return float64(d.Sent) / d.Interval, float64(d.Recv) / d.Interval
+}
+
+// Stats returns the figures of a delta in the units of u.
+func (u Units) Stats(d Delta, precision int) Stats {
+	return Stats{
+		Interface:  d.Interface,
+		SentSpeed:  u.Speed(d.Sent, d.Interval, precision),
+		RecvSpeed:  u.Speed(d.Recv, d.Interval, precision),
+		TotalSent:  u.Usage(d.TotalSent, precision),
+		TotalRecv:  u.Usage(d.TotalRecv, precision),
+		TotalUsage: u.Usage(d.TotalSent+d.TotalRecv, precision),
+	}
+}
+
+// Sampler turns successive readings of the counters of one or more interfaces into deltas and
+// running totals. Each interface keeps its own baseline, so totals never mix, and totals add
+// up the deltas, so they carry on across a counter reset. Monitor.Run drives a Sampler from a
+// ticker; programs with a loop of their own call Tick from it.
+type Sampler struct {
+	elapsed func(prev, now time.Time) time.Duration
+	ceiling func(iface string) float64
+	ifaces  map[string]*samplerState
+}
+
+// samplerState is the baseline of one interface of a Sampler.
+type samplerState struct {
+	prev                 net.IOCountersStat
+	prevAt               time.Time
+	totalSent, totalRecv uint64
+}
+
+// NewSampler creates a sampler using the Elapsed and Ceiling options.
+func NewSampler(opts Options) *Sampler {
+	s := &Sampler{elapsed: opts.Elapsed, ceiling: opts.Ceiling, ifaces: make(map[string]*samplerState)}
+	if s.elapsed == nil {
+		s.elapsed = func(prev, now time.Time) time.Duration { return now.Sub(prev) }
+	}
+	return s
+}
+
+// Tick records readings of the counters taken at now, identified by their Name, and returns
+// the delta of each interface that was read at an earlier tick too, in the order of readings.
+// An interface read for the first time starts its baseline and totals with this reading.
+func (s *Sampler) Tick(readings []net.IOCountersStat, now time.Time) []Delta {
+	deltas := make([]Delta, 0, len(readings))
+	for _, cur := range readings {
+		st := s.ifaces[cur.Name]
+		if st == nil {
+			s.ifaces[cur.Name] = &samplerState{prev: cur, prevAt: now}
+			continue
+		}
+		d := Delta{Interface: cur.Name, Prev: st.prev, Cur: cur, Interval: s.elapsed(st.prevAt, now).Seconds()}
+		var sentOK, recvOK bool
+		d.Sent, sentOK = growth(st.prev.BytesSent, cur.BytesSent)
+		d.Recv, recvOK = growth(st.prev.BytesRecv, cur.BytesRecv)
+		if d.Reset = !sentOK || !recvOK; d.Reset {
+			d.Sent, d.Recv = 0, 0
+		}
+		if s.ceiling != nil && d.Interval > 0 {
+			sentBps, recvBps := d.Rates()
+			ceiling := s.ceiling(cur.Name)
+			d.Implausible = sentBps > ceiling || recvBps > ceiling
+		}
+		if !d.Implausible {
+			st.totalSent += d.Sent
+			st.totalRecv += d.Recv
+		}
+		d.TotalSent, d.TotalRecv = st.totalSent, st.totalRecv
+		st.prev, st.prevAt = cur, now
+		deltas = append(deltas, d)
+	}
+	return deltas
+}
+
+// Known reports whether the named interface has a baseline.
+func (s *Sampler) Known(iface string) bool {
+	return s.ifaces[iface] != nil
+}
+
+// Interfaces returns the names of the interfaces with a baseline, in no particular order.
+func (s *Sampler) Interfaces() []string {
+	names := make([]string, 0, len(s.ifaces))
+	for name := range s.ifaces {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Forget drops the baseline and totals of an interface, such as one that disappeared. When it
+// is read again, it starts afresh.
+func (s *Sampler) Forget(iface string) {
+	delete(s.ifaces, iface)
+}
+
+// Rename carries the baseline and totals of an interface over to its new name.
+func (s *Sampler) Rename(old, new string) {
+	if st := s.ifaces[old]; st != nil && old != new {
+		delete(s.ifaces, old)
+		s.ifaces[new] = st
+	}
+}
+
+// ResetTotals restarts the totals of every interface from zero.
+func (s *Sampler) ResetTotals() {
+	for _, st := range s.ifaces {
+		st.totalSent, st.totalRecv = 0, 0
+	}
+}
+
+// Totals returns the totals of all interfaces together.
+func (s *Sampler) Totals() (sent, recv uint64) {
+	for _, st := range s.ifaces {
+		sent += st.totalSent
+		recv += st.totalRecv
+	}
+	return sent, recv
+}
+
+// Growth returns how much a cumulative counter grew from prev to cur. A counter that went
+// backwards, as after a driver reset or a wrap of a 32-bit counter, counts as no growth.
+func Growth(prev, cur uint64) uint64 {
+	g, _ := growth(prev, cur)
+	return g
+}
+
+// growth returns how much a counter grew, and false with zero when it went backwards.
+func growth(prev, cur uint64) (uint64, bool) {
+	if cur < prev {
+		return 0, false
+	}
+	return cur - prev, true
+}
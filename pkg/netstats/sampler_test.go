@@ -0,0 +1,52 @@
+package netstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+func TestSamplerAcrossReboot(t *testing.T) {
+	s := NewSampler(Options{})
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	read := func(sec int, sent, recv uint64) []Delta {
+		return s.Tick([]net.IOCountersStat{{Name: "eth0", BytesSent: sent, BytesRecv: recv}}, start.Add(time.Duration(sec)*time.Second))
+	}
+
+	if deltas := read(0, 1000, 2000); len(deltas) != 0 {
+		t.Fatalf("first reading gave %d deltas, want only a baseline", len(deltas))
+	}
+	steps := []struct {
+		sent, recv           uint64 // Counters read
+		reset                bool
+		moved                [2]uint64 // Bytes sent and received since the previous reading
+		totalSent, totalRecv uint64
+	}{
+		{5000, 6000, false, [2]uint64{4000, 4000}, 4000, 4000},
+		{300, 100, true, [2]uint64{0, 0}, 4000, 4000}, // The kernel counters restarted, as after a reboot
+		{800, 400, false, [2]uint64{500, 300}, 4500, 4300},
+	}
+	for i, st := range steps {
+		deltas := read(i+1, st.sent, st.recv)
+		if len(deltas) != 1 {
+			t.Fatalf("step %d gave %d deltas, want 1", i, len(deltas))
+		}
+		d := deltas[0]
+		if d.Reset != st.reset || [2]uint64{d.Sent, d.Recv} != st.moved {
+			t.Errorf("step %d moved %d/%d reset %v, want %d/%d reset %v", i, d.Sent, d.Recv, d.Reset, st.moved[0], st.moved[1], st.reset)
+		}
+		if d.TotalSent != st.totalSent || d.TotalRecv != st.totalRecv {
+			t.Errorf("step %d totals %d/%d, want %d/%d", i, d.TotalSent, d.TotalRecv, st.totalSent, st.totalRecv)
+		}
+	}
+
+	// A run started after the reboot counts from its own first reading.
+	s = NewSampler(Options{})
+	if deltas := read(10, 900, 500); len(deltas) != 0 {
+		t.Fatalf("first reading of a new run gave %d deltas, want only a baseline", len(deltas))
+	}
+	if sent, recv := s.Totals(); sent != 0 || recv != 0 {
+		t.Errorf("totals of a new run = %d/%d, want 0/0", sent, recv)
+	}
+}
@@ -0,0 +1,192 @@
+package netstats
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sizes of the binary units accepted by ParseUsage and ParseSpeed
+const (
+	KB = 1024.0
+	MB = KB * 1024
+	GB = MB * 1024
+	TB = GB * 1024
+)
+
+// quantityUnits lists the unit suffixes accepted by ParseUsage and ParseSpeed with their size
+// in bytes, longest suffixes first. KB and KiB both mean 1024 bytes, as the output of earlier
+// releases did; bit units are decimal, as in link and plan rates.
+var quantityUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"tbit", 1e12 / 8}, {"gbit", 1e9 / 8}, {"mbit", 1e6 / 8}, {"kbit", 1e3 / 8}, {"bit", 1.0 / 8},
+	{"tib", TB}, {"gib", GB}, {"mib", MB}, {"kib", KB},
+	{"tb", TB}, {"gb", GB}, {"mb", MB}, {"kb", KB}, {"b", 1},
+}
+
+// QuantityForms describes the forms accepted by ParseUsage and ParseSpeed, for help texts.
+const QuantityForms = "a number with an optional unit: B, KB, MB, GB, TB (1024-based, KiB etc. also accepted) or bit, Kbit, Mbit, Gbit, Tbit (1000-based); rates may end in /s"
+
+// parseQuantity parses a non-negative number with an optional unit suffix into bytes.
+func parseQuantity(s string) (float64, bool) {
+	lower := strings.ToLower(strings.TrimSpace(s))
+	factor := 1.0
+	for _, u := range quantityUnits {
+		if num, ok := strings.CutSuffix(lower, u.suffix); ok {
+			lower, factor = strings.TrimSpace(num), u.bytes
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(lower, 64)
+	if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, false
+	}
+	return v * factor, true
+}
+
+// ParseUsage parses a data amount such as "512MB", "1.5GiB" or "800Mbit" into bytes, rounded
+// to a whole byte. A bare number counts bytes.
+func ParseUsage(s string) (uint64, error) {
+	v, ok := parseQuantity(s)
+	if !ok || strings.HasSuffix(s, "/s") || v > math.MaxUint64 {
+		return 0, fmt.Errorf("invalid size %q, expected a number with an optional unit such as 512MB, 1.5GiB or 800Mbit", s)
+	}
+	return uint64(math.Round(v)), nil
+}
+
+// ParseSpeed parses a transfer speed such as "10MB/s", "500Mbit" or "2.5Gbit/s" into bytes
+// per second. A bare number counts bytes per second.
+func ParseSpeed(s string) (float64, error) {
+	v, ok := parseQuantity(strings.TrimSuffix(s, "/s"))
+	if !ok {
+		return 0, fmt.Errorf("invalid rate %q, expected a number with an optional unit such as 10MB/s or 500Mbit", s)
+	}
+	return v, nil
+}
+
+// Speed describes network transfer speed with a numerical value and its unit.
+type Speed struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// Usage represents network data transfer amount with a numerical value and its unit.
+type Usage struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// Round rounds a floating-point number to a specified number of decimal places.
+func Round(value float64, precision int) float64 {
+	multiplier := math.Pow(10, float64(precision))
+	return math.Round(value*multiplier) / multiplier
+}
+
+// Steps between the units of scaled speeds and sizes, the base parameter of ScaleSpeed and
+// ScaleUsage.
+const (
+	BinaryBase = 1024.0 // B, KiB, MiB, GiB
+	SIBase     = 1000.0 // B, KB, MB, GB
+)
+
+// Prefix is the power of the base a value is reported in, for Units to fix.
+type Prefix int
+
+// Prefixes of scaled values. PrefixAuto, the zero value, picks the largest one a value reaches.
+const (
+	PrefixAuto Prefix = iota
+	PrefixNone        // B, bit
+	PrefixKilo        // KiB, KB, Kbit
+	PrefixMega        // MiB, MB, Mbit
+	PrefixGiga        // GiB, GB, Gbit
+)
+
+// scalePrefixes are the prefixes of the units a value is scaled to, one per power of the base.
+var scalePrefixes = []string{"", "K", "M", "G"}
+
+// scale divides v by the power of base that fixed names, or by the largest one up to the cube
+// that v reaches for PrefixAuto, and returns the prefix of that power: K, M or G, followed by
+// i when base is BinaryBase.
+func scale(v, base float64, fixed Prefix) (float64, string) {
+	i := int(fixed) - 1
+	if fixed == PrefixAuto {
+		i = 0
+		for i+1 < len(scalePrefixes) && v >= math.Pow(base, float64(i+1)) {
+			i++
+		}
+	}
+	prefix := scalePrefixes[i]
+	if i > 0 && base == BinaryBase {
+		prefix += "i"
+	}
+	return v / math.Pow(base, float64(i)), prefix
+}
+
+// ScaleSpeed determines the most appropriate unit for the speed of bytes transferred over
+// interval seconds, which may be fractional. Units step by base: B/s, KiB/s, MiB/s and GiB/s
+// for BinaryBase, or B/s, KB/s, MB/s and GB/s for SIBase.
+func ScaleSpeed(bytes uint64, interval float64, precision int, base float64) Speed {
+	v, prefix := scale(float64(bytes)/interval, base, PrefixAuto)
+	return Speed{Value: Round(v, precision), Unit: prefix + "B/s"}
+}
+
+// ScaleUsage determines the most appropriate unit for a data amount, stepping by base as
+// ScaleSpeed does.
+func ScaleUsage(bytes uint64, precision int, base float64) Usage {
+	v, prefix := scale(float64(bytes), base, PrefixAuto)
+	return Usage{Value: Round(v, precision), Unit: prefix + "B"}
+}
+
+// CalculateSpeed determines the most appropriate binary unit for network transfer speed
+// (B/s, KiB/s, MiB/s, GiB/s) of bytes transferred over interval seconds.
+func CalculateSpeed(bytes uint64, interval float64, precision int) Speed {
+	return ScaleSpeed(bytes, interval, precision, BinaryBase)
+}
+
+// CalculateBitSpeed determines the most appropriate line-rate unit (bit/s, Kbit/s, Mbit/s,
+// Gbit/s) of bytes transferred over interval seconds. Steps are 1000-based, as for link speeds.
+func CalculateBitSpeed(bytes uint64, interval float64, precision int) Speed {
+	v, prefix := scale(float64(bytes)*8/interval, SIBase, PrefixAuto)
+	return Speed{Value: Round(v, precision), Unit: prefix + "bit/s"}
+}
+
+// CalculateUsage determines the most appropriate binary unit for network data transfer (B,
+// KiB, MiB, GiB).
+func CalculateUsage(bytes uint64, precision int) Usage {
+	return ScaleUsage(bytes, precision, BinaryBase)
+}
+
+// Units selects the units speeds and sizes are reported in. The zero value reports bytes and
+// bytes per second in binary units, as CalculateSpeed and CalculateUsage do.
+type Units struct {
+	Bits  bool   // Report speeds in bits per second, as CalculateBitSpeed does; totals stay in bytes
+	SI    bool   // Step by 1000 (KB, MB, GB) rather than 1024 (KiB, MiB, GiB)
+	Fixed Prefix // Prefix of every speed and total, so values of different samples compare directly
+}
+
+// base returns the step between the byte units of u.
+func (u Units) base() float64 {
+	if u.SI {
+		return SIBase
+	}
+	return BinaryBase
+}
+
+// Speed determines the unit of a speed like CalculateSpeed, in the units of u.
+func (u Units) Speed(bytes uint64, interval float64, precision int) Speed {
+	if u.Bits {
+		v, prefix := scale(float64(bytes)*8/interval, SIBase, u.Fixed)
+		return Speed{Value: Round(v, precision), Unit: prefix + "bit/s"}
+	}
+	v, prefix := scale(float64(bytes)/interval, u.base(), u.Fixed)
+	return Speed{Value: Round(v, precision), Unit: prefix + "B/s"}
+}
+
+// Usage determines the unit of a data amount like CalculateUsage, in the units of u.
+func (u Units) Usage(bytes uint64, precision int) Usage {
+	v, prefix := scale(float64(bytes), u.base(), u.Fixed)
+	return Usage{Value: Round(v, precision), Unit: prefix + "B"}
+}
@@ -0,0 +1,116 @@
+package netstats
+
+import "testing"
+
+func TestUnitsFixedPrefix(t *testing.T) {
+	tests := []struct {
+		units     Units
+		bytes     uint64
+		speed     Speed // Of bytes over one second
+		usage     Usage
+		precision int
+	}{
+		// A fixed prefix holds whether the value is far below or above it.
+		{Units{Fixed: PrefixMega}, 512 << 10, Speed{0.5, "MiB/s"}, Usage{0.5, "MiB"}, 2},
+		{Units{Fixed: PrefixMega}, 3 << 30, Speed{3072, "MiB/s"}, Usage{3072, "MiB"}, 2},
+		{Units{Fixed: PrefixKilo}, 100, Speed{0.098, "KiB/s"}, Usage{0.098, "KiB"}, 3},
+		{Units{Fixed: PrefixNone}, 5 << 20, Speed{5 << 20, "B/s"}, Usage{5 << 20, "B"}, 2},
+		{Units{Fixed: PrefixGiga}, 0, Speed{0, "GiB/s"}, Usage{0, "GiB"}, 2},
+		{Units{Fixed: PrefixMega, SI: true}, 2_500_000, Speed{2.5, "MB/s"}, Usage{2.5, "MB"}, 2},
+		{Units{Fixed: PrefixGiga, SI: true}, 1_000_000, Speed{0.001, "GB/s"}, Usage{0.001, "GB"}, 3},
+		// Without a fixed prefix the unit follows the value.
+		{Units{}, 512 << 10, Speed{512, "KiB/s"}, Usage{512, "KiB"}, 2},
+		{Units{}, 3 << 30, Speed{3, "GiB/s"}, Usage{3, "GiB"}, 2},
+	}
+	for _, tt := range tests {
+		if got := tt.units.Speed(tt.bytes, 1, tt.precision); got != tt.speed {
+			t.Errorf("%+v Speed(%d) = %v, want %v", tt.units, tt.bytes, got, tt.speed)
+		}
+		if got := tt.units.Usage(tt.bytes, tt.precision); got != tt.usage {
+			t.Errorf("%+v Usage(%d) = %v, want %v", tt.units, tt.bytes, got, tt.usage)
+		}
+	}
+
+	// The rate is scaled to the fixed prefix after dividing by the interval.
+	u := Units{Fixed: PrefixKilo}
+	if got := u.Speed(512, 0.5, 2); got != (Speed{1, "KiB/s"}) {
+		t.Errorf("Speed(512 in 500ms) = %v, want 1 KiB/s", got)
+	}
+}
+
+func TestBitSpeeds(t *testing.T) {
+	tests := []struct {
+		bytes    uint64
+		interval float64
+		want     Speed
+	}{
+		{0, 1, Speed{0, "bit/s"}},
+		{124, 1, Speed{992, "bit/s"}},
+		{125, 1, Speed{1, "Kbit/s"}}, // 1000 bits, not 1024
+		{125_000, 1, Speed{1, "Mbit/s"}},
+		{125_000_000, 1, Speed{1, "Gbit/s"}},
+		{12_500_000, 0.5, Speed{200, "Mbit/s"}},
+		{12_500_000, 2, Speed{50, "Mbit/s"}},
+	}
+	for _, tt := range tests {
+		if got := CalculateBitSpeed(tt.bytes, tt.interval, 2); got != tt.want {
+			t.Errorf("CalculateBitSpeed(%d, %v) = %v, want %v", tt.bytes, tt.interval, got, tt.want)
+		}
+		if got := (Units{Bits: true}).Speed(tt.bytes, tt.interval, 2); got != tt.want {
+			t.Errorf("bit Speed(%d, %v) = %v, want %v", tt.bytes, tt.interval, got, tt.want)
+		}
+	}
+
+	// The bit base ignores SI, a fixed prefix still applies, and totals stay in bytes.
+	u := Units{Bits: true, Fixed: PrefixMega}
+	if got := u.Speed(125, 1, 3); got != (Speed{0.001, "Mbit/s"}) {
+		t.Errorf("fixed bit Speed = %v, want 0.001 Mbit/s", got)
+	}
+	if got := (Units{Bits: true, SI: true}).Speed(125_000, 1, 2); got != (Speed{1, "Mbit/s"}) {
+		t.Errorf("SI bit Speed = %v, want 1 Mbit/s", got)
+	}
+	if got := (Units{Bits: true}).Usage(1<<20, 2); got != (Usage{1, "MiB"}) {
+		t.Errorf("bit Usage = %v, want 1 MiB", got)
+	}
+}
+
+func TestUnitLabels(t *testing.T) {
+	tests := []struct {
+		bytes uint64
+		base  float64
+		want  Usage
+	}{
+		{1023, BinaryBase, Usage{1023, "B"}},
+		{1024, BinaryBase, Usage{1, "KiB"}},
+		{1<<20 - 1, BinaryBase, Usage{1024, "KiB"}}, // Rounds up without reaching the next unit
+		{1 << 20, BinaryBase, Usage{1, "MiB"}},
+		{1 << 30, BinaryBase, Usage{1, "GiB"}},
+		{1 << 40, BinaryBase, Usage{1024, "GiB"}}, // Nothing beyond G
+		{999, SIBase, Usage{999, "B"}},
+		{1000, SIBase, Usage{1, "KB"}},
+		{1023, SIBase, Usage{1.02, "KB"}},
+		{1_000_000, SIBase, Usage{1, "MB"}},
+		{1_000_000_000, SIBase, Usage{1, "GB"}},
+		{1_000_000_000_000, SIBase, Usage{1000, "GB"}},
+	}
+	for _, tt := range tests {
+		if got := ScaleUsage(tt.bytes, 2, tt.base); got != tt.want {
+			t.Errorf("ScaleUsage(%d, %v) = %v, want %v", tt.bytes, tt.base, got, tt.want)
+		}
+		speed := Speed{tt.want.Value, tt.want.Unit + "/s"}
+		if got := ScaleSpeed(tt.bytes, 1, 2, tt.base); got != speed {
+			t.Errorf("ScaleSpeed(%d, %v) = %v, want %v", tt.bytes, tt.base, got, speed)
+		}
+		if got := (Units{SI: tt.base == SIBase}).Usage(tt.bytes, 2); got != tt.want {
+			t.Errorf("Units Usage(%d, %v) = %v, want %v", tt.bytes, tt.base, got, tt.want)
+		}
+	}
+
+	// The defaults keep the binary labels.
+	if got := CalculateUsage(1<<20, 2); got != (Usage{1, "MiB"}) {
+		t.Errorf("CalculateUsage = %v, want 1 MiB", got)
+	}
+	if got := CalculateSpeed(1000, 1, 2); got != (Speed{1000, "B/s"}) {
+		t.Errorf("CalculateSpeed = %v, want 1000 B/s", got)
+	}
+}